@@ -0,0 +1,82 @@
+package main
+
+import (
+	"api/shared/constants"
+	"api/shared/util"
+	"context"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	userID, err := util.ExtractUserID(request)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       err.Error(),
+			Headers:    constants.CorsHeaders,
+		}, nil
+	}
+
+	reportID := request.QueryStringParameters["reportID"]
+
+	// Check if ReportID is provided
+	if reportID == "" {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       "Bad Request: Missing reportID from query string.",
+			Headers:    constants.CorsHeaders,
+		}, nil
+	}
+
+	// The report csv is used when no dataset is given
+	dataset := request.QueryStringParameters["dataset"]
+
+	// The schema is stored next to the column values map of the csv
+	csvColumnsS3Key, err := util.GetReportCsvColumnsS3Key(reportID, dataset, userID)
+
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       "Error getting csvColumnsS3Key by ReportID: " + err.Error(),
+			Headers:    constants.CorsHeaders,
+		}, nil
+	}
+
+	if csvColumnsS3Key == "no-csv-s3-key" {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusNotFound,
+			Body:       "csv id not set for report",
+			Headers:    constants.CorsHeaders,
+		}, nil
+	}
+
+	schemaJSON, found, err := util.GetColumnSchemaJSONFromS3(csvColumnsS3Key)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       "Error getting column schema from s3: " + err.Error(),
+			Headers:    constants.CorsHeaders,
+		}, nil
+	}
+
+	if !found {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusNotFound,
+			Body:       "column schema not found, the csv was read before schemas were stored and needs to be uploaded again",
+			Headers:    constants.CorsHeaders,
+		}, nil
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(schemaJSON),
+		Headers:    constants.CorsHeaders,
+	}, nil
+}
+
+func main() {
+	lambda.Start(Handler)
+}
@@ -38,7 +38,10 @@ func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 		}, nil
 	}
 
-	csvColumnsS3Key, err := util.GetReportCsvColumnsS3Key(reportID, userID)
+	// The report csv is used when no dataset is given
+	dataset := request.QueryStringParameters["dataset"]
+
+	csvColumnsS3Key, err := util.GetReportCsvColumnsS3Key(reportID, dataset, userID)
 
 	if err != nil {
 		return events.APIGatewayProxyResponse{
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"api/shared/constants"
+	"api/shared/models"
+	"api/shared/util"
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+type SearchCsvColumnValuesResponse struct {
+	Column       string
+	Values       []models.CsvColumnValueCount
+	TotalMatches int
+	Page         int
+	PageSize     int
+	// The column had more distinct values than are counted, so only the counted values were
+	// searched. Matches may be missing and TotalMatches is the least there are.
+	Truncated bool
+}
+
+func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	userID, err := util.ExtractUserID(request)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       err.Error(),
+			Headers:    constants.CorsHeaders,
+		}, nil
+	}
+
+	reportID := request.QueryStringParameters["reportID"]
+	column := request.QueryStringParameters["column"]
+
+	if reportID == "" || column == "" {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       "Bad Request: reportID and column are required.",
+			Headers:    constants.CorsHeaders,
+		}, nil
+	}
+
+	// The report csv is used when no dataset is given
+	dataset := request.QueryStringParameters["dataset"]
+	prefix := request.QueryStringParameters["prefix"]
+
+	order := models.ColumnValueOrder(request.QueryStringParameters["sort"])
+	if order == "" {
+		order = models.FrequencyOrder
+	}
+	if order != models.FrequencyOrder && order != models.AlphabeticalOrder {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       "Bad Request: sort must be frequency or alphabetical.",
+			Headers:    constants.CorsHeaders,
+		}, nil
+	}
+
+	page := 1
+	if pageString := request.QueryStringParameters["page"]; pageString != "" {
+		page, err = strconv.Atoi(pageString)
+		if err != nil || page < 1 {
+			return events.APIGatewayProxyResponse{
+				StatusCode: http.StatusBadRequest,
+				Headers:    constants.CorsHeaders,
+				Body:       "Bad Request: unable to parse page. ensure it is an int of at least 1",
+			}, nil
+		}
+	}
+
+	pageSize := defaultPageSize
+	if pageSizeString := request.QueryStringParameters["pageSize"]; pageSizeString != "" {
+		pageSize, err = strconv.Atoi(pageSizeString)
+		if err != nil || pageSize < 1 || pageSize > maxPageSize {
+			return events.APIGatewayProxyResponse{
+				StatusCode: http.StatusBadRequest,
+				Headers:    constants.CorsHeaders,
+				Body:       "Bad Request: unable to parse pageSize. ensure it is an int between 1 and " + strconv.Itoa(maxPageSize),
+			}, nil
+		}
+	}
+
+	csvColumnsS3Key, err := util.GetReportCsvColumnsS3Key(reportID, dataset, userID)
+
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       "Error getting csvColumnsS3Key by ReportID: " + err.Error(),
+			Headers:    constants.CorsHeaders,
+		}, nil
+	}
+
+	if csvColumnsS3Key == "no-csv-s3-key" {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusNotFound,
+			Body:       "csv id not set for report",
+			Headers:    constants.CorsHeaders,
+		}, nil
+	}
+
+	values, columnValues, err := util.GetColumnValues(csvColumnsS3Key, column)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       "Error getting column values: " + err.Error(),
+			Headers:    constants.CorsHeaders,
+		}, nil
+	}
+
+	// Pages too far out to count to are past the last match
+	offset := math.MaxInt
+	if page-1 <= math.MaxInt/pageSize {
+		offset = (page - 1) * pageSize
+	}
+	pageValues, totalMatches := util.SearchColumnValues(values, prefix, order, offset, pageSize)
+
+	response := SearchCsvColumnValuesResponse{
+		Column:       column,
+		Values:       pageValues,
+		TotalMatches: totalMatches,
+		Page:         page,
+		PageSize:     pageSize,
+		Truncated:    columnValues.CountLimitReached,
+	}
+
+	responseJSON, err := json.Marshal(response)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       "Error marshalling response into JSON: " + err.Error(),
+			Headers:    constants.CorsHeaders,
+		}, nil
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(responseJSON),
+		Headers:    constants.CorsHeaders,
+	}, nil
+}
+
+func main() {
+	lambda.Start(Handler)
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"api/shared/constants"
+	"api/shared/models"
 	"api/shared/util"
 	"context"
 	"encoding/json"
@@ -13,6 +14,11 @@ import (
 
 type UploadCsvRequest struct {
 	ReportID string `json:"reportID"`
+	Dataset  string `json:"dataset"` // Optional, the report csv is replaced when empty
+	// Optional, csv, tsv or xlsx. The presigned url only accepts the content type of this file type
+	FileType  models.UploadFileType `json:"fileType"`
+	Sheet     string                `json:"sheet"`     // Optional, the sheet of an xlsx file. The first sheet when empty
+	Delimiter string                `json:"delimiter"` // Optional, detected from the header line when empty
 }
 
 type UploadCsvResponse struct {
@@ -48,7 +54,11 @@ func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 		}, nil
 	}
 
-	preSignedURL, operationID, err := util.SetReportCSV(req.ReportID, userID)
+	preSignedURL, operationID, err := util.SetReportCSV(req.ReportID, req.Dataset, models.UploadOptions{
+		FileType:  req.FileType,
+		Sheet:     req.Sheet,
+		Delimiter: req.Delimiter,
+	}, userID)
 	if err != nil {
 		return events.APIGatewayProxyResponse{
 			StatusCode: http.StatusInternalServerError,
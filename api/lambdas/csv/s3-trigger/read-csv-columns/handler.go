@@ -1,6 +1,7 @@
 package main
 
 import (
+	"api/shared/models"
 	"api/shared/util"
 	"context"
 	"fmt"
@@ -17,29 +18,72 @@ func Handler(ctx context.Context, s3Event events.S3Event) {
 
 		fmt.Printf("Processing file: %s from bucket: %s\n", key, bucket)
 
+		// Uploaded files are converted to a csv first. Writing the csv triggers this
+		// function again, which then reads its columns.
+		if util.IsRawUploadKey(key) {
+			validation, err := util.NormalizeUploadedFile(key)
+			if err != nil {
+				failOperation(util.CSVKeyFromRawUpload(key), "Error normalizing uploaded file:", err)
+				return
+			}
+			if validation == nil {
+				fmt.Println("Upload was cancelled")
+				continue
+			}
+			if !validation.Valid {
+				fmt.Printf("Uploaded file failed validation with %d diagnostics\n", len(validation.Diagnostics))
+			}
+			continue
+		}
+
 		// Load CSV file from S3
 		file, err := util.GetCSVFileHandle(key)
 		if err != nil {
-			fmt.Println("Error loading CSV from S3:", err)
+			failOperation(key, "Error loading CSV from S3:", err)
 			return
 		}
+		defer file.Close()
 
-		// Process the CSV file
-		uniqueValues, err := util.GetUniqueColumnValuesMapInCSV(file)
+		// Derived columns of the report are listed with the csv columns
+		derivedColumns, err := util.GetDerivedColumnsByCSVID(key)
 		if err != nil {
-			fmt.Println("Error processing CSV file:", err)
+			failOperation(key, "Error getting derived columns:", err)
+			return
+		}
+
+		// Process the CSV file, inferring the type of each column along with its values
+		columnData, err := util.ProfileCSVColumns(file, derivedColumns)
+		if err != nil {
+			failOperation(key, "Error processing CSV file:", err)
 			return
 		}
 
 		// Update DynamoDB
-		err = util.UpdateReportCsvColumns(key, uniqueValues)
+		columnsS3Key, err := util.UpdateReportCsvColumns(key, columnData)
 		if err != nil {
-			fmt.Println("Error updating DynamoDB:", err)
+			failOperation(key, "Error updating DynamoDB:", err)
 			return
 		}
 
+		// Joins using the csv are rebuilt in the background from its new rows
+		err = util.StartCSVJoinsBuild(key)
+		if err != nil {
+			fmt.Println("Error starting join columns build:", err)
+		}
+
 		// This will let the polling function know that the csv has been updated successfully
-		err = util.SetOperationCompleted(key)
+		err = util.CompleteOperation(key, &models.OperationResult{
+			CsvUpload: &models.CsvUploadResult{
+				CSVID:           key,
+				CSVColumnsS3Key: columnsS3Key,
+				RowCount:        columnData.Schema.RowCount,
+				ColumnCount:     len(columnData.Schema.Columns),
+			},
+		})
+		if err == util.ErrOperationFinished {
+			fmt.Println("Operation was cancelled while the csv was read")
+			continue
+		}
 		if err != nil {
 			fmt.Println("Error setting operation completed:", err)
 			return
@@ -47,6 +91,17 @@ func Handler(ctx context.Context, s3Event events.S3Event) {
 	}
 }
 
+// failOperation lets the polling function know the csv can not be processed,
+// rather than leaving it to poll until it times out
+func failOperation(operationID, message string, err error) {
+	fmt.Println(message, err)
+
+	err = util.FailOperation(operationID, err.Error(), nil)
+	if err != nil && err != util.ErrOperationFinished {
+		fmt.Println("Error setting operation failed:", err)
+	}
+}
+
 func main() {
 	lambda.Start(Handler)
 }
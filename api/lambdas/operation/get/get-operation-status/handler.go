@@ -2,6 +2,7 @@ package main
 
 import (
 	"api/shared/constants"
+	"api/shared/models"
 	"api/shared/util"
 	"context"
 	"encoding/json"
@@ -11,11 +12,21 @@ import (
 	"github.com/aws/aws-lambda-go/lambda"
 )
 
-type GetUniqueCsvColumnsResponse struct {
-	OperationCompleted bool
+type GetOperationStatusResponse struct {
+	models.Operation
+	OperationCompleted bool   // Deprecated, true once the state is Succeeded
+	Status             string // Deprecated, "not completed", "completed" or "failed"
 }
 
 func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	userID, err := util.ExtractUserID(request)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       err.Error(),
+			Headers:    constants.CorsHeaders,
+		}, nil
+	}
 
 	operationID := request.QueryStringParameters["operationID"]
 
@@ -28,30 +39,48 @@ func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 		}, nil
 	}
 
-	operationCompleted, err := util.GetOperationStatus(operationID)
+	operation, err := util.GetOperation(operationID, userID)
+
+	if err == util.ErrOperationNotAuthorized {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusForbidden,
+			Body:       "Operation belongs to another user",
+			Headers:    constants.CorsHeaders,
+		}, nil
+	}
 
 	if err != nil {
 		return events.APIGatewayProxyResponse{
 			StatusCode: http.StatusInternalServerError,
-			Body:       "Error checking operation status",
+			Body:       "Error checking operation status: " + err.Error(),
 			Headers:    constants.CorsHeaders,
 		}, nil
 	}
 
-	response := GetUniqueCsvColumnsResponse{
-		OperationCompleted: operationCompleted,
+	if operation == nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusNotFound,
+			Body:       "Operation not found",
+			Headers:    constants.CorsHeaders,
+		}, nil
+	}
+
+	response := GetOperationStatusResponse{
+		Operation:          *operation,
+		OperationCompleted: operation.State == models.OperationSucceeded,
+		Status:             legacyStatus(operation.State),
 	}
 
 	responseJSON, err := json.Marshal(response)
 	if err != nil {
 		return events.APIGatewayProxyResponse{
 			StatusCode: http.StatusInternalServerError,
-			Body:       "Error marshalling operation completed bool into JSON: " + err.Error(),
+			Body:       "Error marshalling operation into JSON: " + err.Error(),
 			Headers:    constants.CorsHeaders,
 		}, nil
 	}
 
-	// Return the report in the response body
+	// Return the operation in the response body
 	return events.APIGatewayProxyResponse{
 		StatusCode: http.StatusOK,
 		Body:       string(responseJSON),
@@ -59,6 +88,18 @@ func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 	}, nil
 }
 
+// legacyStatus is the status returned before operations had states
+func legacyStatus(state models.OperationState) string {
+	switch state {
+	case models.OperationSucceeded:
+		return "completed"
+	case models.OperationFailed, models.OperationCancelled:
+		return "failed"
+	default:
+		return "not completed"
+	}
+}
+
 func main() {
 	lambda.Start(Handler)
 }
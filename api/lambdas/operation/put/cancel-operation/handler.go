@@ -0,0 +1,89 @@
+package main
+
+import (
+	"api/shared/constants"
+	"api/shared/util"
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+type CancelOperationRequest struct {
+	OperationID string `json:"operationID"`
+}
+
+func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	userID, err := util.ExtractUserID(request)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       err.Error(),
+			Headers:    constants.CorsHeaders,
+		}, nil
+	}
+
+	var req CancelOperationRequest
+	err = json.Unmarshal([]byte(request.Body), &req)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusBadRequest,
+			Headers:    constants.CorsHeaders,
+			Body:       "Bad Request: " + err.Error(),
+		}, nil
+	}
+
+	if req.OperationID == "" {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusBadRequest,
+			Headers:    constants.CorsHeaders,
+			Body:       "Bad Request: operationID is required.",
+		}, nil
+	}
+
+	err = util.CancelOperation(req.OperationID, userID)
+
+	if err == util.ErrOperationNotFound {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusNotFound,
+			Headers:    constants.CorsHeaders,
+			Body:       "Operation not found",
+		}, nil
+	}
+
+	if err == util.ErrOperationNotAuthorized {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusForbidden,
+			Headers:    constants.CorsHeaders,
+			Body:       "Operation belongs to another user",
+		}, nil
+	}
+
+	if err == util.ErrOperationFinished {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusConflict,
+			Headers:    constants.CorsHeaders,
+			Body:       "Operation is already finished",
+		}, nil
+	}
+
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Headers:    constants.CorsHeaders,
+			Body:       "Error cancelling operation: " + err.Error(),
+		}, nil
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Headers:    constants.CorsHeaders,
+		Body:       "Operation cancelled successfully",
+	}, nil
+}
+
+func main() {
+	lambda.Start(Handler)
+}
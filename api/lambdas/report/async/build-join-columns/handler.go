@@ -0,0 +1,28 @@
+package main
+
+import (
+	"api/shared/models"
+	"api/shared/util"
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+// Handler builds the column values maps of joins. It is invoked asynchronously when the
+// joins of a report are set and when a csv they use is uploaded again.
+func Handler(ctx context.Context, job models.JoinColumnsJob) error {
+	fmt.Printf("Building the columns of %d joins of report %s\n", len(job.Joins), job.ReportID)
+
+	err := util.RunJoinColumnsBuild(job)
+	if err != nil {
+		fmt.Println("Error building join columns:", err)
+	}
+
+	// Errors are recorded on the operation, retrying the job would build the joins again
+	return nil
+}
+
+func main() {
+	lambda.Start(Handler)
+}
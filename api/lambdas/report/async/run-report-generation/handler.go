@@ -0,0 +1,28 @@
+package main
+
+import (
+	"api/shared/models"
+	"api/shared/util"
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+// Handler runs a report generation job. It is invoked asynchronously by the generate
+// report endpoint, which returns the operation of the job to poll.
+func Handler(ctx context.Context, job models.ReportGenerationJob) error {
+	fmt.Printf("Generating %d sections of report %s\n", len(job.Sections), job.ReportID)
+
+	err := util.RunReportGeneration(ctx, job)
+	if err != nil {
+		fmt.Println("Error generating report:", err)
+	}
+
+	// Errors are recorded on the operation, retrying the job would generate it again
+	return nil
+}
+
+func main() {
+	lambda.Start(Handler)
+}
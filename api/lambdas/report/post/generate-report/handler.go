@@ -0,0 +1,91 @@
+package main
+
+import (
+	"api/shared/constants"
+	"api/shared/models"
+	"api/shared/util"
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+type GenerateReportRequest struct {
+	ReportID         string               `json:"reportID"`
+	Sections         []GenerateSectionRef `json:"sections"` // Optional, every section is generated when empty
+	GenerateAIOutput bool                 `json:"generateAIOutput"`
+}
+
+type GenerateSectionRef struct {
+	PartIndex    int `json:"partIndex"`
+	SectionIndex int `json:"sectionIndex"`
+}
+
+type GenerateReportResponse struct {
+	OperationID string
+}
+
+func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	userID, err := util.ExtractUserID(request)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       err.Error(),
+			Headers:    constants.CorsHeaders,
+		}, nil
+	}
+
+	var req GenerateReportRequest
+	err = json.Unmarshal([]byte(request.Body), &req)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusBadRequest,
+			Headers:    constants.CorsHeaders,
+			Body:       "Bad Request: " + err.Error(),
+		}, nil
+	}
+
+	if req.ReportID == "" {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusBadRequest,
+			Headers:    constants.CorsHeaders,
+			Body:       "Bad Request: reportID is required.",
+		}, nil
+	}
+
+	sections := make([]models.SectionRef, len(req.Sections))
+	for i, section := range req.Sections {
+		sections[i] = models.SectionRef{PartIndex: section.PartIndex, SectionIndex: section.SectionIndex}
+	}
+
+	// The sections are generated in the background, followed through the operation
+	operationID, err := util.StartReportGeneration(req.ReportID, sections, req.GenerateAIOutput, userID)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Headers:    constants.CorsHeaders,
+			Body:       "Error generating report: " + err.Error(),
+		}, nil
+	}
+
+	responseJSON, err := json.Marshal(GenerateReportResponse{OperationID: operationID})
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       "Error marshalling response into JSON: " + err.Error(),
+			Headers:    constants.CorsHeaders,
+		}, nil
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusAccepted,
+		Headers:    constants.CorsHeaders,
+		Body:       string(responseJSON),
+	}, nil
+}
+
+func main() {
+	lambda.Start(Handler)
+}
@@ -46,7 +46,7 @@ func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 		}, nil
 	}
 
-	err = util.GenerateSection(req.ReportID, req.PartIndex, req.SectionIndex, req.GenerateAIOutput, userID)
+	err = util.GenerateSection(ctx, req.ReportID, req.PartIndex, req.SectionIndex, req.GenerateAIOutput, userID)
 
 	if err != nil {
 		return events.APIGatewayProxyResponse{
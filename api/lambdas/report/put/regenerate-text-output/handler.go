@@ -0,0 +1,85 @@
+package main
+
+import (
+	"api/shared/constants"
+	"api/shared/util"
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+type RegenerateTextOutputRequest struct {
+	ReportID        string `json:"reportID"`
+	PartIndex       int    `json:"partIndex"`
+	SectionIndex    int    `json:"sectionIndex"`
+	TextOutputIndex int    `json:"textOutputIndex"`
+	TextOutputID    string `json:"textOutputID"` // Optional, used instead of textOutputIndex when set
+}
+
+func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	userID, err := util.ExtractUserID(request)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       err.Error(),
+			Headers:    constants.CorsHeaders,
+		}, nil
+	}
+
+	var req RegenerateTextOutputRequest
+	err = json.Unmarshal([]byte(request.Body), &req)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusBadRequest,
+			Headers:    constants.CorsHeaders,
+			Body:       "Bad Request: " + err.Error(),
+		}, nil
+	}
+
+	if req.ReportID == "" || req.PartIndex < 0 || req.SectionIndex < 0 || (req.TextOutputID == "" && req.TextOutputIndex < 0) {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusBadRequest,
+			Headers:    constants.CorsHeaders,
+			Body:       "Bad Request: reportID, partIndex, sectionIndex, and textOutputIndex or textOutputID are required.",
+		}, nil
+	}
+
+	textOutput, err := util.RegenerateTextOutput(ctx, req.ReportID, req.PartIndex, req.SectionIndex, req.TextOutputIndex, req.TextOutputID, userID)
+	if err == util.ErrTextOutputNotFound {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusBadRequest,
+			Headers:    constants.CorsHeaders,
+			Body:       "Bad Request: " + err.Error(),
+		}, nil
+	}
+
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Headers:    constants.CorsHeaders,
+			Body:       "Error regenerating text output: " + err.Error(),
+		}, nil
+	}
+
+	textOutputJSON, err := json.Marshal(textOutput)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Headers:    constants.CorsHeaders,
+			Body:       "Error marshalling text output into JSON: " + err.Error(),
+		}, nil
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Headers:    constants.CorsHeaders,
+		Body:       string(textOutputJSON),
+	}, nil
+}
+
+func main() {
+	lambda.Start(Handler)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"api/shared/constants"
+	"api/shared/models"
+	"api/shared/util"
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+type SetDerivedColumnsRequest struct {
+	ReportID       string                 `json:"reportID"`
+	Dataset        string                 `json:"dataset"` // Optional, the report csv is used when empty
+	DerivedColumns []models.DerivedColumn `json:"derivedColumns"`
+}
+
+func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	userID, err := util.ExtractUserID(request)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       err.Error(),
+			Headers:    constants.CorsHeaders,
+		}, nil
+	}
+
+	var req SetDerivedColumnsRequest
+	err = json.Unmarshal([]byte(request.Body), &req)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusBadRequest,
+			Headers:    constants.CorsHeaders,
+			Body:       "Bad Request: " + err.Error(),
+		}, nil
+	}
+
+	if req.ReportID == "" {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusBadRequest,
+			Headers:    constants.CorsHeaders,
+			Body:       "Bad Request: reportID is required.",
+		}, nil
+	}
+
+	err = util.UpdateReportDerivedColumns(req.ReportID, req.Dataset, req.DerivedColumns, userID)
+
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Headers:    constants.CorsHeaders,
+			Body:       "Error setting derived columns: " + err.Error(),
+		}, nil
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Headers:    constants.CorsHeaders,
+		Body:       "Derived columns set successfully",
+	}, nil
+}
+
+func main() {
+	lambda.Start(Handler)
+}
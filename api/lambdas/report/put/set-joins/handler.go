@@ -0,0 +1,81 @@
+package main
+
+import (
+	"api/shared/constants"
+	"api/shared/models"
+	"api/shared/util"
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+type SetJoinsRequest struct {
+	ReportID string        `json:"reportID"`
+	Joins    []models.Join `json:"joins"`
+}
+
+type SetJoinsResponse struct {
+	OperationID string
+}
+
+func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	userID, err := util.ExtractUserID(request)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       err.Error(),
+			Headers:    constants.CorsHeaders,
+		}, nil
+	}
+
+	var req SetJoinsRequest
+	err = json.Unmarshal([]byte(request.Body), &req)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusBadRequest,
+			Headers:    constants.CorsHeaders,
+			Body:       "Bad Request: " + err.Error(),
+		}, nil
+	}
+
+	if req.ReportID == "" {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusBadRequest,
+			Headers:    constants.CorsHeaders,
+			Body:       "Bad Request: reportID is required.",
+		}, nil
+	}
+
+	// The joined columns are built in the background, followed through the operation
+	operationID, err := util.UpdateReportJoins(req.ReportID, req.Joins, userID)
+
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Headers:    constants.CorsHeaders,
+			Body:       "Error setting joins: " + err.Error(),
+		}, nil
+	}
+
+	responseJSON, err := json.Marshal(SetJoinsResponse{OperationID: operationID})
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       "Error marshalling response into JSON: " + err.Error(),
+			Headers:    constants.CorsHeaders,
+		}, nil
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusAccepted,
+		Headers:    constants.CorsHeaders,
+		Body:       string(responseJSON),
+	}, nil
+}
+
+func main() {
+	lambda.Start(Handler)
+}
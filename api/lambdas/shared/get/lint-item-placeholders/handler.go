@@ -0,0 +1,104 @@
+package main
+
+import (
+	"api/shared/constants"
+	"api/shared/models"
+	"api/shared/util"
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	userID, err := util.ExtractUserID(request)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       err.Error(),
+			Headers:    constants.CorsHeaders,
+		}, nil
+	}
+
+	itemType := constants.ItemType(request.QueryStringParameters["itemType"])
+	itemID := request.QueryStringParameters["itemID"]
+
+	if itemType == "" || itemID == "" {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       "Bad Request: itemType and itemID are required.",
+			Headers:    constants.CorsHeaders,
+		}, nil
+	}
+
+	var lint models.PlaceholderLintReport
+
+	if itemType == constants.Report {
+		report, err := util.GetReport(itemID, userID)
+		if err != nil {
+			return events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       "Error getting report by ReportID: " + err.Error(),
+				Headers:    constants.CorsHeaders,
+			}, nil
+		}
+
+		if report == nil {
+			return events.APIGatewayProxyResponse{
+				StatusCode: http.StatusNotFound,
+				Body:       "Report not found",
+				Headers:    constants.CorsHeaders,
+			}, nil
+		}
+
+		lint = util.LintReportPlaceholders(report)
+
+	} else if itemType == constants.Template {
+		template, err := util.GetTemplate(itemID, userID)
+		if err != nil {
+			return events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       "Error getting template by TemplateID: " + err.Error(),
+				Headers:    constants.CorsHeaders,
+			}, nil
+		}
+
+		if template == nil {
+			return events.APIGatewayProxyResponse{
+				StatusCode: http.StatusNotFound,
+				Body:       "Template not found",
+				Headers:    constants.CorsHeaders,
+			}, nil
+		}
+
+		lint = util.LintTemplatePlaceholders(template)
+
+	} else {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       "Bad Request: itemType must be 'report' or 'template' ",
+			Headers:    constants.CorsHeaders,
+		}, nil
+	}
+
+	lintJSON, err := json.Marshal(lint)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       "Error marshalling placeholder lint report into JSON: " + err.Error(),
+			Headers:    constants.CorsHeaders,
+		}, nil
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(lintJSON),
+		Headers:    constants.CorsHeaders,
+	}, nil
+}
+
+func main() {
+	lambda.Start(Handler)
+}
@@ -14,11 +14,13 @@ import (
 )
 
 type AddSectionToPartRequest struct {
-	ItemType     constants.ItemType `json:"itemType"`
-	ItemID       string             `json:"itemID"`
-	PartIndex    int                `json:"partIndex"`
-	SectionIndex int                `json:"sectionIndex"`
-	SectionTitle string             `json:"sectionTitle"`
+	ItemType      constants.ItemType          `json:"itemType"`
+	ItemID        string                      `json:"itemID"`
+	PartIndex     int                         `json:"partIndex"`
+	SectionIndex  int                         `json:"sectionIndex"`
+	SectionTitle  string                      `json:"sectionTitle"`
+	SectionLabel  string                      `json:"sectionLabel"`  // Optional, names the section in placeholders
+	FailurePolicy models.SectionFailurePolicy `json:"failurePolicy"` // Optional, FailOutput when empty
 }
 
 type ReportSectionContents struct {
@@ -64,6 +66,14 @@ func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 		}, nil
 	}
 
+	if !req.FailurePolicy.IsValid() {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusBadRequest,
+			Headers:    constants.CorsHeaders,
+			Body:       "Bad Request: failurePolicy must be 'FailOutput' or 'FailSection'.",
+		}, nil
+	}
+
 	if req.ItemType == constants.Report {
 		var contents ReportSectionContents
 
@@ -76,12 +86,25 @@ func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 			}, nil
 		}
 
+		for _, textOutput := range contents.TextOutputs {
+			err = util.ValidateGenerationOptions(textOutput.Options)
+			if err != nil {
+				return events.APIGatewayProxyResponse{
+					StatusCode: http.StatusBadRequest,
+					Headers:    constants.CorsHeaders,
+					Body:       "Bad Request: text output '" + textOutput.Title + "': " + err.Error(),
+				}, nil
+			}
+		}
+
 		newSection := models.ReportSection{
-			Title:        req.SectionTitle,
-			Questions:    contents.Questions,
-			TextOutputs:  contents.TextOutputs,
-			CSVData:      contents.CSVData,
-			ChartOutputs: contents.ChartOuput,
+			Title:         req.SectionTitle,
+			Label:         req.SectionLabel,
+			Questions:     contents.Questions,
+			TextOutputs:   contents.TextOutputs,
+			CSVData:       contents.CSVData,
+			ChartOutputs:  contents.ChartOuput,
+			FailurePolicy: req.FailurePolicy,
 		}
 		err = util.AddSectionToReport(req.ItemID, req.PartIndex, req.SectionIndex, newSection, userID)
 
@@ -105,12 +128,25 @@ func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 			}, nil
 		}
 
+		for _, textOutput := range contents.TextOutputs {
+			err = util.ValidateGenerationOptions(textOutput.Options)
+			if err != nil {
+				return events.APIGatewayProxyResponse{
+					StatusCode: http.StatusBadRequest,
+					Headers:    constants.CorsHeaders,
+					Body:       "Bad Request: text output '" + textOutput.Title + "': " + err.Error(),
+				}, nil
+			}
+		}
+
 		newSection := models.TemplateSection{
-			Title:        req.SectionTitle,
-			Questions:    contents.Questions,
-			TextOutputs:  contents.TextOutputs,
-			CSVData:      contents.CSVData,
-			ChartOutputs: contents.ChartOuput,
+			Title:         req.SectionTitle,
+			Label:         req.SectionLabel,
+			Questions:     contents.Questions,
+			TextOutputs:   contents.TextOutputs,
+			CSVData:       contents.CSVData,
+			ChartOutputs:  contents.ChartOuput,
+			FailurePolicy: req.FailurePolicy,
 		}
 
 		err = util.AddSectionToTemplate(req.ItemID, req.PartIndex, req.SectionIndex, newSection, userID)
@@ -5,6 +5,7 @@ import (
 	"api/shared/util"
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/aws/aws-lambda-go/events"
@@ -53,6 +54,16 @@ func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 	if req.ItemType == constants.Report {
 		err = util.ConvertReportToTemplate(req.ItemID, req.Title, userID)
 		if err != nil {
+			var lintErr *util.PlaceholderLintError
+			if errors.As(err, &lintErr) {
+				lintJSON, _ := json.Marshal(lintErr.Report)
+				return events.APIGatewayProxyResponse{
+					StatusCode: http.StatusBadRequest,
+					Headers:    constants.CorsHeaders,
+					Body:       string(lintJSON),
+				}, nil
+			}
+
 			return events.APIGatewayProxyResponse{
 				StatusCode: http.StatusInternalServerError,
 				Headers:    constants.CorsHeaders,
@@ -72,6 +83,16 @@ func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 
 		err = util.ConvertTemplateToReport(req.ItemID, req.Title, items.City, items.ReportType, userID)
 		if err != nil {
+			var lintErr *util.PlaceholderLintError
+			if errors.As(err, &lintErr) {
+				lintJSON, _ := json.Marshal(lintErr.Report)
+				return events.APIGatewayProxyResponse{
+					StatusCode: http.StatusBadRequest,
+					Headers:    constants.CorsHeaders,
+					Body:       string(lintJSON),
+				}, nil
+			}
+
 			return events.APIGatewayProxyResponse{
 				StatusCode: http.StatusInternalServerError,
 				Headers:    constants.CorsHeaders,
@@ -6,6 +6,7 @@ import (
 	"api/shared/util"
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/aws/aws-lambda-go/events"
@@ -13,14 +14,16 @@ import (
 )
 
 type UpdatedSectionRequest struct {
-	ItemType              constants.ItemType `json:"itemType"`
-	ItemID                string             `json:"itemID"`
-	OldPartIndex          int                `json:"oldPartIndex"`
-	NewPartIndex          int                `json:"newPartIndex"`
-	OldSectionIndex       int                `json:"oldSectionIndex"`
-	NewSectionIndex       int                `json:"newSectionIndex"`
-	NewSectionTitle       string             `json:"newSectionTitle"`
-	DeleteGeneratedOutput bool               `json:"deleteGeneratedOutput"`
+	ItemType              constants.ItemType          `json:"itemType"`
+	ItemID                string                      `json:"itemID"`
+	OldPartIndex          int                         `json:"oldPartIndex"`
+	NewPartIndex          int                         `json:"newPartIndex"`
+	OldSectionIndex       int                         `json:"oldSectionIndex"`
+	NewSectionIndex       int                         `json:"newSectionIndex"`
+	NewSectionTitle       string                      `json:"newSectionTitle"`
+	NewSectionLabel       string                      `json:"newSectionLabel"` // Optional, names the section in placeholders
+	DeleteGeneratedOutput bool                        `json:"deleteGeneratedOutput"`
+	FailurePolicy         models.SectionFailurePolicy `json:"failurePolicy"` // Optional, FailOutput when empty
 }
 
 type ReportSectionContents struct {
@@ -66,6 +69,14 @@ func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 		}, nil
 	}
 
+	if !req.FailurePolicy.IsValid() {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusBadRequest,
+			Headers:    constants.CorsHeaders,
+			Body:       "Bad Request: failurePolicy must be 'FailOutput' or 'FailSection'.",
+		}, nil
+	}
+
 	if req.ItemType == constants.Report {
 		var sectionContents ReportSectionContents
 
@@ -78,6 +89,17 @@ func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 			}, nil
 		}
 
+		for _, textOutput := range sectionContents.TextOutputs {
+			err = util.ValidateGenerationOptions(textOutput.Options)
+			if err != nil {
+				return events.APIGatewayProxyResponse{
+					StatusCode: http.StatusBadRequest,
+					Headers:    constants.CorsHeaders,
+					Body:       "Bad Request: text output '" + textOutput.Title + "': " + err.Error(),
+				}, nil
+			}
+		}
+
 		err = util.UpdateSectionInReport(
 			req.ItemID,
 			req.OldPartIndex,
@@ -85,14 +107,26 @@ func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 			req.OldSectionIndex,
 			req.NewSectionIndex,
 			req.NewSectionTitle,
+			req.NewSectionLabel,
 			sectionContents.Questions,
 			sectionContents.TextOutputs,
 			sectionContents.CSVData,
 			sectionContents.ChartOuput,
+			req.FailurePolicy,
 			req.DeleteGeneratedOutput,
 			userID)
 
 		if err != nil {
+			var lintErr *util.PlaceholderLintError
+			if errors.As(err, &lintErr) {
+				lintJSON, _ := json.Marshal(lintErr.Report)
+				return events.APIGatewayProxyResponse{
+					StatusCode: http.StatusBadRequest,
+					Headers:    constants.CorsHeaders,
+					Body:       string(lintJSON),
+				}, nil
+			}
+
 			return events.APIGatewayProxyResponse{
 				StatusCode: http.StatusInternalServerError,
 				Headers:    constants.CorsHeaders,
@@ -112,6 +146,17 @@ func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 			}, nil
 		}
 
+		for _, textOutput := range sectionContents.TextOutputs {
+			err = util.ValidateGenerationOptions(textOutput.Options)
+			if err != nil {
+				return events.APIGatewayProxyResponse{
+					StatusCode: http.StatusBadRequest,
+					Headers:    constants.CorsHeaders,
+					Body:       "Bad Request: text output '" + textOutput.Title + "': " + err.Error(),
+				}, nil
+			}
+		}
+
 		err = util.UpdateSectionInTemplate(
 			req.ItemID,
 			req.OldPartIndex,
@@ -119,13 +164,25 @@ func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 			req.OldSectionIndex,
 			req.NewSectionIndex,
 			req.NewSectionTitle,
+			req.NewSectionLabel,
 			sectionContents.Questions,
 			sectionContents.TextOutputs,
 			sectionContents.CSVData,
 			sectionContents.ChartOuput,
+			req.FailurePolicy,
 			userID)
 
 		if err != nil {
+			var lintErr *util.PlaceholderLintError
+			if errors.As(err, &lintErr) {
+				lintJSON, _ := json.Marshal(lintErr.Report)
+				return events.APIGatewayProxyResponse{
+					StatusCode: http.StatusBadRequest,
+					Headers:    constants.CorsHeaders,
+					Body:       string(lintJSON),
+				}, nil
+			}
+
 			return events.APIGatewayProxyResponse{
 				StatusCode: http.StatusInternalServerError,
 				Headers:    constants.CorsHeaders,
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"api/shared/constants"
+	"api/shared/models"
+	"api/shared/util"
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+func Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	userID, err := util.ExtractUserID(request)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       err.Error(),
+			Headers:    constants.CorsHeaders,
+		}, nil
+	}
+
+	// The usage of the user by default, or of everyone in their organization
+	scope := models.UsageScope(request.QueryStringParameters["scope"])
+	if scope == "" {
+		scope = models.UserUsage
+	}
+
+	if scope != models.UserUsage && scope != models.OrganizationUsage {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       "Bad Request: scope must be user or organization.",
+			Headers:    constants.CorsHeaders,
+		}, nil
+	}
+
+	from, to, err := util.UsageMonthRange(request.QueryStringParameters["from"], request.QueryStringParameters["to"])
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       "Bad Request: " + err.Error(),
+			Headers:    constants.CorsHeaders,
+		}, nil
+	}
+
+	summary, err := util.GetUsageSummary(userID, scope, from, to, util.IsOrganizationAdmin(request))
+	if err == util.ErrNoOrganization {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       "Bad Request: " + err.Error(),
+			Headers:    constants.CorsHeaders,
+		}, nil
+	}
+
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       "Error getting usage summary: " + err.Error(),
+			Headers:    constants.CorsHeaders,
+		}, nil
+	}
+
+	responseJSON, err := json.Marshal(summary)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       "Error marshalling usage summary into JSON: " + err.Error(),
+			Headers:    constants.CorsHeaders,
+		}, nil
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(responseJSON),
+		Headers:    constants.CorsHeaders,
+	}, nil
+}
+
+func main() {
+	lambda.Start(Handler)
+}
@@ -3,4 +3,9 @@ package constants
 const (
 	CognitoAttrSub      = "sub"      // Standard Attribute for User ID in Cognito
 	CognitoAttrNickName = "nickname" // Replace with the actual attribute name for Nickname
+
+	CognitoAttrOrganization = "custom:organization" // Optional, the organization the user generates for
+
+	CognitoClaimGroups             = "cognito:groups"
+	CognitoGroupOrganizationAdmins = "OrganizationAdmins" // Can see the usage of every member of their organization
 )
@@ -40,6 +40,13 @@ const (
 const (
 	CSVIDField           string = "CSVID"
 	CSVColumnsS3KeyField string = "CSVColumnsS3Key"
+	DerivedColumnsField  string = "DerivedColumns"
+	DatasetsField        string = "Datasets"
+	JoinsField           string = "Joins"
+	DatasetNameField     string = "Name"
+
+	JoinNameField               string = "Name"
+	JoinColumnsOperationIDField string = "ColumnsOperationID"
 )
 
 const (
@@ -48,8 +55,24 @@ const (
 )
 
 const (
-	OperationIDField        string = "OperationID"
-	OperationCompletedField string = "Completed"
+	OperationIDField         string = "OperationID"
+	OperationCompletedField  string = "Completed"
+	OperationStateField      string = "State"
+	OperationProgressField   string = "Progress"
+	OperationErrorField      string = "Error"
+	OperationResultField     string = "Result"
+	OperationValidationField string = "Validation"
+	OperationUpdatedAtField  string = "UpdatedAt"
+	OperationStartedAtField  string = "StartedAt"
+	OperationFinishedAtField string = "FinishedAt"
 )
 
 const GlobalQuestionsField string = "GlobalQuestions"
+
+const GenerationOptionsField string = "GenerationOptions"
+
+const (
+	UsageKeyField            string = "UsageKey"
+	UsageSortKeyField        string = "SortKey"
+	UsageOrganizationIDIndex string = "OrganizationID"
+)
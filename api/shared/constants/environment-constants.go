@@ -4,12 +4,38 @@ const (
 	ReportTable    string = "REPORT_TABLE"
 	TemplateTable  string = "TEMPLATE_TABLE"
 	OperationTable string = "OPERATION_TABLE"
+	UsageTable     string = "USAGE_TABLE"
 )
 
 const (
 	OpenAIKey string = "OPENAI_API_KEY"
 )
 
+// Generator providers are configured when their key, or endpoint for servers without
+// keys, is set. Models left empty on text outputs are the model of their provider.
+const (
+	GeneratorProvider       string = "GENERATOR_PROVIDER" // The provider of text outputs that do not name one, openai when empty
+	OpenAIBaseURL           string = "OPENAI_BASE_URL"    // Optional
+	OpenAIModel             string = "OPENAI_MODEL"       // Optional, gpt-3.5-turbo when empty
+	AnthropicKey            string = "ANTHROPIC_API_KEY"
+	AnthropicBaseURL        string = "ANTHROPIC_BASE_URL" // Optional
+	AnthropicModel          string = "ANTHROPIC_MODEL"
+	AzureOpenAIKey          string = "AZURE_OPENAI_API_KEY"
+	AzureOpenAIEndpoint     string = "AZURE_OPENAI_ENDPOINT"
+	AzureOpenAIAPIVersion   string = "AZURE_OPENAI_API_VERSION" // Optional
+	AzureOpenAIDeployment   string = "AZURE_OPENAI_DEPLOYMENT"
+	OpenAICompatibleBaseURL string = "OPENAI_COMPATIBLE_BASE_URL" // e.g. http://localhost:11434/v1 for Ollama
+	OpenAICompatibleKey     string = "OPENAI_COMPATIBLE_API_KEY"  // Optional
+	OpenAICompatibleModel   string = "OPENAI_COMPATIBLE_MODEL"
+)
+
+// Quotas left empty or 0 do not limit generation
+const (
+	UserMonthlyTokenQuota         string = "USER_MONTHLY_TOKEN_QUOTA"
+	OrganizationMonthlyTokenQuota string = "ORGANIZATION_MONTHLY_TOKEN_QUOTA"
+	GeneratorTokenPrices          string = "GENERATOR_TOKEN_PRICES" // Optional, json of models to their TokenPrice
+)
+
 const (
 	UserPoolID string = "USER_POOL_ID"
 )
@@ -18,3 +44,8 @@ const (
 	CsvBucketName        string = "CSV_BUCKET_NAME"
 	ColumnDataBucketName string = "COLUMN_DATA_BUCKET_NAME"
 )
+
+const (
+	ReportGenerationLambdaName string = "REPORT_GENERATION_LAMBDA_NAME"
+	JoinColumnsLambdaName      string = "JOIN_COLUMNS_LAMBDA_NAME"
+)
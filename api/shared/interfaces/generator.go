@@ -1,5 +1,34 @@
 package interfaces
 
+import (
+	"api/shared/models"
+	"context"
+)
+
 type Generator interface {
-	GeneratePromptResponse(prompt string) (string, error)
+	GeneratePromptResponse(ctx context.Context, request models.GenerationRequest) (models.GenerationResponse, error)
+}
+
+// GeneratorRegistry is a Generator using the default provider and model of the deployment,
+// which also gives the generator of any provider and model it is configured for. Empty
+// providers and models are the defaults.
+type GeneratorRegistry interface {
+	Generator
+	ModelGenerator(provider models.GeneratorProvider, model string) (Generator, error)
+	// ResolveModel returns the provider and model generating for the ones given
+	ResolveModel(provider models.GeneratorProvider, model string) (models.GeneratorProvider, string, error)
+}
+
+// QuotaChecker is a Generator that limits how much it generates. CheckQuota returns an
+// error when no more requests can be made.
+type QuotaChecker interface {
+	CheckQuota(ctx context.Context) error
+}
+
+// UsageLedger stores the usage of generator requests along with the monthly totals of the
+// users and organizations they were made for
+type UsageLedger interface {
+	RecordUsage(record models.UsageRecord) error
+	// MonthlyUsage returns the totals of a user or organization in a month, e.g. 2024-05
+	MonthlyUsage(scope models.UsageScope, id string, month string) (models.UsageTotals, error)
 }
@@ -1,5 +1,7 @@
 package models
 
+import "encoding/json"
+
 type ChartOperation string
 
 const (
@@ -7,6 +9,29 @@ const (
 	Average               ChartOperation = "Average"
 	UniqueOccurrences     ChartOperation = "UniqueOccurrences"
 	SetElementOccurrences ChartOperation = "SetElementOccurences"
+	Median                ChartOperation = "Median"
+	Min                   ChartOperation = "Min"
+	Max                   ChartOperation = "Max"
+	Percentile            ChartOperation = "Percentile"
+	StdDev                ChartOperation = "StdDev"
+)
+
+// Decides what happens to a row whose value cannot be parsed as a number
+type InvalidValuePolicy string
+
+const (
+	SkipInvalidValue InvalidValuePolicy = "Skip" // The row is left out and counted in SkippedRows
+	ZeroInvalidValue InvalidValuePolicy = "Zero" // The value is treated as zero
+	FailInvalidValue InvalidValuePolicy = "Fail" // Default. The whole generation fails
+)
+
+// Unit of the elapsed time between a start and an end column
+type IntervalUnit string
+
+const (
+	SecondsInterval IntervalUnit = "Seconds"
+	MinutesInterval IntervalUnit = "Minutes"
+	HHMMSSInterval  IntervalUnit = "HH:MM:SS" // Charts plot these in minutes to keep their values numerical, see ResultUnit
 )
 
 type CSVDataType string
@@ -22,6 +47,8 @@ type TemplateOneDimConfig struct {
 	Description string
 
 	OperationType ChartOperation
+	Percentile    float64      // Only used by the Percentile operation, between 0 and 100
+	IntervalUnit  IntervalUnit // Optional, set when the value is the time between two columns
 }
 
 type ReportOneDimConfig struct {
@@ -32,12 +59,37 @@ type ReportOneDimConfig struct {
 	Description string
 
 	OperationType ChartOperation
+	Percentile    float64 // Only used by the Percentile operation, between 0 and 100
 
 	AcceptedValues []string // Optional
 
-	FilterColumns map[string][]string
+	FilterColumns Filter
+
+	InvalidValuePolicy InvalidValuePolicy // Optional
+	DecimalSeparator   string             // Optional, "." by default or "," for values like 1.200,50
+	SkippedRows        int                // Rows left out of the result because their value was not a number or interval
+
+	// Optional. When both are set the value is the time from the start column to the end column instead of Column
+	StartColumn  string
+	EndColumn    string
+	IntervalUnit IntervalUnit // Seconds by default
+	DateFormat   string       // Optional, e.g. "MM/DD/YYYY HH:mm:ss". Common formats are detected when empty
+	TimeZone     string       // Optional, IANA name. Defaults to UTC
+	ResultUnit   IntervalUnit // Set when generated, the unit the chart plots the interval in. Minutes for HH:MM:SS
 }
 
+// Groups a date/time independent column into buckets instead of its raw values
+type TimeBucket string
+
+const (
+	YearBucket      TimeBucket = "Year"
+	QuarterBucket   TimeBucket = "Quarter"
+	MonthBucket     TimeBucket = "Month"
+	ISOWeekBucket   TimeBucket = "ISOWeek"
+	DayOfWeekBucket TimeBucket = "DayOfWeek"
+	HourOfDayBucket TimeBucket = "HourOfDay"
+)
+
 type ChartType string
 
 const (
@@ -49,4 +101,43 @@ const (
 	Radar   ChartType = "Radar"
 )
 
-type CsvDataColumnUniqueValuesMap map[string][]string
+// CsvDataColumnUniqueValuesMap holds the values of every column of a csv by column name
+type CsvDataColumnUniqueValuesMap map[string]CsvColumnValues
+
+// CsvColumnValues lists the most frequent values of a column. Columns with many
+// distinct values, like incident numbers, are capped and the rest of their values
+// are found by searching the column.
+type CsvColumnValues struct {
+	Values            []CsvColumnValueCount // Most frequent first
+	DistinctCount     int                   // Distinct values counted, including the values left out of Values
+	Truncated         bool                  // Values does not list every counted value
+	CountLimitReached bool                  // The column had more distinct values than are counted, the rest were left out
+}
+
+// UnmarshalJSON also reads the plain list of values stored before values were counted
+func (c *CsvColumnValues) UnmarshalJSON(data []byte) error {
+	var legacyValues []string
+	if err := json.Unmarshal(data, &legacyValues); err == nil {
+		c.Values = make([]CsvColumnValueCount, len(legacyValues))
+		for i, value := range legacyValues {
+			c.Values[i] = CsvColumnValueCount{Value: value}
+		}
+		c.DistinctCount = len(legacyValues)
+		return nil
+	}
+
+	type csvColumnValues CsvColumnValues
+	return json.Unmarshal(data, (*csvColumnValues)(c))
+}
+
+type CsvColumnValueCount struct {
+	Value string
+	Count int // Number of rows with the value
+}
+
+type ColumnValueOrder string
+
+const (
+	FrequencyOrder    ColumnValueOrder = "frequency"
+	AlphabeticalOrder ColumnValueOrder = "alphabetical"
+)
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+type FilterOperator string
+
+const (
+	InFilter        FilterOperator = "In"        // Value is one of Values. Without values no row passes
+	NotInFilter     FilterOperator = "NotIn"     // Value is none of Values
+	RangeFilter     FilterOperator = "Range"     // Number between Min and Max, inclusive. Either can be left out
+	DateRangeFilter FilterOperator = "DateRange" // Date between From and To, inclusive. Either can be left out
+	ContainsFilter  FilterOperator = "Contains"  // Value contains one of Values, ignoring case
+	PrefixFilter    FilterOperator = "Prefix"    // Value starts with one of Values, ignoring case
+	RegexFilter     FilterOperator = "Regex"     // Value matches the regular expression in Values
+	EmptyFilter     FilterOperator = "Empty"     // Value is blank
+	NotEmptyFilter  FilterOperator = "NotEmpty"  // Value is not blank
+)
+
+type FilterCondition struct {
+	Column   string
+	Operator FilterOperator // In when empty
+	Values   []string       // In, NotIn, Contains, Prefix and Regex
+
+	Min *float64 // Range
+	Max *float64 // Range
+
+	From       string // DateRange. A date without a time includes that whole day for To
+	To         string // DateRange
+	DateFormat string // Optional, format of the column values. Common formats are detected when empty
+	TimeZone   string // Optional, IANA name. Defaults to UTC
+}
+
+// FilterGroup passes a row when any of its conditions pass
+type FilterGroup struct {
+	Conditions []FilterCondition
+}
+
+// Filter passes a row when every one of its groups passes, so "priority 1 or 2
+// in 2023 where the unit starts with E" is three groups of one condition each.
+//
+// Filters used to be a map of columns to their accepted values. That format is
+// still decoded from JSON and DynamoDB, with every column becoming a group
+// holding a single In condition, so stored reports keep working.
+type Filter []FilterGroup
+
+// AcceptedValuesFilter returns a filter that passes rows whose column is one of the values,
+// or no filter at all when there are no values
+func AcceptedValuesFilter(column string, values []string) Filter {
+	if len(values) == 0 {
+		return nil
+	}
+	return Filter{{Conditions: []FilterCondition{{Column: column, Operator: InFilter, Values: values}}}}
+}
+
+// FilterFromColumns converts the map of columns to their accepted values that filters used to be
+func FilterFromColumns(filterColumns map[string][]string) Filter {
+	// Sorted so the same map always decodes to the same filter
+	columns := make([]string, 0, len(filterColumns))
+	for column := range filterColumns {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	filter := Filter{}
+	for _, column := range columns {
+		filter = append(filter, AcceptedValuesFilter(column, filterColumns[column])...)
+	}
+	return filter
+}
+
+func (f *Filter) UnmarshalJSON(data []byte) error {
+	var legacy map[string][]string
+	if len(data) > 0 && data[0] == '{' {
+		if err := json.Unmarshal(data, &legacy); err != nil {
+			return err
+		}
+		*f = FilterFromColumns(legacy)
+		return nil
+	}
+	return json.Unmarshal(data, (*[]FilterGroup)(f))
+}
+
+func (f *Filter) UnmarshalDynamoDBAttributeValue(av *dynamodb.AttributeValue) error {
+	if av.M != nil {
+		var legacy map[string][]string
+		if err := dynamodbattribute.Unmarshal(av, &legacy); err != nil {
+			return err
+		}
+		*f = FilterFromColumns(legacy)
+		return nil
+	}
+	return dynamodbattribute.Unmarshal(av, (*[]FilterGroup)(f))
+}
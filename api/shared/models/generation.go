@@ -0,0 +1,16 @@
+package models
+
+// SectionRef points to a section of a report by its position
+type SectionRef struct {
+	PartIndex    int
+	SectionIndex int
+}
+
+// ReportGenerationJob is the payload the report generation task is invoked with
+type ReportGenerationJob struct {
+	OperationID      string
+	ReportID         string
+	UserID           string
+	Sections         []SectionRef
+	GenerateAIOutput bool
+}
@@ -0,0 +1,62 @@
+package models
+
+// GeneratorProvider is a service the prompts of generator outputs are sent to
+type GeneratorProvider string
+
+const (
+	OpenAIProvider           GeneratorProvider = "openai"
+	AnthropicProvider        GeneratorProvider = "anthropic"
+	AzureOpenAIProvider      GeneratorProvider = "azure-openai"
+	OpenAICompatibleProvider GeneratorProvider = "openai-compatible" // A server with the OpenAI api, such as Ollama or llama.cpp
+)
+
+// SectionFailurePolicy decides what a generator output failing to generate does to its section
+type SectionFailurePolicy string
+
+const (
+	FailOutput  SectionFailurePolicy = "FailOutput"  // Only the output fails, the rest of the section is saved
+	FailSection SectionFailurePolicy = "FailSection" // The section fails and is not saved
+)
+
+// IsValid reports whether the policy is known, empty being the default
+func (p SectionFailurePolicy) IsValid() bool {
+	return p == "" || p == FailOutput || p == FailSection
+}
+
+// GenerationOptions control how generator outputs are written. Reports and templates hold
+// the defaults of their outputs, which each output can override. Unset options are left
+// to the provider.
+type GenerationOptions struct {
+	SystemPrompt  string   // Optional, e.g. the house style of the reports
+	Temperature   *float32 // Optional, 0 to 2. Lower is more deterministic
+	MaxTokens     int      // Optional, the longest result in tokens
+	StopSequences []string // Optional, up to 4 sequences the result ends before
+}
+
+// GenerationRequest is a prompt sent to a generator with the options to write its result with
+type GenerationRequest struct {
+	Prompt  string
+	Options GenerationOptions
+}
+
+// GenerationRecord is how the result of a generator output was written, so it can be
+// generated again the same way
+type GenerationRecord struct {
+	Provider    GeneratorProvider
+	Model       string
+	Options     GenerationOptions // The options of the item with those of the output applied
+	GeneratedAt int64
+	Prompt      string // The input of the output with its placeholders filled in
+}
+
+// TokenUsage is the tokens a provider counted for a generation request
+type TokenUsage struct {
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// GenerationResponse is the result of a generation request with the tokens it used
+type GenerationResponse struct {
+	Text  string
+	Usage TokenUsage
+}
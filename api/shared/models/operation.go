@@ -1,8 +1,86 @@
 package models
 
-// Used to store ongoing and complete operations
+type OperationType string
+
+const (
+	CsvUploadOperation        OperationType = "CsvUpload"
+	ReportGenerationOperation OperationType = "ReportGeneration"
+	JoinColumnsOperation      OperationType = "JoinColumns"
+)
+
+type OperationState string
+
+const (
+	OperationPending   OperationState = "Pending" // Created, the task has not started yet
+	OperationRunning   OperationState = "Running"
+	OperationSucceeded OperationState = "Succeeded"
+	OperationFailed    OperationState = "Failed"
+	OperationCancelled OperationState = "Cancelled"
+)
+
+// IsFinished reports whether the operation can no longer change state
+func (s OperationState) IsFinished() bool {
+	return s == OperationSucceeded || s == OperationFailed || s == OperationCancelled
+}
+
+// Used to store ongoing and complete operations. Any long running task can be
+// followed with an operation, which its owner polls until it is finished.
 type Operation struct {
 	OperationID string
-	Completed   bool
+	Type        OperationType
+	State       OperationState
+	Completed   bool // Set along with the Succeeded state, for pollers that only read this
+	Progress    OperationProgress
+	Error       string            // Set when the state is Failed
+	Result      *OperationResult  // Set when the state is Succeeded
+	Validation  *ValidationReport // Diagnostics of an uploaded file, set once it is validated
+	Upload      *UploadOptions    // Set on file upload operations, read when the uploaded file is normalized
+	UserID      string            // Owner of the operation, the only user that can read or cancel it
+	ItemID      string            // The report or template the operation works on
+	CreatedAt   int64
+	UpdatedAt   int64
+	StartedAt   int64 // 0 until the task starts
+	FinishedAt  int64 // 0 until the operation succeeds, fails or is cancelled
 	DeleteAt    int64
 }
+
+// OperationProgress is how far a running operation is. Tasks with known steps fill
+// in the step counts, others only the percentage.
+type OperationProgress struct {
+	Percent        int    // 0 to 100
+	Step           string // Description of the current step
+	CompletedSteps int
+	TotalSteps     int
+	Steps          []OperationStep // Optional, the state of each step for tasks that report them
+}
+
+// OperationStep is one unit of work of an operation, such as a section of a generated report
+type OperationStep struct {
+	Key   string // Identifies the step, e.g. "0.1" for the second section of the first part
+	Name  string
+	State OperationState
+	Error string // Set when the step failed
+}
+
+// OperationResult holds what an operation produced, in the field of its type
+type OperationResult struct {
+	CsvUpload        *CsvUploadResult
+	ReportGeneration *ReportGenerationResult
+	JoinColumns      *JoinColumnsResult
+}
+
+type CsvUploadResult struct {
+	CSVID           string
+	CSVColumnsS3Key string
+	RowCount        int
+	ColumnCount     int
+}
+
+type ReportGenerationResult struct {
+	GeneratedSections int
+	FailedSections    int // The errors are found in the steps of the progress
+}
+
+type JoinColumnsResult struct {
+	Joins []string // The joins whose column values map was built
+}
@@ -14,13 +14,28 @@ const (
 )
 
 type ReportTextOutput struct {
-	Title  string
-	Type   TextOutputType
-	Input  string
-	Result string
+	ID         string // Identifies the output across edits that move it within its section
+	Title      string
+	Type       TextOutputType
+	Input      string
+	Result     string
+	Provider   GeneratorProvider   // Optional, the provider of generator outputs. The deployment default when empty
+	Model      string              // Optional, the model of the provider. The provider default when empty
+	Options    *GenerationOptions  // Optional, overrides the generation options of the report
+	Generation *GenerationRecord   // Set along with the result of generator outputs
+	Error      string              // Set instead of the result when a generator output failed to generate
+	History    []TextOutputVersion // Previous results of generator outputs, newest first
+}
+
+// TextOutputVersion is a previous result of a generator output
+type TextOutputVersion struct {
+	Result     string
+	Generation GenerationRecord
 }
 
 type ReportChartOutput struct {
+	Dataset                string // Optional, name of the dataset to analyse. The report csv when empty
+	Label                  string // Optional, names the results in text output placeholders
 	Title                  string
 	Type                   ChartType
 	Description            string
@@ -32,30 +47,56 @@ type ReportChartOutput struct {
 	IndependentColumn string   // Actual column
 	AcceptedValues    []string // Optional
 
-	FilterColumns map[string][]string
+	TimeBucket  TimeBucket // Optional, buckets a date/time independent column
+	DateFormat  string     // Optional, e.g. "MM/DD/YYYY HH:mm". Common formats are detected when empty
+	TimeZone    string     // Optional, IANA name such as "America/Toronto". Defaults to UTC
+	SkippedRows int        // Rows whose independent column could not be read as a date/time
+
+	FilterColumns Filter
 
 	DependentColumns []ReportOneDimConfig
 
+	// Optional. Splits every dependent column into one series per value of this column, e.g. incident type per station
+	SeriesColumn     string
+	SeriesLimit      int      // Optional, keeps the series with the most rows and merges the rest into OtherSeriesLabel
+	OtherSeriesLabel string   // Optional, "Other" by default
+	Series           []string // Keys of the series in every row of Results, in order
+
 	Results []map[string]interface{}
 }
 
 type ReportCSVData struct {
+	Dataset         string // Optional, name of the dataset to analyse. The report csv when empty
 	Label           string
 	Description     string
 	OperationType   ChartOperation
+	Percentile      float64 // Only used by the Percentile operation, between 0 and 100
 	OperationColumn string
 	AcceptedValues  []string
-	FilterColumns   map[string][]string
+	FilterColumns   Filter
 	Result          string
+
+	InvalidValuePolicy InvalidValuePolicy // Optional
+	DecimalSeparator   string             // Optional, "." by default or "," for values like 1.200,50
+	SkippedRows        int                // Rows left out of the result because their value was not a number or interval
+
+	// Optional. When both are set the value is the time from the start column to the end column instead of OperationColumn
+	StartColumn  string
+	EndColumn    string
+	IntervalUnit IntervalUnit // Seconds by default
+	DateFormat   string       // Optional, e.g. "MM/DD/YYYY HH:mm:ss". Common formats are detected when empty
+	TimeZone     string       // Optional, IANA name. Defaults to UTC
 }
 
 type ReportSection struct {
 	Title           string
+	Label           string // Optional, names the section in the placeholders of other sections
 	OutputGenerated bool
 	Questions       []ReportQuestion
 	CSVData         []ReportCSVData
 	TextOutputs     []ReportTextOutput
 	ChartOutputs    []ReportChartOutput
+	FailurePolicy   SectionFailurePolicy // FailOutput when empty
 }
 
 type ReportPart struct {
@@ -63,6 +104,56 @@ type ReportPart struct {
 	Sections []ReportSection
 }
 
+// DerivedColumn is a column computed from the other columns of the report csv
+// with an expression, e.g. if([Code] < 3, "High", "Low")
+type DerivedColumn struct {
+	Name       string
+	Expression string
+}
+
+// Dataset is a named csv of a report, such as unit responses or inspections,
+// uploaded and analysed separately from the report csv
+type Dataset struct {
+	Name            string
+	CSVID           string // S3 key of the csv, also the ID of its upload operation
+	CSVColumnsS3Key string
+	DerivedColumns  []DerivedColumn
+}
+
+type JoinType string
+
+const (
+	LeftJoin  JoinType = "Left"  // Keeps every left row, right columns are blank without a match
+	InnerJoin JoinType = "Inner" // Keeps only the rows with a match on both sides
+)
+
+type JoinKey struct {
+	LeftColumn  string
+	RightColumn string // Optional, the left column name when empty
+}
+
+// Join combines two datasets of a report on key columns. Outputs analyse the
+// joined rows by using the join name as their dataset.
+type Join struct {
+	Name               string
+	Type               JoinType
+	LeftDataset        string // The report csv when empty
+	RightDataset       string // The report csv when empty
+	LeftPrefix         string // Optional, added to left column names found on both sides. The left dataset name when empty
+	RightPrefix        string // Optional, added to right column names found on both sides. The right dataset name when empty
+	Keys               []JoinKey
+	CSVColumnsS3Key    string // "no-csv-s3-key" until the column values map of the joined rows is built
+	ColumnsOperationID string // The operation building the column values map, which replaces the map once it succeeds
+}
+
+// JoinColumnsJob is the payload of the background task building the column values maps of joins
+type JoinColumnsJob struct {
+	OperationID string
+	ReportID    string
+	UserID      string
+	Joins       []string // Names of the joins to build
+}
+
 type Report struct {
 	ReportID       string
 	ReportType     string
@@ -76,10 +167,15 @@ type Report struct {
 	IsDeleted      bool
 	DeleteAt       int64
 	CSVID          string
+	DerivedColumns []DerivedColumn // Resolved as if they were headers of the csv
+	Datasets       []Dataset       // Additional named csvs, the report csv is the default unnamed dataset
+	Joins          []Join          // Joined views of the datasets, analysed like datasets
 
 	CSVColumnsS3Key string
 
 	GlobalQuestions []ReportQuestion
+
+	GenerationOptions GenerationOptions // Defaults of the generator outputs of the report
 }
 
 type ReportMetadata struct {
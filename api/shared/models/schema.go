@@ -0,0 +1,33 @@
+package models
+
+type ColumnType string
+
+const (
+	IntegerColumn     ColumnType = "Integer"
+	DecimalColumn     ColumnType = "Decimal"
+	DateTimeColumn    ColumnType = "DateTime"
+	BooleanColumn     ColumnType = "Boolean"
+	CategoricalColumn ColumnType = "Categorical" // Few distinct values repeated across rows, such as a station or an incident type
+	TextColumn        ColumnType = "Text"        // Free text, or a column with no values
+)
+
+// ColumnProfile describes the values of a csv column. Blank cells are counted
+// separately and left out of the type, cardinality and ranges.
+type ColumnProfile struct {
+	Name         string
+	Type         ColumnType
+	BlankCount   int
+	Cardinality  int      // Number of distinct values, up to the number of values counted per column
+	Min          *float64 // Only set for Integer and Decimal columns
+	Max          *float64 // Only set for Integer and Decimal columns
+	Mean         *float64 // Only set for Integer and Decimal columns
+	EarliestDate string   // RFC 3339, only set for DateTime columns
+	LatestDate   string   // RFC 3339, only set for DateTime columns
+}
+
+// CsvSchema holds the inferred type and profile of every column of a csv,
+// including the derived columns of its dataset
+type CsvSchema struct {
+	RowCount int
+	Columns  []ColumnProfile
+}
@@ -5,22 +5,43 @@ type Answer struct {
 }
 
 type OneDimConfigResponse struct {
-	Column         string   // The actual column in the csv
-	AcceptedValues []string // Optional
-	FilterColumns  map[string][]string
+	Column             string   // The actual column in the csv
+	AcceptedValues     []string // Optional
+	FilterColumns      Filter
+	InvalidValuePolicy InvalidValuePolicy // Optional
+	DecimalSeparator   string             // Optional
+	StartColumn        string             // Optional, with EndColumn replaces Column with an interval
+	EndColumn          string             // Optional
+	DateFormat         string             // Optional
+	TimeZone           string             // Optional
 }
 
 type ChartOutputResponse struct {
+	Dataset           string // Optional
 	IndependentColumn string
 	AcceptedValues    []string
+	DateFormat        string // Optional
+	TimeZone          string // Optional
 
 	DependentColumns []OneDimConfigResponse
-	FilterColumns    map[string][]string // Top level filter columns
+	FilterColumns    Filter // Top level filter columns
+
+	SeriesColumn     string // Optional
+	SeriesLimit      int    // Optional
+	OtherSeriesLabel string // Optional
 }
 
 type CsvDataResponse struct {
+	Dataset         string   // Optional
 	OperationColumn string   // The actual column in the csv
 	AcceptedValues  []string // Optional
 
-	FilterColumns map[string][]string // Has a map of filter columns and their accepted values
+	FilterColumns Filter // Groups of filter conditions that every row must pass
+
+	InvalidValuePolicy InvalidValuePolicy // Optional
+	DecimalSeparator   string             // Optional
+	StartColumn        string             // Optional, with EndColumn replaces OperationColumn with an interval
+	EndColumn          string             // Optional
+	DateFormat         string             // Optional
+	TimeZone           string             // Optional
 }
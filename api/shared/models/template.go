@@ -6,12 +6,17 @@ type TemplateQuestion struct {
 }
 
 type TemplateTextOutput struct {
-	Title string
-	Type  TextOutputType
-	Input string
+	Title    string
+	Type     TextOutputType
+	Input    string
+	Provider GeneratorProvider  // Optional, the provider of generator outputs. The deployment default when empty
+	Model    string             // Optional, the model of the provider. The provider default when empty
+	Options  *GenerationOptions // Optional, overrides the generation options of the template
 }
 
 type TemplateChartOutput struct {
+	Dataset       string // Optional, name of the dataset to analyse
+	Label         string // Optional, names the results in text output placeholders
 	Title         string
 	Type          ChartType
 	Description   string
@@ -20,21 +25,29 @@ type TemplateChartOutput struct {
 	CartesianGrid bool
 
 	IndependentColumnLabel string
+	TimeBucket             TimeBucket // Optional
+	SeriesLimit            int        // Optional, caps the series when the report picks a series column
+	OtherSeriesLabel       string     // Optional
 	DependentColumns       []TemplateOneDimConfig
 }
 
 type TemplateCSVData struct {
+	Dataset       string // Optional, name of the dataset to analyse
 	Label         string
 	Description   string
 	OperationType ChartOperation
+	Percentile    float64      // Only used by the Percentile operation, between 0 and 100
+	IntervalUnit  IntervalUnit // Optional, set when the value is the time between two columns
 }
 
 type TemplateSection struct {
-	Title        string
-	Questions    []TemplateQuestion
-	CSVData      []TemplateCSVData
-	TextOutputs  []TemplateTextOutput
-	ChartOutputs []TemplateChartOutput
+	Title         string
+	Label         string // Optional, names the section in the placeholders of other sections
+	Questions     []TemplateQuestion
+	CSVData       []TemplateCSVData
+	TextOutputs   []TemplateTextOutput
+	ChartOutputs  []TemplateChartOutput
+	FailurePolicy SectionFailurePolicy // FailOutput when empty
 }
 
 type TemplatePart struct {
@@ -54,6 +67,8 @@ type Template struct {
 	DeleteAt       int64
 
 	GlobalQuestions []TemplateQuestion
+
+	GenerationOptions GenerationOptions // Defaults of the generator outputs of the template
 }
 
 type TemplateMetadata struct {
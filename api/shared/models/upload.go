@@ -0,0 +1,17 @@
+package models
+
+type UploadFileType string
+
+const (
+	CSVFile  UploadFileType = "csv"
+	TSVFile  UploadFileType = "tsv"
+	XLSXFile UploadFileType = "xlsx"
+)
+
+// UploadOptions describe how an uploaded file is read before it is stored as a
+// comma delimited UTF-8 csv
+type UploadOptions struct {
+	FileType  UploadFileType // Optional, csv when empty
+	Sheet     string         // Optional, only used by xlsx files. The first sheet when empty
+	Delimiter string         // Optional, detected from the header line of csv files when empty
+}
@@ -0,0 +1,90 @@
+package models
+
+// UsageScope is who the usage of generator requests is totalled and limited for
+type UsageScope string
+
+const (
+	UserUsage         UsageScope = "user"
+	OrganizationUsage UsageScope = "organization"
+)
+
+// UsageContext is who and what the generator requests of a section are made for
+type UsageContext struct {
+	UserID         string
+	OrganizationID string // Empty when the user is not in an organization
+	ReportID       string
+	PartIndex      int
+	SectionIndex   int
+	SectionTitle   string
+}
+
+// UsageRecord is the usage of one generator request in the usage ledger
+type UsageRecord struct {
+	UsageKey         string // USER#<UserID>
+	SortKey          string // <Month>#<time of the request>#<id>, so records are in order by month
+	UserID           string
+	OrganizationID   string `dynamodbav:",omitempty"` // Index of the records of an organization, which can not be empty
+	ReportID         string
+	PartIndex        int
+	SectionIndex     int
+	SectionTitle     string
+	Provider         GeneratorProvider
+	Model            string
+	PromptTokens     int
+	CompletionTokens int
+	LatencyMs        int64
+	CostUSD          float64 // 0 when the model has no price configured
+	Error            string  // Set when the request failed
+	Month            string  // e.g. 2024-05
+	CreatedAt        int64
+}
+
+// UsageTotals are the totals of a set of usage records
+type UsageTotals struct {
+	Requests         int
+	FailedRequests   int
+	PromptTokens     int
+	CompletionTokens int
+	TotalTokens      int
+	CostUSD          float64
+}
+
+// UsageSummaryEntry is the usage of a user for a report in a month
+type UsageSummaryEntry struct {
+	UserID   string
+	ReportID string
+	Month    string
+	UsageTotals
+}
+
+// QuotaStatus is how much of the monthly token quota of a user or organization is used
+type QuotaStatus struct {
+	Scope         UsageScope
+	ID            string
+	Month         string
+	UsedTokens    int
+	MonthlyTokens int // 0 when there is no quota
+}
+
+// UsageSummary is the usage of a user, or of everyone in their organization, between two
+// months, along with the quotas of the current month
+type UsageSummary struct {
+	Scope   UsageScope
+	From    string
+	To      string
+	Entries []UsageSummaryEntry
+	Totals  UsageTotals
+	Quotas  []QuotaStatus
+}
+
+// TokenPrice is the price of a model in USD per million tokens
+type TokenPrice struct {
+	Prompt     float64
+	Completion float64
+}
+
+// UsageQuotas are the monthly token quotas of each user and each organization. 0 is no quota.
+type UsageQuotas struct {
+	UserMonthlyTokens         int
+	OrganizationMonthlyTokens int
+}
@@ -0,0 +1,67 @@
+package models
+
+type DiagnosticSeverity string
+
+const (
+	ErrorSeverity   DiagnosticSeverity = "Error"   // The upload fails, or the section is not saved
+	WarningSeverity DiagnosticSeverity = "Warning" // The upload completes or the section is saved, the diagnostic is shown with it
+)
+
+type DiagnosticKind string
+
+const (
+	MissingHeaderDiagnostic   DiagnosticKind = "MissingHeader" // No header row, or a column without a header
+	DuplicateHeaderDiagnostic DiagnosticKind = "DuplicateHeader"
+	RaggedRowDiagnostic       DiagnosticKind = "RaggedRow"    // A row with a different number of values than there are headers
+	MalformedRowDiagnostic    DiagnosticKind = "MalformedRow" // The file could not be parsed past this row, such as after a stray quote
+	EncodingDiagnostic        DiagnosticKind = "Encoding"     // Characters that are not text, usually from reading the file in the wrong encoding
+	EmptyColumnDiagnostic     DiagnosticKind = "EmptyColumn"
+	NumericParseDiagnostic    DiagnosticKind = "NumericParse" // Values of a mostly numeric column that are not numbers
+)
+
+// UploadDiagnostic is a problem found while validating an uploaded file
+type UploadDiagnostic struct {
+	Kind        DiagnosticKind
+	Severity    DiagnosticSeverity
+	Message     string
+	Line        int     // Line of the file the row starts on, 0 when the problem is not about a row
+	Column      string  // Header of the column, when the problem is about a column
+	FailureRate float64 // Share of the values of the column that are not numbers, only set for NumericParse
+}
+
+// ValidationReport holds the diagnostics of an uploaded file. Row diagnostics past
+// a limit for each kind are counted in OmittedDiagnostics rather than listed.
+type ValidationReport struct {
+	Valid              bool // False when any diagnostic is an error
+	RowCount           int
+	Diagnostics        []UploadDiagnostic
+	OmittedDiagnostics int
+}
+
+type PlaceholderIssueKind string
+
+const (
+	UndefinedPlaceholderIssue PlaceholderIssueKind = "UndefinedPlaceholder" // A placeholder without a question, csv data or chart with its label
+	InvalidPlaceholderIssue   PlaceholderIssueKind = "InvalidPlaceholder"   // A placeholder that can not be rendered, such as an unclosed {{#if}}
+	UnusedQuestionIssue       PlaceholderIssueKind = "UnusedQuestion"       // A question whose answer no text output uses
+	DuplicateLabelIssue       PlaceholderIssueKind = "DuplicateLabel"
+	PrefixLabelIssue          PlaceholderIssueKind = "PrefixLabel"    // A label starting with another label, ambiguous in **label text
+	ReferenceCycleIssue       PlaceholderIssueKind = "ReferenceCycle" // Sections referring to each other, which can not be generated in an order
+)
+
+// PlaceholderIssue is a problem with the placeholders of a text output or the labels they refer to
+type PlaceholderIssue struct {
+	Kind         PlaceholderIssueKind
+	Severity     DiagnosticSeverity
+	Message      string
+	PartIndex    int    // -1 for global questions
+	SectionIndex int    // -1 for global questions
+	TextOutput   string // Title of the text output, when the problem is in one
+	Label        string
+}
+
+// PlaceholderLintReport holds the placeholder issues of a report or template
+type PlaceholderLintReport struct {
+	Valid  bool // False when any issue is an error
+	Issues []PlaceholderIssue
+}
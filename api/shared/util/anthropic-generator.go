@@ -0,0 +1,124 @@
+package util
+
+import (
+	"api/shared/models"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	anthropicAPIURL    = "https://api.anthropic.com"
+	anthropicVersion   = "2023-06-01"
+	anthropicMaxTokens = 1024 // Required by the messages api, used when no max tokens are given
+)
+
+// AnthropicGenerator sends prompts to the Anthropic messages api
+type AnthropicGenerator struct {
+	APIKey     string
+	BaseURL    string // Optional, the Anthropic api when empty
+	Model      string
+	HTTPClient *http.Client
+}
+
+type anthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type anthropicRequest struct {
+	Model         string             `json:"model"`
+	System        string             `json:"system,omitempty"`
+	MaxTokens     int                `json:"max_tokens"`
+	Temperature   *float32           `json:"temperature,omitempty"`
+	StopSequences []string           `json:"stop_sequences,omitempty"`
+	Messages      []anthropicMessage `json:"messages"`
+}
+
+type anthropicResponse struct {
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	Usage struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (g AnthropicGenerator) GeneratePromptResponse(ctx context.Context, request models.GenerationRequest) (models.GenerationResponse, error) {
+	maxTokens := request.Options.MaxTokens
+	if maxTokens == 0 {
+		maxTokens = anthropicMaxTokens
+	}
+
+	body, err := json.Marshal(anthropicRequest{
+		Model:         g.Model,
+		System:        request.Options.SystemPrompt,
+		MaxTokens:     maxTokens,
+		Temperature:   request.Options.Temperature,
+		StopSequences: request.Options.StopSequences,
+		Messages:      []anthropicMessage{{Role: "user", Content: request.Prompt}},
+	})
+	if err != nil {
+		return models.GenerationResponse{}, fmt.Errorf("error marshalling anthropic request: %v", err)
+	}
+
+	baseURL := g.BaseURL
+	if baseURL == "" {
+		baseURL = anthropicAPIURL
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(baseURL, "/")+"/v1/messages", bytes.NewReader(body))
+	if err != nil {
+		return models.GenerationResponse{}, fmt.Errorf("error creating anthropic request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", g.APIKey)
+	req.Header.Set("anthropic-version", anthropicVersion)
+
+	client := g.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return models.GenerationResponse{}, fmt.Errorf("error sending anthropic request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var message anthropicResponse
+	err = json.NewDecoder(resp.Body).Decode(&message)
+	if err != nil {
+		return models.GenerationResponse{}, fmt.Errorf("error decoding anthropic response with status %d: %v", resp.StatusCode, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		if message.Error != nil {
+			return models.GenerationResponse{}, fmt.Errorf("anthropic error, status code: %d, message: %s", resp.StatusCode, message.Error.Message)
+		}
+		return models.GenerationResponse{}, fmt.Errorf("anthropic error, status code: %d", resp.StatusCode)
+	}
+
+	var text strings.Builder
+	for _, content := range message.Content {
+		if content.Type == "text" {
+			text.WriteString(content.Text)
+		}
+	}
+	return models.GenerationResponse{
+		Text: text.String(),
+		Usage: models.TokenUsage{
+			PromptTokens:     message.Usage.InputTokens,
+			CompletionTokens: message.Usage.OutputTokens,
+		},
+	}, nil
+}
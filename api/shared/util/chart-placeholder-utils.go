@@ -0,0 +1,218 @@
+package util
+
+import (
+	"api/shared/models"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The results of a chart output with a label are placeholders of the text outputs of its
+// section, as are those of every chart in the charts list:
+//
+//	{{calls.table}}                  the results as a markdown table, for prompts
+//	{{calls.csv}}                    the results as csv
+//	{{calls.max.label}} at {{calls.max.value}}, and min the same way
+//	{{calls.total}}                  the sum of the values
+//	{{#each calls.top.3}}{{label}}: {{value}}{{/each}}
+//
+// The helpers use the first value column of the chart. The helpers of every column are in
+// columns, such as {{calls.columns.1.max.value}}, along with the name of the column.
+
+// placeholderRanking is the rows of a chart column with the largest values first. It is a
+// list, and top.N is the list of its first N rows.
+type placeholderRanking []interface{}
+
+// chartPlaceholderFields are the fields of the results of a chart in placeholders
+func chartPlaceholderFields(chart models.ReportChartOutput) map[string]interface{} {
+	columns := chartValueColumns(chart)
+
+	var rows []interface{}
+	for _, result := range chart.Results {
+		row := map[string]interface{}{"label": chartPlaceholderValue(result[chart.IndependentColumn])}
+		for i, column := range columns {
+			value := chartPlaceholderValue(result[column])
+			if i == 0 {
+				row["value"] = value
+			}
+			if _, ok := row[column]; !ok {
+				row[column] = value
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	var columnFields []interface{}
+	for _, column := range columns {
+		fields := chartColumnPlaceholderFields(chart, column)
+		fields["name"] = column
+		columnFields = append(columnFields, fields)
+	}
+
+	fields := map[string]interface{}{
+		"label":       chart.Label,
+		"title":       chart.Title,
+		"description": chart.Description,
+		"table":       chartMarkdownTable(chart, columns),
+		"csv":         chartCSVTable(chart, columns),
+		"rows":        rows,
+		"columns":     columnFields,
+	}
+
+	// An empty column when the chart has none, so that {{#if calls.max.value}} is false
+	first := chartColumnPlaceholderFields(chart, "")
+	if len(columns) > 0 {
+		first = columnFields[0].(map[string]interface{})
+	}
+	for _, helper := range []string{"max", "min", "total", "top"} {
+		fields[helper] = first[helper]
+	}
+	return fields
+}
+
+// chartColumnPlaceholderFields has the max, min, total and ranking of the numbers of a
+// column. Rows without a number in the column are left out of them.
+func chartColumnPlaceholderFields(chart models.ReportChartOutput, column string) map[string]interface{} {
+	type chartRow struct {
+		label string
+		value float64
+		text  string
+	}
+
+	var rows []chartRow
+	total := 0.0
+	for _, result := range chart.Results {
+		text := chartPlaceholderValue(result[column])
+		value, ok := parseNumericalValue(text, ".")
+		if column == "" || !ok {
+			continue
+		}
+		rows = append(rows, chartRow{label: chartPlaceholderValue(result[chart.IndependentColumn]), value: value, text: text})
+		total += value
+	}
+
+	rowFields := func(row chartRow) map[string]interface{} {
+		return map[string]interface{}{"label": row.label, "value": row.text}
+	}
+
+	fields := map[string]interface{}{
+		"max":   map[string]interface{}{"label": "", "value": ""},
+		"min":   map[string]interface{}{"label": "", "value": ""},
+		"total": "",
+		"top":   placeholderRanking{},
+	}
+	if len(rows) == 0 {
+		return fields
+	}
+
+	// The first row wins ties, in the order of the chart
+	ranked := make([]chartRow, len(rows))
+	copy(ranked, rows)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].value > ranked[j].value
+	})
+
+	minimum := rows[0]
+	for _, row := range rows[1:] {
+		if row.value < minimum.value {
+			minimum = row
+		}
+	}
+
+	top := make(placeholderRanking, len(ranked))
+	for i, row := range ranked {
+		top[i] = rowFields(row)
+	}
+
+	fields["max"] = rowFields(ranked[0])
+	fields["min"] = rowFields(minimum)
+	fields["total"] = strconv.FormatFloat(math.Round(total*1000)/1000, 'f', -1, 64)
+	fields["top"] = top
+	return fields
+}
+
+// chartValueColumns are the keys of the values in each row of the results of a chart
+func chartValueColumns(chart models.ReportChartOutput) []string {
+	if len(chart.Series) > 0 {
+		return chart.Series
+	}
+
+	columns := make([]string, 0, len(chart.DependentColumns))
+	for _, dependent := range chart.DependentColumns {
+		columns = append(columns, dependent.AggregateValueLabel)
+	}
+	return columns
+}
+
+// chartIndependentHeader names the column of the labels of the rows in tables
+func chartIndependentHeader(chart models.ReportChartOutput) string {
+	switch {
+	case chart.IndependentColumnLabel != "":
+		return chart.IndependentColumnLabel
+	case chart.XAxisTitle != "":
+		return chart.XAxisTitle
+	default:
+		return chart.IndependentColumn
+	}
+}
+
+func chartMarkdownTable(chart models.ReportChartOutput, columns []string) string {
+	cell := func(text string) string {
+		text = strings.ReplaceAll(text, "|", `\|`)
+		return strings.Join(strings.Fields(text), " ")
+	}
+
+	var table strings.Builder
+	table.WriteString("| " + cell(chartIndependentHeader(chart)))
+	for _, column := range columns {
+		table.WriteString(" | " + cell(column))
+	}
+	table.WriteString(" |\n|" + strings.Repeat(" --- |", len(columns)+1))
+
+	for _, result := range chart.Results {
+		table.WriteString("\n| " + cell(chartPlaceholderValue(result[chart.IndependentColumn])))
+		for _, column := range columns {
+			table.WriteString(" | " + cell(chartPlaceholderValue(result[column])))
+		}
+		table.WriteString(" |")
+	}
+	return table.String()
+}
+
+func chartCSVTable(chart models.ReportChartOutput, columns []string) string {
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+
+	writer.Write(append([]string{chartIndependentHeader(chart)}, columns...))
+	for _, result := range chart.Results {
+		record := []string{chartPlaceholderValue(result[chart.IndependentColumn])}
+		for _, column := range columns {
+			record = append(record, chartPlaceholderValue(result[column]))
+		}
+		writer.Write(record)
+	}
+	writer.Flush()
+
+	return strings.TrimSuffix(buffer.String(), "\n")
+}
+
+// chartPlaceholderValue writes a value of the results, which are numbers when generated
+// and after being read back from DynamoDB
+func chartPlaceholderValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
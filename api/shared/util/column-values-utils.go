@@ -0,0 +1,189 @@
+package util
+
+import (
+	"api/shared/constants"
+	"api/shared/models"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+const (
+	// Distinct values counted per column. Once a column has this many, rows with
+	// new values are left out so that memory stays bounded for id and address columns.
+	maxCountedColumnValues = 10000
+	// Values listed per column in the column values map, the rest are found by searching the column
+	maxColumnMapValues = 500
+)
+
+// CsvColumnData is what is stored for the columns of a csv once it is read
+type CsvColumnData struct {
+	ColumnsMap   models.CsvDataColumnUniqueValuesMap
+	ColumnValues map[string][]models.CsvColumnValueCount // Every counted value of the columns truncated in ColumnsMap
+	Schema       *models.CsvSchema
+}
+
+// GetUniqueColumnValuesMapInCSV takes the CSV file and returns a map where keys are column
+// names and values are the most frequent values of those columns with their counts. Derived
+// columns are included so they can be used in filters and charts like the csv columns.
+func GetUniqueColumnValuesMapInCSV(file *os.File, derivedColumns []models.DerivedColumn) (models.CsvDataColumnUniqueValuesMap, error) {
+	aggregator := &uniqueValuesAggregator{}
+	err := streamCSV(file, derivedColumns, func(headers []string) ([]csvAggregator, error) {
+		aggregator.init(headers)
+		return []csvAggregator{aggregator}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return aggregator.result, nil
+}
+
+// uniqueValuesAggregator counts the rows of every value seen in every column
+type uniqueValuesAggregator struct {
+	headers      []string
+	valueCounts  []map[string]int
+	limitReached []bool
+	result       models.CsvDataColumnUniqueValuesMap
+	columnValues map[string][]models.CsvColumnValueCount
+}
+
+func (a *uniqueValuesAggregator) init(headers []string) {
+	a.headers = headers
+	a.valueCounts = make([]map[string]int, len(headers))
+	a.limitReached = make([]bool, len(headers))
+	for i := range headers {
+		a.valueCounts[i] = make(map[string]int)
+	}
+}
+
+func (a *uniqueValuesAggregator) consume(record []string) error {
+	for i, value := range record {
+		counts := a.valueCounts[i]
+		if _, seen := counts[value]; seen || len(counts) < maxCountedColumnValues {
+			counts[value]++
+		} else {
+			a.limitReached[i] = true
+		}
+	}
+	return nil
+}
+
+func (a *uniqueValuesAggregator) finish() error {
+	a.result = make(models.CsvDataColumnUniqueValuesMap, len(a.headers))
+	a.columnValues = make(map[string][]models.CsvColumnValueCount)
+	for i, header := range a.headers {
+		values := sortedValueCounts(a.valueCounts[i])
+		columnValues := models.CsvColumnValues{
+			Values:            values,
+			DistinctCount:     len(values),
+			CountLimitReached: a.limitReached[i],
+		}
+		if len(values) > maxColumnMapValues {
+			a.columnValues[header] = values
+			columnValues.Values = values[:maxColumnMapValues]
+			columnValues.Truncated = true
+		}
+		a.result[header] = columnValues
+	}
+	return nil
+}
+
+// sortedValueCounts orders the values of a column by their number of rows, most first
+func sortedValueCounts(counts map[string]int) []models.CsvColumnValueCount {
+	values := make([]models.CsvColumnValueCount, 0, len(counts))
+	for value, count := range counts {
+		values = append(values, models.CsvColumnValueCount{Value: value, Count: count})
+	}
+	sortValueCounts(values, models.FrequencyOrder)
+	return values
+}
+
+func sortValueCounts(values []models.CsvColumnValueCount, order models.ColumnValueOrder) {
+	sort.Slice(values, func(i, j int) bool {
+		if order != models.AlphabeticalOrder && values[i].Count != values[j].Count {
+			return values[i].Count > values[j].Count
+		}
+		return values[i].Value < values[j].Value
+	})
+}
+
+// columnValuesS3Key is the key of the values of a truncated column, stored next to
+// the column values map. Column names are encoded since they can hold any character.
+func columnValuesS3Key(csvColumnsS3Key, column string) string {
+	return strings.TrimSuffix(csvColumnsS3Key, ".json") + "/values/" + base64.RawURLEncoding.EncodeToString([]byte(column)) + ".json"
+}
+
+// SearchColumnValues returns a page of the values starting with the prefix, ignoring case,
+// along with the number of values that match
+func SearchColumnValues(values []models.CsvColumnValueCount, prefix string, order models.ColumnValueOrder, offset, limit int) ([]models.CsvColumnValueCount, int) {
+	prefix = strings.ToLower(prefix)
+	matches := make([]models.CsvColumnValueCount, 0, len(values))
+	for _, value := range values {
+		if strings.HasPrefix(strings.ToLower(value.Value), prefix) {
+			matches = append(matches, value)
+		}
+	}
+	sortValueCounts(matches, order)
+
+	if offset < 0 || offset >= len(matches) {
+		return []models.CsvColumnValueCount{}, len(matches)
+	}
+	end := min(offset+limit, len(matches))
+	return matches[offset:end], len(matches)
+}
+
+// GetColumnValues fetches every counted value of a column of the column values map
+func GetColumnValues(csvColumnsS3Key, column string) ([]models.CsvColumnValueCount, models.CsvColumnValues, error) {
+	columnsMapJSON, err := GetColumnValuesMapJSONFromS3(csvColumnsS3Key)
+	if err != nil {
+		return nil, models.CsvColumnValues{}, fmt.Errorf("error getting column values map from s3: %v", err)
+	}
+
+	var columnsMap models.CsvDataColumnUniqueValuesMap
+	err = json.Unmarshal(columnsMapJSON, &columnsMap)
+	if err != nil {
+		return nil, models.CsvColumnValues{}, fmt.Errorf("error unmarshalling column values map: %v", err)
+	}
+
+	columnValues, ok := columnsMap[column]
+	if !ok {
+		return nil, models.CsvColumnValues{}, fmt.Errorf("column '%s' not found", column)
+	}
+	if !columnValues.Truncated {
+		return columnValues.Values, columnValues, nil
+	}
+
+	s3Client, err := GetS3Client(constants.USEast2)
+	if err != nil {
+		return nil, models.CsvColumnValues{}, err
+	}
+
+	result, err := s3Client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(os.Getenv(constants.ColumnDataBucketName)),
+		Key:    aws.String(columnValuesS3Key(csvColumnsS3Key, column)),
+	})
+	if err != nil {
+		return nil, models.CsvColumnValues{}, fmt.Errorf("failed to get object: %v", err)
+	}
+	defer result.Body.Close()
+
+	valuesJSON, err := io.ReadAll(result.Body)
+	if err != nil {
+		return nil, models.CsvColumnValues{}, fmt.Errorf("failed to read object: %v", err)
+	}
+
+	var values []models.CsvColumnValueCount
+	err = json.Unmarshal(valuesJSON, &values)
+	if err != nil {
+		return nil, models.CsvColumnValues{}, fmt.Errorf("error unmarshalling values of column %s: %v", column, err)
+	}
+
+	return values, columnValues, nil
+}
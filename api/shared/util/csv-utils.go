@@ -4,15 +4,15 @@ import (
 	"api/shared/constants"
 	"api/shared/models"
 	"bytes"
-	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"io"
 	"os"
-	"path/filepath"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 	"github.com/aws/aws-sdk-go/service/s3"
 	jsoniter "github.com/json-iterator/go"
 )
@@ -25,11 +25,9 @@ func GetCSVFileHandle(s3Key string) (*os.File, error) {
 		return nil, err
 	}
 
-	tmpDir := "/tmp"
-	tempFileName := filepath.Join(tmpDir, "temp.csv")
-
-	// Create a file to write the S3 Object contents to.
-	file, err := os.Create(tempFileName)
+	// Create a file to write the S3 Object contents to. Each csv gets its own
+	// file so that several datasets can be open at once, such as for a join.
+	file, err := createTempCSVFile()
 	if err != nil {
 		return nil, err
 	}
@@ -65,68 +63,107 @@ func GetCSVFileHandle(s3Key string) (*os.File, error) {
 	return file, nil
 }
 
-// uniqueValuesInCSV takes theCSV file and returns a map where keys are column names
-// and values are slices of unique values in those columns.
-func GetUniqueColumnValuesMapInCSV(file *os.File) (models.CsvDataColumnUniqueValuesMap, error) {
-	reader := csv.NewReader(file)
-	headers, err := reader.Read()
+// createTempCSVFile creates a file in /tmp that is removed once it is closed
+func createTempCSVFile() (*os.File, error) {
+	file, err := os.CreateTemp("/tmp", "*.csv")
 	if err != nil {
 		return nil, err
 	}
+	// The open handle keeps the contents readable, so the name can be unlinked right away
+	// and the space is freed as soon as the caller closes the file
+	err = os.Remove(file.Name())
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
 
-	// Initialize map to hold sets for unique values per column
-	uniqueValuesMap := make(map[string]map[string]bool)
-	for _, header := range headers {
-		uniqueValuesMap[header] = make(map[string]bool)
+// UpdateReportCsvColumns uploads the column values map and schema of a csv and stores the key
+// of the map on its report or dataset, returning the key. The schema and the values of truncated
+// columns are found next to the map by csvSchemaS3Key and columnValuesS3Key.
+func UpdateReportCsvColumns(csvid string, columnData *CsvColumnData) (string, error) {
+	s3Key, err := uploadCsvColumnData(csvid+".json", columnData)
+	if err != nil {
+		return "", err
 	}
 
-	// Iterate over each record
-	for {
-		record, err := reader.Read()
-		if err == io.EOF {
-			break
-		}
+	// Store s3Key in DynamoDB
+	err = updateDynamoDBWithColumnDataS3Key(csvid, s3Key)
+	if err != nil {
+		return "", fmt.Errorf("error updating DynamoDB with S3 key: %v", err)
+	}
+
+	return s3Key, nil
+}
+
+func uploadCsvColumnData(s3Key string, columnData *CsvColumnData) (string, error) {
+	// Serialize csvColumns to JSON
+	jsonData, err := json.Marshal(columnData.ColumnsMap)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling csvColumns to JSON: %v", err)
+	}
+
+	// Upload JSON to S3
+	s3Key, err = uploadColumnDataToS3(s3Key, jsonData)
+	if err != nil {
+		return "", fmt.Errorf("error uploading csvColumns to S3: %v", err)
+	}
+
+	// Only the columns with more values than the map lists get their own file
+	for column, values := range columnData.ColumnValues {
+		valuesData, err := json.Marshal(values)
 		if err != nil {
-			return nil, err
+			return "", fmt.Errorf("error marshaling values of column %s to JSON: %v", column, err)
 		}
 
-		for i, value := range record {
-			// Update the set of unique values for the corresponding column
-			uniqueValuesMap[headers[i]][value] = true
+		_, err = uploadColumnDataToS3(columnValuesS3Key(s3Key, column), valuesData)
+		if err != nil {
+			return "", fmt.Errorf("error uploading values of column %s to S3: %v", column, err)
 		}
 	}
 
-	// Convert sets to slices
-	result := make(models.CsvDataColumnUniqueValuesMap)
-	for header, valuesSet := range uniqueValuesMap {
-		for value := range valuesSet {
-			result[header] = append(result[header], value)
+	if columnData.Schema != nil {
+		schemaData, err := json.Marshal(columnData.Schema)
+		if err != nil {
+			return "", fmt.Errorf("error marshaling schema to JSON: %v", err)
+		}
+
+		_, err = uploadColumnDataToS3(csvSchemaS3Key(s3Key), schemaData)
+		if err != nil {
+			return "", fmt.Errorf("error uploading schema to S3: %v", err)
 		}
 	}
 
-	return result, nil
+	return s3Key, nil
 }
 
-func UpdateReportCsvColumns(csvid string, csvColumns models.CsvDataColumnUniqueValuesMap) error {
-	// Serialize csvColumns to JSON
-	jsonData, err := json.Marshal(csvColumns)
+// GetColumnSchemaJSONFromS3 fetches the schema stored next to a column values map.
+// The returned bool is false when the csv was read before schemas were stored.
+func GetColumnSchemaJSONFromS3(csvColumnsS3Key string) ([]byte, bool, error) {
+	s3Client, err := GetS3Client(constants.USEast2)
 	if err != nil {
-		return fmt.Errorf("error marshaling csvColumns to JSON: %v", err)
+		return nil, false, err
 	}
 
-	// Upload JSON to S3
-	s3Key, err := uploadColumnDataToS3(csvid+".json", jsonData)
+	result, err := s3Client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(os.Getenv(constants.ColumnDataBucketName)),
+		Key:    aws.String(csvSchemaS3Key(csvColumnsS3Key)),
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == s3.ErrCodeNoSuchKey {
+		return nil, false, nil
+	}
 	if err != nil {
-		return fmt.Errorf("error uploading csvColumns to S3: %v", err)
+		return nil, false, fmt.Errorf("failed to get object: %v", err)
 	}
+	defer result.Body.Close()
 
-	// Store s3Key in DynamoDB
-	err = updateDynamoDBWithColumnDataS3Key(csvid, s3Key)
+	schemaJSON, err := io.ReadAll(result.Body)
 	if err != nil {
-		return fmt.Errorf("error updating DynamoDB with S3 key: %v", err)
+		return nil, false, fmt.Errorf("failed to read object: %v", err)
 	}
 
-	return nil
+	return schemaJSON, true, nil
 }
 
 // *models.CsvDataColumnUniqueValuesMap
@@ -184,6 +221,10 @@ func uploadColumnDataToS3(s3Key string, data []byte) (string, error) {
 }
 
 func updateDynamoDBWithColumnDataS3Key(csvid, s3Key string) error {
+	if reportID, ok := reportIDFromDatasetKey(csvid); ok {
+		return updateDatasetColumnDataS3Key(reportID, csvid, s3Key)
+	}
+
 	tableName := os.Getenv(constants.ReportTable)
 	dynamoDBClient, err := GetDynamoDBClient(constants.USEast2)
 	if err != nil {
@@ -264,3 +305,53 @@ func queryPrimaryKeyByCSVID(dynamoDBClient *dynamodb.DynamoDB, tableName, csvid
 
 	return *primaryKey, nil
 }
+
+// GetDerivedColumnsByCSVID fetches the derived columns of the report or dataset a csv was uploaded to
+func GetDerivedColumnsByCSVID(csvid string) ([]models.DerivedColumn, error) {
+	if reportID, ok := reportIDFromDatasetKey(csvid); ok {
+		datasets, err := getReportDatasets(reportID)
+		if err != nil {
+			return nil, err
+		}
+		index, err := findDatasetByCSVID(datasets, csvid)
+		if err != nil {
+			return nil, err
+		}
+		return datasets[index].DerivedColumns, nil
+	}
+
+	tableName := os.Getenv(constants.ReportTable)
+	dynamoDBClient, err := GetDynamoDBClient(constants.USEast2)
+	if err != nil {
+		return nil, err
+	}
+
+	primaryKey, err := queryPrimaryKeyByCSVID(dynamoDBClient, tableName, csvid)
+	if err != nil {
+		return nil, fmt.Errorf("error querying primary key by CSVID: %v", err)
+	}
+	if primaryKey == "" {
+		return nil, nil
+	}
+
+	result, err := dynamoDBClient.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			constants.ReportIDField: {
+				S: aws.String(primaryKey),
+			},
+		},
+		ProjectionExpression: aws.String(constants.DerivedColumnsField),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error getting item from DynamoDB: %v", err)
+	}
+
+	var report models.Report
+	err = dynamodbattribute.UnmarshalMap(result.Item, &report)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling dynamo item into report: %v", err)
+	}
+
+	return report.DerivedColumns, nil
+}
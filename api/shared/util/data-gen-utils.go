@@ -7,128 +7,495 @@ import (
 	"io"
 	"math"
 	"os"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
 )
 
+// csvAggregator is fed every row of a csv during a single streaming pass.
+// consume is called once per record (excluding the headers) and finish once
+// the end of the file is reached, at which point the aggregator writes its
+// results back into the report output it was built from.
+type csvAggregator interface {
+	consume(record []string) error
+	finish() error
+}
+
+// AnalyzeSectionData computes the csv data and chart output results that use
+// the dataset in every given section with a single pass over its csv. Passing
+// every section of a report lets the whole report be computed from one read
+// of each file. The derived columns of the dataset can be used like any other
+// column.
+func AnalyzeSectionData(csvFile *os.File, dataset models.Dataset, sections ...*models.ReportSection) error {
+	return streamCSV(csvFile, dataset.DerivedColumns, func(headers []string) ([]csvAggregator, error) {
+		var aggregators []csvAggregator
+		for _, section := range sections {
+			csvDataAggregators, err := newCSVDataAggregators(headers, dataset.Name, section)
+			if err != nil {
+				return nil, err
+			}
+			chartAggregators, err := newChartOutputAggregators(headers, dataset.Name, section)
+			if err != nil {
+				return nil, err
+			}
+			aggregators = append(aggregators, csvDataAggregators...)
+			aggregators = append(aggregators, chartAggregators...)
+		}
+		return aggregators, nil
+	})
+}
+
 func AnalyzeOneDimensionalData(csvFile *os.File, columnConfig *models.ReportCSVData) error {
-	records, err := readCSVFromHandler(csvFile)
+	return streamCSV(csvFile, nil, func(headers []string) ([]csvAggregator, error) {
+		aggregator, err := newOneDimAggregator(headers, columnConfig)
+		if err != nil {
+			return nil, err
+		}
+		return []csvAggregator{aggregator}, nil
+	})
+}
+
+func AnalyzeTwoDimensionalData(csvFile *os.File, reportOutput *models.ReportChartOutput) error {
+	return streamCSV(csvFile, nil, func(headers []string) ([]csvAggregator, error) {
+		aggregator, err := newTwoDimAggregator(headers, reportOutput)
+		if err != nil {
+			return nil, err
+		}
+		return []csvAggregator{aggregator}, nil
+	})
+}
+
+func newCSVDataAggregators(headers []string, dataset string, section *models.ReportSection) ([]csvAggregator, error) {
+	aggregators := make([]csvAggregator, 0, len(section.CSVData))
+	for index := range section.CSVData {
+		if section.CSVData[index].Dataset != dataset {
+			continue
+		}
+		aggregator, err := newOneDimAggregator(headers, &section.CSVData[index])
+		if err != nil {
+			return nil, fmt.Errorf("error generating section csv data results: %v", err)
+		}
+		aggregators = append(aggregators, aggregator)
+	}
+	return aggregators, nil
+}
+
+func newChartOutputAggregators(headers []string, dataset string, section *models.ReportSection) ([]csvAggregator, error) {
+	aggregators := make([]csvAggregator, 0, len(section.ChartOutputs))
+	for index := range section.ChartOutputs {
+		if section.ChartOutputs[index].Dataset != dataset {
+			continue
+		}
+		aggregator, err := newTwoDimAggregator(headers, &section.ChartOutputs[index])
+		if err != nil {
+			return nil, fmt.Errorf("error generating section chart output results: %v", err)
+		}
+		aggregators = append(aggregators, aggregator)
+	}
+	return aggregators, nil
+}
+
+// streamCSV reads the csv one record at a time, handing every record to the
+// aggregators built from the header row. Only a single record is held in
+// memory at once, so the file size is not bound by the lambda memory. Derived
+// columns are computed for each record and appended after the csv columns.
+func streamCSV(csvFile *os.File, derivedColumns []models.DerivedColumn, build func(headers []string) ([]csvAggregator, error)) error {
+	_, err := csvFile.Seek(0, io.SeekStart)
+	if err != nil {
+		return fmt.Errorf("error seeking in file: %v", err)
+	}
+
+	reader := csv.NewReader(csvFile)
+	reader.ReuseRecord = true
+
+	headerRecord, err := reader.Read()
+	if err == io.EOF {
+		return fmt.Errorf("error reading CSV: file is empty")
+	}
+	if err != nil {
+		return fmt.Errorf("error reading CSV: %v", err)
+	}
+	// The reader reuses its record slice, so the headers need their own copy
+	headers := append([]string(nil), headerRecord...)
+
+	headers, derived, err := compileDerivedColumns(headers, derivedColumns)
 	if err != nil {
 		return err
 	}
-	output := make(map[string]interface{})
-	headers := records[0]
-	columnIndex := findColumnIndex(headers, columnConfig.OperationColumn)
-	if columnIndex == -1 {
-		return fmt.Errorf("column '%s' not found in the CSV file", columnConfig.OperationColumn)
+
+	aggregators, err := build(headers)
+	if err != nil {
+		return err
 	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("error reading CSV: %v", err)
+		}
+		if derived != nil {
+			record = derived.extend(record)
+		}
+		for _, aggregator := range aggregators {
+			if err := aggregator.consume(record); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, aggregator := range aggregators {
+		if err := aggregator.finish(); err != nil {
+			return err
+		}
+	}
+
+	// Seek to the beginning of the file to allow for reading again
+	_, err = csvFile.Seek(0, io.SeekStart)
+	return err
+}
+
+// oneDimAggregator reduces a single column to the one value stored in a
+// ReportCSVData result.
+type oneDimAggregator struct {
+	columnConfig *models.ReportCSVData
+	config       models.ReportOneDimConfig
+	value        operationValue
+	filters      rowFilter
+	output       map[string]interface{}
+}
+
+func newOneDimAggregator(headers []string, columnConfig *models.ReportCSVData) (*oneDimAggregator, error) {
 	transformedInput := models.ReportOneDimConfig{
 		AggregateValueLabel: columnConfig.Label,
 		Column:              columnConfig.OperationColumn,
 		OperationType:       columnConfig.OperationType,
+		Percentile:          columnConfig.Percentile,
 		FilterColumns:       columnConfig.FilterColumns,
 		AcceptedValues:      columnConfig.AcceptedValues,
+		InvalidValuePolicy:  columnConfig.InvalidValuePolicy,
+		DecimalSeparator:    columnConfig.DecimalSeparator,
+		StartColumn:         columnConfig.StartColumn,
+		EndColumn:           columnConfig.EndColumn,
+		IntervalUnit:        columnConfig.IntervalUnit,
+		DateFormat:          columnConfig.DateFormat,
+		TimeZone:            columnConfig.TimeZone,
+	}
+	if err := validateStatisticOperation(&transformedInput); err != nil {
+		return nil, err
 	}
-	for _, record := range records[1:] {
-		err := processOperation(record, output, &transformedInput, columnIndex, headers, true)
-		if err != nil {
-			return fmt.Errorf("error occurred during process operation: '%s", err)
-		}
+	value, err := newOperationValue(headers, &transformedInput)
+	if err != nil {
+		return nil, err
+	}
+	if value.missingColumn != "" {
+		return nil, fmt.Errorf("column '%s' not found in the CSV file", value.missingColumn)
+	}
+	filters, err := compileOperationFilters(headers, &transformedInput)
+	if err != nil {
+		return nil, err
 	}
-	if columnConfig.OperationType == models.Average {
-		calculateAverages(output, &transformedInput)
+	return &oneDimAggregator{
+		columnConfig: columnConfig,
+		config:       transformedInput,
+		value:        value,
+		filters:      filters,
+		output:       make(map[string]interface{}),
+	}, nil
+}
+
+func (a *oneDimAggregator) consume(record []string) error {
+	err := processOperation(record, a.output, &a.config, a.value, a.filters, true)
+	if err != nil {
+		return fmt.Errorf("error occurred during process operation: '%s", err)
 	}
-	fmt.Println(output)
-	value, ok := output[columnConfig.Label]
+	return nil
+}
+
+func (a *oneDimAggregator) finish() error {
+	finalizeOperation(a.output, &a.config)
+	a.columnConfig.SkippedRows = a.config.SkippedRows
+	value, ok := a.output[a.columnConfig.Label]
 	// No counts were found. Set to zero
 	if !ok {
 		value = 0
 	}
 	switch v := value.(type) {
 	case int:
-		columnConfig.Result = strconv.Itoa(v)
+		a.columnConfig.Result = strconv.Itoa(v)
 	case float64:
-		columnConfig.Result = strconv.FormatFloat(v, 'f', -1, 64)
+		if a.config.IntervalUnit == models.HHMMSSInterval && isIntervalOperation(&a.config) {
+			a.columnConfig.Result = formatDuration(v)
+		} else {
+			a.columnConfig.Result = strconv.FormatFloat(v, 'f', -1, 64)
+		}
 	default:
 		return fmt.Errorf("value for key is neither an int nor a float64")
 	}
+	return nil
+}
 
-	// Seek to the beginning of the file to allow for reading again
-	_, err = csvFile.Seek(0, io.SeekStart)
-	if err != nil {
-		return err
-	}
+// twoDimAggregator groups rows on the independent column of a chart and
+// aggregates every dependent column within each group.
+type twoDimAggregator struct {
+	reportOutput           *models.ReportChartOutput
+	independentColumnIndex int
+	filters                rowFilter
+	dependents             []dependentColumn
+	output                 map[string]map[string]interface{}
 
-	return nil
+	bucketer    *timeBucketer  // Only set when the independent column is bucketed by time
+	ordinals    map[string]int // Ordinal of every time bucket label, used for sorting
+	skippedRows int
+
+	// Only used with a series column. The values of each series are kept apart
+	// until finish, where they are pivoted into the keys of the output groups.
+	seriesColumnIndex int
+	seriesOutput      map[string]map[string]map[string]interface{}
+	seriesRows        map[string]int
 }
 
-func AnalyzeTwoDimensionalData(csvFile *os.File, reportOutput *models.ReportChartOutput) error {
-	records, err := readCSVFromHandler(csvFile)
-	if err != nil {
-		return err
-	}
+type dependentColumn struct {
+	config  models.ReportOneDimConfig
+	value   operationValue
+	filters rowFilter
+}
 
-	headers := records[0]
+func newTwoDimAggregator(headers []string, reportOutput *models.ReportChartOutput) (*twoDimAggregator, error) {
 	independentColumnIndex := findColumnIndex(headers, reportOutput.IndependentColumn)
 	if independentColumnIndex == -1 {
-		return fmt.Errorf("column '%s' not found in the CSV file", reportOutput.IndependentColumn)
-	}
-	output := make(map[string]map[string]interface{})
-	for _, record := range records[1:] {
-		independentValue := record[independentColumnIndex]
-		if !rowPassesFilters(record, reportOutput.FilterColumns, headers) || !rowPassesFilters(record,
-			map[string][]string{
-				reportOutput.IndependentColumn: reportOutput.AcceptedValues,
-			}, headers) {
-			continue
+		return nil, fmt.Errorf("column '%s' not found in the CSV file", reportOutput.IndependentColumn)
+	}
+
+	filter := append(models.Filter{}, reportOutput.FilterColumns...)
+	filter = append(filter, models.AcceptedValuesFilter(reportOutput.IndependentColumn, reportOutput.AcceptedValues)...)
+	filters, err := compileFilters(headers, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	dependents := make([]dependentColumn, len(reportOutput.DependentColumns))
+	for i, columnConfig := range reportOutput.DependentColumns {
+		if err := validateStatisticOperation(&columnConfig); err != nil {
+			return nil, err
 		}
-		if _, exists := output[independentValue]; !exists {
-			output[independentValue] = make(map[string]interface{})
+		// Skipped rows are counted again on every generation
+		columnConfig.SkippedRows = 0
+		columnConfig.ResultUnit = ""
+		if isIntervalOperation(&columnConfig) {
+			columnConfig.IntervalUnit = chartIntervalUnit(columnConfig.IntervalUnit)
+			columnConfig.ResultUnit = columnConfig.IntervalUnit
 		}
-		for _, columnConfig := range reportOutput.DependentColumns {
-			columnIndex := findColumnIndex(headers, columnConfig.Column)
-			if columnIndex == -1 {
-				return fmt.Errorf("column '%s' not found in the CSV file", columnConfig.Column)
-			}
-			err := processOperation(record, output[independentValue], &columnConfig, columnIndex, headers, false)
-			if err != nil {
-				return err
+		value, err := newOperationValue(headers, &columnConfig)
+		if err != nil {
+			return nil, err
+		}
+		dependentFilters, err := compileOperationFilters(headers, &columnConfig)
+		if err != nil {
+			return nil, err
+		}
+		dependents[i] = dependentColumn{
+			config:  columnConfig,
+			value:   value,
+			filters: dependentFilters,
+		}
+	}
+
+	bucketer, err := newTimeBucketer(reportOutput)
+	if err != nil {
+		return nil, err
+	}
+
+	seriesColumnIndex := -1
+	if reportOutput.SeriesColumn != "" {
+		seriesColumnIndex = findColumnIndex(headers, reportOutput.SeriesColumn)
+		if seriesColumnIndex == -1 {
+			return nil, fmt.Errorf("column '%s' not found in the CSV file", reportOutput.SeriesColumn)
+		}
+		for _, dependent := range dependents {
+			// Every raw value is its own key with UniqueOccurrences, which the series already does
+			if dependent.config.OperationType == models.UniqueOccurrences {
+				return nil, fmt.Errorf("operation type %s cannot be used with a series column", models.UniqueOccurrences)
 			}
 		}
 	}
-	for _, data := range output {
-		for _, columnConfig := range reportOutput.DependentColumns {
-			if columnConfig.OperationType == models.Average {
-				calculateAverages(data, &columnConfig)
+
+	return &twoDimAggregator{
+		reportOutput:           reportOutput,
+		independentColumnIndex: independentColumnIndex,
+		filters:                filters,
+		dependents:             dependents,
+		output:                 make(map[string]map[string]interface{}),
+		bucketer:               bucketer,
+		ordinals:               make(map[string]int),
+		seriesColumnIndex:      seriesColumnIndex,
+		seriesOutput:           make(map[string]map[string]map[string]interface{}),
+		seriesRows:             make(map[string]int),
+	}, nil
+}
+
+func (a *twoDimAggregator) consume(record []string) error {
+	if !a.filters.passes(record) {
+		return nil
+	}
+	independentValue := record[a.independentColumnIndex]
+	if a.bucketer != nil {
+		label, ordinal, ok := a.bucketer.bucketOf(independentValue)
+		if !ok {
+			a.skippedRows++
+			return nil
+		}
+		independentValue = label
+		a.ordinals[label] = ordinal
+	}
+	var group map[string]interface{}
+	if a.seriesColumnIndex != -1 {
+		group = a.seriesGroup(independentValue, record[a.seriesColumnIndex])
+	} else {
+		var exists bool
+		group, exists = a.output[independentValue]
+		if !exists {
+			group = make(map[string]interface{})
+			a.output[independentValue] = group
+		}
+	}
+	for i := range a.dependents {
+		dependent := &a.dependents[i]
+		// A missing dependent column is only an error once a row needs it
+		if dependent.value.missingColumn != "" {
+			return fmt.Errorf("column '%s' not found in the CSV file", dependent.value.missingColumn)
+		}
+		err := processOperation(record, group, &dependent.config, dependent.value, dependent.filters, false)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *twoDimAggregator) finish() error {
+	if a.seriesColumnIndex != -1 {
+		a.pivotSeries()
+	} else {
+		for _, data := range a.output {
+			for i := range a.dependents {
+				finalizeOperation(data, &a.dependents[i].config)
 			}
 		}
+		a.reportOutput.Series = nil
 	}
-	reportOutput.Results, err = convertToChartFormat(reportOutput.IndependentColumn, &output)
-	if err != nil {
-		return err
+	for i := range a.dependents {
+		a.reportOutput.DependentColumns[i].SkippedRows = a.dependents[i].config.SkippedRows
+		a.reportOutput.DependentColumns[i].ResultUnit = a.dependents[i].config.ResultUnit
 	}
+	a.reportOutput.SkippedRows = a.skippedRows
 
-	// Seek to the beginning of the file to allow for reading again
-	_, err = csvFile.Seek(0, io.SeekStart)
+	var ordinals map[string]int
+	if a.bucketer != nil {
+		err := a.bucketer.fillEmptyBuckets(a.output, a.ordinals)
+		if err != nil {
+			return err
+		}
+		ordinals = a.ordinals
+	}
+	results, err := convertToChartFormat(a.reportOutput.IndependentColumn, &a.output, ordinals)
 	if err != nil {
 		return err
 	}
-
+	a.reportOutput.Results = results
 	return nil
 }
 
-func readCSVFromHandler(fileHandler *os.File) ([][]string, error) {
-	_, err := fileHandler.Seek(0, 0)
-	if err != nil {
-		return nil, fmt.Errorf("error seeking in file: %v", err)
+// seriesGroup returns the values accumulated for the series within the independent group
+func (a *twoDimAggregator) seriesGroup(independentValue string, series string) map[string]interface{} {
+	if series == "" {
+		series = blankSeriesLabel
 	}
-	reader := csv.NewReader(fileHandler)
-	records, err := reader.ReadAll()
-	if err != nil {
-		return nil, fmt.Errorf("error reading CSV: %v", err)
+	a.seriesRows[series]++
+
+	seriesGroups, exists := a.seriesOutput[independentValue]
+	if !exists {
+		seriesGroups = make(map[string]map[string]interface{})
+		a.seriesOutput[independentValue] = seriesGroups
+	}
+	group, exists := seriesGroups[series]
+	if !exists {
+		group = make(map[string]interface{})
+		seriesGroups[series] = group
+	}
+	return group
+}
+
+// pivotSeries finalizes every series and sets its values as keys of the output
+// groups. Every group gets the same keys, with zero for series it has no rows in.
+func (a *twoDimAggregator) pivotSeries() {
+	series, merged := rankSeries(a.seriesRows, a.reportOutput.SeriesLimit)
+	if len(merged) > 0 {
+		otherLabel := a.reportOutput.OtherSeriesLabel
+		if otherLabel == "" {
+			otherLabel = defaultOtherSeriesLabel
+		}
+		// Merge the raw values so operations like the average stay exact
+		for _, seriesGroups := range a.seriesOutput {
+			other := make(map[string]interface{})
+			for s, group := range seriesGroups {
+				if merged[s] {
+					mergeOperationOutput(other, group)
+					delete(seriesGroups, s)
+				}
+			}
+			if existing, ok := seriesGroups[otherLabel]; ok {
+				mergeOperationOutput(other, existing)
+			}
+			seriesGroups[otherLabel] = other
+		}
+		// A series may already have the label of the merged ones
+		if !slices.Contains(series, otherLabel) {
+			series = append(series, otherLabel)
+		}
+	}
+
+	keys := make([]string, 0, len(series)*len(a.dependents))
+	for _, s := range series {
+		for i := range a.dependents {
+			keys = append(keys, seriesKey(s, &a.dependents[i].config, len(a.dependents)))
+		}
+	}
+	a.reportOutput.Series = keys
+
+	for independentValue, seriesGroups := range a.seriesOutput {
+		group := make(map[string]interface{}, len(keys))
+		for _, s := range series {
+			data := seriesGroups[s]
+			for i := range a.dependents {
+				config := &a.dependents[i].config
+				var value interface{} = 0
+				if data != nil {
+					finalizeOperation(data, config)
+					if v, ok := data[config.AggregateValueLabel]; ok {
+						value = v
+					}
+				}
+				group[seriesKey(s, config, len(a.dependents))] = value
+			}
+		}
+		a.output[independentValue] = group
+	}
+}
+
+// finalizeOperation turns the values accumulated for an operation into its result once every row has been read
+func finalizeOperation(output map[string]interface{}, columnConfig *models.ReportOneDimConfig) {
+	switch {
+	case columnConfig.OperationType == models.Average:
+		calculateAverages(output, columnConfig)
+	case columnConfig.OperationType == models.NumericalSum:
+		roundSum(output, columnConfig)
+	case isStatisticOperation(columnConfig.OperationType):
+		calculateStatistics(output, columnConfig)
 	}
-	return records, nil
 }
 
 func incrementValue(output map[string]interface{}, key string) {
@@ -139,44 +506,34 @@ func incrementValue(output map[string]interface{}, key string) {
 	}
 }
 
-func addNumericalValue(output map[string]interface{}, value string, key string) error {
-	num, err := strconv.Atoi(value)
-	if err != nil {
-		return fmt.Errorf("error parsing numerical value: %w", err)
-	}
+func addNumericalValue(output map[string]interface{}, num float64, key string) {
 	if _, exists := output[key]; !exists {
 		output[key] = num
 	} else {
-		output[key] = output[key].(int) + num
+		output[key] = output[key].(float64) + num
 	}
-	return nil
 }
 
-func addNumericalValueForAverage(output map[string]interface{}, value string, key string) error {
-	num, err := strconv.Atoi(value)
-	if err != nil {
-		return fmt.Errorf("error parsing numerical value: %w", err)
-	}
+func addNumericalValueForAverage(output map[string]interface{}, num float64, key string) {
 	totalKey := key + "_total"
 	countKey := key + "_count"
 	if _, exists := output[totalKey]; !exists {
-		output[totalKey] = 0
+		output[totalKey] = 0.0
 	}
 	if _, exists := output[countKey]; !exists {
 		output[countKey] = 0
 	}
-	output[totalKey] = output[totalKey].(int) + num
+	output[totalKey] = output[totalKey].(float64) + num
 	output[countKey] = output[countKey].(int) + 1
-	return nil
 }
 
 func calculateAverages(output map[string]interface{}, columnConfig *models.ReportOneDimConfig) {
 	for key, value := range output {
 		if strings.HasSuffix(key, "_total") {
-			total := value.(int)
+			total := value.(float64)
 			countKey := strings.TrimSuffix(key, "_total") + "_count"
 			count := output[countKey].(int)
-			average := float64(total) / float64(count)
+			average := total / float64(count)
 			roundedAverage := math.Round(average*1000) / 1000
 			avgKey := strings.TrimSuffix(key, "_total")
 			output[avgKey] = roundedAverage
@@ -186,75 +543,47 @@ func calculateAverages(output map[string]interface{}, columnConfig *models.Repor
 	}
 }
 
-func rowPassesFilters(row []string, filterColumns map[string][]string, headers []string) bool {
-	if len(filterColumns) == 0 {
-		return true
-	}
-	for column, filterValues := range filterColumns {
-		if len(filterValues) == 0 {
-			continue
-		}
-
-		colIndex := -1
-		for i, header := range headers {
-			if header == column {
-				colIndex = i
-				break
-			}
-		}
-		if colIndex == -1 {
-			return false
-		}
-		value := row[colIndex]
-		valuePasses := false
-		for _, filterValue := range filterValues {
-			if value == filterValue {
-				valuePasses = true
-				break
-			}
-		}
-		if !valuePasses {
-			return false
-		}
-	}
-	return true
-}
-
-func processOperation(record []string, output map[string]interface{}, columnConfig *models.ReportOneDimConfig, columnIndex int, headers []string, isOneDimension bool) error {
-	dynamicFilters := columnConfig.FilterColumns
-	if len(columnConfig.AcceptedValues) > 0 {
-		if dynamicFilters == nil {
-			dynamicFilters = make(map[string][]string)
-		}
-		dynamicFilters[columnConfig.Column] = columnConfig.AcceptedValues
-	}
-	if !rowPassesFilters(record, dynamicFilters, headers) {
+func processOperation(record []string, output map[string]interface{}, columnConfig *models.ReportOneDimConfig, value operationValue, filters rowFilter, isOneDimension bool) error {
+	if !filters.passes(record) {
 		return nil // Skip this row, but don't return an error.
 	}
-	yValue := record[columnIndex]
+	yValue, ok := value.read(record, columnConfig)
+	if !ok {
+		return nil // Dropped intervals are counted in the skipped rows
+	}
 	switch columnConfig.OperationType {
 	case models.UniqueOccurrences:
 		if isOneDimension {
 			incrementValue(output, columnConfig.AggregateValueLabel)
 		} else {
-			incrementValue(output, yValue)
+			incrementValue(output, yValue.text)
 		}
 	case models.Average:
 		if columnConfig.AggregateValueLabel == "" {
 			return fmt.Errorf("aggregate value cannot be unassigned for operation type: %s", columnConfig.OperationType)
 		}
-		if err := addNumericalValueForAverage(output, yValue, columnConfig.AggregateValueLabel); err != nil {
+		num, ok, err := yValue.number(columnConfig)
+		if !ok {
 			return err
 		}
+		addNumericalValueForAverage(output, num, columnConfig.AggregateValueLabel)
 	case models.NumericalSum:
-		if err := addNumericalValue(output, yValue, columnConfig.AggregateValueLabel); err != nil {
+		num, ok, err := yValue.number(columnConfig)
+		if !ok {
 			return err
 		}
+		addNumericalValue(output, num, columnConfig.AggregateValueLabel)
 	case models.SetElementOccurrences:
 		if columnConfig.AggregateValueLabel == "" {
 			return fmt.Errorf("aggregate value cannot be unassigned for operation type: %s", columnConfig.OperationType)
 		}
 		incrementValue(output, columnConfig.AggregateValueLabel)
+	case models.Median, models.Min, models.Max, models.Percentile, models.StdDev:
+		num, ok, err := yValue.number(columnConfig)
+		if !ok {
+			return err
+		}
+		addStatisticValue(output, num, columnConfig.AggregateValueLabel, columnConfig.OperationType)
 	default:
 		return fmt.Errorf("unsupported operation type: %s", columnConfig.OperationType)
 	}
@@ -270,7 +599,9 @@ func findColumnIndex(headers []string, columnName string) int {
 	return -1
 }
 
-func convertToChartFormat(IndependentColumn string, output *map[string]map[string]interface{}) ([]map[string]interface{}, error) {
+// convertToChartFormat flattens the grouped output into chart rows. Rows are
+// sorted by their ordinal when one is given, otherwise by the group key.
+func convertToChartFormat(IndependentColumn string, output *map[string]map[string]interface{}, ordinals map[string]int) ([]map[string]interface{}, error) {
 	var transformedData []map[string]interface{}
 	for key, value := range *output {
 		if key == "" {
@@ -283,21 +614,26 @@ func convertToChartFormat(IndependentColumn string, output *map[string]map[strin
 		transformedMap[IndependentColumn] = key
 		transformedData = append(transformedData, transformedMap)
 	}
-	sortedData, err := sortData(IndependentColumn, transformedData)
+	sortedData, err := sortData(IndependentColumn, transformedData, ordinals)
 	if err != nil {
 		return nil, err
 	}
 	return sortedData, nil
 }
 
-func sortData(sortKey string, listOfMaps []map[string]interface{}) ([]map[string]interface{}, error) {
+func sortData(sortKey string, listOfMaps []map[string]interface{}, ordinals map[string]int) ([]map[string]interface{}, error) {
 	for _, m := range listOfMaps {
 		if _, ok := m[sortKey]; !ok {
 			return nil, fmt.Errorf("sort key %s not found in one of the maps", sortKey)
 		}
 	}
 	sort.Slice(listOfMaps, func(i, j int) bool {
-		return listOfMaps[i][sortKey].(string) < listOfMaps[j][sortKey].(string)
+		left := listOfMaps[i][sortKey].(string)
+		right := listOfMaps[j][sortKey].(string)
+		if ordinals != nil {
+			return ordinals[left] < ordinals[right]
+		}
+		return left < right
 	})
 	return listOfMaps, nil
 }
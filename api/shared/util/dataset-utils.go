@@ -0,0 +1,394 @@
+package util
+
+import (
+	"api/shared/constants"
+	"api/shared/models"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/google/uuid"
+)
+
+// Named datasets are uploaded under the ID of their report, so the csv
+// trigger can find the report without the CSVID index of the report csv
+const datasetKeyPrefix = "datasets/"
+
+// hasCSV reports whether a csv was uploaded, reports start with a placeholder CSVID
+func hasCSV(csvid string) bool {
+	return csvid != "" && csvid != "no-csv-id"
+}
+
+func newDatasetCSVKey(reportID string) string {
+	return datasetKeyPrefix + reportID + "/" + uuid.New().String() + ".csv"
+}
+
+// reportIDFromDatasetKey returns the report a dataset csv was uploaded to, or
+// false when the key is of a report csv
+func reportIDFromDatasetKey(csvid string) (string, bool) {
+	if !strings.HasPrefix(csvid, datasetKeyPrefix) {
+		return "", false
+	}
+	reportID, _, found := strings.Cut(strings.TrimPrefix(csvid, datasetKeyPrefix), "/")
+	return reportID, found
+}
+
+// FindReportDataset returns the dataset of the report with the name. The report
+// csv is the dataset with an empty name.
+func FindReportDataset(report *models.Report, name string) (models.Dataset, error) {
+	if name == "" {
+		return models.Dataset{
+			CSVID:           report.CSVID,
+			CSVColumnsS3Key: report.CSVColumnsS3Key,
+			DerivedColumns:  report.DerivedColumns,
+		}, nil
+	}
+	for _, dataset := range report.Datasets {
+		if dataset.Name == name {
+			return dataset, nil
+		}
+	}
+	return models.Dataset{}, fmt.Errorf("dataset '%s' not found in report", name)
+}
+
+// sectionDatasetNames returns the names of the datasets the outputs of the section analyse
+func sectionDatasetNames(section *models.ReportSection) []string {
+	var names []string
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	for _, data := range section.CSVData {
+		add(data.Dataset)
+	}
+	for _, chart := range section.ChartOutputs {
+		add(chart.Dataset)
+	}
+	return names
+}
+
+// openReportDataset downloads the csv of a dataset, or joins the csvs of a join, and returns
+// the file to analyse along with the dataset its outputs are matched with
+func openReportDataset(report *models.Report, datasetName string) (*os.File, models.Dataset, error) {
+	if join, ok := findReportJoin(report, datasetName); ok {
+		csvFile, err := openReportJoin(report, join)
+		if err != nil {
+			return nil, models.Dataset{}, err
+		}
+		return csvFile, models.Dataset{Name: join.Name}, nil
+	}
+
+	dataset, err := FindReportDataset(report, datasetName)
+	if err != nil {
+		return nil, models.Dataset{}, err
+	}
+	if !hasCSV(dataset.CSVID) {
+		return nil, models.Dataset{}, fmt.Errorf("no csv has been uploaded for dataset '%s'", datasetName)
+	}
+
+	// Load CSV file from S3
+	csvFile, err := GetCSVFileHandle(dataset.CSVID)
+	if err != nil {
+		return nil, models.Dataset{}, fmt.Errorf("error loading CSV from S3: %v", err)
+	}
+
+	return csvFile, dataset, nil
+}
+
+func findReportJoin(report *models.Report, name string) (models.Join, bool) {
+	if name == "" {
+		return models.Join{}, false
+	}
+	for _, join := range report.Joins {
+		if join.Name == name {
+			return join, true
+		}
+	}
+	return models.Join{}, false
+}
+
+// openReportJoin downloads both datasets of the join and joins their rows into a temporary csv
+func openReportJoin(report *models.Report, join models.Join) (*os.File, error) {
+	left, err := openJoinSide(report, join.LeftDataset)
+	if err != nil {
+		return nil, err
+	}
+	defer left.File.Close()
+
+	right, err := openJoinSide(report, join.RightDataset)
+	if err != nil {
+		return nil, err
+	}
+	defer right.File.Close()
+
+	csvFile, err := JoinCSV(join, left, right)
+	if err != nil {
+		return nil, fmt.Errorf("error joining datasets of join '%s': %v", join.Name, err)
+	}
+	return csvFile, nil
+}
+
+func openJoinSide(report *models.Report, datasetName string) (JoinSide, error) {
+	dataset, err := FindReportDataset(report, datasetName)
+	if err != nil {
+		return JoinSide{}, err
+	}
+	if !hasCSV(dataset.CSVID) {
+		return JoinSide{}, fmt.Errorf("no csv has been uploaded for %s", joinSideName(datasetName))
+	}
+
+	csvFile, err := GetCSVFileHandle(dataset.CSVID)
+	if err != nil {
+		return JoinSide{}, fmt.Errorf("error loading CSV from S3: %v", err)
+	}
+	return JoinSide{File: csvFile, Dataset: dataset}, nil
+}
+
+// ValidateJoins checks that the joins of a report have unique names, which are
+// not also dataset names, and that they join existing datasets on key columns
+func ValidateJoins(report *models.Report, joins []models.Join) error {
+	names := make(map[string]bool, len(joins))
+	for _, join := range joins {
+		if strings.TrimSpace(join.Name) == "" {
+			return fmt.Errorf("join name cannot be empty")
+		}
+		if names[join.Name] {
+			return fmt.Errorf("join name '%s' is used more than once", join.Name)
+		}
+		names[join.Name] = true
+
+		for _, dataset := range report.Datasets {
+			if dataset.Name == join.Name {
+				return fmt.Errorf("join name '%s' is already used by a dataset", join.Name)
+			}
+		}
+		if join.Type != models.LeftJoin && join.Type != models.InnerJoin {
+			return fmt.Errorf("join '%s' has an unsupported type '%s'", join.Name, join.Type)
+		}
+		if _, err := FindReportDataset(report, join.LeftDataset); err != nil {
+			return fmt.Errorf("join '%s': %v", join.Name, err)
+		}
+		if _, err := FindReportDataset(report, join.RightDataset); err != nil {
+			return fmt.Errorf("join '%s': %v", join.Name, err)
+		}
+		if len(join.Keys) == 0 {
+			return fmt.Errorf("join '%s' has no key columns", join.Name)
+		}
+		for _, key := range join.Keys {
+			if key.LeftColumn == "" {
+				return fmt.Errorf("join '%s' has a key without a column", join.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// errDatasetsChanged is returned when the datasets of a report were changed since they were read
+var errDatasetsChanged = errors.New("datasets were changed at the same time, try again")
+
+// setReportDatasetCSVID points the named dataset of the report to a new csv, adding the dataset if it is new.
+// Only that dataset is written, so uploads to other datasets at the same time are kept.
+func setReportDatasetCSVID(report *models.Report, datasetName, csvid string) error {
+	datasets := report.Datasets
+	for attempt := 0; ; attempt++ {
+		index := -1
+		for i := range datasets {
+			if datasets[i].Name == datasetName {
+				index = i
+			}
+		}
+
+		var err error
+		if index >= 0 {
+			// The column map is written again once the new csv is read
+			err = updateReportDataset(report.ReportID, index, datasetName, map[string]*dynamodb.AttributeValue{
+				constants.CSVIDField:           {S: aws.String(csvid)},
+				constants.CSVColumnsS3KeyField: {S: aws.String("no-csv-s3-key")},
+			})
+		} else {
+			err = appendReportDataset(report.ReportID, len(datasets), models.Dataset{Name: datasetName, CSVID: csvid, CSVColumnsS3Key: "no-csv-s3-key"})
+		}
+		if err != errDatasetsChanged || attempt == 2 {
+			return err
+		}
+
+		datasets, err = getReportDatasets(report.ReportID)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// updateReportDataset sets fields of the dataset at the index, if it is still the named dataset
+func updateReportDataset(reportID string, index int, datasetName string, fields map[string]*dynamodb.AttributeValue) error {
+	dynamoDBClient, err := GetDynamoDBClient(constants.USEast2)
+	if err != nil {
+		return fmt.Errorf("error getting dynamodb client: %v", err)
+	}
+
+	datasetPath := fmt.Sprintf("%s[%d]", constants.DatasetsField, index)
+	values := map[string]*dynamodb.AttributeValue{
+		":name": {S: aws.String(datasetName)},
+		":lm":   {N: aws.String(strconv.FormatInt(GetCurrentTime(), 10))},
+	}
+	updates := []string{constants.LastModifiedAtField + " = :lm"}
+	for field, value := range fields {
+		values[":"+field] = value
+		updates = append(updates, datasetPath+"."+field+" = :"+field)
+	}
+	sort.Strings(updates)
+
+	_, err = dynamoDBClient.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String(os.Getenv(constants.ReportTable)),
+		Key: map[string]*dynamodb.AttributeValue{
+			constants.ReportIDField: {
+				S: aws.String(reportID),
+			},
+		},
+		UpdateExpression:          aws.String("set " + strings.Join(updates, ", ")),
+		ConditionExpression:       aws.String(datasetPath + ".#name = :name"),
+		ExpressionAttributeNames:  map[string]*string{"#name": aws.String(constants.DatasetNameField)},
+		ExpressionAttributeValues: values,
+	})
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return errDatasetsChanged
+		}
+		return fmt.Errorf("failed to update item: %v", err)
+	}
+	return nil
+}
+
+// appendReportDataset adds a dataset to the report, if it still has count datasets
+func appendReportDataset(reportID string, count int, dataset models.Dataset) error {
+	dynamoDBClient, err := GetDynamoDBClient(constants.USEast2)
+	if err != nil {
+		return fmt.Errorf("error getting dynamodb client: %v", err)
+	}
+
+	datasetAttrValue, err := dynamodbattribute.MarshalMap(dataset)
+	if err != nil {
+		return fmt.Errorf("failed to marshal dataset: %v", err)
+	}
+
+	condition := "size(" + constants.DatasetsField + ") = :count"
+	if count == 0 {
+		condition = "attribute_not_exists(" + constants.DatasetsField + ") OR " + condition
+	}
+
+	_, err = dynamoDBClient.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String(os.Getenv(constants.ReportTable)),
+		Key: map[string]*dynamodb.AttributeValue{
+			constants.ReportIDField: {
+				S: aws.String(reportID),
+			},
+		},
+		UpdateExpression:    aws.String("set " + constants.DatasetsField + " = list_append(if_not_exists(" + constants.DatasetsField + ", :empty), :d), " + constants.LastModifiedAtField + " = :lm"),
+		ConditionExpression: aws.String(condition),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":d":     {L: []*dynamodb.AttributeValue{{M: datasetAttrValue}}},
+			":empty": {L: []*dynamodb.AttributeValue{}},
+			":count": {N: aws.String(strconv.Itoa(count))},
+			":lm":    {N: aws.String(strconv.FormatInt(GetCurrentTime(), 10))},
+		},
+	})
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return errDatasetsChanged
+		}
+		return fmt.Errorf("failed to update item: %v", err)
+	}
+	return nil
+}
+
+// getReportDatasets fetches the datasets of a report without checking who is asking,
+// for the csv trigger which has no user
+func getReportDatasets(reportID string) ([]models.Dataset, error) {
+	dynamoDBClient, err := GetDynamoDBClient(constants.USEast2)
+	if err != nil {
+		return nil, fmt.Errorf("error getting dynamodb client: %v", err)
+	}
+
+	result, err := dynamoDBClient.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(os.Getenv(constants.ReportTable)),
+		Key: map[string]*dynamodb.AttributeValue{
+			constants.ReportIDField: {
+				S: aws.String(reportID),
+			},
+		},
+		ProjectionExpression: aws.String(constants.DatasetsField),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error getting item from DynamoDB: %v", err)
+	}
+	if result.Item == nil {
+		return nil, fmt.Errorf("report not found")
+	}
+
+	var report models.Report
+	err = dynamodbattribute.UnmarshalMap(result.Item, &report)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling dynamo item into report: %v", err)
+	}
+	return report.Datasets, nil
+}
+
+// findDatasetByCSVID returns the index of the dataset holding the csv
+func findDatasetByCSVID(datasets []models.Dataset, csvid string) (int, error) {
+	for i, dataset := range datasets {
+		if dataset.CSVID == csvid {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("no dataset holds csv '%s'", csvid)
+}
+
+// updateDatasetColumnDataS3Key stores the key of the column map of a dataset csv
+func updateDatasetColumnDataS3Key(reportID, csvid, s3Key string) error {
+	datasets, err := getReportDatasets(reportID)
+	if err != nil {
+		return err
+	}
+	index, err := findDatasetByCSVID(datasets, csvid)
+	if err != nil {
+		return err
+	}
+
+	dynamoDBClient, err := GetDynamoDBClient(constants.USEast2)
+	if err != nil {
+		return fmt.Errorf("error getting dynamodb client: %v", err)
+	}
+
+	// The condition makes sure the dataset was not replaced or removed since it was read
+	datasetPath := fmt.Sprintf("%s[%d]", constants.DatasetsField, index)
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(os.Getenv(constants.ReportTable)),
+		Key: map[string]*dynamodb.AttributeValue{
+			constants.ReportIDField: {
+				S: aws.String(reportID),
+			},
+		},
+		UpdateExpression:    aws.String("set " + datasetPath + "." + constants.CSVColumnsS3KeyField + " = :v"),
+		ConditionExpression: aws.String(datasetPath + "." + constants.CSVIDField + " = :csvid"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":v":     {S: aws.String(s3Key)},
+			":csvid": {S: aws.String(csvid)},
+		},
+	}
+
+	_, err = dynamoDBClient.UpdateItem(input)
+	if err != nil {
+		return fmt.Errorf("error updating item: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package util
+
+import (
+	"api/shared/models"
+	"fmt"
+	"strings"
+)
+
+// derivedColumnSet computes the derived columns of a report for every row of
+// its csv. The values are appended after the csv columns, in the order the
+// columns were defined, so a derived column can use the ones before it.
+type derivedColumnSet struct {
+	expressions []exprNode
+	row         []string // Reused for every row, like the csv reader's record
+}
+
+// compileDerivedColumns parses the derived columns against the csv headers and
+// returns the headers with the derived column names appended
+func compileDerivedColumns(headers []string, derivedColumns []models.DerivedColumn) ([]string, *derivedColumnSet, error) {
+	if len(derivedColumns) == 0 {
+		return headers, nil, nil
+	}
+
+	extendedHeaders := append([]string(nil), headers...)
+	set := &derivedColumnSet{}
+	for _, derivedColumn := range derivedColumns {
+		if err := validateDerivedColumnName(extendedHeaders, derivedColumn.Name); err != nil {
+			return nil, nil, err
+		}
+		expression, err := parseExpression(derivedColumn.Expression, extendedHeaders)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error in derived column '%s': %v", derivedColumn.Name, err)
+		}
+		set.expressions = append(set.expressions, expression)
+		extendedHeaders = append(extendedHeaders, derivedColumn.Name)
+	}
+	return extendedHeaders, set, nil
+}
+
+// ValidateDerivedColumns checks the names and expression syntax of derived
+// columns without a csv, so their column references are not resolved
+func ValidateDerivedColumns(derivedColumns []models.DerivedColumn) error {
+	var names []string
+	for _, derivedColumn := range derivedColumns {
+		if err := validateDerivedColumnName(names, derivedColumn.Name); err != nil {
+			return err
+		}
+		if _, err := parseExpression(derivedColumn.Expression, nil); err != nil {
+			return fmt.Errorf("error in derived column '%s': %v", derivedColumn.Name, err)
+		}
+		names = append(names, derivedColumn.Name)
+	}
+	return nil
+}
+
+func validateDerivedColumnName(headers []string, name string) error {
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("derived column name cannot be empty")
+	}
+	if findColumnIndex(headers, name) != -1 {
+		return fmt.Errorf("derived column '%s' has the same name as another column", name)
+	}
+	return nil
+}
+
+// extend returns the record with the derived column values appended. A value
+// that cannot be computed for a row, such as a division by zero, is left blank.
+func (s *derivedColumnSet) extend(record []string) []string {
+	s.row = append(s.row[:0], record...)
+	for _, expression := range s.expressions {
+		value, err := expression.eval(s.row)
+		if err != nil {
+			s.row = append(s.row, "")
+			continue
+		}
+		s.row = append(s.row, exprText(value))
+	}
+	return s.row
+}
@@ -0,0 +1,905 @@
+package util
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Derived column expressions are evaluated on every row of a csv, so the
+// language is kept small: there are no loops, variables or regular expressions,
+// and the length and nesting of an expression are bounded. Columns are referenced
+// in brackets, e.g.
+//
+//	if([Travel Time] + [Turnout Time] > 300, "Late", "On time")
+//	year([Dispatched]) & " " & upper(trim([Station]))
+const (
+	maxExpressionLength = 2000
+	maxExpressionDepth  = 64
+	// Bytes a text value built by an expression can have, so nested replaces can not
+	// grow it on every row until the memory runs out
+	maxExpressionTextLength = 64 * 1024
+)
+
+type exprTokenKind int
+
+const (
+	exprEOF exprTokenKind = iota
+	exprNumber
+	exprString
+	exprColumn
+	exprIdent
+	exprOperator
+)
+
+type exprToken struct {
+	kind exprTokenKind
+	text string
+	pos  int
+}
+
+// Two character operators are matched before single character ones
+var exprOperators = []string{"==", "!=", "<>", "<=", ">=", "&&", "||", "+", "-", "*", "/", "%", "&", "(", ")", ",", "=", "<", ">", "!"}
+
+func tokenizeExpression(expression string) ([]exprToken, error) {
+	if len(expression) > maxExpressionLength {
+		return nil, fmt.Errorf("expression is longer than %d characters", maxExpressionLength)
+	}
+
+	var tokens []exprToken
+	runes := []rune(expression)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsDigit(r) || (r == '.' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, exprToken{exprNumber, string(runes[start:i]), start})
+		case r == '"' || r == '\'' || r == '[':
+			closing := r
+			kind := exprString
+			if r == '[' {
+				closing = ']'
+				kind = exprColumn
+			}
+			start := i
+			var text strings.Builder
+			i++
+			closed := false
+			for i < len(runes) {
+				if runes[i] == closing {
+					// A doubled closing character is a literal one, as in "say ""hi"""
+					if i+1 < len(runes) && runes[i+1] == closing {
+						text.WriteRune(closing)
+						i += 2
+						continue
+					}
+					closed = true
+					i++
+					break
+				}
+				text.WriteRune(runes[i])
+				i++
+			}
+			if !closed {
+				return nil, fmt.Errorf("missing closing %q for the value at position %d", closing, start)
+			}
+			if kind == exprColumn && text.Len() == 0 {
+				return nil, fmt.Errorf("empty column name at position %d", start)
+			}
+			tokens = append(tokens, exprToken{kind, text.String(), start})
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, exprToken{exprIdent, string(runes[start:i]), start})
+		default:
+			matched := false
+			for _, operator := range exprOperators {
+				if strings.HasPrefix(string(runes[i:min(i+2, len(runes))]), operator) {
+					tokens = append(tokens, exprToken{exprOperator, operator, i})
+					i += len(operator)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unexpected character %q at position %d", r, i)
+			}
+		}
+	}
+	return append(tokens, exprToken{exprEOF, "", len(runes)}), nil
+}
+
+// exprNode is a node of a parsed expression, evaluated against a row of the csv.
+// Values are nil, float64, string or bool.
+type exprNode interface {
+	eval(row []string) (interface{}, error)
+}
+
+type exprParser struct {
+	tokens  []exprToken
+	pos     int
+	depth   int
+	columns map[string]int // nil when the columns are only checked for syntax
+}
+
+// parseExpression parses an expression, resolving its column references to
+// their index in the headers. With nil headers only the syntax is checked.
+func parseExpression(expression string, headers []string) (exprNode, error) {
+	tokens, err := tokenizeExpression(expression)
+	if err != nil {
+		return nil, err
+	}
+	parser := &exprParser{tokens: tokens}
+	if headers != nil {
+		parser.columns = make(map[string]int, len(headers))
+		for i, header := range headers {
+			if _, exists := parser.columns[header]; !exists {
+				parser.columns[header] = i
+			}
+		}
+	}
+
+	node, err := parser.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if token := parser.peek(); token.kind != exprEOF {
+		return nil, fmt.Errorf("unexpected %q at position %d", token.text, token.pos)
+	}
+	return node, nil
+}
+
+func (p *exprParser) peek() exprToken {
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) next() exprToken {
+	token := p.tokens[p.pos]
+	if token.kind != exprEOF {
+		p.pos++
+	}
+	return token
+}
+
+// acceptOperator consumes the next token if it is one of the operators or keywords
+func (p *exprParser) acceptOperator(operators ...string) (string, bool) {
+	token := p.peek()
+	if token.kind != exprOperator && token.kind != exprIdent {
+		return "", false
+	}
+	for _, operator := range operators {
+		if token.text == operator || (token.kind == exprIdent && strings.EqualFold(token.text, operator)) {
+			p.pos++
+			return operator, true
+		}
+	}
+	return "", false
+}
+
+func (p *exprParser) expect(operator string) error {
+	token := p.next()
+	if token.kind != exprOperator || token.text != operator {
+		if token.kind == exprEOF {
+			return fmt.Errorf("expected %q at the end of the expression", operator)
+		}
+		return fmt.Errorf("expected %q at position %d, found %q", operator, token.pos, token.text)
+	}
+	return nil
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxExpressionDepth {
+		return nil, fmt.Errorf("expression is nested more than %d levels deep", maxExpressionDepth)
+	}
+
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.acceptOperator("||", "or"); !ok {
+			return left, nil
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: "or", left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.acceptOperator("&&", "and"); !ok {
+			return left, nil
+		}
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: "and", left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseNot() (exprNode, error) {
+	if _, ok := p.acceptOperator("!", "not"); ok {
+		p.depth++
+		defer func() { p.depth-- }()
+		if p.depth > maxExpressionDepth {
+			return nil, fmt.Errorf("expression is nested more than %d levels deep", maxExpressionDepth)
+		}
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{op: "not", operand: operand}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *exprParser) parseComparison() (exprNode, error) {
+	left, err := p.parseConcat()
+	if err != nil {
+		return nil, err
+	}
+	operator, ok := p.acceptOperator("==", "!=", "<>", "<=", ">=", "=", "<", ">")
+	if !ok {
+		return left, nil
+	}
+	right, err := p.parseConcat()
+	if err != nil {
+		return nil, err
+	}
+	return &binaryNode{op: operator, left: left, right: right}, nil
+}
+
+func (p *exprParser) parseConcat() (exprNode, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.acceptOperator("&"); !ok {
+			return left, nil
+		}
+		right, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: "&", left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseAdditive() (exprNode, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		operator, ok := p.acceptOperator("+", "-")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: operator, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseTerm() (exprNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		operator, ok := p.acceptOperator("*", "/", "%")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: operator, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if _, ok := p.acceptOperator("-"); ok {
+		p.depth++
+		defer func() { p.depth-- }()
+		if p.depth > maxExpressionDepth {
+			return nil, fmt.Errorf("expression is nested more than %d levels deep", maxExpressionDepth)
+		}
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{op: "-", operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	token := p.next()
+	switch token.kind {
+	case exprNumber:
+		num, err := strconv.ParseFloat(token.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at position %d", token.text, token.pos)
+		}
+		return &literalNode{value: num}, nil
+	case exprString:
+		return &literalNode{value: token.text}, nil
+	case exprColumn:
+		if p.columns == nil {
+			return &columnNode{name: token.text, index: -1}, nil
+		}
+		index, ok := p.columns[token.text]
+		if !ok {
+			return nil, fmt.Errorf("column '%s' not found in the CSV file", token.text)
+		}
+		return &columnNode{name: token.text, index: index}, nil
+	case exprIdent:
+		switch strings.ToLower(token.text) {
+		case "true":
+			return &literalNode{value: true}, nil
+		case "false":
+			return &literalNode{value: false}, nil
+		}
+		return p.parseCall(token)
+	case exprOperator:
+		if token.text == "(" {
+			node, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return node, nil
+		}
+		return nil, fmt.Errorf("unexpected %q at position %d", token.text, token.pos)
+	}
+	return nil, fmt.Errorf("unexpected end of the expression")
+}
+
+func (p *exprParser) parseCall(name exprToken) (exprNode, error) {
+	function, ok := exprFunctions[strings.ToLower(name.text)]
+	if !ok {
+		return nil, fmt.Errorf("unknown function '%s' at position %d. Column names go in brackets, like [%s]", name.text, name.pos, name.text)
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+
+	var args []exprNode
+	if _, ok := p.acceptOperator(")"); !ok {
+		for {
+			arg, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if _, ok := p.acceptOperator(","); !ok {
+				break
+			}
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(args) < function.minArgs || (function.maxArgs >= 0 && len(args) > function.maxArgs) {
+		return nil, fmt.Errorf("wrong number of arguments for %s: got %d", strings.ToLower(name.text), len(args))
+	}
+	// Replacing empty text would insert the replacement between every character
+	if strings.EqualFold(name.text, "replace") {
+		if search, ok := args[1].(*literalNode); ok && search.value == "" {
+			return nil, fmt.Errorf("text to replace cannot be empty at position %d", name.pos)
+		}
+	}
+	return &callNode{name: strings.ToLower(name.text), function: function, args: args}, nil
+}
+
+type literalNode struct {
+	value interface{}
+}
+
+func (n *literalNode) eval(row []string) (interface{}, error) {
+	return n.value, nil
+}
+
+type columnNode struct {
+	name  string
+	index int
+}
+
+func (n *columnNode) eval(row []string) (interface{}, error) {
+	if n.index < 0 || n.index >= len(row) {
+		return nil, fmt.Errorf("column '%s' not found in the CSV file", n.name)
+	}
+	return row[n.index], nil
+}
+
+type unaryNode struct {
+	op      string
+	operand exprNode
+}
+
+func (n *unaryNode) eval(row []string) (interface{}, error) {
+	value, err := n.operand.eval(row)
+	if err != nil {
+		return nil, err
+	}
+	if n.op == "not" {
+		return !exprTruthy(value), nil
+	}
+	num, err := exprNumberOf(value)
+	if err != nil {
+		return nil, err
+	}
+	return -num, nil
+}
+
+type binaryNode struct {
+	op          string
+	left, right exprNode
+}
+
+func (n *binaryNode) eval(row []string) (interface{}, error) {
+	left, err := n.left.eval(row)
+	if err != nil {
+		return nil, err
+	}
+	// Logical operators only evaluate their right side when needed
+	switch n.op {
+	case "and":
+		if !exprTruthy(left) {
+			return false, nil
+		}
+	case "or":
+		if exprTruthy(left) {
+			return true, nil
+		}
+	}
+	right, err := n.right.eval(row)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "and", "or":
+		return exprTruthy(right), nil
+	case "&":
+		return exprTextResult(exprText(left) + exprText(right))
+	case "=", "==", "!=", "<>", "<", "<=", ">", ">=":
+		return exprCompare(n.op, left, right), nil
+	}
+
+	l, err := exprNumberOf(left)
+	if err != nil {
+		return nil, err
+	}
+	r, err := exprNumberOf(right)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "+":
+		return l + r, nil
+	case "-":
+		return l - r, nil
+	case "*":
+		return l * r, nil
+	case "/":
+		if r == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return l / r, nil
+	case "%":
+		if r == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return math.Mod(l, r), nil
+	}
+	return nil, fmt.Errorf("unsupported operator %q", n.op)
+}
+
+type callNode struct {
+	name     string
+	function exprFunction
+	args     []exprNode
+}
+
+func (n *callNode) eval(row []string) (interface{}, error) {
+	value, err := n.function.call(row, n.args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", n.name, err)
+	}
+	return value, nil
+}
+
+// exprFunction is a function callable from an expression. Its arguments are
+// passed unevaluated so that conditionals only evaluate the branch they return.
+type exprFunction struct {
+	minArgs int
+	maxArgs int // -1 for any number of arguments
+	call    func(row []string, args []exprNode) (interface{}, error)
+}
+
+// eagerFunction builds a function that receives its arguments already evaluated
+func eagerFunction(minArgs, maxArgs int, call func(values []interface{}) (interface{}, error)) exprFunction {
+	return exprFunction{
+		minArgs: minArgs,
+		maxArgs: maxArgs,
+		call: func(row []string, args []exprNode) (interface{}, error) {
+			values := make([]interface{}, len(args))
+			for i, arg := range args {
+				value, err := arg.eval(row)
+				if err != nil {
+					return nil, err
+				}
+				values[i] = value
+			}
+			return call(values)
+		},
+	}
+}
+
+func textFunction(transform func(string) string) exprFunction {
+	return eagerFunction(1, 1, func(values []interface{}) (interface{}, error) {
+		return transform(exprText(values[0])), nil
+	})
+}
+
+func numberFunction(transform func(float64) float64) exprFunction {
+	return eagerFunction(1, 1, func(values []interface{}) (interface{}, error) {
+		num, err := exprNumberOf(values[0])
+		if err != nil {
+			return nil, err
+		}
+		return transform(num), nil
+	})
+}
+
+// dateFunction reads the first argument as a date, with an optional format as the last argument
+func dateFunction(part func(time.Time) interface{}) exprFunction {
+	return eagerFunction(1, 2, func(values []interface{}) (interface{}, error) {
+		parsed, err := exprTimeOf(values[0], values[1:])
+		if err != nil {
+			return nil, err
+		}
+		return part(parsed), nil
+	})
+}
+
+var exprFunctions = map[string]exprFunction{
+	"if": {minArgs: 2, maxArgs: 3, call: func(row []string, args []exprNode) (interface{}, error) {
+		condition, err := args[0].eval(row)
+		if err != nil {
+			return nil, err
+		}
+		if exprTruthy(condition) {
+			return args[1].eval(row)
+		}
+		if len(args) == 3 {
+			return args[2].eval(row)
+		}
+		return nil, nil
+	}},
+	// case(condition1, value1, condition2, value2, ..., default)
+	"case": {minArgs: 2, maxArgs: -1, call: func(row []string, args []exprNode) (interface{}, error) {
+		for i := 0; i+1 < len(args); i += 2 {
+			condition, err := args[i].eval(row)
+			if err != nil {
+				return nil, err
+			}
+			if exprTruthy(condition) {
+				return args[i+1].eval(row)
+			}
+		}
+		if len(args)%2 == 1 {
+			return args[len(args)-1].eval(row)
+		}
+		return nil, nil
+	}},
+	"coalesce": {minArgs: 1, maxArgs: -1, call: func(row []string, args []exprNode) (interface{}, error) {
+		for _, arg := range args {
+			value, err := arg.eval(row)
+			if err != nil {
+				return nil, err
+			}
+			if strings.TrimSpace(exprText(value)) != "" {
+				return value, nil
+			}
+		}
+		return nil, nil
+	}},
+	"isblank": eagerFunction(1, 1, func(values []interface{}) (interface{}, error) {
+		return strings.TrimSpace(exprText(values[0])) == "", nil
+	}),
+
+	"upper": textFunction(strings.ToUpper),
+	"lower": textFunction(strings.ToLower),
+	"trim":  textFunction(strings.TrimSpace),
+	"len": eagerFunction(1, 1, func(values []interface{}) (interface{}, error) {
+		return float64(len([]rune(exprText(values[0])))), nil
+	}),
+	"left": eagerFunction(2, 2, func(values []interface{}) (interface{}, error) {
+		text := []rune(exprText(values[0]))
+		count, err := exprCountOf(values[1], len(text))
+		if err != nil {
+			return nil, err
+		}
+		return string(text[:count]), nil
+	}),
+	"right": eagerFunction(2, 2, func(values []interface{}) (interface{}, error) {
+		text := []rune(exprText(values[0]))
+		count, err := exprCountOf(values[1], len(text))
+		if err != nil {
+			return nil, err
+		}
+		return string(text[len(text)-count:]), nil
+	}),
+	// mid(text, start, length) with a start of 1 for the first character
+	"mid": eagerFunction(2, 3, func(values []interface{}) (interface{}, error) {
+		text := []rune(exprText(values[0]))
+		start, err := exprCountOf(values[1], len(text)+1)
+		if err != nil {
+			return nil, err
+		}
+		if start > 0 {
+			start--
+		}
+		length := len(text) - start
+		if len(values) == 3 {
+			length, err = exprCountOf(values[2], length)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return string(text[start : start+length]), nil
+	}),
+	"replace": eagerFunction(3, 3, func(values []interface{}) (interface{}, error) {
+		text, search, replacement := exprText(values[0]), exprText(values[1]), exprText(values[2])
+		if search == "" {
+			return nil, fmt.Errorf("text to replace cannot be empty")
+		}
+		// Checked before replacing so a long result is never built
+		if len(replacement) > len(search) && len(text)+strings.Count(text, search)*(len(replacement)-len(search)) > maxExpressionTextLength {
+			return nil, errExpressionTextTooLong
+		}
+		return strings.ReplaceAll(text, search, replacement), nil
+	}),
+	"contains": eagerFunction(2, 2, func(values []interface{}) (interface{}, error) {
+		return strings.Contains(exprText(values[0]), exprText(values[1])), nil
+	}),
+	"startswith": eagerFunction(2, 2, func(values []interface{}) (interface{}, error) {
+		return strings.HasPrefix(exprText(values[0]), exprText(values[1])), nil
+	}),
+	"endswith": eagerFunction(2, 2, func(values []interface{}) (interface{}, error) {
+		return strings.HasSuffix(exprText(values[0]), exprText(values[1])), nil
+	}),
+	"concat": eagerFunction(1, -1, func(values []interface{}) (interface{}, error) {
+		var builder strings.Builder
+		for _, value := range values {
+			builder.WriteString(exprText(value))
+			if builder.Len() > maxExpressionTextLength {
+				return nil, errExpressionTextTooLong
+			}
+		}
+		return builder.String(), nil
+	}),
+
+	// number returns a blank value instead of an error when the value is not a number
+	"number": eagerFunction(1, 1, func(values []interface{}) (interface{}, error) {
+		num, err := exprNumberOf(values[0])
+		if err != nil {
+			return nil, nil
+		}
+		return num, nil
+	}),
+	"round": eagerFunction(1, 2, func(values []interface{}) (interface{}, error) {
+		num, err := exprNumberOf(values[0])
+		if err != nil {
+			return nil, err
+		}
+		digits := 0.0
+		if len(values) == 2 {
+			if digits, err = exprNumberOf(values[1]); err != nil {
+				return nil, err
+			}
+		}
+		scale := math.Pow(10, math.Round(digits))
+		return math.Round(num*scale) / scale, nil
+	}),
+	"floor": numberFunction(math.Floor),
+	"ceil":  numberFunction(math.Ceil),
+	"abs":   numberFunction(math.Abs),
+	"min": eagerFunction(1, -1, func(values []interface{}) (interface{}, error) {
+		return exprReduceNumbers(values, math.Min)
+	}),
+	"max": eagerFunction(1, -1, func(values []interface{}) (interface{}, error) {
+		return exprReduceNumbers(values, math.Max)
+	}),
+
+	"year":  dateFunction(func(t time.Time) interface{} { return float64(t.Year()) }),
+	"month": dateFunction(func(t time.Time) interface{} { return float64(t.Month()) }),
+	"day":   dateFunction(func(t time.Time) interface{} { return float64(t.Day()) }),
+	"hour":  dateFunction(func(t time.Time) interface{} { return float64(t.Hour()) }),
+	"weekday": dateFunction(func(t time.Time) interface{} {
+		return t.Weekday().String()
+	}),
+	// formatdate(date, outputFormat, inputFormat) with an optional input format
+	"formatdate": eagerFunction(2, 3, func(values []interface{}) (interface{}, error) {
+		parsed, err := exprTimeOf(values[0], values[2:])
+		if err != nil {
+			return nil, err
+		}
+		format := exprText(values[1])
+		if format == "" {
+			return nil, fmt.Errorf("output format cannot be empty")
+		}
+		return parsed.Format(dateLayouts(format)[0]), nil
+	}),
+	// datediff(start, end, unit, inputFormat) where the unit is seconds, minutes, hours or days
+	"datediff": eagerFunction(3, 4, func(values []interface{}) (interface{}, error) {
+		start, err := exprTimeOf(values[0], values[3:])
+		if err != nil {
+			return nil, err
+		}
+		end, err := exprTimeOf(values[1], values[3:])
+		if err != nil {
+			return nil, err
+		}
+		elapsed := end.Sub(start)
+		switch strings.ToLower(exprText(values[2])) {
+		case "seconds":
+			return elapsed.Seconds(), nil
+		case "minutes":
+			return elapsed.Minutes(), nil
+		case "hours":
+			return elapsed.Hours(), nil
+		case "days":
+			return elapsed.Hours() / 24, nil
+		}
+		return nil, fmt.Errorf("unsupported unit '%s'", exprText(values[2]))
+	}),
+}
+
+var errExpressionTextTooLong = fmt.Errorf("text is longer than %d bytes", maxExpressionTextLength)
+
+// exprTextResult returns text built by an expression, or an error when it is too long
+func exprTextResult(text string) (interface{}, error) {
+	if len(text) > maxExpressionTextLength {
+		return nil, errExpressionTextTooLong
+	}
+	return text, nil
+}
+
+func exprText(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		// 15 significant digits drops floating point noise such as 0.30000000000000004
+		return strconv.FormatFloat(v, 'g', 15, 64)
+	}
+	return fmt.Sprint(value)
+}
+
+func exprNumberOf(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case string:
+		if num, ok := parseNumericalValue(v, "."); ok {
+			return num, nil
+		}
+	}
+	return 0, fmt.Errorf("'%s' is not a number", exprText(value))
+}
+
+// exprCountOf reads a character count, clamped between 0 and limit
+func exprCountOf(value interface{}, limit int) (int, error) {
+	num, err := exprNumberOf(value)
+	if err != nil {
+		return 0, err
+	}
+	return int(math.Max(0, math.Min(float64(limit), math.Floor(num)))), nil
+}
+
+func exprTimeOf(value interface{}, format []interface{}) (time.Time, error) {
+	layouts := commonDateLayouts
+	if len(format) > 0 {
+		layouts = dateLayouts(exprText(format[0]))
+	}
+	parsed, ok := parseTimeValue(exprText(value), layouts, time.UTC)
+	if !ok {
+		return time.Time{}, fmt.Errorf("'%s' is not a date", exprText(value))
+	}
+	return parsed, nil
+}
+
+func exprReduceNumbers(values []interface{}, reduce func(float64, float64) float64) (interface{}, error) {
+	result, err := exprNumberOf(values[0])
+	if err != nil {
+		return nil, err
+	}
+	for _, value := range values[1:] {
+		num, err := exprNumberOf(value)
+		if err != nil {
+			return nil, err
+		}
+		result = reduce(result, num)
+	}
+	return result, nil
+}
+
+func exprTruthy(value interface{}) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case float64:
+		return v != 0
+	case string:
+		return strings.TrimSpace(v) != ""
+	}
+	return false
+}
+
+// exprCompare compares values as numbers when both are numbers, otherwise as text
+func exprCompare(operator string, left, right interface{}) bool {
+	result := 0
+	l, leftErr := exprNumberOf(left)
+	r, rightErr := exprNumberOf(right)
+	if leftErr == nil && rightErr == nil {
+		switch {
+		case l < r:
+			result = -1
+		case l > r:
+			result = 1
+		}
+	} else {
+		result = strings.Compare(exprText(left), exprText(right))
+	}
+
+	switch operator {
+	case "=", "==":
+		return result == 0
+	case "!=", "<>":
+		return result != 0
+	case "<":
+		return result < 0
+	case "<=":
+		return result <= 0
+	case ">":
+		return result > 0
+	case ">=":
+		return result >= 0
+	}
+	return false
+}
@@ -0,0 +1,201 @@
+package util
+
+import (
+	"api/shared/models"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// rowFilter is a filter resolved against the csv headers once, so that checking
+// a row does not have to search the headers or parse the filter values again.
+// Every group has to pass, and a group passes when any of its conditions do.
+type rowFilter []filterGroup
+
+type filterGroup []filterCondition
+
+type filterCondition struct {
+	columnIndex int // -1 when the column is not in the csv, which fails every row
+	matches     func(value string) bool
+}
+
+func compileFilters(headers []string, filter models.Filter) (rowFilter, error) {
+	var compiled rowFilter
+	for _, group := range filter {
+		var conditions filterGroup
+		for _, condition := range group.Conditions {
+			matches, err := compileFilterCondition(condition)
+			if err != nil {
+				return nil, fmt.Errorf("error in filter on column '%s': %v", condition.Column, err)
+			}
+			conditions = append(conditions, filterCondition{
+				columnIndex: findColumnIndex(headers, condition.Column),
+				matches:     matches,
+			})
+		}
+		if len(conditions) > 0 {
+			compiled = append(compiled, conditions)
+		}
+	}
+	return compiled, nil
+}
+
+func compileFilterCondition(condition models.FilterCondition) (func(string) bool, error) {
+	switch condition.Operator {
+	case models.InFilter, "", models.NotInFilter:
+		values := make(map[string]struct{}, len(condition.Values))
+		for _, value := range condition.Values {
+			values[value] = struct{}{}
+		}
+		negate := condition.Operator == models.NotInFilter
+		return func(value string) bool {
+			_, ok := values[value]
+			return ok != negate
+		}, nil
+
+	case models.RangeFilter:
+		if condition.Min == nil && condition.Max == nil {
+			return nil, fmt.Errorf("range needs a minimum or a maximum")
+		}
+		return func(value string) bool {
+			num, ok := parseNumericalValue(value, ".")
+			if !ok {
+				return false
+			}
+			return (condition.Min == nil || num >= *condition.Min) && (condition.Max == nil || num <= *condition.Max)
+		}, nil
+
+	case models.DateRangeFilter:
+		return compileDateRangeCondition(condition)
+
+	case models.ContainsFilter, models.PrefixFilter:
+		if len(condition.Values) == 0 {
+			return nil, fmt.Errorf("%s needs at least one value", condition.Operator)
+		}
+		match := strings.Contains
+		if condition.Operator == models.PrefixFilter {
+			match = strings.HasPrefix
+		}
+		values := make([]string, len(condition.Values))
+		for i, value := range condition.Values {
+			values[i] = strings.ToLower(value)
+		}
+		return func(value string) bool {
+			value = strings.ToLower(value)
+			for _, v := range values {
+				if match(value, v) {
+					return true
+				}
+			}
+			return false
+		}, nil
+
+	case models.RegexFilter:
+		if len(condition.Values) != 1 {
+			return nil, fmt.Errorf("regex needs exactly one pattern")
+		}
+		// Go regular expressions run in linear time, so a pattern cannot stall the analysis
+		pattern, err := regexp.Compile(condition.Values[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex: %v", err)
+		}
+		return pattern.MatchString, nil
+
+	case models.EmptyFilter:
+		return func(value string) bool { return strings.TrimSpace(value) == "" }, nil
+
+	case models.NotEmptyFilter:
+		return func(value string) bool { return strings.TrimSpace(value) != "" }, nil
+	}
+	return nil, fmt.Errorf("unsupported filter operator: %s", condition.Operator)
+}
+
+func compileDateRangeCondition(condition models.FilterCondition) (func(string) bool, error) {
+	if condition.From == "" && condition.To == "" {
+		return nil, fmt.Errorf("date range needs a start or an end")
+	}
+	location, err := loadTimeZone(condition.TimeZone)
+	if err != nil {
+		return nil, err
+	}
+	// The bounds may be written in the column's format or a common one like 2023-01-31
+	boundLayouts := append(append([]string(nil), dateLayouts(condition.DateFormat)...), commonDateLayouts...)
+
+	var from, to time.Time
+	if condition.From != "" {
+		parsed, ok := parseTimeValue(condition.From, boundLayouts, location)
+		if !ok {
+			return nil, fmt.Errorf("could not read the start date '%s'", condition.From)
+		}
+		from = parsed
+	}
+	if condition.To != "" {
+		parsed, ok := parseTimeValue(condition.To, boundLayouts, location)
+		if !ok {
+			return nil, fmt.Errorf("could not read the end date '%s'", condition.To)
+		}
+		// An end date without a time includes the whole day
+		if parsed.Hour() == 0 && parsed.Minute() == 0 && parsed.Second() == 0 && parsed.Nanosecond() == 0 {
+			parsed = parsed.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+		to = parsed
+	}
+
+	layouts := dateLayouts(condition.DateFormat)
+	return func(value string) bool {
+		parsed, ok := parseTimeValue(value, layouts, location)
+		if !ok {
+			return false
+		}
+		return (from.IsZero() || !parsed.Before(from)) && (to.IsZero() || !parsed.After(to))
+	}, nil
+}
+
+// compileOperationFilters combines the filter columns of a config with its
+// accepted values, which act as a filter on the operation column itself.
+func compileOperationFilters(headers []string, columnConfig *models.ReportOneDimConfig) (rowFilter, error) {
+	filter := columnConfig.FilterColumns
+	// Intervals have no operation column for the accepted values to apply to
+	if len(columnConfig.AcceptedValues) > 0 && !isIntervalOperation(columnConfig) {
+		// Build a new filter so the stored one is left untouched. As when filters
+		// were a map of columns, the accepted values replace a plain filter on
+		// the operation column.
+		filter = make(models.Filter, 0, len(columnConfig.FilterColumns)+1)
+		for _, group := range columnConfig.FilterColumns {
+			if isAcceptedValuesGroup(group, columnConfig.Column) {
+				continue
+			}
+			filter = append(filter, group)
+		}
+		filter = append(filter, models.AcceptedValuesFilter(columnConfig.Column, columnConfig.AcceptedValues)...)
+	}
+	return compileFilters(headers, filter)
+}
+
+// isAcceptedValuesGroup reports whether the group is a single In condition on the column
+func isAcceptedValuesGroup(group models.FilterGroup, column string) bool {
+	if len(group.Conditions) != 1 {
+		return false
+	}
+	condition := group.Conditions[0]
+	return condition.Column == column && (condition.Operator == models.InFilter || condition.Operator == "")
+}
+
+func (f rowFilter) passes(row []string) bool {
+	for _, group := range f {
+		if !group.passes(row) {
+			return false
+		}
+	}
+	return true
+}
+
+func (g filterGroup) passes(row []string) bool {
+	for _, condition := range g {
+		if condition.columnIndex != -1 && condition.matches(row[condition.columnIndex]) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package util
+
+import (
+	"api/shared/models"
+	"fmt"
+)
+
+const (
+	maxGenerationTemperature = 2
+	maxStopSequences         = 4
+)
+
+// ResolveGenerationOptions returns the options of an item with the options set on one of
+// its outputs applied over them
+func ResolveGenerationOptions(defaults *models.GenerationOptions, overrides *models.GenerationOptions) models.GenerationOptions {
+	var options models.GenerationOptions
+	if defaults != nil {
+		options = *defaults
+	}
+	if overrides == nil {
+		return options
+	}
+
+	if overrides.SystemPrompt != "" {
+		options.SystemPrompt = overrides.SystemPrompt
+	}
+	if overrides.Temperature != nil {
+		options.Temperature = overrides.Temperature
+	}
+	if overrides.MaxTokens != 0 {
+		options.MaxTokens = overrides.MaxTokens
+	}
+	if overrides.StopSequences != nil {
+		options.StopSequences = overrides.StopSequences
+	}
+	return options
+}
+
+// ValidateGenerationOptions checks the options are accepted by the providers
+func ValidateGenerationOptions(options *models.GenerationOptions) error {
+	if options == nil {
+		return nil
+	}
+
+	if options.Temperature != nil && (*options.Temperature < 0 || *options.Temperature > maxGenerationTemperature) {
+		return fmt.Errorf("temperature must be between 0 and %d", maxGenerationTemperature)
+	}
+
+	if options.MaxTokens < 0 {
+		return fmt.Errorf("max tokens can not be negative")
+	}
+
+	if len(options.StopSequences) > maxStopSequences {
+		return fmt.Errorf("at most %d stop sequences can be given", maxStopSequences)
+	}
+	for _, sequence := range options.StopSequences {
+		if sequence == "" {
+			return fmt.Errorf("stop sequences can not be empty")
+		}
+	}
+
+	return nil
+}
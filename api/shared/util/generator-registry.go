@@ -0,0 +1,208 @@
+package util
+
+import (
+	"api/shared/constants"
+	"api/shared/interfaces"
+	"api/shared/models"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// GeneratorConfig holds the providers generator outputs can use
+type GeneratorConfig struct {
+	DefaultProvider models.GeneratorProvider // OpenAI when empty
+	Providers       map[models.GeneratorProvider]ProviderConfig
+	Retry           *RetryPolicy // DefaultRetryPolicy when nil
+}
+
+// ProviderConfig is how a provider is reached and the model used when none is named
+type ProviderConfig struct {
+	APIKey       string
+	BaseURL      string // Optional for OpenAI and Anthropic, the endpoint of Azure OpenAI
+	APIVersion   string // Optional, Azure OpenAI only
+	DefaultModel string // The deployment name for Azure OpenAI
+}
+
+// ProviderRegistry gives the generator of a provider and model. A client is made once for
+// each provider and shared by its generators, whose calls are retried with the policy of
+// the registry.
+type ProviderRegistry struct {
+	config     GeneratorConfig
+	httpClient *http.Client
+
+	mu      sync.Mutex
+	clients map[models.GeneratorProvider]*openai.Client
+}
+
+var (
+	providerRegistry     *ProviderRegistry
+	providerRegistryOnce sync.Once
+)
+
+// GetProviderRegistry returns a singleton registry of the providers configured in the environment
+func GetProviderRegistry() *ProviderRegistry {
+	providerRegistryOnce.Do(func() {
+		providerRegistry = NewProviderRegistry(generatorConfigFromEnv())
+	})
+	return providerRegistry
+}
+
+func NewProviderRegistry(config GeneratorConfig) *ProviderRegistry {
+	if config.Retry == nil {
+		config.Retry = &DefaultRetryPolicy
+	}
+
+	return &ProviderRegistry{
+		config: config,
+		httpClient: &http.Client{
+			Transport: responseRecordingTransport{base: http.DefaultTransport},
+		},
+		clients: make(map[models.GeneratorProvider]*openai.Client),
+	}
+}
+
+func generatorConfigFromEnv() GeneratorConfig {
+	config := GeneratorConfig{
+		DefaultProvider: models.GeneratorProvider(os.Getenv(constants.GeneratorProvider)),
+		Providers:       make(map[models.GeneratorProvider]ProviderConfig),
+	}
+
+	if key := os.Getenv(constants.OpenAIKey); key != "" {
+		model := os.Getenv(constants.OpenAIModel)
+		if model == "" {
+			model = openai.GPT3Dot5Turbo
+		}
+		config.Providers[models.OpenAIProvider] = ProviderConfig{
+			APIKey:       key,
+			BaseURL:      os.Getenv(constants.OpenAIBaseURL),
+			DefaultModel: model,
+		}
+	}
+
+	if key := os.Getenv(constants.AnthropicKey); key != "" {
+		config.Providers[models.AnthropicProvider] = ProviderConfig{
+			APIKey:       key,
+			BaseURL:      os.Getenv(constants.AnthropicBaseURL),
+			DefaultModel: os.Getenv(constants.AnthropicModel),
+		}
+	}
+
+	if key, endpoint := os.Getenv(constants.AzureOpenAIKey), os.Getenv(constants.AzureOpenAIEndpoint); key != "" && endpoint != "" {
+		config.Providers[models.AzureOpenAIProvider] = ProviderConfig{
+			APIKey:       key,
+			BaseURL:      endpoint,
+			APIVersion:   os.Getenv(constants.AzureOpenAIAPIVersion),
+			DefaultModel: os.Getenv(constants.AzureOpenAIDeployment),
+		}
+	}
+
+	if baseURL := os.Getenv(constants.OpenAICompatibleBaseURL); baseURL != "" {
+		config.Providers[models.OpenAICompatibleProvider] = ProviderConfig{
+			APIKey:       os.Getenv(constants.OpenAICompatibleKey),
+			BaseURL:      baseURL,
+			DefaultModel: os.Getenv(constants.OpenAICompatibleModel),
+		}
+	}
+
+	return config
+}
+
+// GeneratePromptResponse generates with the default provider and model
+func (r *ProviderRegistry) GeneratePromptResponse(ctx context.Context, request models.GenerationRequest) (models.GenerationResponse, error) {
+	generator, err := r.ModelGenerator("", "")
+	if err != nil {
+		return models.GenerationResponse{}, err
+	}
+	return generator.GeneratePromptResponse(ctx, request)
+}
+
+// ModelGenerator returns the generator of a model of a provider, using the default
+// provider when none is given and the model of the provider when no model is
+func (r *ProviderRegistry) ModelGenerator(provider models.GeneratorProvider, model string) (interfaces.Generator, error) {
+	provider, model, err := r.ResolveModel(provider, model)
+	if err != nil {
+		return nil, err
+	}
+	providerConfig := r.config.Providers[provider]
+
+	var generator interfaces.Generator
+	if provider == models.AnthropicProvider {
+		generator = AnthropicGenerator{
+			APIKey:     providerConfig.APIKey,
+			BaseURL:    providerConfig.BaseURL,
+			Model:      model,
+			HTTPClient: r.httpClient,
+		}
+	} else {
+		generator = OpenAiGenerator{Client: r.openAIClient(provider, providerConfig), Model: model}
+	}
+
+	return RetryingGenerator{Generator: generator, Policy: *r.config.Retry}, nil
+}
+
+// ResolveModel returns the configured provider and model used for the ones given
+func (r *ProviderRegistry) ResolveModel(provider models.GeneratorProvider, model string) (models.GeneratorProvider, string, error) {
+	if provider == "" {
+		provider = r.config.DefaultProvider
+	}
+	if provider == "" {
+		provider = models.OpenAIProvider
+	}
+
+	switch provider {
+	case models.OpenAIProvider, models.AnthropicProvider, models.AzureOpenAIProvider, models.OpenAICompatibleProvider:
+	default:
+		return "", "", fmt.Errorf("unknown generator provider '%s'", provider)
+	}
+
+	providerConfig, ok := r.config.Providers[provider]
+	if !ok {
+		return "", "", fmt.Errorf("generator provider '%s' is not configured", provider)
+	}
+
+	if model == "" {
+		model = providerConfig.DefaultModel
+	}
+	if model == "" {
+		return "", "", fmt.Errorf("no model given for generator provider '%s'", provider)
+	}
+
+	return provider, model, nil
+}
+
+// openAIClient returns the client of a provider with the OpenAI api, making it on first use
+func (r *ProviderRegistry) openAIClient(provider models.GeneratorProvider, providerConfig ProviderConfig) *openai.Client {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if client, ok := r.clients[provider]; ok {
+		return client
+	}
+
+	var clientConfig openai.ClientConfig
+	if provider == models.AzureOpenAIProvider {
+		clientConfig = openai.DefaultAzureConfig(providerConfig.APIKey, providerConfig.BaseURL)
+		if providerConfig.APIVersion != "" {
+			clientConfig.APIVersion = providerConfig.APIVersion
+		}
+		// Models are named by their deployment
+		clientConfig.AzureModelMapperFunc = func(model string) string {
+			return model
+		}
+	} else {
+		clientConfig = openai.DefaultConfig(providerConfig.APIKey)
+		if providerConfig.BaseURL != "" {
+			clientConfig.BaseURL = providerConfig.BaseURL
+		}
+	}
+	clientConfig.HTTPClient = r.httpClient
+
+	client := openai.NewClientWithConfig(clientConfig)
+	r.clients[provider] = client
+	return client
+}
@@ -0,0 +1,166 @@
+package util
+
+import (
+	"api/shared/interfaces"
+	"api/shared/models"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy is how long a generator call can take and how it is retried. Calls are
+// retried when they time out, can not reach the provider, or get a 429 or 5xx response.
+type RetryPolicy struct {
+	Timeout     time.Duration // Of each attempt
+	MaxAttempts int
+	BaseDelay   time.Duration // Doubled after each attempt, with jitter
+	MaxDelay    time.Duration // Longer Retry-After delays are not waited for
+}
+
+// DefaultRetryPolicy keeps the attempts of an output within the time of a section generation
+var DefaultRetryPolicy = RetryPolicy{
+	Timeout:     30 * time.Second,
+	MaxAttempts: 3,
+	BaseDelay:   time.Second,
+	MaxDelay:    20 * time.Second,
+}
+
+// RetryingGenerator makes the calls of a generator with the timeouts and retries of a policy
+type RetryingGenerator struct {
+	Generator interfaces.Generator
+	Policy    RetryPolicy
+}
+
+func (g RetryingGenerator) GeneratePromptResponse(ctx context.Context, request models.GenerationRequest) (models.GenerationResponse, error) {
+	var err error
+	for attempt := 1; ; attempt++ {
+		var response generatorResponse
+		var result models.GenerationResponse
+		result, err = g.attempt(ctx, request, &response)
+		if err == nil {
+			return result, nil
+		}
+
+		if ctx.Err() != nil || attempt >= g.Policy.MaxAttempts || !response.retryable() {
+			break
+		}
+
+		delay := backoffDelay(g.Policy, attempt)
+		if response.RetryAfter > g.Policy.MaxDelay {
+			break
+		}
+		if response.RetryAfter > delay {
+			delay = response.RetryAfter
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return models.GenerationResponse{}, ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return models.GenerationResponse{}, err
+}
+
+// attempt makes one call within the timeout of the policy, recording its response
+func (g RetryingGenerator) attempt(ctx context.Context, request models.GenerationRequest, response *generatorResponse) (models.GenerationResponse, error) {
+	attemptCtx := withGeneratorResponse(ctx, response)
+	if g.Policy.Timeout > 0 {
+		var cancel context.CancelFunc
+		attemptCtx, cancel = context.WithTimeout(attemptCtx, g.Policy.Timeout)
+		defer cancel()
+	}
+
+	result, err := g.Generator.GeneratePromptResponse(attemptCtx, request)
+	if err != nil && ctx.Err() == nil && errors.Is(attemptCtx.Err(), context.DeadlineExceeded) {
+		response.TimedOut = true
+		return models.GenerationResponse{}, fmt.Errorf("generator timed out after %v: %v", g.Policy.Timeout, err)
+	}
+	return result, err
+}
+
+// backoffDelay is a random delay up to the base delay doubled for each attempt made
+func backoffDelay(policy RetryPolicy, attempt int) time.Duration {
+	limit := policy.BaseDelay << (attempt - 1)
+	if limit > policy.MaxDelay || limit <= 0 {
+		limit = policy.MaxDelay
+	}
+	if limit <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(limit)))
+}
+
+// generatorResponse is what the transport of the generators saw of the last response to
+// a call, since the errors of the provider clients do not hold the status and headers
+type generatorResponse struct {
+	StatusCode  int
+	RetryAfter  time.Duration
+	Unreachable bool // The request failed before a response, e.g. a dropped connection
+	TimedOut    bool
+}
+
+type generatorResponseKey struct{}
+
+func withGeneratorResponse(ctx context.Context, response *generatorResponse) context.Context {
+	return context.WithValue(ctx, generatorResponseKey{}, response)
+}
+
+// retryable reports whether a call that failed with the response can succeed when it is
+// made again
+func (r generatorResponse) retryable() bool {
+	if r.TimedOut || r.Unreachable {
+		return true
+	}
+	return r.StatusCode == http.StatusTooManyRequests || r.StatusCode >= http.StatusInternalServerError
+}
+
+// responseRecordingTransport records the status and Retry-After header of responses on
+// the generatorResponse of the request context
+type responseRecordingTransport struct {
+	base http.RoundTripper
+}
+
+func (t responseRecordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+
+	response, ok := req.Context().Value(generatorResponseKey{}).(*generatorResponse)
+	if !ok {
+		return resp, err
+	}
+
+	if err != nil {
+		// Timeouts and cancellations are found from the context of the call
+		response.Unreachable = req.Context().Err() == nil
+		return resp, err
+	}
+
+	response.StatusCode = resp.StatusCode
+	response.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	return resp, nil
+}
+
+// parseRetryAfter reads a Retry-After header, given in seconds or as a date
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
@@ -0,0 +1,210 @@
+package util
+
+import (
+	"api/shared/constants"
+	"api/shared/interfaces"
+	"api/shared/models"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// UsageConfig is how the usage of generator requests is limited and priced
+type UsageConfig struct {
+	Quotas models.UsageQuotas
+	Prices map[string]models.TokenPrice // By model
+}
+
+// usageConfigFromEnv reads the quotas and prices of the deployment from its environment
+func usageConfigFromEnv() UsageConfig {
+	config := UsageConfig{
+		Quotas: models.UsageQuotas{
+			UserMonthlyTokens:         envInt(constants.UserMonthlyTokenQuota),
+			OrganizationMonthlyTokens: envInt(constants.OrganizationMonthlyTokenQuota),
+		},
+	}
+
+	if prices := os.Getenv(constants.GeneratorTokenPrices); prices != "" {
+		err := json.Unmarshal([]byte(prices), &config.Prices)
+		if err != nil {
+			log.Printf("error reading generator token prices: %v", err)
+		}
+	}
+
+	return config
+}
+
+func envInt(name string) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || value < 0 {
+		return 0
+	}
+	return value
+}
+
+// MeteredRegistry is a generator registry recording the usage of every request it makes
+// in a ledger, for the user, report and section of its usage context. Requests are not
+// made once the user or their organization has used their monthly token quota.
+type MeteredRegistry struct {
+	Registry interfaces.GeneratorRegistry
+	Ledger   interfaces.UsageLedger
+	Config   UsageConfig
+	Usage    models.UsageContext
+}
+
+// NewMeteredRegistry meters the providers of the deployment for a usage context, with the
+// usage table as its ledger
+func NewMeteredRegistry(usage models.UsageContext) *MeteredRegistry {
+	return &MeteredRegistry{
+		Registry: GetProviderRegistry(),
+		Ledger:   DynamoDBUsageLedger{},
+		Config:   usageConfigFromEnv(),
+		Usage:    usage,
+	}
+}
+
+// GeneratePromptResponse generates with the default provider and model
+func (r *MeteredRegistry) GeneratePromptResponse(ctx context.Context, request models.GenerationRequest) (models.GenerationResponse, error) {
+	generator, err := r.ModelGenerator("", "")
+	if err != nil {
+		return models.GenerationResponse{}, err
+	}
+	return generator.GeneratePromptResponse(ctx, request)
+}
+
+// ModelGenerator returns the metered generator of a model of a provider
+func (r *MeteredRegistry) ModelGenerator(provider models.GeneratorProvider, model string) (interfaces.Generator, error) {
+	provider, model, err := r.Registry.ResolveModel(provider, model)
+	if err != nil {
+		return nil, err
+	}
+
+	generator, err := r.Registry.ModelGenerator(provider, model)
+	if err != nil {
+		return nil, err
+	}
+
+	return meteredGenerator{registry: r, generator: generator, provider: provider, model: model}, nil
+}
+
+func (r *MeteredRegistry) ResolveModel(provider models.GeneratorProvider, model string) (models.GeneratorProvider, string, error) {
+	return r.Registry.ResolveModel(provider, model)
+}
+
+// CheckQuota returns an error when the user or their organization has used the tokens of
+// their quota this month. Requests already started are still recorded, so a quota can be
+// passed by the requests of the last section generated within it.
+func (r *MeteredRegistry) CheckQuota(ctx context.Context) error {
+	month := usageMonth(time.Now())
+
+	statuses, err := getQuotaStatuses(r.Ledger, r.Config.Quotas, r.Usage.UserID, r.Usage.OrganizationID, month)
+	if err != nil {
+		return fmt.Errorf("error checking usage quota: %v", err)
+	}
+
+	for _, status := range statuses {
+		if status.MonthlyTokens > 0 && status.UsedTokens >= status.MonthlyTokens {
+			return fmt.Errorf("monthly token quota of the %s is used: %d of %d tokens in %s",
+				status.Scope, status.UsedTokens, status.MonthlyTokens, month)
+		}
+	}
+	return nil
+}
+
+// getQuotaStatuses returns how much of the quotas of a user and their organization are
+// used in a month
+func getQuotaStatuses(ledger interfaces.UsageLedger, quotas models.UsageQuotas, userID, organizationID, month string) ([]models.QuotaStatus, error) {
+	userTotals, err := ledger.MonthlyUsage(models.UserUsage, userID, month)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := []models.QuotaStatus{{
+		Scope:         models.UserUsage,
+		ID:            userID,
+		Month:         month,
+		UsedTokens:    userTotals.TotalTokens,
+		MonthlyTokens: quotas.UserMonthlyTokens,
+	}}
+
+	if organizationID != "" {
+		organizationTotals, err := ledger.MonthlyUsage(models.OrganizationUsage, organizationID, month)
+		if err != nil {
+			return nil, err
+		}
+
+		statuses = append(statuses, models.QuotaStatus{
+			Scope:         models.OrganizationUsage,
+			ID:            organizationID,
+			Month:         month,
+			UsedTokens:    organizationTotals.TotalTokens,
+			MonthlyTokens: quotas.OrganizationMonthlyTokens,
+		})
+	}
+
+	return statuses, nil
+}
+
+// meteredGenerator records the usage of the requests of the generator of a model
+type meteredGenerator struct {
+	registry  *MeteredRegistry
+	generator interfaces.Generator
+	provider  models.GeneratorProvider
+	model     string
+}
+
+// GeneratePromptResponse makes the request and records its usage, including when it
+// fails. Failing to record the usage does not fail the request.
+func (g meteredGenerator) GeneratePromptResponse(ctx context.Context, request models.GenerationRequest) (models.GenerationResponse, error) {
+	start := time.Now()
+	response, err := g.generator.GeneratePromptResponse(ctx, request)
+	latency := time.Since(start)
+
+	usage := g.registry.Usage
+	record := models.UsageRecord{
+		UserID:           usage.UserID,
+		OrganizationID:   usage.OrganizationID,
+		ReportID:         usage.ReportID,
+		PartIndex:        usage.PartIndex,
+		SectionIndex:     usage.SectionIndex,
+		SectionTitle:     usage.SectionTitle,
+		Provider:         g.provider,
+		Model:            g.model,
+		PromptTokens:     response.Usage.PromptTokens,
+		CompletionTokens: response.Usage.CompletionTokens,
+		LatencyMs:        latency.Milliseconds(),
+		CostUSD:          usageCost(g.registry.Config.Prices, g.model, response.Usage),
+		Month:            usageMonth(start),
+		CreatedAt:        start.Unix(),
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+
+	recordErr := g.registry.Ledger.RecordUsage(record)
+	if recordErr != nil {
+		log.Printf("error recording generator usage: %v", recordErr)
+	}
+
+	return response, err
+}
+
+// usageCost is the price of the tokens of a request to a model, 0 when it has no price
+func usageCost(prices map[string]models.TokenPrice, model string, usage models.TokenUsage) float64 {
+	price, ok := prices[model]
+	if !ok {
+		return 0
+	}
+	return (float64(usage.PromptTokens)*price.Prompt + float64(usage.CompletionTokens)*price.Completion) / 1e6
+}
+
+// usageMonth is the month usage at a time is totalled in, e.g. 2024-05
+func usageMonth(t time.Time) string {
+	return t.UTC().Format(usageMonthLayout)
+}
+
+const usageMonthLayout = "2006-01"
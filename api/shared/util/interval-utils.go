@@ -0,0 +1,148 @@
+package util
+
+import (
+	"api/shared/models"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// operationValue reads the value an operation aggregates from a row. This is
+// either the operation column itself or, when the config names a start and an
+// end column, the time elapsed between the two.
+type operationValue struct {
+	columnIndex   int
+	interval      *intervalReader
+	missingColumn string // Set when a column is not in the csv
+}
+
+// intervalReader computes the time between a start and an end column in the
+// unit of the operation
+type intervalReader struct {
+	startIndex int
+	endIndex   int
+	layouts    []string
+	location   *time.Location
+	unit       float64 // Seconds per unit
+}
+
+func isIntervalOperation(columnConfig *models.ReportOneDimConfig) bool {
+	return columnConfig.StartColumn != "" || columnConfig.EndColumn != ""
+}
+
+// rowValue is the value of a row for an operation. Intervals are numbers
+// already and are not parsed again from their text, which would read it with
+// the decimal separator of the column.
+type rowValue struct {
+	text       string
+	interval   float64
+	isInterval bool
+}
+
+// number returns the value as a number, applying the invalid value policy of
+// the config to values that are not one
+func (r rowValue) number(columnConfig *models.ReportOneDimConfig) (float64, bool, error) {
+	if r.isInterval {
+		return r.interval, true, nil
+	}
+	return numericalValue(r.text, columnConfig)
+}
+
+// chartIntervalUnit is the unit a chart plots an interval in. Chart results
+// must stay numerical, so HH:MM:SS intervals are plotted in minutes.
+func chartIntervalUnit(unit models.IntervalUnit) models.IntervalUnit {
+	if unit == models.HHMMSSInterval {
+		return models.MinutesInterval
+	}
+	return unit
+}
+
+// newOperationValue resolves the columns of the config against the headers.
+// Intervals are read in the IntervalUnit of the config, HH:MM:SS being seconds
+// that are formatted once the result is known.
+func newOperationValue(headers []string, columnConfig *models.ReportOneDimConfig) (operationValue, error) {
+	if !isIntervalOperation(columnConfig) {
+		value := operationValue{columnIndex: findColumnIndex(headers, columnConfig.Column)}
+		if value.columnIndex == -1 {
+			value.missingColumn = columnConfig.Column
+		}
+		return value, nil
+	}
+
+	if columnConfig.StartColumn == "" || columnConfig.EndColumn == "" {
+		return operationValue{}, fmt.Errorf("interval for '%s' needs both a start and an end column", columnConfig.AggregateValueLabel)
+	}
+
+	var unit float64
+	switch columnConfig.IntervalUnit {
+	case models.SecondsInterval, models.HHMMSSInterval, "":
+		unit = 1
+	case models.MinutesInterval:
+		unit = 60
+	default:
+		return operationValue{}, fmt.Errorf("unsupported interval unit: %s", columnConfig.IntervalUnit)
+	}
+
+	location, err := loadTimeZone(columnConfig.TimeZone)
+	if err != nil {
+		return operationValue{}, err
+	}
+
+	reader := &intervalReader{
+		startIndex: findColumnIndex(headers, columnConfig.StartColumn),
+		endIndex:   findColumnIndex(headers, columnConfig.EndColumn),
+		layouts:    dateLayouts(columnConfig.DateFormat),
+		location:   location,
+		unit:       unit,
+	}
+	value := operationValue{columnIndex: -1, interval: reader}
+	if reader.startIndex == -1 {
+		value.missingColumn = columnConfig.StartColumn
+	} else if reader.endIndex == -1 {
+		value.missingColumn = columnConfig.EndColumn
+	}
+	return value, nil
+}
+
+// read returns the value of the row. The returned bool is false when the row
+// has a missing or negative interval, which is dropped and counted as skipped.
+func (v operationValue) read(record []string, columnConfig *models.ReportOneDimConfig) (rowValue, bool) {
+	if v.interval == nil {
+		return rowValue{text: record[v.columnIndex]}, true
+	}
+	elapsed, ok := v.interval.elapsed(record)
+	if !ok {
+		columnConfig.SkippedRows++
+		return rowValue{}, false
+	}
+	return rowValue{text: strconv.FormatFloat(elapsed, 'f', -1, 64), interval: elapsed, isInterval: true}, true
+}
+
+func (r *intervalReader) elapsed(record []string) (float64, bool) {
+	start, ok := parseTimeValue(record[r.startIndex], r.layouts, r.location)
+	if !ok {
+		return 0, false
+	}
+	end, ok := parseTimeValue(record[r.endIndex], r.layouts, r.location)
+	if !ok {
+		return 0, false
+	}
+	seconds := end.Sub(start).Seconds()
+	if seconds < 0 {
+		return 0, false
+	}
+	return seconds / r.unit, true
+}
+
+// formatDuration formats a number of seconds as HH:MM:SS, rounded to the
+// nearest second. Hours are not wrapped, so a day and a half is 36:00:00.
+func formatDuration(seconds float64) string {
+	total := int64(math.Round(seconds))
+	sign := ""
+	if total < 0 {
+		sign = "-"
+		total = -total
+	}
+	return fmt.Sprintf("%s%02d:%02d:%02d", sign, total/3600, total%3600/60, total%60)
+}
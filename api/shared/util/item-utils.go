@@ -157,6 +157,65 @@ func UpdateItemGlobalQuestions(itemType constants.ItemType, itemID string, quest
 	return nil
 }
 
+// UpdateItemGenerationOptions updates the default generation options of the outputs of a report or template
+func UpdateItemGenerationOptions(itemType constants.ItemType, itemID string, options models.GenerationOptions, userID string) error {
+	isAuthorized, err := isUserAuthorizedForItem(itemType, itemID, userID)
+
+	if err != nil {
+		return fmt.Errorf("error getting authentication status for item: %v", err)
+	}
+
+	if !isAuthorized {
+		return fmt.Errorf("user is not authorized for item")
+	}
+
+	dynamoDBClient, err := GetDynamoDBClient(constants.USEast2)
+	if err != nil {
+		return fmt.Errorf("error getting dynamodb client: %v", err)
+	}
+
+	optionsAttrValue, err := dynamodbattribute.Marshal(options)
+	if err != nil {
+		return fmt.Errorf("failed to marshal generation options: %v", err)
+	}
+
+	var tableName string
+	var itemKey string
+
+	if itemType == constants.Report {
+		tableName = os.Getenv(constants.ReportTable)
+		itemKey = constants.ReportIDField
+	} else if itemType == constants.Template {
+		tableName = os.Getenv(constants.TemplateTable)
+		itemKey = constants.TemplateIDField
+	} else {
+		return fmt.Errorf("incorrect item type specified. must be either 'report' or 'template'")
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			itemKey: {
+				S: aws.String(itemID),
+			},
+		},
+		UpdateExpression: aws.String("set " + constants.GenerationOptionsField + " = :go, " + constants.LastModifiedAtField + " = :lm"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":go": optionsAttrValue,
+			":lm": {
+				N: aws.String(strconv.FormatInt(GetCurrentTime(), 10)),
+			},
+		},
+	}
+
+	_, err = dynamoDBClient.UpdateItem(input)
+	if err != nil {
+		return fmt.Errorf("failed to update item: %v", err)
+	}
+
+	return nil
+}
+
 func UpdateItemTitle(itemType constants.ItemType, itemID, newTitle string, userID string) error {
 
 	isAuthorized, err := isUserAuthorizedForItem(itemType, itemID, userID)
@@ -0,0 +1,292 @@
+package util
+
+import (
+	"api/shared/constants"
+	"api/shared/models"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/google/uuid"
+)
+
+// errJoinReplaced is returned when a join was changed or removed while its column values
+// map was built, so the map is left for the operation building the new join
+var errJoinReplaced = errors.New("join was replaced while its columns were built")
+
+// StartCSVJoinsBuild starts rebuilding the column values maps of the joins using a csv once
+// it is read by the csv trigger, since the joined rows change with it. The operation is
+// owned by the user who uploaded the csv.
+func StartCSVJoinsBuild(csvid string) error {
+	reportID, ok := reportIDFromDatasetKey(csvid)
+	if !ok {
+		dynamoDBClient, err := GetDynamoDBClient(constants.USEast2)
+		if err != nil {
+			return fmt.Errorf("error getting dynamodb client: %v", err)
+		}
+		reportID, err = queryPrimaryKeyByCSVID(dynamoDBClient, os.Getenv(constants.ReportTable), csvid)
+		if err != nil {
+			return fmt.Errorf("error querying primary key by CSVID: %v", err)
+		}
+		if reportID == "" {
+			return nil
+		}
+	}
+
+	upload, err := getOperation(csvid)
+	if err != nil {
+		return err
+	}
+	if upload == nil {
+		return fmt.Errorf("upload operation not found")
+	}
+
+	return startDatasetJoinsBuild(reportID, csvid, upload.UserID)
+}
+
+// startDatasetJoinsBuild marks the column values maps of the joins using the csv as being
+// rebuilt and starts the task rebuilding them. Nothing is started when no join uses it.
+func startDatasetJoinsBuild(reportID, csvid, userID string) error {
+	report, err := getReportJoinSources(reportID)
+	if err != nil {
+		return err
+	}
+
+	var datasets []string
+	if report.CSVID == csvid {
+		datasets = append(datasets, "")
+	}
+	for _, dataset := range report.Datasets {
+		if dataset.CSVID == csvid {
+			datasets = append(datasets, dataset.Name)
+		}
+	}
+
+	joins := make(map[int]string)
+	for i, join := range report.Joins {
+		usesCSV := false
+		for _, dataset := range datasets {
+			usesCSV = usesCSV || join.LeftDataset == dataset || join.RightDataset == dataset
+		}
+		if usesCSV {
+			joins[i] = join.Name
+		}
+	}
+	if len(joins) == 0 {
+		return nil
+	}
+
+	operationID := uuid.New().String()
+	err = CreateOperation(operationID, models.JoinColumnsOperation, reportID, userID)
+	if err != nil {
+		return fmt.Errorf("error creating operation: %v", err)
+	}
+
+	err = markJoinColumnsStale(reportID, joins, operationID)
+	if err != nil {
+		return failJoinColumnsBuild(operationID, err)
+	}
+
+	names := make([]string, 0, len(joins))
+	for _, name := range joins {
+		names = append(names, name)
+	}
+	return startJoinColumnsBuild(operationID, reportID, names, userID)
+}
+
+// startJoinColumnsBuild starts the task building the column values maps of the joins in the
+// background. The operation must exist and be named by the joins.
+func startJoinColumnsBuild(operationID, reportID string, joins []string, userID string) error {
+	err := InvokeLambdaAsync(os.Getenv(constants.JoinColumnsLambdaName), models.JoinColumnsJob{
+		OperationID: operationID,
+		ReportID:    reportID,
+		UserID:      userID,
+		Joins:       joins,
+	})
+	if err != nil {
+		// Stop the operation from being polled until it expires
+		failErr := FailOperation(operationID, "building the join columns could not be started", nil)
+		if failErr != nil {
+			log.Printf("error setting operation failed: %v", failErr)
+		}
+		return fmt.Errorf("error starting join columns build: %v", err)
+	}
+	return nil
+}
+
+// RunJoinColumnsBuild builds the column values map of each join of the job from its joined
+// rows. Joins that were changed or removed since the job started are left to the operation
+// building them.
+func RunJoinColumnsBuild(job models.JoinColumnsJob) error {
+	report, err := GetReport(job.ReportID, job.UserID)
+	if err != nil {
+		return failJoinColumnsBuild(job.OperationID, fmt.Errorf("error getting report from DynamoDB: %v", err))
+	}
+
+	if report == nil {
+		return failJoinColumnsBuild(job.OperationID, fmt.Errorf("report not found"))
+	}
+
+	progress := models.OperationProgress{Step: "Joining datasets", TotalSteps: len(job.Joins)}
+	err = StartOperation(job.OperationID, progress)
+	if err != nil {
+		return err
+	}
+
+	built := []string{}
+	for i, name := range job.Joins {
+		index := -1
+		for j, join := range report.Joins {
+			if join.Name == name && join.ColumnsOperationID == job.OperationID {
+				index = j
+			}
+		}
+
+		if index != -1 {
+			s3Key, err := buildJoinColumnValuesMap(report, report.Joins[index])
+			if err != nil {
+				return failJoinColumnsBuild(job.OperationID, fmt.Errorf("error building columns of join '%s': %v", name, err))
+			}
+
+			err = setJoinColumnsS3Key(job.ReportID, index, job.OperationID, s3Key)
+			if err != nil && err != errJoinReplaced {
+				return failJoinColumnsBuild(job.OperationID, err)
+			}
+			if err == nil {
+				built = append(built, name)
+			}
+		}
+
+		progress.CompletedSteps = i + 1
+		progress.Percent = progress.CompletedSteps * 100 / progress.TotalSteps
+		err = UpdateOperationProgress(job.OperationID, progress)
+		if err != nil {
+			return err
+		}
+	}
+
+	return CompleteOperation(job.OperationID, &models.OperationResult{
+		JoinColumns: &models.JoinColumnsResult{Joins: built},
+	})
+}
+
+func failJoinColumnsBuild(operationID string, err error) error {
+	failErr := FailOperation(operationID, err.Error(), nil)
+	if failErr != nil && failErr != ErrOperationFinished {
+		return fmt.Errorf("error setting operation failed: %v", failErr)
+	}
+	return err
+}
+
+// getReportJoinSources fetches the csvs, datasets and joins of a report without checking
+// who is asking, for the csv trigger which has no user
+func getReportJoinSources(reportID string) (*models.Report, error) {
+	dynamoDBClient, err := GetDynamoDBClient(constants.USEast2)
+	if err != nil {
+		return nil, fmt.Errorf("error getting dynamodb client: %v", err)
+	}
+
+	result, err := dynamoDBClient.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(os.Getenv(constants.ReportTable)),
+		Key: map[string]*dynamodb.AttributeValue{
+			constants.ReportIDField: {
+				S: aws.String(reportID),
+			},
+		},
+		ProjectionExpression: aws.String(constants.CSVIDField + ", " + constants.DatasetsField + ", " + constants.JoinsField),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error getting item from DynamoDB: %v", err)
+	}
+	if result.Item == nil {
+		return nil, fmt.Errorf("report not found")
+	}
+
+	var report models.Report
+	err = dynamodbattribute.UnmarshalMap(result.Item, &report)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling dynamo item into report: %v", err)
+	}
+	return &report, nil
+}
+
+// markJoinColumnsStale clears the column values maps of the joins at the indexes and names
+// the operation rebuilding them. The names make sure the joins were not replaced since
+// they were read.
+func markJoinColumnsStale(reportID string, joins map[int]string, operationID string) error {
+	dynamoDBClient, err := GetDynamoDBClient(constants.USEast2)
+	if err != nil {
+		return fmt.Errorf("error getting dynamodb client: %v", err)
+	}
+
+	values := map[string]*dynamodb.AttributeValue{
+		":none": {S: aws.String("no-csv-s3-key")},
+		":op":   {S: aws.String(operationID)},
+	}
+	var updates, conditions []string
+	for index, name := range joins {
+		joinPath := fmt.Sprintf("%s[%d]", constants.JoinsField, index)
+		nameValue := fmt.Sprintf(":name%d", index)
+		updates = append(updates, joinPath+"."+constants.CSVColumnsS3KeyField+" = :none", joinPath+"."+constants.JoinColumnsOperationIDField+" = :op")
+		conditions = append(conditions, joinPath+".#name = "+nameValue)
+		values[nameValue] = &dynamodb.AttributeValue{S: aws.String(name)}
+	}
+
+	_, err = dynamoDBClient.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String(os.Getenv(constants.ReportTable)),
+		Key: map[string]*dynamodb.AttributeValue{
+			constants.ReportIDField: {
+				S: aws.String(reportID),
+			},
+		},
+		UpdateExpression:          aws.String("set " + strings.Join(updates, ", ")),
+		ConditionExpression:       aws.String(strings.Join(conditions, " AND ")),
+		ExpressionAttributeNames:  map[string]*string{"#name": aws.String(constants.JoinNameField)},
+		ExpressionAttributeValues: values,
+	})
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return fmt.Errorf("joins were changed while the csv was read")
+		}
+		return fmt.Errorf("error updating item: %v", err)
+	}
+	return nil
+}
+
+// setJoinColumnsS3Key stores the key of the column values map of the join at the index,
+// if it is still the join the operation builds
+func setJoinColumnsS3Key(reportID string, index int, operationID, s3Key string) error {
+	dynamoDBClient, err := GetDynamoDBClient(constants.USEast2)
+	if err != nil {
+		return fmt.Errorf("error getting dynamodb client: %v", err)
+	}
+
+	joinPath := fmt.Sprintf("%s[%d]", constants.JoinsField, index)
+	_, err = dynamoDBClient.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String(os.Getenv(constants.ReportTable)),
+		Key: map[string]*dynamodb.AttributeValue{
+			constants.ReportIDField: {
+				S: aws.String(reportID),
+			},
+		},
+		UpdateExpression:    aws.String("set " + joinPath + "." + constants.CSVColumnsS3KeyField + " = :v"),
+		ConditionExpression: aws.String(joinPath + "." + constants.JoinColumnsOperationIDField + " = :op"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":v":  {S: aws.String(s3Key)},
+			":op": {S: aws.String(operationID)},
+		},
+	})
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return errJoinReplaced
+		}
+		return fmt.Errorf("error updating item: %v", err)
+	}
+	return nil
+}
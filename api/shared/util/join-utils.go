@@ -0,0 +1,303 @@
+package util
+
+import (
+	"api/shared/models"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+const reportCSVPrefix = "Report"
+
+// JoinSide is one of the csvs of a join along with the dataset it was uploaded to,
+// whose derived columns are added before the rows are joined
+type JoinSide struct {
+	File    *os.File
+	Dataset models.Dataset
+}
+
+// JoinCSV joins the rows of two csvs on the key columns of the join and writes
+// them to a temporary csv, which can be analysed like any uploaded csv. The
+// columns of the left csv come first, followed by the right columns except for
+// its keys. Column names found on both sides are prefixed with the prefix of
+// their side.
+//
+// Only the smaller csv is held in memory, in a hash table of its key values,
+// while the other one is streamed. Rows with a blank key never match.
+func JoinCSV(join models.Join, left, right JoinSide) (*os.File, error) {
+	if join.Type != models.LeftJoin && join.Type != models.InnerJoin {
+		return nil, fmt.Errorf("unsupported join type '%s'", join.Type)
+	}
+	if len(join.Keys) == 0 {
+		return nil, fmt.Errorf("join '%s' has no key columns", join.Name)
+	}
+
+	leftSize, err := fileSize(left.File)
+	if err != nil {
+		return nil, err
+	}
+	rightSize, err := fileSize(right.File)
+	if err != nil {
+		return nil, err
+	}
+
+	// A left join streaming its left side writes unmatched rows as they come, otherwise
+	// they are written once every row of the streamed side has been looked up
+	buildIsLeft := leftSize < rightSize
+	build, probe := right, left
+	if buildIsLeft {
+		build, probe = left, right
+	}
+
+	table := &hashJoinTable{matches: make(map[string][]int)}
+	err = streamCSV(build.File, build.Dataset.DerivedColumns, func(headers []string) ([]csvAggregator, error) {
+		keyIndexes, err := joinKeyIndexes(headers, join.Keys, buildIsLeft)
+		if err != nil {
+			return nil, err
+		}
+		table.headers = headers
+		table.keyIndexes = keyIndexes
+		return []csvAggregator{table}, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", joinSideName(build.Dataset.Name), err)
+	}
+
+	output, err := createTempCSVFile()
+	if err != nil {
+		return nil, fmt.Errorf("error creating joined csv: %v", err)
+	}
+
+	joiner := &hashJoinProbe{
+		table:       table,
+		buildIsLeft: buildIsLeft,
+		leftJoin:    join.Type == models.LeftJoin,
+		writer:      csv.NewWriter(output),
+	}
+	err = streamCSV(probe.File, probe.Dataset.DerivedColumns, func(headers []string) ([]csvAggregator, error) {
+		keyIndexes, err := joinKeyIndexes(headers, join.Keys, !buildIsLeft)
+		if err != nil {
+			return nil, err
+		}
+		joiner.keyIndexes = keyIndexes
+
+		leftHeaders, rightHeaders := headers, table.headers
+		leftKeys, rightKeys := joiner.keyIndexes, table.keyIndexes
+		if buildIsLeft {
+			leftHeaders, rightHeaders = table.headers, headers
+			leftKeys, rightKeys = table.keyIndexes, joiner.keyIndexes
+		}
+		joiner.leftWidth = len(leftHeaders)
+		joiner.rightKept = keptRightColumns(rightHeaders, rightKeys)
+
+		outputHeaders := joinedHeaders(leftHeaders, rightHeaders, joiner.rightKept, leftKeys, join, left.Dataset.Name, right.Dataset.Name)
+		if err := joiner.writer.Write(outputHeaders); err != nil {
+			return nil, err
+		}
+		return []csvAggregator{joiner}, nil
+	})
+	if err != nil {
+		output.Close()
+		return nil, fmt.Errorf("error joining %s: %v", joinSideName(probe.Dataset.Name), err)
+	}
+
+	_, err = output.Seek(0, io.SeekStart)
+	if err != nil {
+		output.Close()
+		return nil, fmt.Errorf("error seeking in file: %v", err)
+	}
+	return output, nil
+}
+
+func fileSize(file *os.File) (int64, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("error getting csv size: %v", err)
+	}
+	return info.Size(), nil
+}
+
+func joinSideName(dataset string) string {
+	if dataset == "" {
+		return "report csv"
+	}
+	return fmt.Sprintf("dataset '%s'", dataset)
+}
+
+// joinKeyIndexes finds the key columns of one side of the join in its headers
+func joinKeyIndexes(headers []string, keys []models.JoinKey, isLeft bool) ([]int, error) {
+	indexes := make([]int, len(keys))
+	for i, key := range keys {
+		column := key.LeftColumn
+		if !isLeft && key.RightColumn != "" {
+			column = key.RightColumn
+		}
+		index := findColumnIndex(headers, column)
+		if index == -1 {
+			return nil, fmt.Errorf("join key column '%s' not found", column)
+		}
+		indexes[i] = index
+	}
+	return indexes, nil
+}
+
+// joinKeyValue combines the key values of a row. Values are trimmed so exports
+// padding their ids still match, and a blank value means the row has no key.
+func joinKeyValue(record []string, keyIndexes []int) (string, bool) {
+	var key strings.Builder
+	for i, index := range keyIndexes {
+		value := strings.TrimSpace(record[index])
+		if value == "" {
+			return "", false
+		}
+		if i > 0 {
+			key.WriteByte(0)
+		}
+		key.WriteString(value)
+	}
+	return key.String(), true
+}
+
+// keptRightColumns lists the right columns written to the joined csv. The right
+// keys are left out since they hold the same values as the left keys.
+func keptRightColumns(rightHeaders []string, rightKeys []int) []int {
+	kept := make([]int, 0, len(rightHeaders))
+	for index := range rightHeaders {
+		if !slices.Contains(rightKeys, index) {
+			kept = append(kept, index)
+		}
+	}
+	return kept
+}
+
+func joinedHeaders(leftHeaders, rightHeaders []string, rightKept []int, leftKeys []int, join models.Join, leftDataset, rightDataset string) []string {
+	leftPrefix := joinPrefix(join.LeftPrefix, leftDataset)
+	rightPrefix := joinPrefix(join.RightPrefix, rightDataset)
+
+	leftNames := make(map[string]bool, len(leftHeaders))
+	for _, header := range leftHeaders {
+		leftNames[header] = true
+	}
+	rightNames := make(map[string]bool, len(rightKept))
+	for _, index := range rightKept {
+		rightNames[rightHeaders[index]] = true
+	}
+
+	headers := make([]string, 0, len(leftHeaders)+len(rightKept))
+	for index, header := range leftHeaders {
+		// The keys stand for both sides, so they keep their names
+		if rightNames[header] && !slices.Contains(leftKeys, index) {
+			header = leftPrefix + "." + header
+		}
+		headers = append(headers, header)
+	}
+	for _, index := range rightKept {
+		header := rightHeaders[index]
+		if leftNames[header] {
+			header = rightPrefix + "." + header
+		}
+		headers = append(headers, header)
+	}
+	return headers
+}
+
+func joinPrefix(prefix, dataset string) string {
+	if prefix != "" {
+		return prefix
+	}
+	if dataset != "" {
+		return dataset
+	}
+	return reportCSVPrefix
+}
+
+// hashJoinTable holds the rows of the smaller side of a join by their key values
+type hashJoinTable struct {
+	headers    []string
+	keyIndexes []int
+	rows       [][]string
+	matches    map[string][]int
+	matched    []bool
+}
+
+func (t *hashJoinTable) consume(record []string) error {
+	// The reader reuses its record slice, so the row needs its own copy
+	t.rows = append(t.rows, append([]string(nil), record...))
+	t.matched = append(t.matched, false)
+	if key, ok := joinKeyValue(record, t.keyIndexes); ok {
+		t.matches[key] = append(t.matches[key], len(t.rows)-1)
+	}
+	return nil
+}
+
+func (t *hashJoinTable) finish() error {
+	return nil
+}
+
+// hashJoinProbe streams the larger side of a join, writing a joined row for
+// every row of the table with the same key values
+type hashJoinProbe struct {
+	table       *hashJoinTable
+	keyIndexes  []int
+	buildIsLeft bool
+	leftJoin    bool
+	leftWidth   int
+	rightKept   []int
+	writer      *csv.Writer
+	row         []string
+}
+
+func (p *hashJoinProbe) consume(record []string) error {
+	var matches []int
+	if key, ok := joinKeyValue(record, p.keyIndexes); ok {
+		matches = p.table.matches[key]
+	}
+
+	for _, index := range matches {
+		p.table.matched[index] = true
+		var err error
+		if p.buildIsLeft {
+			err = p.write(p.table.rows[index], record)
+		} else {
+			err = p.write(record, p.table.rows[index])
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(matches) == 0 && p.leftJoin && !p.buildIsLeft {
+		return p.write(record, nil)
+	}
+	return nil
+}
+
+func (p *hashJoinProbe) finish() error {
+	if p.leftJoin && p.buildIsLeft {
+		for index, row := range p.table.rows {
+			if !p.table.matched[index] {
+				if err := p.write(row, nil); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	p.writer.Flush()
+	return p.writer.Error()
+}
+
+// write adds a joined row to the output, a nil right row leaves the right columns blank
+func (p *hashJoinProbe) write(left, right []string) error {
+	p.row = append(p.row[:0], left[:p.leftWidth]...)
+	for _, index := range p.rightKept {
+		if right == nil {
+			p.row = append(p.row, "")
+		} else {
+			p.row = append(p.row, right[index])
+		}
+	}
+	return p.writer.Write(p.row)
+}
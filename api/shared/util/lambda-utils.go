@@ -0,0 +1,61 @@
+package util
+
+import (
+	"api/shared/constants"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+var (
+	lambdaClient    *lambda.Lambda
+	lambdaOnce      sync.Once
+	lambdaCreateErr error
+)
+
+// GetLambdaClient returns a singleton Lambda client
+func GetLambdaClient(region string) (*lambda.Lambda, error) {
+	lambdaOnce.Do(func() {
+		lambdaClient, lambdaCreateErr = newLambdaClient(region)
+	})
+	return lambdaClient, lambdaCreateErr
+}
+
+func newLambdaClient(region string) (*lambda.Lambda, error) {
+	sess, err := session.NewSession(&aws.Config{
+		Region: aws.String(region)},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return lambda.New(sess), nil
+}
+
+// InvokeLambdaAsync starts a function with the payload and returns without waiting for it,
+// so work that outlasts an API Gateway request can run in the background
+func InvokeLambdaAsync(functionName string, payload interface{}) error {
+	client, err := GetLambdaClient(constants.USEast2)
+	if err != nil {
+		return err
+	}
+
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("error marshalling payload: %v", err)
+	}
+
+	_, err = client.Invoke(&lambda.InvokeInput{
+		FunctionName:   aws.String(functionName),
+		InvocationType: aws.String(lambda.InvocationTypeEvent),
+		Payload:        payloadJSON,
+	})
+	if err != nil {
+		return fmt.Errorf("error invoking %s: %v", functionName, err)
+	}
+
+	return nil
+}
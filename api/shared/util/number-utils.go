@@ -0,0 +1,115 @@
+package util
+
+import (
+	"api/shared/models"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// parseNumericalValue parses a csv cell as a number. Surrounding whitespace,
+// currency symbols, percent signs and thousands separators are removed, and
+// values in parentheses are read as negative, as in accounting exports.
+// decimalSeparator is "." unless the column uses a comma, like 1.200,50.
+// Thousands separators, spaces and apostrophes only count as such between groups
+// of 3 digits left of the decimal, so 1,5 is not read as 15 with a "." decimal.
+func parseNumericalValue(value string, decimalSeparator string) (float64, bool) {
+	thousandsSeparator := ','
+	decimal := '.'
+	if decimalSeparator == "," {
+		thousandsSeparator = '.'
+		decimal = ','
+	}
+	isSeparator := func(r rune) bool {
+		return r == thousandsSeparator || r == '\'' || unicode.IsSpace(r)
+	}
+
+	cleaned := strings.TrimSpace(value)
+	negative := false
+	if strings.HasPrefix(cleaned, "(") && strings.HasSuffix(cleaned, ")") {
+		negative = true
+		cleaned = cleaned[1 : len(cleaned)-1]
+	}
+	cleaned = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if r == '%' || unicode.Is(unicode.Sc, r) {
+			return -1
+		}
+		return r
+	}, cleaned))
+
+	integer, fraction, hasDecimal := strings.Cut(cleaned, string(decimal))
+	if strings.IndexFunc(fraction, isSeparator) != -1 {
+		return 0, false
+	}
+	sign := ""
+	if strings.HasPrefix(integer, "-") || strings.HasPrefix(integer, "+") {
+		sign, integer = integer[:1], integer[1:]
+	}
+	// Separators are only left out between groups of 3 digits, the first of 1 to 3
+	var digits strings.Builder
+	group, separators := 0, 0
+	for _, r := range integer {
+		if isSeparator(r) {
+			if group == 0 || group > 3 || (separators > 0 && group != 3) {
+				return 0, false
+			}
+			separators++
+			group = 0
+			continue
+		}
+		digits.WriteRune(r)
+		group++
+	}
+	integer = digits.String()
+	if separators > 0 && (group != 3 || strings.Trim(integer, "0123456789") != "") {
+		return 0, false
+	}
+
+	number := sign + integer
+	if hasDecimal {
+		number += "." + fraction
+	}
+	if number == "" {
+		return 0, false
+	}
+
+	num, err := strconv.ParseFloat(number, 64)
+	if err != nil || math.IsNaN(num) || math.IsInf(num, 0) {
+		return 0, false
+	}
+	if negative {
+		num = -num
+	}
+	return num, true
+}
+
+// numericalValue parses the value of a row for a numerical operation and applies the invalid value policy of the config.
+// The returned bool is false when the row should be left out of the result.
+func numericalValue(value string, columnConfig *models.ReportOneDimConfig) (float64, bool, error) {
+	num, ok := parseNumericalValue(value, columnConfig.DecimalSeparator)
+	if ok {
+		return num, true, nil
+	}
+
+	// Items saved before the policy existed fail on invalid values, as they always have
+	switch columnConfig.InvalidValuePolicy {
+	case models.FailInvalidValue, "":
+		return 0, false, fmt.Errorf("error parsing numerical value: %q in column '%s'", value, columnConfig.Column)
+	case models.ZeroInvalidValue:
+		return 0, true, nil
+	case models.SkipInvalidValue:
+		columnConfig.SkippedRows++
+		return 0, false, nil
+	default:
+		return 0, false, fmt.Errorf("unsupported invalid value policy: %s", columnConfig.InvalidValuePolicy)
+	}
+}
+
+// roundSum removes floating point noise such as 0.30000000000000004 from a sum
+func roundSum(output map[string]interface{}, columnConfig *models.ReportOneDimConfig) {
+	if sum, ok := output[columnConfig.AggregateValueLabel].(float64); ok {
+		output[columnConfig.AggregateValueLabel] = math.Round(sum*1e6) / 1e6
+	}
+}
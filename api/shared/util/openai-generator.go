@@ -1,35 +1,63 @@
 package util
 
 import (
-	"api/shared/constants"
+	"api/shared/models"
 	"context"
-	"os"
+	"fmt"
+	"math"
 
 	openai "github.com/sashabaranov/go-openai"
 )
 
-type OpenAiGenerator struct{}
-
-func (g OpenAiGenerator) GeneratePromptResponse(prompt string) (string, error) {
-	key := os.Getenv(constants.OpenAIKey)
-
-	client := openai.NewClient(key)
-	resp, err := client.CreateChatCompletion(
-		context.Background(),
-		openai.ChatCompletionRequest{
-			Model: openai.GPT3Dot5Turbo, // Ensure this constant is defined in the `openai` package
-			Messages: []openai.ChatCompletionMessage{
-				{
-					Role:    openai.ChatMessageRoleUser, // Ensure this constant is defined
-					Content: prompt,
-				},
-			},
-		},
-	)
+// OpenAiGenerator sends prompts to a chat completions api: OpenAI, Azure OpenAI or any
+// server compatible with OpenAI, depending on how its client is configured
+type OpenAiGenerator struct {
+	Client *openai.Client
+	Model  string // The deployment name for Azure OpenAI
+}
+
+func (g OpenAiGenerator) GeneratePromptResponse(ctx context.Context, request models.GenerationRequest) (models.GenerationResponse, error) {
+	var messages []openai.ChatCompletionMessage
+	if request.Options.SystemPrompt != "" {
+		messages = append(messages, openai.ChatCompletionMessage{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: request.Options.SystemPrompt,
+		})
+	}
+	messages = append(messages, openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleUser,
+		Content: request.Prompt,
+	})
+
+	completionRequest := openai.ChatCompletionRequest{
+		Model:     g.Model,
+		Messages:  messages,
+		MaxTokens: request.Options.MaxTokens,
+		Stop:      request.Options.StopSequences,
+	}
+	if request.Options.Temperature != nil {
+		completionRequest.Temperature = *request.Options.Temperature
+		// A temperature of 0 is left out of the request, so the smallest one is sent instead
+		if completionRequest.Temperature == 0 {
+			completionRequest.Temperature = math.SmallestNonzeroFloat32
+		}
+	}
+
+	resp, err := g.Client.CreateChatCompletion(ctx, completionRequest)
 
 	if err != nil {
-		return "", err
+		return models.GenerationResponse{}, err
 	}
 
-	return resp.Choices[0].Message.Content, nil
+	if len(resp.Choices) == 0 {
+		return models.GenerationResponse{}, fmt.Errorf("no choices returned by model %s", g.Model)
+	}
+
+	return models.GenerationResponse{
+		Text: resp.Choices[0].Message.Content,
+		Usage: models.TokenUsage{
+			PromptTokens:     resp.Usage.PromptTokens,
+			CompletionTokens: resp.Usage.CompletionTokens,
+		},
+	}, nil
 }
@@ -3,27 +3,61 @@ package util
 import (
 	"api/shared/constants"
 	"api/shared/models"
+	"errors"
 	"fmt"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 )
 
-func CreateOperation(operationID string) error {
-	tableName := os.Getenv(constants.OperationTable)
-	dynamoDBClient, err := GetDynamoDBClient(constants.USEast2)
-	if err != nil {
-		return err
-	}
+var (
+	// ErrOperationFinished is returned when an operation is updated after it succeeded,
+	// failed or was cancelled, or when there is no such operation. Tasks stop when they
+	// get it, since it usually means their owner cancelled them.
+	ErrOperationFinished      = errors.New("operation is already finished")
+	ErrOperationNotFound      = errors.New("operation not found")
+	ErrOperationNotAuthorized = errors.New("user is not authorized for operation")
+)
+
+// CreateOperation creates a pending operation owned by the user, for a task working on the item
+func CreateOperation(operationID string, operationType models.OperationType, itemID, userID string) error {
+	return putOperation(newOperation(operationID, operationType, itemID, userID))
+}
+
+// CreateUploadOperation creates the operation of a file upload along with the options
+// used to normalize the file once it is uploaded
+func CreateUploadOperation(operationID, reportID, userID string, options models.UploadOptions) error {
+	operation := newOperation(operationID, models.CsvUploadOperation, reportID, userID)
+	operation.Upload = &options
+	return putOperation(operation)
+}
 
-	operation := models.Operation{
+func newOperation(operationID string, operationType models.OperationType, itemID, userID string) models.Operation {
+	currentTime := GetCurrentTime()
+	return models.Operation{
 		OperationID: operationID,
+		Type:        operationType,
+		State:       models.OperationPending,
 		Completed:   false,
+		UserID:      userID,
+		ItemID:      itemID,
+		CreatedAt:   currentTime,
+		UpdatedAt:   currentTime,
 		DeleteAt:    time.Now().Add(24 * time.Hour).Unix(), // Set to delete 24 hours from now
 	}
+}
+
+func putOperation(operation models.Operation) error {
+	tableName := os.Getenv(constants.OperationTable)
+	dynamoDBClient, err := GetDynamoDBClient(constants.USEast2)
+	if err != nil {
+		return err
+	}
 
 	item, err := dynamodbattribute.MarshalMap(operation)
 	if err != nil {
@@ -43,68 +77,249 @@ func CreateOperation(operationID string) error {
 	return nil
 }
 
-func SetOperationCompleted(operationID string) error {
+// GetOperation fetches an operation for its owner. Returns nil when there is no operation
+// and ErrOperationNotAuthorized when it belongs to another user.
+func GetOperation(operationID, userID string) (*models.Operation, error) {
+	operation, err := getOperation(operationID)
+	if err != nil {
+		return nil, err
+	}
+
+	if operation == nil {
+		return nil, nil
+	}
+
+	if operation.UserID != userID {
+		return nil, ErrOperationNotAuthorized
+	}
+
+	return operation, nil
+}
+
+func getOperation(operationID string) (*models.Operation, error) {
 	tableName := os.Getenv(constants.OperationTable)
 	dynamoDBClient, err := GetDynamoDBClient(constants.USEast2)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	input := &dynamodb.UpdateItemInput{
+	input := &dynamodb.GetItemInput{
 		TableName: aws.String(tableName),
 		Key: map[string]*dynamodb.AttributeValue{
 			constants.OperationIDField: {
 				S: aws.String(operationID),
 			},
 		},
-		UpdateExpression: aws.String("set " + constants.OperationCompletedField + " = :c"),
-		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
-			":c": {
-				BOOL: aws.Bool(true),
-			},
-		},
-		ReturnValues: aws.String("UPDATED_NEW"),
 	}
 
-	_, err = dynamoDBClient.UpdateItem(input)
+	result, err := dynamoDBClient.GetItem(input)
 	if err != nil {
-		return fmt.Errorf("failed to update item in DynamoDB: %v", err)
+		return nil, fmt.Errorf("failed to get item from DynamoDB: %v", err)
 	}
 
-	return nil
+	if result.Item == nil {
+		return nil, nil
+	}
+
+	var operation models.Operation
+	err = dynamodbattribute.UnmarshalMap(result.Item, &operation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal operation: %v", err)
+	}
+
+	// Operations created before they had states only have the completed flag
+	if operation.State == "" {
+		operation.State = models.OperationPending
+		if operation.Completed {
+			operation.State = models.OperationSucceeded
+		}
+	}
+
+	return &operation, nil
+}
+
+// GetOperationUploadOptions fetches the options of a file upload operation. Operations
+// created without options read the file as a csv.
+func GetOperationUploadOptions(operationID string) (models.UploadOptions, error) {
+	operation, err := getOperation(operationID)
+	if err != nil {
+		return models.UploadOptions{}, err
+	}
+
+	if operation == nil {
+		return models.UploadOptions{}, fmt.Errorf("operation not found")
+	}
+
+	if operation.Upload == nil {
+		return models.UploadOptions{FileType: models.CSVFile}, nil
+	}
+	return *operation.Upload, nil
+}
+
+// StartOperation moves a pending operation to running, at the first step of its task
+func StartOperation(operationID string, progress models.OperationProgress) error {
+	currentTime := strconv.FormatInt(GetCurrentTime(), 10)
+	return updateOperation(operationID, map[string]*dynamodb.AttributeValue{
+		constants.OperationStateField:     {S: aws.String(string(models.OperationRunning))},
+		constants.OperationStartedAtField: {N: aws.String(currentTime)},
+		constants.OperationProgressField:  progressAttribute(progress),
+	})
 }
 
-func GetOperationStatus(operationID string) (bool, error) {
+// UpdateOperationProgress records how far a running operation is
+func UpdateOperationProgress(operationID string, progress models.OperationProgress) error {
+	return updateOperation(operationID, map[string]*dynamodb.AttributeValue{
+		constants.OperationStateField:    {S: aws.String(string(models.OperationRunning))},
+		constants.OperationProgressField: progressAttribute(progress),
+	})
+}
+
+// CompleteOperation marks an operation as succeeded with the result of its task. The
+// progress is filled in, keeping the state of its steps.
+func CompleteOperation(operationID string, result *models.OperationResult) error {
+	resultAttr, err := dynamodbattribute.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to marshal operation result: %v", err)
+	}
+
+	operation, err := getOperation(operationID)
+	if err != nil {
+		return err
+	}
+
+	if operation == nil {
+		return ErrOperationFinished
+	}
+
+	progress := operation.Progress
+	progress.Percent = 100
+	progress.Step = ""
+	progress.CompletedSteps = progress.TotalSteps
+
+	currentTime := strconv.FormatInt(GetCurrentTime(), 10)
+	return updateOperation(operationID, map[string]*dynamodb.AttributeValue{
+		constants.OperationStateField:      {S: aws.String(string(models.OperationSucceeded))},
+		constants.OperationCompletedField:  {BOOL: aws.Bool(true)},
+		constants.OperationProgressField:   progressAttribute(progress),
+		constants.OperationResultField:     resultAttr,
+		constants.OperationFinishedAtField: {N: aws.String(currentTime)},
+	})
+}
+
+// FailOperation stops the polling of an operation that can not complete, storing why
+// it failed and the validation report of an uploaded file when there is one
+func FailOperation(operationID, message string, validation *models.ValidationReport) error {
+	currentTime := strconv.FormatInt(GetCurrentTime(), 10)
+	updates := map[string]*dynamodb.AttributeValue{
+		constants.OperationStateField:      {S: aws.String(string(models.OperationFailed))},
+		constants.OperationErrorField:      {S: aws.String(message)},
+		constants.OperationFinishedAtField: {N: aws.String(currentTime)},
+	}
+
+	if validation != nil {
+		validationAttr, err := dynamodbattribute.Marshal(validation)
+		if err != nil {
+			return fmt.Errorf("failed to marshal validation report: %v", err)
+		}
+		updates[constants.OperationValidationField] = validationAttr
+	}
+
+	return updateOperation(operationID, updates)
+}
+
+// CancelOperation cancels an operation of the user that has not finished. Its task
+// stops at its next update.
+func CancelOperation(operationID, userID string) error {
+	operation, err := GetOperation(operationID, userID)
+	if err != nil {
+		return err
+	}
+
+	if operation == nil {
+		return ErrOperationNotFound
+	}
+
+	currentTime := strconv.FormatInt(GetCurrentTime(), 10)
+	return updateOperation(operationID, map[string]*dynamodb.AttributeValue{
+		constants.OperationStateField:      {S: aws.String(string(models.OperationCancelled))},
+		constants.OperationFinishedAtField: {N: aws.String(currentTime)},
+	})
+}
+
+// SetOperationValidation stores the validation report of an uploaded file that passed
+// validation, so its warnings are returned once the operation completes
+func SetOperationValidation(operationID string, validation models.ValidationReport) error {
+	validationAttr, err := dynamodbattribute.Marshal(validation)
+	if err != nil {
+		return fmt.Errorf("failed to marshal validation report: %v", err)
+	}
+
+	return updateOperation(operationID, map[string]*dynamodb.AttributeValue{
+		constants.OperationValidationField: validationAttr,
+	})
+}
+
+func progressAttribute(progress models.OperationProgress) *dynamodb.AttributeValue {
+	// A struct of ints and strings always marshals, as do its steps
+	progressAttr, _ := dynamodbattribute.Marshal(progress)
+	return progressAttr
+}
+
+// updateOperation sets the fields of an operation that has not finished yet, returning
+// ErrOperationFinished otherwise
+func updateOperation(operationID string, updates map[string]*dynamodb.AttributeValue) error {
 	tableName := os.Getenv(constants.OperationTable)
 	dynamoDBClient, err := GetDynamoDBClient(constants.USEast2)
 	if err != nil {
-		return false, err
+		return err
 	}
 
-	input := &dynamodb.GetItemInput{
+	updates[constants.OperationUpdatedAtField] = &dynamodb.AttributeValue{
+		N: aws.String(strconv.FormatInt(GetCurrentTime(), 10)),
+	}
+
+	// Fields are set through attribute names since State is a reserved word
+	updateExpression := "set "
+	names := map[string]*string{
+		"#state": aws.String(constants.OperationStateField),
+	}
+	values := map[string]*dynamodb.AttributeValue{
+		":pending": {S: aws.String(string(models.OperationPending))},
+		":running": {S: aws.String(string(models.OperationRunning))},
+	}
+	i := 0
+	for field, value := range updates {
+		if i > 0 {
+			updateExpression += ", "
+		}
+		name, placeholder := "#f"+strconv.Itoa(i), ":v"+strconv.Itoa(i)
+		updateExpression += name + " = " + placeholder
+		names[name] = aws.String(field)
+		values[placeholder] = value
+		i++
+	}
+
+	input := &dynamodb.UpdateItemInput{
 		TableName: aws.String(tableName),
 		Key: map[string]*dynamodb.AttributeValue{
 			constants.OperationIDField: {
 				S: aws.String(operationID),
 			},
 		},
-		ProjectionExpression: aws.String(constants.OperationCompletedField),
+		UpdateExpression: aws.String(updateExpression),
+		// Operations created before they had states can still be updated
+		ConditionExpression:       aws.String("attribute_exists(" + constants.OperationIDField + ") AND (attribute_not_exists(#state) OR #state IN (:pending, :running))"),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
 	}
 
-	result, err := dynamoDBClient.GetItem(input)
+	_, err = dynamoDBClient.UpdateItem(input)
 	if err != nil {
-		return false, fmt.Errorf("failed to get item from DynamoDB: %v", err)
-	}
-
-	if result.Item == nil {
-		return false, nil // Assuming false for non-existent operations
-	}
-
-	// Assuming 'Complete' attribute exists and is a boolean
-	completeAttr := result.Item[constants.OperationCompletedField]
-	if completeAttr == nil || completeAttr.BOOL == nil {
-		return false, fmt.Errorf("complete attribute is missing or not a boolean")
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return ErrOperationFinished
+		}
+		return fmt.Errorf("failed to update item in DynamoDB: %v", err)
 	}
 
-	return *completeAttr.BOOL, nil
+	return nil
 }
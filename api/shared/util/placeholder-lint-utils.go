@@ -0,0 +1,588 @@
+package util
+
+import (
+	"api/shared/models"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// PlaceholderLintError is returned when an item is not saved because its placeholders
+// have errors. The report holds every issue that was found.
+type PlaceholderLintError struct {
+	Report models.PlaceholderLintReport
+}
+
+func (e *PlaceholderLintError) Error() string {
+	var messages []string
+	for _, issue := range e.Report.Issues {
+		if issue.Severity == models.ErrorSeverity {
+			messages = append(messages, issue.Message)
+		}
+	}
+	return "placeholders have errors: " + strings.Join(messages, "; ")
+}
+
+// lintItem is the labels and text outputs of a report or template
+type lintItem struct {
+	GlobalLabels []string
+	Sections     []lintSection
+}
+
+type lintSection struct {
+	PartIndex      int
+	SectionIndex   int
+	Label          string
+	QuestionLabels []string
+	CSVDataLabels  []string
+	ChartLabels    []string
+	TextOutputs    []lintTextOutput
+}
+
+type lintTextOutput struct {
+	Title string
+	Input string
+}
+
+// LintReportPlaceholders checks the placeholders of the text outputs of a report
+func LintReportPlaceholders(report *models.Report) models.PlaceholderLintReport {
+	var item lintItem
+	for _, question := range report.GlobalQuestions {
+		item.GlobalLabels = append(item.GlobalLabels, question.Label)
+	}
+
+	for p, part := range report.Parts {
+		for s, section := range part.Sections {
+			lint := lintSection{PartIndex: p, SectionIndex: s, Label: section.Label}
+			for _, question := range section.Questions {
+				lint.QuestionLabels = append(lint.QuestionLabels, question.Label)
+			}
+			for _, csvData := range section.CSVData {
+				lint.CSVDataLabels = append(lint.CSVDataLabels, csvData.Label)
+			}
+			for _, chart := range section.ChartOutputs {
+				if chart.Label != "" {
+					lint.ChartLabels = append(lint.ChartLabels, chart.Label)
+				}
+			}
+			for _, textOutput := range section.TextOutputs {
+				lint.TextOutputs = append(lint.TextOutputs, lintTextOutput{Title: textOutput.Title, Input: textOutput.Input})
+			}
+			item.Sections = append(item.Sections, lint)
+		}
+	}
+
+	return lintPlaceholders(item)
+}
+
+// LintTemplatePlaceholders checks the placeholders of the text outputs of a template
+func LintTemplatePlaceholders(template *models.Template) models.PlaceholderLintReport {
+	var item lintItem
+	for _, question := range template.GlobalQuestions {
+		item.GlobalLabels = append(item.GlobalLabels, question.Label)
+	}
+
+	for p, part := range template.Parts {
+		for s, section := range part.Sections {
+			lint := lintSection{PartIndex: p, SectionIndex: s, Label: section.Label}
+			for _, question := range section.Questions {
+				lint.QuestionLabels = append(lint.QuestionLabels, question.Label)
+			}
+			for _, csvData := range section.CSVData {
+				lint.CSVDataLabels = append(lint.CSVDataLabels, csvData.Label)
+			}
+			for _, chart := range section.ChartOutputs {
+				if chart.Label != "" {
+					lint.ChartLabels = append(lint.ChartLabels, chart.Label)
+				}
+			}
+			for _, textOutput := range section.TextOutputs {
+				lint.TextOutputs = append(lint.TextOutputs, lintTextOutput{Title: textOutput.Title, Input: textOutput.Input})
+			}
+			item.Sections = append(item.Sections, lint)
+		}
+	}
+
+	return lintPlaceholders(item)
+}
+
+// SectionPlaceholderErrors returns the lint error of an item when one of the sections given has
+// placeholder errors, so a section can be saved while other sections still have errors
+func SectionPlaceholderErrors(report models.PlaceholderLintReport, sections ...models.SectionRef) error {
+	for _, issue := range report.Issues {
+		if issue.Severity != models.ErrorSeverity {
+			continue
+		}
+		for _, ref := range sections {
+			if issue.PartIndex == ref.PartIndex && issue.SectionIndex == ref.SectionIndex {
+				return &PlaceholderLintError{Report: report}
+			}
+		}
+	}
+	return nil
+}
+
+// ItemPlaceholderErrors returns the lint error of an item when any of it has placeholder errors
+func ItemPlaceholderErrors(report models.PlaceholderLintReport) error {
+	if !report.Valid {
+		return &PlaceholderLintError{Report: report}
+	}
+	return nil
+}
+
+// placeholderLinter collects the issues of an item
+type placeholderLinter struct {
+	issues        []models.PlaceholderIssue
+	usedGlobal    map[string]bool
+	sectionFields map[string]map[string]bool // The placeholders of each section label
+	usedElsewhere map[string]map[string]bool // The labels of each section label used by other sections
+}
+
+func lintPlaceholders(item lintItem) models.PlaceholderLintReport {
+	linter := &placeholderLinter{
+		usedGlobal:    make(map[string]bool),
+		sectionFields: make(map[string]map[string]bool),
+		usedElsewhere: make(map[string]map[string]bool),
+	}
+	linter.lintSectionReferences(item)
+
+	for _, label := range duplicateLabels(item.GlobalLabels) {
+		linter.add(models.PlaceholderIssue{
+			Kind:     models.DuplicateLabelIssue,
+			Severity: models.ErrorSeverity,
+			Message:  fmt.Sprintf("global question label '%s' is used more than once", label),
+			Label:    label,
+		}, -1, -1)
+	}
+
+	for _, section := range item.Sections {
+		linter.lintSection(section, item.GlobalLabels)
+	}
+
+	for _, label := range item.GlobalLabels {
+		if label != "" && !linter.usedGlobal[label] {
+			linter.add(models.PlaceholderIssue{
+				Kind:     models.UnusedQuestionIssue,
+				Severity: models.WarningSeverity,
+				Message:  fmt.Sprintf("global question '%s' is not used by any text output", label),
+				Label:    label,
+			}, -1, -1)
+		}
+	}
+
+	report := models.PlaceholderLintReport{Valid: true, Issues: linter.issues}
+	if report.Issues == nil {
+		report.Issues = []models.PlaceholderIssue{}
+	}
+	for _, issue := range report.Issues {
+		if issue.Severity == models.ErrorSeverity {
+			report.Valid = false
+		}
+	}
+	return report
+}
+
+func (l *placeholderLinter) add(issue models.PlaceholderIssue, partIndex, sectionIndex int) {
+	issue.PartIndex, issue.SectionIndex = partIndex, sectionIndex
+	l.issues = append(l.issues, issue)
+}
+
+func (l *placeholderLinter) lintSection(section lintSection, globalLabels []string) {
+	add := func(issue models.PlaceholderIssue) {
+		l.add(issue, section.PartIndex, section.SectionIndex)
+	}
+
+	sectionLabels := append(append(append([]string{}, section.QuestionLabels...), section.CSVDataLabels...), section.ChartLabels...)
+	for _, label := range duplicateLabels(sectionLabels) {
+		add(models.PlaceholderIssue{
+			Kind:     models.DuplicateLabelIssue,
+			Severity: models.ErrorSeverity,
+			Message:  fmt.Sprintf("label '%s' is used more than once in the section", label),
+			Label:    label,
+		})
+	}
+
+	// The labels placeholders can refer to, section labels being used over global ones
+	scope := make(map[string]bool)
+	isGlobal := make(map[string]bool)
+	for _, label := range sectionLabels {
+		scope[label] = true
+	}
+	for _, label := range globalLabels {
+		if scope[label] && !isGlobal[label] {
+			add(models.PlaceholderIssue{
+				Kind:     models.DuplicateLabelIssue,
+				Severity: models.WarningSeverity,
+				Message:  fmt.Sprintf("label '%s' of the section hides the global question with the label", label),
+				Label:    label,
+			})
+			continue
+		}
+		scope[label] = true
+		isGlobal[label] = true
+	}
+	delete(scope, "")
+
+	used := make(map[string]bool)
+	usesLegacy := false
+	for _, textOutput := range section.TextOutputs {
+		var references []string
+		var issues []models.PlaceholderIssue
+		if UsesPlaceholderSyntax(textOutput.Input) {
+			references, issues = placeholderReferences(textOutput.Input, scope, l.sectionFields)
+		} else {
+			usesLegacy = true
+			references, issues = legacyPlaceholderReferences(textOutput.Input, scope)
+		}
+
+		for _, issue := range issues {
+			issue.TextOutput = textOutput.Title
+			issue.Message = fmt.Sprintf("text output '%s': %s", textOutput.Title, issue.Message)
+			add(issue)
+		}
+
+		for _, label := range references {
+			switch label {
+			case "questions":
+				for _, question := range section.QuestionLabels {
+					used[question] = true
+				}
+			case "globalQuestions":
+				for _, question := range globalLabels {
+					l.usedGlobal[question] = true
+				}
+			}
+			used[label] = true
+			if isGlobal[label] {
+				l.usedGlobal[label] = true
+			}
+		}
+	}
+
+	for _, label := range section.QuestionLabels {
+		if label != "" && !used[label] && !l.usedElsewhere[section.Label][label] {
+			add(models.PlaceholderIssue{
+				Kind:     models.UnusedQuestionIssue,
+				Severity: models.WarningSeverity,
+				Message:  fmt.Sprintf("question '%s' is not used by any text output", label),
+				Label:    label,
+			})
+		}
+	}
+
+	// Only **label text is ambiguous when a label starts with another
+	if usesLegacy {
+		labels := make([]string, 0, len(scope))
+		for label := range scope {
+			labels = append(labels, label)
+		}
+		sort.Strings(labels)
+		for _, label := range labels {
+			for _, other := range labels {
+				if other != label && strings.HasPrefix(other, label) {
+					add(models.PlaceholderIssue{
+						Kind:     models.PrefixLabelIssue,
+						Severity: models.WarningSeverity,
+						Message:  fmt.Sprintf("label '%s' starts with label '%s', so **%s is read as '%s'", other, label, other, other),
+						Label:    label,
+					})
+				}
+			}
+		}
+	}
+}
+
+// lintSectionReferences finds the sections with the same label and the cycles of sections
+// referring to each other, and what sections use of the sections they refer to
+func (l *placeholderLinter) lintSectionReferences(item lintItem) {
+	var nodes []sectionReferenceNode
+	for _, section := range item.Sections {
+		ref := models.SectionRef{PartIndex: section.PartIndex, SectionIndex: section.SectionIndex}
+		node := sectionReferenceNode{Ref: ref, Label: section.Label}
+		for _, textOutput := range section.TextOutputs {
+			node.Inputs = append(node.Inputs, textOutput.Input)
+		}
+		nodes = append(nodes, node)
+
+		if section.Label == "" {
+			continue
+		}
+		if _, ok := l.sectionFields[section.Label]; ok {
+			l.add(models.PlaceholderIssue{
+				Kind:     models.DuplicateLabelIssue,
+				Severity: models.ErrorSeverity,
+				Message:  fmt.Sprintf("section label '%s' is used by another section", section.Label),
+				Label:    section.Label,
+			}, section.PartIndex, section.SectionIndex)
+			continue
+		}
+
+		fields := map[string]bool{"label": true, "title": true, "textOutputs": true, "questions": true, "csvData": true, "charts": true}
+		for _, labels := range [][]string{section.QuestionLabels, section.CSVDataLabels, section.ChartLabels} {
+			for _, label := range labels {
+				fields[label] = true
+			}
+		}
+		l.sectionFields[section.Label] = fields
+	}
+
+	// The questions of a section are used when another section refers to them
+	for _, section := range item.Sections {
+		for _, textOutput := range section.TextOutputs {
+			nodes, err := parsePlaceholders(textOutput.Input)
+			if err != nil || !UsesPlaceholderSyntax(textOutput.Input) {
+				continue
+			}
+			for _, path := range placeholderPaths(nodes) {
+				segments := strings.Split(path, ".")
+				if len(segments) < 3 || segments[0] != "sections" {
+					continue
+				}
+				if l.usedElsewhere[segments[1]] == nil {
+					l.usedElsewhere[segments[1]] = make(map[string]bool)
+				}
+				l.usedElsewhere[segments[1]][segments[2]] = true
+				if segments[2] == "questions" {
+					for _, other := range item.Sections {
+						if other.Label == segments[1] {
+							for _, question := range other.QuestionLabels {
+								l.usedElsewhere[segments[1]][question] = true
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+
+	graph := newSectionReferenceGraph(nodes)
+	for _, node := range nodes {
+		cycle := graph.selfCycle(node.Ref)
+		if cycle == nil {
+			continue
+		}
+		l.add(models.PlaceholderIssue{
+			Kind:     models.ReferenceCycleIssue,
+			Severity: models.ErrorSeverity,
+			Message:  graph.describeCycle(cycle),
+			Label:    node.Label,
+		}, node.Ref.PartIndex, node.Ref.SectionIndex)
+	}
+}
+
+// duplicateLabels returns the labels given more than once, in the order they are first repeated
+func duplicateLabels(labels []string) []string {
+	seen := make(map[string]int)
+	var duplicates []string
+	for _, label := range labels {
+		if label == "" {
+			continue
+		}
+		seen[label]++
+		if seen[label] == 2 {
+			duplicates = append(duplicates, label)
+		}
+	}
+	return duplicates
+}
+
+// placeholderFields are the fields of the items of the lists placeholders can loop over
+var placeholderFields = map[string][]string{
+	"questions":       {"label", "question", "answer"},
+	"globalQuestions": {"label", "question", "answer"},
+	"csvData":         {"label", "description", "result"},
+	"charts":          {"label", "title", "description", "table", "csv", "rows", "columns", "max", "min", "total", "top"},
+}
+
+// placeholderReferences returns the labels and lists the placeholders of text refer to,
+// along with its syntax errors and undefined placeholders
+func placeholderReferences(text string, scope map[string]bool, sectionFields map[string]map[string]bool) ([]string, []models.PlaceholderIssue) {
+	nodes, err := parsePlaceholders(text)
+	if err != nil {
+		return nil, []models.PlaceholderIssue{{
+			Kind:     models.InvalidPlaceholderIssue,
+			Severity: models.ErrorSeverity,
+			Message:  err.Error(),
+		}}
+	}
+
+	var references []string
+	var issues []models.PlaceholderIssue
+	var visit func(nodes []placeholderNode, locals []map[string]bool)
+
+	// Paths of other sections are sections.label, then a placeholder of the section
+	resolveSection := func(path string) bool {
+		segments := strings.Split(path, ".")
+		if len(segments) < 2 {
+			issues = append(issues, models.PlaceholderIssue{
+				Kind:     models.InvalidPlaceholderIssue,
+				Severity: models.ErrorSeverity,
+				Message:  fmt.Sprintf("'%s' needs the label of a section, such as sections.summary", path),
+			})
+			return false
+		}
+
+		fields, ok := sectionFields[segments[1]]
+		if !ok {
+			issues = append(issues, models.PlaceholderIssue{
+				Kind:     models.UndefinedPlaceholderIssue,
+				Severity: models.ErrorSeverity,
+				Message:  fmt.Sprintf("no section has the label '%s'", segments[1]),
+				Label:    segments[1],
+			})
+			return false
+		}
+
+		if len(segments) > 2 && !fields[segments[2]] {
+			issues = append(issues, models.PlaceholderIssue{
+				Kind:     models.UndefinedPlaceholderIssue,
+				Severity: models.ErrorSeverity,
+				Message:  fmt.Sprintf("section '%s' has no question, csv data or chart with the label '%s'", segments[1], segments[2]),
+				Label:    segments[2],
+			})
+			return false
+		}
+		return true
+	}
+
+	resolve := func(path string, locals []map[string]bool) (string, bool) {
+		name := path
+		if !scope[path] {
+			name, _, _ = strings.Cut(path, ".")
+		}
+		for _, local := range locals {
+			if local == nil || local[name] {
+				return "", true // A field of a loop item
+			}
+		}
+		if scope[name] || placeholderFields[name] != nil {
+			references = append(references, name)
+			return name, true
+		}
+		if name == "sections" {
+			return "", resolveSection(path)
+		}
+		issues = append(issues, models.PlaceholderIssue{
+			Kind:     models.UndefinedPlaceholderIssue,
+			Severity: models.ErrorSeverity,
+			Message:  fmt.Sprintf("no question, csv data or chart has the label '%s'", name),
+			Label:    name,
+		})
+		return "", false
+	}
+
+	visit = func(nodes []placeholderNode, locals []map[string]bool) {
+		for _, node := range nodes {
+			switch node.Type {
+			case placeholderValue:
+				resolve(node.Path, locals)
+
+			case placeholderIf:
+				resolve(node.Condition.Path, locals)
+				visit(node.Body, locals)
+				visit(node.Else, locals)
+
+			case placeholderEach:
+				name, ok := resolve(node.Path, locals)
+
+				// The fields of the items of lists that are not known are not checked
+				var itemLocals map[string]bool
+				if fields, known := placeholderFields[name]; known && node.Path == name {
+					itemLocals = map[string]bool{"this": true, "@index": true, "@number": true, "@first": true, "@last": true}
+					for _, field := range fields {
+						itemLocals[field] = true
+					}
+				} else if ok && scope[name] && node.Path == name {
+					issues = append(issues, models.PlaceholderIssue{
+						Kind:     models.InvalidPlaceholderIssue,
+						Severity: models.ErrorSeverity,
+						Message:  fmt.Sprintf("{{%s}}: %s is not a list", node.Text, name),
+						Label:    name,
+					})
+				}
+
+				visit(node.Body, append(locals[:len(locals):len(locals)], itemLocals))
+				visit(node.Else, locals)
+			}
+		}
+	}
+	visit(nodes, nil)
+
+	return references, issues
+}
+
+// legacyPlaceholderReferences returns the labels **label text refers to, along with
+// warnings for the words after ** that are not labels. **bold text** closed on the same line is not a
+// placeholder, the renderer leaves it as it is.
+func legacyPlaceholderReferences(text string, scope map[string]bool) ([]string, []models.PlaceholderIssue) {
+	labels := make([]placeholderLabel, 0, len(scope))
+	for label := range scope {
+		labels = append(labels, placeholderLabel{Label: label})
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		return len(labels[i].Label) > len(labels[j].Label)
+	})
+
+	var references []string
+	var issues []models.PlaceholderIssue
+	bold := false // An unmatched ** opened bold text that is not closed yet
+	for {
+		start := strings.Index(text, "**")
+		if start == -1 {
+			return references, issues
+		}
+		if strings.Contains(text[:start], "\n") {
+			bold = false
+		}
+		text = text[start+2:]
+
+		matched := false
+		for _, label := range labels {
+			if strings.HasPrefix(text, label.Label) {
+				references = append(references, label.Label)
+				text = text[len(label.Label):]
+				matched = true
+				break
+			}
+		}
+		if matched {
+			continue
+		}
+
+		if bold {
+			bold = false
+			continue
+		}
+		line, _, _ := strings.Cut(text, "\n")
+		if strings.Contains(line, "**") {
+			bold = true
+			continue
+		}
+
+		word := legacyPlaceholderWord(text)
+		if word == "" {
+			continue
+		}
+		// Unmatched ** is left as it is when rendering, so it only warns
+		issues = append(issues, models.PlaceholderIssue{
+			Kind:     models.UndefinedPlaceholderIssue,
+			Severity: models.WarningSeverity,
+			Message:  fmt.Sprintf("no question, csv data or chart has the label '%s'", word),
+			Label:    word,
+		})
+		text = text[len(word):]
+	}
+}
+
+// legacyPlaceholderWord is the letters, digits and underscores text starts with
+func legacyPlaceholderWord(text string) string {
+	end := 0
+	for end < len(text) {
+		c := text[end]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_') {
+			break
+		}
+		end++
+	}
+	return text[:end]
+}
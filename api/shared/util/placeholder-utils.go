@@ -0,0 +1,754 @@
+package util
+
+import (
+	"api/shared/models"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Text outputs splice the answers of questions and the results of csv data and charts
+// into their input with placeholders:
+//
+//	{{label}}                             the answer or result with the label
+//	{{label | decimals:1 | thousands}}    formatted, see placeholderFilters
+//	{{label | default:"not answered"}}    a fallback for empty values
+//	{{#if label}}...{{else}}...{{/if}}    when the value is not empty
+//	{{#if label > 100}}...{{/if}}         comparisons: == != > >= < <=
+//	{{#each csvData}}{{label}}: {{result}}{{/each}}
+//	{{calls.table}}                       the results of a chart, see chart-placeholder-utils.go
+//	{{sections.summary.avgTravel}}        the answer or result of another section, by its label
+//
+// Lists can be looped over with #each, which also has an {{else}} for empty lists. Inside
+// a loop the fields of the item are placeholders along with @index, @number, @first and
+// @last, and {{this}} is the item itself. A literal {{ is written \{{.
+//
+// Sections with a label are in sections, with the placeholders of their own questions, csv
+// data and charts, and their title and textOutputs, such as
+// {{sections.stations.textOutputs.0.result}}. Sections are generated after the sections
+// they refer to, see section-reference-utils.go.
+//
+// Text without {{ is rendered in the compatibility mode, where **label is replaced by the
+// value of the longest label it starts with, the markdown table of the results of charts.
+
+// placeholderFilters are the formats values can be piped through. Number formats leave
+// values that are not numbers unchanged, as date formats do for values that are not dates.
+var placeholderFilters = map[string]struct {
+	argument string // "none", "optional" or "required"
+	apply    func(value, arg string) string
+}{
+	"decimals":  {"required", formatDecimals},     // decimals:2 gives 1234.50
+	"thousands": {"none", formatThousands},        // 1234567.5 gives 1,234,567.5
+	"percent":   {"optional", formatPercent},      // percent:1 gives 0.1234 as 12.3%
+	"date":      {"required", formatDate},         // date:"MMMM D, YYYY", with the tokens of column date formats
+	"default":   {"required", defaultPlaceholder}, // default:"n/a" replaces empty values
+}
+
+type placeholderNodeType int
+
+const (
+	placeholderText placeholderNodeType = iota
+	placeholderValue
+	placeholderIf
+	placeholderEach
+)
+
+type placeholderNode struct {
+	Type      placeholderNodeType
+	Text      string // Of text nodes, or the tag of the others for errors
+	Path      string
+	Filters   []placeholderFilter
+	Condition placeholderCondition
+	Body      []placeholderNode
+	Else      []placeholderNode
+}
+
+type placeholderFilter struct {
+	Name string
+	Arg  string
+}
+
+type placeholderCondition struct {
+	Path     string
+	Operator string // Empty when the condition is the value not being empty
+	Value    string
+}
+
+// UsesPlaceholderSyntax reports whether text is rendered with {{label}} placeholders
+// rather than in the **label compatibility mode
+func UsesPlaceholderSyntax(text string) bool {
+	return strings.Contains(text, "{{")
+}
+
+// RenderPlaceholders renders the placeholders of text with the questions, csv data and
+// charts of a section, the global questions of its item and the sections with a label
+func RenderPlaceholders(text string, section *models.ReportSection, globalQuestions []models.ReportQuestion, sections map[string]*models.ReportSection) (string, error) {
+	data := newPlaceholderData(section, globalQuestions, sections)
+
+	if !UsesPlaceholderSyntax(text) {
+		return renderLegacyPlaceholders(text, data.labels), nil
+	}
+
+	nodes, err := parsePlaceholders(text)
+	if err != nil {
+		return "", err
+	}
+
+	var result strings.Builder
+	err = renderPlaceholderNodes(&result, nodes, []map[string]interface{}{data.scope})
+	if err != nil {
+		return "", err
+	}
+	return result.String(), nil
+}
+
+// placeholderData is what placeholders can refer to. Values are strings, lists of
+// values or maps of fields to values, along with the rankings of charts and the sections.
+type placeholderData struct {
+	labels []placeholderLabel // In the order they are replaced in the compatibility mode
+	scope  map[string]interface{}
+}
+
+type placeholderLabel struct {
+	Label string
+	Value string
+}
+
+// newPlaceholderData has the labels of the section questions, then of the csv data, then
+// of the charts and then of the global questions, the first one winning when labels are
+// the same. The lists of each are questions, csvData, charts and globalQuestions, and the
+// other sections are sections, unless a label has the name.
+func newPlaceholderData(section *models.ReportSection, globalQuestions []models.ReportQuestion, sections map[string]*models.ReportSection) placeholderData {
+	data := placeholderData{scope: make(map[string]interface{})}
+	addLabel := func(label, value string, fields interface{}) {
+		if _, ok := data.scope[label]; ok || label == "" {
+			return
+		}
+		data.labels = append(data.labels, placeholderLabel{Label: label, Value: value})
+		data.scope[label] = fields
+	}
+
+	var questions, csvData, charts, global []interface{}
+	for _, question := range section.Questions {
+		addLabel(question.Label, question.Answer, question.Answer)
+		questions = append(questions, questionPlaceholderFields(question))
+	}
+	for _, csv := range section.CSVData {
+		addLabel(csv.Label, csv.Result, csv.Result)
+		csvData = append(csvData, map[string]interface{}{
+			"label":       csv.Label,
+			"description": csv.Description,
+			"result":      csv.Result,
+		})
+	}
+	for _, chart := range section.ChartOutputs {
+		fields := chartPlaceholderFields(chart)
+		addLabel(chart.Label, fields["table"].(string), fields)
+		charts = append(charts, fields)
+	}
+	for _, question := range globalQuestions {
+		addLabel(question.Label, question.Answer, question.Answer)
+		global = append(global, questionPlaceholderFields(question))
+	}
+
+	for name, list := range map[string][]interface{}{"questions": questions, "csvData": csvData, "charts": charts, "globalQuestions": global} {
+		if _, ok := data.scope[name]; !ok {
+			data.scope[name] = list
+		}
+	}
+	if _, ok := data.scope["sections"]; !ok {
+		data.scope["sections"] = placeholderSections(sections)
+	}
+
+	return data
+}
+
+// placeholderSections are the sections with a label. The placeholders of a section are
+// only read when a placeholder refers to it, as other sections may be generating.
+type placeholderSections map[string]*models.ReportSection
+
+// sectionPlaceholderFields are the placeholders of a section referred to from another
+func sectionPlaceholderFields(section *models.ReportSection) map[string]interface{} {
+	fields := newPlaceholderData(section, nil, nil).scope
+	delete(fields, "sections")
+
+	var textOutputs []interface{}
+	for _, textOutput := range section.TextOutputs {
+		textOutputs = append(textOutputs, map[string]interface{}{
+			"title":  textOutput.Title,
+			"result": textOutput.Result,
+		})
+	}
+
+	for name, value := range map[string]interface{}{"label": section.Label, "title": section.Title, "textOutputs": textOutputs} {
+		if _, ok := fields[name]; !ok {
+			fields[name] = value
+		}
+	}
+	return fields
+}
+
+func questionPlaceholderFields(question models.ReportQuestion) map[string]interface{} {
+	return map[string]interface{}{
+		"label":    question.Label,
+		"question": question.Question,
+		"answer":   question.Answer,
+	}
+}
+
+// renderLegacyPlaceholders replaces **label with the value of the longest label it starts
+// with, in one pass so that values are never replaced themselves
+func renderLegacyPlaceholders(text string, labels []placeholderLabel) string {
+	sorted := make([]placeholderLabel, len(labels))
+	copy(sorted, labels)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return len(sorted[i].Label) > len(sorted[j].Label)
+	})
+
+	var result strings.Builder
+	for {
+		start := strings.Index(text, "**")
+		if start == -1 {
+			result.WriteString(text)
+			return result.String()
+		}
+
+		result.WriteString(text[:start])
+		text = text[start+2:]
+
+		matched := false
+		for _, label := range sorted {
+			if strings.HasPrefix(text, label.Label) {
+				result.WriteString(label.Value)
+				text = text[len(label.Label):]
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			result.WriteString("**")
+		}
+	}
+}
+
+// parsePlaceholders parses text into its text and placeholders, checking that every
+// block is closed and every filter is known
+func parsePlaceholders(text string) ([]placeholderNode, error) {
+	tokens, err := tokenizePlaceholders(text)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, next, closer, err := parsePlaceholderNodes(tokens, 0)
+	if err != nil {
+		return nil, err
+	}
+	if next < len(tokens) || closer != "" {
+		return nil, fmt.Errorf("unexpected {{%s}}", closer)
+	}
+	return nodes, nil
+}
+
+type placeholderToken struct {
+	Tag  bool
+	Text string
+}
+
+func tokenizePlaceholders(text string) ([]placeholderToken, error) {
+	var tokens []placeholderToken
+	var literal strings.Builder
+	for {
+		start := strings.Index(text, "{{")
+		if start == -1 {
+			literal.WriteString(text)
+			break
+		}
+
+		// An escaped {{ is written as is
+		if start > 0 && text[start-1] == '\\' {
+			literal.WriteString(text[:start-1])
+			literal.WriteString("{{")
+			text = text[start+2:]
+			continue
+		}
+
+		end := strings.Index(text[start+2:], "}}")
+		if end == -1 {
+			return nil, fmt.Errorf("placeholder is not closed: %s", abbreviate(text[start:]))
+		}
+
+		literal.WriteString(text[:start])
+		if literal.Len() > 0 {
+			tokens = append(tokens, placeholderToken{Text: literal.String()})
+			literal.Reset()
+		}
+
+		tag := strings.TrimSpace(text[start+2 : start+2+end])
+		if tag == "" {
+			return nil, fmt.Errorf("empty placeholder {{}}")
+		}
+		tokens = append(tokens, placeholderToken{Tag: true, Text: tag})
+		text = text[start+2+end+2:]
+	}
+
+	if literal.Len() > 0 {
+		tokens = append(tokens, placeholderToken{Text: literal.String()})
+	}
+	return tokens, nil
+}
+
+// parsePlaceholderNodes parses tokens from i until the end or a closing tag: else, /if or
+// /each. Returns the nodes, the index after the closing tag and the closing tag.
+func parsePlaceholderNodes(tokens []placeholderToken, i int) ([]placeholderNode, int, string, error) {
+	var nodes []placeholderNode
+	for i < len(tokens) {
+		token := tokens[i]
+		i++
+
+		if !token.Tag {
+			nodes = append(nodes, placeholderNode{Type: placeholderText, Text: token.Text})
+			continue
+		}
+
+		switch {
+		case token.Text == "else" || token.Text == "/if" || token.Text == "/each":
+			return nodes, i, token.Text, nil
+
+		case strings.HasPrefix(token.Text, "#if ") || strings.HasPrefix(token.Text, "#each "):
+			node, next, err := parsePlaceholderBlock(tokens, i, token.Text)
+			if err != nil {
+				return nil, 0, "", err
+			}
+			nodes = append(nodes, node)
+			i = next
+
+		case strings.HasPrefix(token.Text, "#") || strings.HasPrefix(token.Text, "/"):
+			return nil, 0, "", fmt.Errorf("unknown block {{%s}}", token.Text)
+
+		default:
+			node, err := parsePlaceholderValue(token.Text)
+			if err != nil {
+				return nil, 0, "", err
+			}
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes, i, "", nil
+}
+
+// parsePlaceholderBlock parses an #if or #each block, from the tokens after its tag
+func parsePlaceholderBlock(tokens []placeholderToken, i int, tag string) (placeholderNode, int, error) {
+	keyword, expression, _ := strings.Cut(tag, " ")
+	expression = strings.TrimSpace(expression)
+	node := placeholderNode{Text: tag}
+	closer := "/" + keyword[1:]
+
+	if keyword == "#if" {
+		node.Type = placeholderIf
+		condition, err := parsePlaceholderCondition(expression)
+		if err != nil {
+			return placeholderNode{}, 0, fmt.Errorf("{{%s}}: %v", tag, err)
+		}
+		node.Condition = condition
+	} else {
+		node.Type = placeholderEach
+		node.Path = expression
+		if node.Path == "" {
+			return placeholderNode{}, 0, fmt.Errorf("{{%s}}: no list to loop over", tag)
+		}
+	}
+
+	body, next, end, err := parsePlaceholderNodes(tokens, i)
+	if err != nil {
+		return placeholderNode{}, 0, err
+	}
+	node.Body = body
+
+	if end == "else" {
+		node.Else, next, end, err = parsePlaceholderNodes(tokens, next)
+		if err != nil {
+			return placeholderNode{}, 0, err
+		}
+	}
+
+	if end != closer {
+		return placeholderNode{}, 0, fmt.Errorf("{{%s}} is not closed with {{%s}}", tag, closer)
+	}
+	return node, next, nil
+}
+
+var placeholderOperators = []string{"==", "!=", ">=", "<=", ">", "<"}
+
+func parsePlaceholderCondition(expression string) (placeholderCondition, error) {
+	for i := 0; i < len(expression); i++ {
+		if expression[i] == '"' {
+			break // Operators come before the quoted value
+		}
+		for _, operator := range placeholderOperators {
+			if strings.HasPrefix(expression[i:], operator) {
+				condition := placeholderCondition{
+					Path:     strings.TrimSpace(expression[:i]),
+					Operator: operator,
+					Value:    unquotePlaceholderArg(strings.TrimSpace(expression[i+len(operator):])),
+				}
+				if condition.Path == "" {
+					return placeholderCondition{}, fmt.Errorf("no value to compare")
+				}
+				return condition, nil
+			}
+		}
+	}
+
+	if expression == "" {
+		return placeholderCondition{}, fmt.Errorf("no condition")
+	}
+	return placeholderCondition{Path: expression}, nil
+}
+
+func parsePlaceholderValue(tag string) (placeholderNode, error) {
+	parts := splitPlaceholderFilters(tag)
+	node := placeholderNode{Type: placeholderValue, Text: tag, Path: strings.TrimSpace(parts[0])}
+	if node.Path == "" {
+		return placeholderNode{}, fmt.Errorf("{{%s}}: no label", tag)
+	}
+
+	for _, part := range parts[1:] {
+		name, arg, hasArg := strings.Cut(strings.TrimSpace(part), ":")
+		name = strings.TrimSpace(name)
+
+		filter, ok := placeholderFilters[name]
+		if !ok {
+			return placeholderNode{}, fmt.Errorf("{{%s}}: unknown format '%s'", tag, name)
+		}
+		if hasArg && filter.argument == "none" {
+			return placeholderNode{}, fmt.Errorf("{{%s}}: format '%s' takes no argument", tag, name)
+		}
+		if !hasArg && filter.argument == "required" {
+			return placeholderNode{}, fmt.Errorf("{{%s}}: format '%s' needs an argument", tag, name)
+		}
+
+		arg = unquotePlaceholderArg(strings.TrimSpace(arg))
+		if (name == "decimals" || name == "percent") && arg != "" {
+			if decimals, err := strconv.Atoi(arg); err != nil || decimals < 0 || decimals > 10 {
+				return placeholderNode{}, fmt.Errorf("{{%s}}: %s must be a number of decimals from 0 to 10", tag, name)
+			}
+		}
+
+		node.Filters = append(node.Filters, placeholderFilter{Name: name, Arg: arg})
+	}
+	return node, nil
+}
+
+// splitPlaceholderFilters splits a placeholder at the pipes that are not quoted
+func splitPlaceholderFilters(tag string) []string {
+	var parts []string
+	quoted := false
+	start := 0
+	for i := 0; i < len(tag); i++ {
+		switch tag[i] {
+		case '"':
+			quoted = !quoted
+		case '|':
+			if !quoted {
+				parts = append(parts, tag[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, tag[start:])
+}
+
+func unquotePlaceholderArg(arg string) string {
+	if len(arg) >= 2 && strings.HasPrefix(arg, `"`) && strings.HasSuffix(arg, `"`) {
+		return arg[1 : len(arg)-1]
+	}
+	return arg
+}
+
+// renderPlaceholderNodes writes the nodes with the values of the scopes, the innermost
+// scope being last
+func renderPlaceholderNodes(result *strings.Builder, nodes []placeholderNode, scopes []map[string]interface{}) error {
+	for _, node := range nodes {
+		switch node.Type {
+		case placeholderText:
+			result.WriteString(node.Text)
+
+		case placeholderValue:
+			value, err := lookupPlaceholder(node.Path, scopes)
+			if err != nil {
+				return err
+			}
+			text, ok := value.(string)
+			if !ok {
+				return fmt.Errorf("{{%s}} is a list or an item, not a value", node.Text)
+			}
+			for _, filter := range node.Filters {
+				text = placeholderFilters[filter.Name].apply(text, filter.Arg)
+			}
+			result.WriteString(text)
+
+		case placeholderIf:
+			matched, err := evaluatePlaceholderCondition(node.Condition, scopes)
+			if err != nil {
+				return fmt.Errorf("{{%s}}: %v", node.Text, err)
+			}
+			body := node.Else
+			if matched {
+				body = node.Body
+			}
+			err = renderPlaceholderNodes(result, body, scopes)
+			if err != nil {
+				return err
+			}
+
+		case placeholderEach:
+			value, err := lookupPlaceholder(node.Path, scopes)
+			if err != nil {
+				return err
+			}
+			items, ok := placeholderList(value)
+			if !ok {
+				return fmt.Errorf("{{%s}}: %s is not a list", node.Text, node.Path)
+			}
+
+			if len(items) == 0 {
+				err = renderPlaceholderNodes(result, node.Else, scopes)
+				if err != nil {
+					return err
+				}
+			}
+
+			for i, item := range items {
+				itemScope := map[string]interface{}{
+					"this":    item,
+					"@index":  strconv.Itoa(i),
+					"@number": strconv.Itoa(i + 1),
+					"@first":  boolPlaceholder(i == 0),
+					"@last":   boolPlaceholder(i == len(items)-1),
+				}
+				if fields, ok := item.(map[string]interface{}); ok {
+					for name, field := range fields {
+						itemScope[name] = field
+					}
+				}
+
+				err = renderPlaceholderNodes(result, node.Body, append(scopes[:len(scopes):len(scopes)], itemScope))
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// boolPlaceholder is a value that is true in an #if when it is not empty
+func boolPlaceholder(value bool) string {
+	if value {
+		return "true"
+	}
+	return ""
+}
+
+// lookupPlaceholder finds the value of a path in the innermost scope that has it. Paths
+// are labels or fields, or the fields and indexes of one separated by dots.
+func lookupPlaceholder(path string, scopes []map[string]interface{}) (interface{}, error) {
+	for i := len(scopes) - 1; i >= 0; i-- {
+		if value, ok := scopes[i][path]; ok {
+			return value, nil
+		}
+	}
+
+	segments := strings.Split(path, ".")
+	var value interface{}
+	found := false
+	for i := len(scopes) - 1; i >= 0 && !found; i-- {
+		value, found = scopes[i][segments[0]]
+	}
+	if !found {
+		return nil, fmt.Errorf("unknown placeholder '%s'", path)
+	}
+
+	for _, segment := range segments[1:] {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			value, found = v[segment]
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			found = err == nil && index >= 0 && index < len(v)
+			if found {
+				value = v[index]
+			}
+		case placeholderSections:
+			var section *models.ReportSection
+			section, found = v[segment]
+			if found {
+				value = sectionPlaceholderFields(section)
+			}
+		case placeholderRanking:
+			count, err := strconv.Atoi(segment)
+			found = err == nil && count >= 0
+			if found {
+				value = []interface{}(v[:min(count, len(v))])
+			}
+		default:
+			found = false
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown placeholder '%s'", path)
+		}
+	}
+	return value, nil
+}
+
+// placeholderList is the items of a value that can be looped over
+func placeholderList(value interface{}) ([]interface{}, bool) {
+	switch v := value.(type) {
+	case []interface{}:
+		return v, true
+	case placeholderRanking:
+		return v, true
+	default:
+		return nil, false
+	}
+}
+
+// evaluatePlaceholderCondition compares values as numbers when both are numbers, and as
+// text otherwise. Without an operator, the condition is the value or list not being empty.
+func evaluatePlaceholderCondition(condition placeholderCondition, scopes []map[string]interface{}) (bool, error) {
+	value, err := lookupPlaceholder(condition.Path, scopes)
+	if err != nil {
+		return false, err
+	}
+
+	if condition.Operator == "" {
+		switch v := value.(type) {
+		case string:
+			return strings.TrimSpace(v) != "", nil
+		case []interface{}:
+			return len(v) > 0, nil
+		case placeholderRanking:
+			return len(v) > 0, nil
+		default:
+			return value != nil, nil
+		}
+	}
+
+	text, ok := value.(string)
+	if !ok {
+		return false, fmt.Errorf("%s is a list or an item, not a value", condition.Path)
+	}
+
+	var comparison int
+	left, leftIsNumber := parseNumericalValue(text, ".")
+	right, rightIsNumber := parseNumericalValue(condition.Value, ".")
+	if leftIsNumber && rightIsNumber {
+		comparison = compareFloats(left, right)
+	} else {
+		comparison = strings.Compare(strings.TrimSpace(text), condition.Value)
+	}
+
+	switch condition.Operator {
+	case "==":
+		return comparison == 0, nil
+	case "!=":
+		return comparison != 0, nil
+	case ">":
+		return comparison > 0, nil
+	case ">=":
+		return comparison >= 0, nil
+	case "<":
+		return comparison < 0, nil
+	default:
+		return comparison <= 0, nil
+	}
+}
+
+func compareFloats(a, b float64) int {
+	if a < b {
+		return -1
+	}
+	if a > b {
+		return 1
+	}
+	return 0
+}
+
+func formatDecimals(value, arg string) string {
+	number, ok := parseNumericalValue(value, ".")
+	if !ok {
+		return value
+	}
+	decimals, _ := strconv.Atoi(arg)
+	return strconv.FormatFloat(number, 'f', decimals, 64)
+}
+
+// formatThousands separates the thousands of a number with commas, keeping its decimals
+func formatThousands(value, _ string) string {
+	trimmed := strings.TrimSpace(value)
+	if _, err := strconv.ParseFloat(trimmed, 64); err != nil {
+		return value
+	}
+
+	sign := ""
+	if strings.HasPrefix(trimmed, "-") || strings.HasPrefix(trimmed, "+") {
+		sign, trimmed = trimmed[:1], trimmed[1:]
+	}
+	integer, fraction, hasFraction := strings.Cut(trimmed, ".")
+	if strings.ContainsAny(integer, "eE") {
+		return value
+	}
+
+	var grouped strings.Builder
+	for i, digit := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			grouped.WriteByte(',')
+		}
+		grouped.WriteRune(digit)
+	}
+
+	if hasFraction {
+		return sign + grouped.String() + "." + fraction
+	}
+	return sign + grouped.String()
+}
+
+// formatPercent writes a fraction as a percent, with no decimals unless they are given
+func formatPercent(value, arg string) string {
+	number, ok := parseNumericalValue(value, ".")
+	if !ok || strings.Contains(value, "%") {
+		return value
+	}
+	decimals, _ := strconv.Atoi(arg)
+	percent := number * 100
+	if math.Abs(percent) < 0.5*math.Pow10(-decimals) {
+		percent = 0 // No -0%
+	}
+	return strconv.FormatFloat(percent, 'f', decimals, 64) + "%"
+}
+
+func formatDate(value, format string) string {
+	date, ok := parseTimeValue(value, commonDateLayouts, time.UTC)
+	if !ok {
+		return value
+	}
+	return date.Format(dateLayouts(format)[0])
+}
+
+func defaultPlaceholder(value, fallback string) string {
+	if strings.TrimSpace(value) == "" {
+		return fallback
+	}
+	return value
+}
+
+// abbreviate shortens text for an error message
+func abbreviate(text string) string {
+	if len(text) > 40 {
+		return text[:40] + "..."
+	}
+	return text
+}
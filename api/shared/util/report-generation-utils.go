@@ -0,0 +1,434 @@
+package util
+
+import (
+	"api/shared/constants"
+	"api/shared/models"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Sections generate side by side, each making its own generator requests, so the
+// number running at once is bounded to stay under the rate limits of the generator
+const maxConcurrentSectionGenerations = 4
+
+// StartReportGeneration creates the operation of a report generation and starts the task
+// generating the sections in the background. When no sections are given, every section
+// of the report is generated. Returns the id of the operation to poll.
+func StartReportGeneration(reportID string, sections []models.SectionRef, generateAIOutput bool, userID string) (string, error) {
+	report, err := GetReport(reportID, userID)
+	if err != nil {
+		return "", fmt.Errorf("error getting report from DynamoDB: %v", err)
+	}
+
+	if report == nil {
+		return "", fmt.Errorf("report not found")
+	}
+
+	sections, err = ReportSectionRefs(report, sections)
+	if err != nil {
+		return "", err
+	}
+
+	operationID := uuid.New().String()
+	err = CreateOperation(operationID, models.ReportGenerationOperation, reportID, userID)
+	if err != nil {
+		return "", fmt.Errorf("error creating operation: %v", err)
+	}
+
+	err = InvokeLambdaAsync(os.Getenv(constants.ReportGenerationLambdaName), models.ReportGenerationJob{
+		OperationID:      operationID,
+		ReportID:         reportID,
+		UserID:           userID,
+		Sections:         sections,
+		GenerateAIOutput: generateAIOutput,
+	})
+	if err != nil {
+		// Stop the operation from being polled until it expires
+		failErr := FailOperation(operationID, "report generation could not be started", nil)
+		if failErr != nil {
+			log.Printf("error setting operation failed: %v", failErr)
+		}
+		return "", fmt.Errorf("error starting report generation: %v", err)
+	}
+
+	return operationID, nil
+}
+
+// ReportSectionRefs checks that the sections are in the report and are each given once.
+// When none are given, it returns every section of the report in order.
+func ReportSectionRefs(report *models.Report, sections []models.SectionRef) ([]models.SectionRef, error) {
+	if len(sections) == 0 {
+		for p, part := range report.Parts {
+			for s := range part.Sections {
+				sections = append(sections, models.SectionRef{PartIndex: p, SectionIndex: s})
+			}
+		}
+		if len(sections) == 0 {
+			return nil, fmt.Errorf("report has no sections to generate")
+		}
+		return sections, nil
+	}
+
+	seen := make(map[models.SectionRef]bool)
+	for _, ref := range sections {
+		if ref.PartIndex < 0 || ref.SectionIndex < 0 {
+			return nil, fmt.Errorf("section %s not found", sectionRefKey(ref))
+		}
+		if _, err := GetReportSection(report, ref.PartIndex, ref.SectionIndex); err != nil {
+			return nil, fmt.Errorf("section %s: %v", sectionRefKey(ref), err)
+		}
+		if seen[ref] {
+			return nil, fmt.Errorf("section %s is given more than once", sectionRefKey(ref))
+		}
+		seen[ref] = true
+	}
+	return sections, nil
+}
+
+// sectionRefKey is the key of the operation step of a section
+func sectionRefKey(ref models.SectionRef) string {
+	return strconv.Itoa(ref.PartIndex) + "." + strconv.Itoa(ref.SectionIndex)
+}
+
+// RunReportGeneration generates the sections of a report generation job, saving each
+// section once it is generated and recording its progress on the operation of the job.
+// Every dataset the sections use is downloaded and read once for all of them. The job
+// stops starting sections once the operation is cancelled.
+func RunReportGeneration(ctx context.Context, job models.ReportGenerationJob) error {
+	report, err := GetReport(job.ReportID, job.UserID)
+	if err != nil {
+		return failReportGeneration(job.OperationID, fmt.Errorf("error getting report from DynamoDB: %v", err))
+	}
+
+	if report == nil {
+		return failReportGeneration(job.OperationID, fmt.Errorf("report not found"))
+	}
+
+	sections, err := ReportSectionRefs(report, job.Sections)
+	if err != nil {
+		return failReportGeneration(job.OperationID, err)
+	}
+
+	// The usage of the generator requests of every section is recorded for the user
+	usage := models.UsageContext{UserID: job.UserID, ReportID: job.ReportID}
+	if job.GenerateAIOutput {
+		usage.OrganizationID, err = GetUserOrganization(job.UserID)
+		if err != nil {
+			return failReportGeneration(job.OperationID, fmt.Errorf("error getting user organization: %v", err))
+		}
+	}
+
+	progress := newReportGenerationProgress(job.OperationID, report, sections)
+	err = StartOperation(job.OperationID, progress.progress)
+	if err == ErrOperationFinished {
+		log.Print("Report generation was cancelled before it started")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// Sections in or referring to a cycle of references can not be ordered, and sections
+	// that can not be analysed are not generated
+	graph := reportSectionReferenceGraph(report)
+	for i, ref := range sections {
+		if err := graph.cycleError(ref); err != nil {
+			progress.finish(i, err)
+		}
+	}
+	for i, err := range analyzeReportSections(report, sections) {
+		if !progress.isFinished(i) {
+			progress.finish(i, err)
+		}
+	}
+
+	// Each section waits for the sections of the job it refers to, which are generated
+	// first. The others are used as they were last generated.
+	dependencies := sectionDependencies(graph, sections)
+	done := make([]chan struct{}, len(sections))
+	for i := range sections {
+		done[i] = make(chan struct{})
+	}
+
+	semaphore := make(chan struct{}, maxConcurrentSectionGenerations)
+	sectionsByLabel := ReportSectionsByLabel(report)
+	var wg sync.WaitGroup
+	for i, ref := range sections {
+		wg.Add(1)
+		go func(i int, ref models.SectionRef) {
+			defer wg.Done()
+			defer close(done[i])
+
+			// Sections in a cycle have already failed, and do not wait for each other
+			if progress.isFinished(i) {
+				return
+			}
+			for _, dependency := range dependencies[i] {
+				<-done[dependency]
+			}
+			if progress.isCancelled() {
+				return
+			}
+			for _, dependency := range dependencies[i] {
+				if !progress.isSucceeded(dependency) {
+					referenced := sections[dependency]
+					progress.finish(i, fmt.Errorf("referenced section '%s' was not generated", report.Parts[referenced.PartIndex].Sections[referenced.SectionIndex].Title))
+					return
+				}
+			}
+
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+			if progress.isCancelled() {
+				return
+			}
+
+			progress.start(i)
+			progress.finish(i, generateReportSection(ctx, report, ref, sectionsByLabel, job.GenerateAIOutput, usage))
+		}(i, ref)
+	}
+	wg.Wait()
+
+	return progress.complete()
+}
+
+// sectionDependencies returns the sections of the job each section refers to, by their
+// index in the sections given
+func sectionDependencies(graph sectionReferenceGraph, sections []models.SectionRef) [][]int {
+	indexes := make(map[models.SectionRef]int)
+	for i, ref := range sections {
+		indexes[ref] = i
+	}
+
+	dependencies := make([][]int, len(sections))
+	for i, ref := range sections {
+		for _, reference := range graph.references[ref] {
+			if j, ok := indexes[reference]; ok && j != i {
+				dependencies[i] = append(dependencies[i], j)
+			}
+		}
+	}
+	return dependencies
+}
+
+// failReportGeneration fails the operation of a job that could not start generating
+func failReportGeneration(operationID string, err error) error {
+	failErr := FailOperation(operationID, err.Error(), nil)
+	if failErr != nil && failErr != ErrOperationFinished {
+		return fmt.Errorf("error setting operation failed: %v", failErr)
+	}
+	return err
+}
+
+// analyzeReportSections computes the csv data and chart output results of the sections,
+// reading each dataset once for every section using it. Returns the errors of the
+// sections that could not be analysed, by their index in the sections given.
+func analyzeReportSections(report *models.Report, sections []models.SectionRef) map[int]error {
+	failed := make(map[int]error)
+
+	// The sections using each dataset, in the order the datasets are first used
+	var datasetNames []string
+	datasetSections := make(map[string][]int)
+	for i, ref := range sections {
+		section := &report.Parts[ref.PartIndex].Sections[ref.SectionIndex]
+		for _, name := range sectionDatasetNames(section) {
+			if _, ok := datasetSections[name]; !ok {
+				datasetNames = append(datasetNames, name)
+			}
+			datasetSections[name] = append(datasetSections[name], i)
+		}
+	}
+
+	for _, name := range datasetNames {
+		indexes := datasetSections[name]
+
+		csvFile, dataset, err := openReportDataset(report, name)
+		if err != nil {
+			for _, i := range indexes {
+				failed[i] = fmt.Errorf("error generating section data results: %v", err)
+			}
+			continue
+		}
+
+		var analyzed []*models.ReportSection
+		for _, i := range indexes {
+			if failed[i] == nil {
+				ref := sections[i]
+				analyzed = append(analyzed, &report.Parts[ref.PartIndex].Sections[ref.SectionIndex])
+			}
+		}
+
+		err = AnalyzeSectionData(csvFile, dataset, analyzed...)
+		if err != nil {
+			// Analyse the sections one at a time to find the ones that fail, so the others
+			// are still generated
+			for _, i := range indexes {
+				if failed[i] != nil {
+					continue
+				}
+				ref := sections[i]
+				err = AnalyzeSectionData(csvFile, dataset, &report.Parts[ref.PartIndex].Sections[ref.SectionIndex])
+				if err != nil {
+					failed[i] = fmt.Errorf("error generating section data results: %v", err)
+				}
+			}
+		}
+
+		csvFile.Close()
+	}
+
+	return failed
+}
+
+// generateReportSection generates the text outputs of an analysed section and saves it,
+// recording the usage of its generator requests in the usage context of the job
+func generateReportSection(ctx context.Context, report *models.Report, ref models.SectionRef, sections map[string]*models.ReportSection, generateAIOutput bool, usage models.UsageContext) error {
+	section := &report.Parts[ref.PartIndex].Sections[ref.SectionIndex]
+
+	// Reset the text output results so that they can be created from input again
+	ResetTextOutputResults(section, generateAIOutput)
+
+	GenerateSectionStaticText(section, &report.GlobalQuestions, sections)
+
+	if generateAIOutput {
+		usage.PartIndex, usage.SectionIndex, usage.SectionTitle = ref.PartIndex, ref.SectionIndex, section.Title
+		err := GenerateSectionGeneratorText(ctx, NewMeteredRegistry(usage), section, &report.GlobalQuestions, sections, &report.GenerationOptions)
+		if err != nil {
+			return fmt.Errorf("error creating generator outputs: %v", err)
+		}
+	}
+
+	section.OutputGenerated = true
+
+	return saveReportSection(report.ReportID, ref, section)
+}
+
+// reportGenerationProgress records the state of each section of a report generation on
+// its operation. Sections finish concurrently, so updates are made one at a time.
+type reportGenerationProgress struct {
+	mu          sync.Mutex
+	operationID string
+	progress    models.OperationProgress
+	generated   int
+	failed      int
+	cancelled   bool
+}
+
+func newReportGenerationProgress(operationID string, report *models.Report, sections []models.SectionRef) *reportGenerationProgress {
+	steps := make([]models.OperationStep, len(sections))
+	for i, ref := range sections {
+		steps[i] = models.OperationStep{
+			Key:   sectionRefKey(ref),
+			Name:  report.Parts[ref.PartIndex].Sections[ref.SectionIndex].Title,
+			State: models.OperationPending,
+		}
+	}
+
+	return &reportGenerationProgress{
+		operationID: operationID,
+		progress: models.OperationProgress{
+			Step:       "Analysing datasets",
+			TotalSteps: len(sections),
+			Steps:      steps,
+		},
+	}
+}
+
+func (p *reportGenerationProgress) isFinished(i int) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.progress.Steps[i].State.IsFinished()
+}
+
+func (p *reportGenerationProgress) isSucceeded(i int) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.progress.Steps[i].State == models.OperationSucceeded
+}
+
+func (p *reportGenerationProgress) isCancelled() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.cancelled
+}
+
+// start marks a section as generating
+func (p *reportGenerationProgress) start(i int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.progress.Steps[i].State = models.OperationRunning
+	p.progress.Step = "Generating " + p.progress.Steps[i].Name
+	p.update()
+}
+
+// finish marks a section as generated, or as failed with the error it failed with
+func (p *reportGenerationProgress) finish(i int, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	step := &p.progress.Steps[i]
+	if err != nil {
+		log.Printf("error generating section %s: %v", step.Key, err)
+		step.State = models.OperationFailed
+		step.Error = err.Error()
+		p.failed++
+	} else {
+		step.State = models.OperationSucceeded
+		p.generated++
+	}
+
+	p.progress.CompletedSteps = p.generated + p.failed
+	p.progress.Percent = p.progress.CompletedSteps * 100 / p.progress.TotalSteps
+	p.update()
+}
+
+// update stores the progress on the operation. Failing to store it does not stop the
+// generation, but the operation being finished means it was cancelled.
+func (p *reportGenerationProgress) update() {
+	if p.cancelled {
+		return
+	}
+
+	err := UpdateOperationProgress(p.operationID, p.progress)
+	if err == ErrOperationFinished {
+		log.Print("Report generation was cancelled")
+		p.cancelled = true
+	} else if err != nil {
+		log.Printf("error updating operation progress: %v", err)
+	}
+}
+
+// complete finishes the operation once every section has been generated or has failed.
+// The operation only fails when no section could be generated.
+func (p *reportGenerationProgress) complete() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.cancelled {
+		return nil
+	}
+
+	var err error
+	if p.generated == 0 {
+		err = FailOperation(p.operationID, "no section could be generated", nil)
+	} else {
+		err = CompleteOperation(p.operationID, &models.OperationResult{
+			ReportGeneration: &models.ReportGenerationResult{
+				GeneratedSections: p.generated,
+				FailedSections:    p.failed,
+			},
+		})
+	}
+
+	if err != nil && err != ErrOperationFinished {
+		return fmt.Errorf("error finishing operation: %v", err)
+	}
+	return nil
+}
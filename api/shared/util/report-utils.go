@@ -221,6 +221,11 @@ func ConvertReportToTemplate(reportID, templateTitle, userID string) error {
 		return fmt.Errorf("report not found: %v", err)
 	}
 
+	err = ItemPlaceholderErrors(LintReportPlaceholders(report))
+	if err != nil {
+		return err
+	}
+
 	ownerNickName, err := GetUserNickname(userID)
 
 	if err != nil {
@@ -240,8 +245,9 @@ func ConvertReportToTemplate(reportID, templateTitle, userID string) error {
 		CreatedAt:      GetCurrentTime(),
 		LastModifiedAt: GetCurrentTime(),
 		// Create empty parts for filling
-		Parts:           make([]models.TemplatePart, 0),
-		GlobalQuestions: make([]models.TemplateQuestion, len(report.GlobalQuestions)),
+		Parts:             make([]models.TemplatePart, 0),
+		GlobalQuestions:   make([]models.TemplateQuestion, len(report.GlobalQuestions)),
+		GenerationOptions: report.GenerationOptions,
 	}
 
 	var templateParts []models.TemplatePart
@@ -266,11 +272,13 @@ func ConvertReportToTemplate(reportID, templateTitle, userID string) error {
 		// Iterate through each section in reportPart
 		for _, reportSection := range reportPart.Sections {
 			templateSection := models.TemplateSection{
-				Title:        reportSection.Title,
-				Questions:    make([]models.TemplateQuestion, len(reportSection.Questions)),
-				CSVData:      make([]models.TemplateCSVData, len(reportSection.CSVData)),
-				TextOutputs:  make([]models.TemplateTextOutput, len(reportSection.TextOutputs)),
-				ChartOutputs: make([]models.TemplateChartOutput, len(reportSection.ChartOutputs)),
+				Title:         reportSection.Title,
+				Label:         reportSection.Label,
+				Questions:     make([]models.TemplateQuestion, len(reportSection.Questions)),
+				CSVData:       make([]models.TemplateCSVData, len(reportSection.CSVData)),
+				TextOutputs:   make([]models.TemplateTextOutput, len(reportSection.TextOutputs)),
+				ChartOutputs:  make([]models.TemplateChartOutput, len(reportSection.ChartOutputs)),
+				FailurePolicy: reportSection.FailurePolicy,
 			}
 
 			// Convert ReportQuestions to TemplateQuestions
@@ -284,18 +292,24 @@ func ConvertReportToTemplate(reportID, templateTitle, userID string) error {
 			// Convert ReportTextOutputs to TemplateTextOutputs
 			for k, textOutput := range reportSection.TextOutputs {
 				templateSection.TextOutputs[k] = models.TemplateTextOutput{
-					Title: textOutput.Title,
-					Type:  textOutput.Type,
-					Input: textOutput.Input,
+					Title:    textOutput.Title,
+					Type:     textOutput.Type,
+					Input:    textOutput.Input,
+					Provider: textOutput.Provider,
+					Model:    textOutput.Model,
+					Options:  textOutput.Options,
 				}
 			}
 
 			// Convert ReportCsvData to TemplateCsvData
 			for l, data := range reportSection.CSVData {
 				templateSection.CSVData[l] = models.TemplateCSVData{
+					Dataset:       data.Dataset,
 					Label:         data.Label,
 					Description:   data.Description,
 					OperationType: data.OperationType,
+					Percentile:    data.Percentile,
+					IntervalUnit:  data.IntervalUnit,
 				}
 			}
 
@@ -308,10 +322,14 @@ func ConvertReportToTemplate(reportID, templateTitle, userID string) error {
 						AggregateValueLabel: reportDependentColumn.AggregateValueLabel,
 						Description:         reportDependentColumn.Description,
 						OperationType:       reportDependentColumn.OperationType,
+						Percentile:          reportDependentColumn.Percentile,
+						IntervalUnit:        reportDependentColumn.IntervalUnit,
 					}
 				}
 
 				templateSection.ChartOutputs[h] = models.TemplateChartOutput{
+					Dataset:                chart.Dataset,
+					Label:                  chart.Label,
 					Title:                  chart.Title,
 					Type:                   chart.Type,
 					Description:            chart.Description,
@@ -319,6 +337,9 @@ func ConvertReportToTemplate(reportID, templateTitle, userID string) error {
 					YAxisTitle:             chart.YAxisTitle,
 					CartesianGrid:          chart.CartesianGrid,
 					IndependentColumnLabel: chart.IndependentColumnLabel,
+					TimeBucket:             chart.TimeBucket,
+					SeriesLimit:            chart.SeriesLimit,
+					OtherSeriesLabel:       chart.OtherSeriesLabel,
 					DependentColumns:       newDependentColumns,
 				}
 			}
@@ -352,7 +373,16 @@ func ConvertReportToTemplate(reportID, templateTitle, userID string) error {
 	return nil
 }
 
-func SetReportCSV(reportID, userID string) (string, string, error) {
+// SetReportCSV creates the upload url of a new csv for the report. An empty dataset
+// name replaces the report csv, otherwise the csv of the named dataset is replaced,
+// adding the dataset when the report does not have it yet. The file is uploaded in the
+// format of the options and converted to a csv before its columns are read.
+func SetReportCSV(reportID, datasetName string, options models.UploadOptions, userID string) (string, string, error) {
+	err := ValidateUploadOptions(&options)
+	if err != nil {
+		return "", "", err
+	}
+
 	isAuthorized, err := isUserAuthorizedForItem(constants.Report, reportID, userID)
 
 	if err != nil {
@@ -368,8 +398,12 @@ func SetReportCSV(reportID, userID string) (string, string, error) {
 		return "", "", fmt.Errorf("error getting dynamodb client: %v", err)
 	}
 
+	if datasetName != "" {
+		return setReportDatasetCSV(reportID, datasetName, options, userID)
+	}
+
 	fileS3Key := uuid.New().String() + ".csv"
-	preSignedURL, err := GeneratePresignedURL(os.Getenv(constants.CsvBucketName), fileS3Key, "text/csv", 3*time.Minute)
+	preSignedURL, err := GeneratePresignedURL(os.Getenv(constants.CsvBucketName), rawUploadKey(fileS3Key, options.FileType), uploadContentTypes[options.FileType], 3*time.Minute)
 
 	if err != nil {
 		return "", "", fmt.Errorf("error generating presigned url: %v", err)
@@ -400,7 +434,7 @@ func SetReportCSV(reportID, userID string) (string, string, error) {
 
 	// Create an operation that will be used by a polling function to check
 	// when the whole upload process is complete
-	err = CreateOperation(fileS3Key)
+	err = CreateUploadOperation(fileS3Key, reportID, userID, options)
 
 	if err != nil {
 		return "", "", fmt.Errorf("failed to create operation: %v", err)
@@ -415,6 +449,260 @@ func SetReportCSV(reportID, userID string) (string, string, error) {
 	return preSignedURL, fileS3Key, nil
 }
 
+func setReportDatasetCSV(reportID, datasetName string, options models.UploadOptions, userID string) (string, string, error) {
+	report, err := GetReport(reportID, userID)
+	if err != nil {
+		return "", "", fmt.Errorf("error getting report from DynamoDB: %v", err)
+	}
+
+	if report == nil {
+		return "", "", fmt.Errorf("report not found")
+	}
+
+	if _, ok := findReportJoin(report, datasetName); ok {
+		return "", "", fmt.Errorf("dataset name '%s' is already used by a join", datasetName)
+	}
+
+	fileS3Key := newDatasetCSVKey(reportID)
+	preSignedURL, err := GeneratePresignedURL(os.Getenv(constants.CsvBucketName), rawUploadKey(fileS3Key, options.FileType), uploadContentTypes[options.FileType], 3*time.Minute)
+
+	if err != nil {
+		return "", "", fmt.Errorf("error generating presigned url: %v", err)
+	}
+
+	// Create an operation that will be used by a polling function to check
+	// when the whole upload process is complete
+	err = CreateUploadOperation(fileS3Key, reportID, userID, options)
+
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create operation: %v", err)
+	}
+
+	err = setReportDatasetCSVID(report, datasetName, fileS3Key)
+	if err != nil {
+		return "", "", err
+	}
+
+	return preSignedURL, fileS3Key, nil
+}
+
+// UpdateReportDerivedColumns replaces the derived columns of a report, or of one of its
+// datasets when a dataset name is given. When there is a csv the columns are checked
+// against its headers and the column values map is rebuilt, so the new columns can be
+// picked in filters and charts right away.
+func UpdateReportDerivedColumns(reportID, datasetName string, derivedColumns []models.DerivedColumn, userID string) error {
+	err := ValidateDerivedColumns(derivedColumns)
+	if err != nil {
+		return err
+	}
+
+	report, err := GetReport(reportID, userID)
+	if err != nil {
+		return fmt.Errorf("error getting report from DynamoDB: %v", err)
+	}
+
+	if report == nil {
+		return fmt.Errorf("report not found")
+	}
+
+	dataset, err := FindReportDataset(report, datasetName)
+	if err != nil {
+		return err
+	}
+
+	var columnData *CsvColumnData
+	if hasCSV(dataset.CSVID) {
+		csvFile, err := GetCSVFileHandle(dataset.CSVID)
+		if err != nil {
+			return fmt.Errorf("error loading CSV from S3: %v", err)
+		}
+		defer csvFile.Close()
+
+		columnData, err = ProfileCSVColumns(csvFile, derivedColumns)
+		if err != nil {
+			return fmt.Errorf("error computing derived columns: %v", err)
+		}
+	}
+
+	if datasetName != "" {
+		derivedAttrValue, err := dynamodbattribute.MarshalList(derivedColumns)
+		if err != nil {
+			return fmt.Errorf("failed to marshal derived columns: %v", err)
+		}
+		for i := range report.Datasets {
+			if report.Datasets[i].Name == datasetName {
+				err = updateReportDataset(reportID, i, datasetName, map[string]*dynamodb.AttributeValue{
+					constants.DerivedColumnsField: {L: derivedAttrValue},
+				})
+			}
+		}
+		if err != nil {
+			return err
+		}
+	} else {
+		err = updateReportDerivedColumnsField(reportID, derivedColumns)
+		if err != nil {
+			return err
+		}
+	}
+
+	if columnData != nil {
+		_, err = UpdateReportCsvColumns(dataset.CSVID, columnData)
+		if err != nil {
+			return fmt.Errorf("error updating csv columns: %v", err)
+		}
+
+		// Joins using the dataset join its derived columns too
+		err = startDatasetJoinsBuild(reportID, dataset.CSVID, userID)
+		if err != nil {
+			return fmt.Errorf("error rebuilding join columns: %v", err)
+		}
+	}
+
+	return nil
+}
+
+func updateReportDerivedColumnsField(reportID string, derivedColumns []models.DerivedColumn) error {
+	dynamoDBClient, err := GetDynamoDBClient(constants.USEast2)
+	if err != nil {
+		return fmt.Errorf("error getting dynamodb client: %v", err)
+	}
+
+	derivedColumnsAttrValue, err := dynamodbattribute.MarshalList(derivedColumns)
+	if err != nil {
+		return fmt.Errorf("failed to marshal derived columns: %v", err)
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(os.Getenv(constants.ReportTable)),
+		Key: map[string]*dynamodb.AttributeValue{
+			constants.ReportIDField: {
+				S: aws.String(reportID),
+			},
+		},
+		UpdateExpression: aws.String("set " + constants.DerivedColumnsField + " = :dc, " + constants.LastModifiedAtField + " = :lm"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":dc": {
+				L: derivedColumnsAttrValue,
+			},
+			":lm": {
+				N: aws.String(strconv.FormatInt(GetCurrentTime(), 10)),
+			},
+		},
+	}
+
+	_, err = dynamoDBClient.UpdateItem(input)
+	if err != nil {
+		return fmt.Errorf("failed to update item: %v", err)
+	}
+
+	return nil
+}
+
+// UpdateReportJoins replaces the joins of a report and starts building the column values map
+// of every join from its joined rows in the background, so its columns can be picked in
+// filters and charts like those of a dataset. Returns the id of the operation to poll.
+func UpdateReportJoins(reportID string, joins []models.Join, userID string) (string, error) {
+	report, err := GetReport(reportID, userID)
+	if err != nil {
+		return "", fmt.Errorf("error getting report from DynamoDB: %v", err)
+	}
+
+	if report == nil {
+		return "", fmt.Errorf("report not found")
+	}
+
+	err = ValidateJoins(report, joins)
+	if err != nil {
+		return "", err
+	}
+
+	if joins == nil {
+		joins = []models.Join{}
+	}
+
+	operationID := uuid.New().String()
+	names := make([]string, len(joins))
+	for i := range joins {
+		joins[i].CSVColumnsS3Key = "no-csv-s3-key"
+		joins[i].ColumnsOperationID = operationID
+		names[i] = joins[i].Name
+	}
+
+	err = CreateOperation(operationID, models.JoinColumnsOperation, reportID, userID)
+	if err != nil {
+		return "", fmt.Errorf("error creating operation: %v", err)
+	}
+
+	dynamoDBClient, err := GetDynamoDBClient(constants.USEast2)
+	if err != nil {
+		return "", failJoinColumnsBuild(operationID, fmt.Errorf("error getting dynamodb client: %v", err))
+	}
+
+	joinsAttrValue, err := dynamodbattribute.MarshalList(joins)
+	if err != nil {
+		return "", failJoinColumnsBuild(operationID, fmt.Errorf("failed to marshal joins: %v", err))
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(os.Getenv(constants.ReportTable)),
+		Key: map[string]*dynamodb.AttributeValue{
+			constants.ReportIDField: {
+				S: aws.String(reportID),
+			},
+		},
+		UpdateExpression: aws.String("set " + constants.JoinsField + " = :j, " + constants.LastModifiedAtField + " = :lm"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":j": {
+				L: joinsAttrValue,
+			},
+			":lm": {
+				N: aws.String(strconv.FormatInt(GetCurrentTime(), 10)),
+			},
+		},
+	}
+
+	_, err = dynamoDBClient.UpdateItem(input)
+	if err != nil {
+		return "", failJoinColumnsBuild(operationID, fmt.Errorf("failed to update item: %v", err))
+	}
+
+	err = startJoinColumnsBuild(operationID, reportID, names, userID)
+	if err != nil {
+		return "", err
+	}
+
+	return operationID, nil
+}
+
+// buildJoinColumnValuesMap uploads the column values map of the joined rows and returns its key
+func buildJoinColumnValuesMap(report *models.Report, join models.Join) (string, error) {
+	left, err := FindReportDataset(report, join.LeftDataset)
+	if err != nil {
+		return "", err
+	}
+	right, err := FindReportDataset(report, join.RightDataset)
+	if err != nil {
+		return "", err
+	}
+	if !hasCSV(left.CSVID) || !hasCSV(right.CSVID) {
+		return "no-csv-s3-key", nil
+	}
+
+	csvFile, err := openReportJoin(report, join)
+	if err != nil {
+		return "", err
+	}
+	defer csvFile.Close()
+
+	columnData, err := ProfileCSVColumns(csvFile, nil)
+	if err != nil {
+		return "", fmt.Errorf("error reading columns of join '%s': %v", join.Name, err)
+	}
+
+	return uploadCsvColumnData("joins/"+report.ReportID+"/"+join.Name+".json", columnData)
+}
+
 func ensureNonNullReportFields(report *models.Report) {
 	// Check if Parts is nil, if so, initialize it as an empty slice
 	if report.Parts == nil {
@@ -425,6 +713,18 @@ func ensureNonNullReportFields(report *models.Report) {
 		report.SharedWithIDs = []string{}
 	}
 
+	if report.DerivedColumns == nil {
+		report.DerivedColumns = []models.DerivedColumn{}
+	}
+
+	if report.Datasets == nil {
+		report.Datasets = []models.Dataset{}
+	}
+
+	if report.Joins == nil {
+		report.Joins = []models.Join{}
+	}
+
 	// Iterate over each part
 	for i := range report.Parts {
 		part := &report.Parts[i]
@@ -451,8 +751,9 @@ func ensureNonNullReportFields(report *models.Report) {
 	}
 }
 
-// GetReportCsvColumnsS3Key fetches the CSVColumnsS3Key for a given reportID from DynamoDB
-func GetReportCsvColumnsS3Key(reportID, userID string) (string, error) {
+// GetReportCsvColumnsS3Key fetches the CSVColumnsS3Key for a given reportID from DynamoDB.
+// An empty dataset name returns the key of the report csv.
+func GetReportCsvColumnsS3Key(reportID, datasetName, userID string) (string, error) {
 	isAuthorized, err := isUserAuthorizedForItem(constants.Report, reportID, userID)
 
 	if err != nil {
@@ -477,7 +778,7 @@ func GetReportCsvColumnsS3Key(reportID, userID string) (string, error) {
 				S: aws.String(reportID),
 			},
 		},
-		ProjectionExpression: aws.String(constants.CSVColumnsS3KeyField),
+		ProjectionExpression: aws.String(constants.CSVColumnsS3KeyField + ", " + constants.DatasetsField + ", " + constants.JoinsField),
 	})
 
 	if err != nil {
@@ -496,7 +797,16 @@ func GetReportCsvColumnsS3Key(reportID, userID string) (string, error) {
 		return "", fmt.Errorf("failed to unmarshal DynamoDB item to Report: %v", err)
 	}
 
-	return report.CSVColumnsS3Key, nil
+	if join, ok := findReportJoin(&report, datasetName); ok {
+		return join.CSVColumnsS3Key, nil
+	}
+
+	dataset, err := FindReportDataset(&report, datasetName)
+	if err != nil {
+		return "", err
+	}
+
+	return dataset.CSVColumnsS3Key, nil
 }
 
 func ensureNonNullReportMetadataFields(report *models.ReportMetadata) {
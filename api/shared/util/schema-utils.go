@@ -0,0 +1,200 @@
+package util
+
+import (
+	"api/shared/models"
+	"math"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	maxCategoricalCardinality = 100
+	// Values of a categorical column are found in at least this many rows on average
+	minCategoricalRowsPerValue = 2
+)
+
+var booleanValues = map[string]bool{
+	"true": true, "false": true,
+	"yes": true, "no": true,
+	"y": true, "n": true,
+}
+
+// ProfileCSVColumns reads the csv once to build both its column values map and its
+// schema, with the type and profile of every column including the derived columns
+func ProfileCSVColumns(file *os.File, derivedColumns []models.DerivedColumn) (*CsvColumnData, error) {
+	uniqueValues := &uniqueValuesAggregator{}
+	schema := &schemaAggregator{uniqueValues: uniqueValues}
+	err := streamCSV(file, derivedColumns, func(headers []string) ([]csvAggregator, error) {
+		uniqueValues.init(headers)
+		schema.headers = headers
+		schema.profilers = make([]*columnProfiler, len(headers))
+		for i := range headers {
+			schema.profilers[i] = newColumnProfiler()
+		}
+		// The schema reads the cardinality from the value counts, so it finishes last
+		return []csvAggregator{uniqueValues, schema}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &CsvColumnData{
+		ColumnsMap:   uniqueValues.result,
+		ColumnValues: uniqueValues.columnValues,
+		Schema:       &schema.result,
+	}, nil
+}
+
+// csvSchemaS3Key is the key of the schema stored next to a column values map
+func csvSchemaS3Key(csvColumnsS3Key string) string {
+	return strings.TrimSuffix(csvColumnsS3Key, ".json") + ".schema.json"
+}
+
+type schemaAggregator struct {
+	headers      []string
+	profilers    []*columnProfiler
+	rows         int
+	uniqueValues *uniqueValuesAggregator
+	result       models.CsvSchema
+}
+
+func (a *schemaAggregator) consume(record []string) error {
+	a.rows++
+	for i, value := range record {
+		a.profilers[i].add(value)
+	}
+	return nil
+}
+
+func (a *schemaAggregator) finish() error {
+	a.result = models.CsvSchema{
+		RowCount: a.rows,
+		Columns:  make([]models.ColumnProfile, len(a.headers)),
+	}
+	for i, header := range a.headers {
+		cardinality := 0
+		for value := range a.uniqueValues.valueCounts[i] {
+			if strings.TrimSpace(value) != "" {
+				cardinality++
+			}
+		}
+		a.result.Columns[i] = a.profilers[i].profile(header, cardinality)
+	}
+	return nil
+}
+
+// columnProfiler narrows down the type of a column as its values are read. Once a
+// value does not parse as a type, the type is ruled out and no longer checked.
+type columnProfiler struct {
+	blanks     int
+	values     int
+	isBoolean  bool
+	isNumeric  bool
+	isIntegral bool
+	isDate     bool
+	min        float64
+	max        float64
+	sum        float64
+	dateLayout string // Layout of the last date, tried first since exports use one format per column
+	earliest   time.Time
+	latest     time.Time
+}
+
+func newColumnProfiler() *columnProfiler {
+	return &columnProfiler{
+		isBoolean:  true,
+		isNumeric:  true,
+		isIntegral: true,
+		isDate:     true,
+	}
+}
+
+func (p *columnProfiler) add(value string) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		p.blanks++
+		return
+	}
+	p.values++
+
+	if p.isBoolean {
+		p.isBoolean = booleanValues[strings.ToLower(value)]
+	}
+
+	if p.isNumeric {
+		num, ok := parseNumericalValue(value, "")
+		if ok {
+			if p.values == 1 || num < p.min {
+				p.min = num
+			}
+			if p.values == 1 || num > p.max {
+				p.max = num
+			}
+			p.sum += num
+			p.isIntegral = p.isIntegral && num == math.Trunc(num)
+		} else {
+			p.isNumeric = false
+		}
+	}
+
+	if p.isDate {
+		parsed, ok := p.parseDate(value)
+		if ok {
+			if p.earliest.IsZero() || parsed.Before(p.earliest) {
+				p.earliest = parsed
+			}
+			if p.latest.IsZero() || parsed.After(p.latest) {
+				p.latest = parsed
+			}
+		} else {
+			p.isDate = false
+		}
+	}
+}
+
+func (p *columnProfiler) parseDate(value string) (time.Time, bool) {
+	if p.dateLayout != "" {
+		if parsed, ok := parseTimeValue(value, []string{p.dateLayout}, time.UTC); ok {
+			return parsed, true
+		}
+	}
+	for _, layout := range commonDateLayouts {
+		if parsed, ok := parseTimeValue(value, []string{layout}, time.UTC); ok {
+			p.dateLayout = layout
+			return parsed, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func (p *columnProfiler) profile(name string, cardinality int) models.ColumnProfile {
+	profile := models.ColumnProfile{
+		Name:        name,
+		BlankCount:  p.blanks,
+		Cardinality: cardinality,
+	}
+
+	switch {
+	case p.values == 0:
+		profile.Type = models.TextColumn
+	case p.isBoolean:
+		profile.Type = models.BooleanColumn
+	case p.isNumeric:
+		profile.Type = models.DecimalColumn
+		if p.isIntegral {
+			profile.Type = models.IntegerColumn
+		}
+		minValue, maxValue := p.min, p.max
+		mean := math.Round(p.sum/float64(p.values)*1000) / 1000
+		profile.Min, profile.Max, profile.Mean = &minValue, &maxValue, &mean
+	case p.isDate:
+		profile.Type = models.DateTimeColumn
+		profile.EarliestDate = p.earliest.Format(time.RFC3339)
+		profile.LatestDate = p.latest.Format(time.RFC3339)
+	case cardinality <= maxCategoricalCardinality && cardinality*minCategoricalRowsPerValue <= p.values:
+		profile.Type = models.CategoricalColumn
+	default:
+		profile.Type = models.TextColumn
+	}
+	return profile
+}
@@ -0,0 +1,243 @@
+package util
+
+import (
+	"api/shared/models"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ReportSectionsByLabel returns the sections of a report that have a label, the first
+// section winning when labels are the same
+func ReportSectionsByLabel(report *models.Report) map[string]*models.ReportSection {
+	sections := make(map[string]*models.ReportSection)
+	for p := range report.Parts {
+		for s := range report.Parts[p].Sections {
+			section := &report.Parts[p].Sections[s]
+			if _, ok := sections[section.Label]; section.Label != "" && !ok {
+				sections[section.Label] = section
+			}
+		}
+	}
+	return sections
+}
+
+// sectionReferenceNode is a section with the inputs of its text outputs
+type sectionReferenceNode struct {
+	Ref    models.SectionRef
+	Label  string
+	Inputs []string
+}
+
+// sectionReferenceGraph has the sections each section refers to in its placeholders
+type sectionReferenceGraph struct {
+	nodes      map[models.SectionRef]sectionReferenceNode
+	references map[models.SectionRef][]models.SectionRef
+}
+
+func newSectionReferenceGraph(nodes []sectionReferenceNode) sectionReferenceGraph {
+	graph := sectionReferenceGraph{
+		nodes:      make(map[models.SectionRef]sectionReferenceNode),
+		references: make(map[models.SectionRef][]models.SectionRef),
+	}
+
+	byLabel := make(map[string]models.SectionRef)
+	for _, node := range nodes {
+		graph.nodes[node.Ref] = node
+		if _, ok := byLabel[node.Label]; node.Label != "" && !ok {
+			byLabel[node.Label] = node.Ref
+		}
+	}
+
+	// References to labels no section has are left to the placeholders to report
+	for _, node := range nodes {
+		seen := make(map[models.SectionRef]bool)
+		for _, input := range node.Inputs {
+			for _, label := range placeholderSectionLabels(input) {
+				ref, ok := byLabel[label]
+				if ok && !seen[ref] {
+					seen[ref] = true
+					graph.references[node.Ref] = append(graph.references[node.Ref], ref)
+				}
+			}
+		}
+	}
+	return graph
+}
+
+func reportSectionReferenceGraph(report *models.Report) sectionReferenceGraph {
+	var nodes []sectionReferenceNode
+	for p, part := range report.Parts {
+		for s, section := range part.Sections {
+			node := sectionReferenceNode{
+				Ref:   models.SectionRef{PartIndex: p, SectionIndex: s},
+				Label: section.Label,
+			}
+			for _, textOutput := range section.TextOutputs {
+				node.Inputs = append(node.Inputs, textOutput.Input)
+			}
+			nodes = append(nodes, node)
+		}
+	}
+	return newSectionReferenceGraph(nodes)
+}
+
+// cycle returns the sections of a cycle of references that the section is in or refers
+// to, starting and ending with the same section. Returns nil when there is none.
+func (g sectionReferenceGraph) cycle(start models.SectionRef) []models.SectionRef {
+	visiting := make(map[models.SectionRef]bool)
+	visited := make(map[models.SectionRef]bool)
+	var path []models.SectionRef
+
+	var visit func(ref models.SectionRef) []models.SectionRef
+	visit = func(ref models.SectionRef) []models.SectionRef {
+		if visiting[ref] {
+			for i := range path {
+				if path[i] == ref {
+					return append(append([]models.SectionRef{}, path[i:]...), ref)
+				}
+			}
+		}
+		if visited[ref] {
+			return nil
+		}
+
+		visiting[ref] = true
+		path = append(path, ref)
+		for _, reference := range g.references[ref] {
+			if cycle := visit(reference); cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		visiting[ref] = false
+		visited[ref] = true
+		return nil
+	}
+
+	return visit(start)
+}
+
+// selfCycle returns the sections of a cycle of references through the section, starting
+// and ending with it. Returns nil when the section is not in a cycle.
+func (g sectionReferenceGraph) selfCycle(start models.SectionRef) []models.SectionRef {
+	visited := make(map[models.SectionRef]bool)
+
+	var visit func(path []models.SectionRef) []models.SectionRef
+	visit = func(path []models.SectionRef) []models.SectionRef {
+		for _, reference := range g.references[path[len(path)-1]] {
+			if reference == start {
+				return append(path, start)
+			}
+			if visited[reference] {
+				continue
+			}
+			visited[reference] = true
+			if cycle := visit(append(path[:len(path):len(path)], reference)); cycle != nil {
+				return cycle
+			}
+		}
+		return nil
+	}
+
+	return visit([]models.SectionRef{start})
+}
+
+// cycleError returns the error of a section that can not be generated because it is in or
+// refers to a cycle of references, nil when it is not
+func (g sectionReferenceGraph) cycleError(ref models.SectionRef) error {
+	cycle := g.cycle(ref)
+	if cycle == nil {
+		return nil
+	}
+	return errors.New(g.describeCycle(cycle))
+}
+
+// dependencyOrder returns the sections the section refers to, directly or through other
+// sections, each after the sections it refers to, and then the section itself. The section
+// must not be in or refer to a cycle.
+func (g sectionReferenceGraph) dependencyOrder(start models.SectionRef) []models.SectionRef {
+	visited := make(map[models.SectionRef]bool)
+	var order []models.SectionRef
+
+	var visit func(ref models.SectionRef)
+	visit = func(ref models.SectionRef) {
+		if visited[ref] {
+			return
+		}
+		visited[ref] = true
+		for _, reference := range g.references[ref] {
+			visit(reference)
+		}
+		order = append(order, ref)
+	}
+
+	visit(start)
+	return order
+}
+
+// ReportSectionGenerationOrder returns the sections to generate for the section to be
+// generated from up to date sections: those it refers to, directly or through other
+// sections, each after the ones it refers to, and the section last. Returns an error when
+// the section is in or refers to a cycle of references.
+func ReportSectionGenerationOrder(report *models.Report, ref models.SectionRef) ([]models.SectionRef, error) {
+	graph := reportSectionReferenceGraph(report)
+	err := graph.cycleError(ref)
+	if err != nil {
+		return nil, err
+	}
+	return graph.dependencyOrder(ref), nil
+}
+
+func (g sectionReferenceGraph) describeCycle(cycle []models.SectionRef) string {
+	names := make([]string, len(cycle))
+	for i, ref := range cycle {
+		names[i] = fmt.Sprintf("'%s'", g.nodes[ref].Label)
+	}
+	if len(cycle) == 2 {
+		return fmt.Sprintf("section %s refers to itself", names[0])
+	}
+	return "sections refer to each other in a cycle: " + strings.Join(names, " -> ")
+}
+
+// placeholderSectionLabels returns the labels of the sections the placeholders of text
+// refer to. Text that can not be parsed refers to none, its placeholders failing to render.
+func placeholderSectionLabels(text string) []string {
+	if !UsesPlaceholderSyntax(text) {
+		return nil
+	}
+
+	nodes, err := parsePlaceholders(text)
+	if err != nil {
+		return nil
+	}
+
+	var labels []string
+	for _, path := range placeholderPaths(nodes) {
+		segments := strings.Split(path, ".")
+		if len(segments) >= 2 && segments[0] == "sections" {
+			labels = append(labels, segments[1])
+		}
+	}
+	return labels
+}
+
+// placeholderPaths returns the paths of the values, conditions and lists of the nodes
+func placeholderPaths(nodes []placeholderNode) []string {
+	var paths []string
+	for _, node := range nodes {
+		switch node.Type {
+		case placeholderValue:
+			paths = append(paths, node.Path)
+		case placeholderIf:
+			paths = append(paths, node.Condition.Path)
+			paths = append(paths, placeholderPaths(node.Body)...)
+			paths = append(paths, placeholderPaths(node.Else)...)
+		case placeholderEach:
+			paths = append(paths, node.Path)
+			paths = append(paths, placeholderPaths(node.Body)...)
+			paths = append(paths, placeholderPaths(node.Else)...)
+		}
+	}
+	return paths
+}
@@ -4,6 +4,7 @@ import (
 	"api/shared/constants"
 	"api/shared/interfaces"
 	"api/shared/models"
+	"context"
 	"errors"
 	"fmt"
 	"log"
@@ -12,6 +13,7 @@ import (
 	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 )
@@ -34,6 +36,7 @@ func AddSectionToReport(reportID string, partIndex int, sectionIndex int, newSec
 		return fmt.Errorf("report not found: %v", err)
 	}
 
+	assignTextOutputIDs(&newSection)
 	err = insertSectionInReport(report, partIndex, sectionIndex, newSection)
 
 	if err != nil {
@@ -205,10 +208,12 @@ func UpdateSectionInReport(
 	oldSectionIndex int,
 	newSectionIndex int,
 	newSectionTitle string,
+	newSectionLabel string,
 	newQuestions []models.ReportQuestion,
 	newTextOutputs []models.ReportTextOutput,
 	newCSVData []models.ReportCSVData,
 	newChartOutputs []models.ReportChartOutput,
+	newFailurePolicy models.SectionFailurePolicy,
 	deleteGeneratedOutput bool,
 	userID string,
 ) error {
@@ -231,8 +236,9 @@ func UpdateSectionInReport(
 
 	updatedSection := &report.Parts[oldPartIndex].Sections[oldSectionIndex]
 
-	// Update the title and questions of the section
+	// Update the title, label and questions of the section
 	updatedSection.Title = newSectionTitle
+	updatedSection.Label = newSectionLabel
 	updatedSection.Questions = newQuestions
 
 	updateReportTextOutputs(updatedSection, newTextOutputs, deleteGeneratedOutput)
@@ -240,6 +246,13 @@ func UpdateSectionInReport(
 	// Update csv data and chart outputs
 	updatedSection.CSVData = newCSVData
 	updatedSection.ChartOutputs = newChartOutputs
+	updatedSection.FailurePolicy = newFailurePolicy
+
+	// The section is not saved with placeholder errors. Errors in other sections do not block it.
+	err = SectionPlaceholderErrors(LintReportPlaceholders(report), models.SectionRef{PartIndex: oldPartIndex, SectionIndex: oldSectionIndex})
+	if err != nil {
+		return err
+	}
 
 	if oldPartIndex != newPartIndex || oldSectionIndex != newSectionIndex {
 		err = moveSectionInReport(report, oldPartIndex, oldSectionIndex, newPartIndex, newSectionIndex)
@@ -277,10 +290,12 @@ func UpdateSectionInTemplate(
 	oldSectionIndex int,
 	newSectionIndex int,
 	newSectionTitle string,
+	newSectionLabel string,
 	newQuestions []models.TemplateQuestion,
 	newTextOutputs []models.TemplateTextOutput,
 	newCSVData []models.TemplateCSVData,
 	newChartOutputs []models.TemplateChartOutput,
+	newFailurePolicy models.SectionFailurePolicy,
 	userID string,
 ) error {
 	tableName := os.Getenv(constants.TemplateTable)
@@ -303,10 +318,18 @@ func UpdateSectionInTemplate(
 
 	// Update the qualities of the section
 	updatedSection.Title = newSectionTitle
+	updatedSection.Label = newSectionLabel
 	updatedSection.Questions = newQuestions
 	updatedSection.TextOutputs = newTextOutputs
 	updatedSection.CSVData = newCSVData
 	updatedSection.ChartOutputs = newChartOutputs
+	updatedSection.FailurePolicy = newFailurePolicy
+
+	// The section is not saved with placeholder errors. Errors in other sections do not block it.
+	err = SectionPlaceholderErrors(LintTemplatePlaceholders(template), models.SectionRef{PartIndex: oldPartIndex, SectionIndex: oldSectionIndex})
+	if err != nil {
+		return err
+	}
 
 	if oldPartIndex != newPartIndex || oldSectionIndex != newSectionIndex {
 		err = moveSectionInTemplate(template, oldPartIndex, oldSectionIndex, newPartIndex, newSectionIndex)
@@ -378,24 +401,43 @@ func SetReportSectionResponses(reportID string,
 	// Next, update csv data responses
 	if section.CSVData != nil {
 		for i := range section.CSVData {
+			section.CSVData[i].Dataset = csvDataResponses[i].Dataset
 			section.CSVData[i].OperationColumn = csvDataResponses[i].OperationColumn
 			section.CSVData[i].AcceptedValues = csvDataResponses[i].AcceptedValues
 			section.CSVData[i].FilterColumns = csvDataResponses[i].FilterColumns
+			section.CSVData[i].InvalidValuePolicy = csvDataResponses[i].InvalidValuePolicy
+			section.CSVData[i].DecimalSeparator = csvDataResponses[i].DecimalSeparator
+			section.CSVData[i].StartColumn = csvDataResponses[i].StartColumn
+			section.CSVData[i].EndColumn = csvDataResponses[i].EndColumn
+			section.CSVData[i].DateFormat = csvDataResponses[i].DateFormat
+			section.CSVData[i].TimeZone = csvDataResponses[i].TimeZone
 		}
 	}
 
 	// Next, update chart output responses
 	if section.ChartOutputs != nil {
 		for i := range section.ChartOutputs {
+			section.ChartOutputs[i].Dataset = chartOutputResponses[i].Dataset
 			section.ChartOutputs[i].IndependentColumn = chartOutputResponses[i].IndependentColumn
 			section.ChartOutputs[i].AcceptedValues = chartOutputResponses[i].AcceptedValues
 			section.ChartOutputs[i].FilterColumns = chartOutputResponses[i].FilterColumns
+			section.ChartOutputs[i].SeriesColumn = chartOutputResponses[i].SeriesColumn
+			section.ChartOutputs[i].SeriesLimit = chartOutputResponses[i].SeriesLimit
+			section.ChartOutputs[i].OtherSeriesLabel = chartOutputResponses[i].OtherSeriesLabel
+			section.ChartOutputs[i].DateFormat = chartOutputResponses[i].DateFormat
+			section.ChartOutputs[i].TimeZone = chartOutputResponses[i].TimeZone
 
 			// Update the dependent columns
 			for j := range section.ChartOutputs[i].DependentColumns {
 				section.ChartOutputs[i].DependentColumns[j].Column = chartOutputResponses[i].DependentColumns[j].Column
 				section.ChartOutputs[i].DependentColumns[j].AcceptedValues = chartOutputResponses[i].DependentColumns[j].AcceptedValues
 				section.ChartOutputs[i].DependentColumns[j].FilterColumns = chartOutputResponses[i].DependentColumns[j].FilterColumns
+				section.ChartOutputs[i].DependentColumns[j].InvalidValuePolicy = chartOutputResponses[i].DependentColumns[j].InvalidValuePolicy
+				section.ChartOutputs[i].DependentColumns[j].DecimalSeparator = chartOutputResponses[i].DependentColumns[j].DecimalSeparator
+				section.ChartOutputs[i].DependentColumns[j].StartColumn = chartOutputResponses[i].DependentColumns[j].StartColumn
+				section.ChartOutputs[i].DependentColumns[j].EndColumn = chartOutputResponses[i].DependentColumns[j].EndColumn
+				section.ChartOutputs[i].DependentColumns[j].DateFormat = chartOutputResponses[i].DependentColumns[j].DateFormat
+				section.ChartOutputs[i].DependentColumns[j].TimeZone = chartOutputResponses[i].DependentColumns[j].TimeZone
 			}
 		}
 	}
@@ -416,14 +458,7 @@ func SetReportSectionResponses(reportID string,
 	return err
 }
 
-func GenerateSection(reportID string, partIndex int, sectionIndex int, generateAIOutput bool, userID string) error {
-	tableName := os.Getenv(constants.ReportTable)
-	dynamoDBClient, err := GetDynamoDBClient(constants.USEast2)
-
-	if err != nil {
-		return fmt.Errorf("error getting dynamodb client: %v", err)
-	}
-
+func GenerateSection(ctx context.Context, reportID string, partIndex int, sectionIndex int, generateAIOutput bool, userID string) error {
 	report, err := GetReport(reportID, userID)
 
 	if err != nil {
@@ -434,210 +469,239 @@ func GenerateSection(reportID string, partIndex int, sectionIndex int, generateA
 		return fmt.Errorf("report not found: %v", err)
 	}
 
-	section, err := GetReportSection(report, partIndex, sectionIndex)
+	_, err = GetReportSection(report, partIndex, sectionIndex)
 
 	if err != nil {
 		return fmt.Errorf("error getting section: %v", err)
 	}
 
-	// Load CSV file from S3
-	csvFile, err := GetCSVFileHandle(report.CSVID)
+	// The sections the section refers to are generated first, so it uses their new results
+	ref := models.SectionRef{PartIndex: partIndex, SectionIndex: sectionIndex}
+	refs, err := ReportSectionGenerationOrder(report, ref)
 	if err != nil {
-		return fmt.Errorf("error loading CSV from S3: %v", err)
+		return err
 	}
+	sections := ReportSectionsByLabel(report)
 
-	// Generate csv data results from csv
-	err = GenerateSectionCsvDataResults(csvFile, section)
-
-	if err != nil {
-		return fmt.Errorf("error generating section csv data results: %v", err)
+	// Generate csv data and chart output results in a single pass over each dataset the sections use.
+	// They come before the text outputs, whose placeholders use them.
+	failed := analyzeReportSections(report, refs)
+	for i, r := range refs {
+		if failed[i] != nil {
+			return referencedSectionError(report, ref, r, failed[i])
+		}
 	}
 
-	// Reset the text output results so that they can be created from input again
-	ResetTextOutputResults(section, generateAIOutput)
-
-	GenerateSectionStaticText(section, &report.GlobalQuestions)
-
+	// The usage of the generator requests is recorded for the user
+	usage := models.UsageContext{UserID: userID, ReportID: reportID}
 	if generateAIOutput {
-		generator := OpenAiGenerator{}
+		usage.OrganizationID, err = GetUserOrganization(userID)
+		if err != nil {
+			return fmt.Errorf("error getting user organization: %v", err)
+		}
+	}
 
-		err = GenerateSectionGeneratorText(generator, section, &report.GlobalQuestions)
+	// Only the sections are saved, so sections a report generation saves meanwhile are kept
+	for _, r := range refs {
+		err = generateReportSection(ctx, report, r, sections, generateAIOutput, usage)
 		if err != nil {
-			log.Panicf("error creating generator outputs: %v", err)
-			return fmt.Errorf("error creating generator outputs: %v", err)
+			return referencedSectionError(report, ref, r, err)
 		}
 	}
+	return nil
+}
 
-	// Generate Chart Output from CSV
-	err = GenerateChartOutputResults(csvFile, section)
+// referencedSectionError names the section the error is from when it is a section the
+// generated section refers to
+func referencedSectionError(report *models.Report, ref, failed models.SectionRef, err error) error {
+	if failed == ref {
+		return err
+	}
+	return fmt.Errorf("referenced section '%s' could not be generated: %v", report.Parts[failed.PartIndex].Sections[failed.SectionIndex].Title, err)
+}
 
+// saveReportSection stores one section of a report without writing the rest of it, so
+// sections generated side by side do not overwrite each other. The section must still
+// be at its position with the same title, otherwise it was moved while it generated.
+func saveReportSection(reportID string, ref models.SectionRef, section *models.ReportSection) error {
+	tableName := os.Getenv(constants.ReportTable)
+	dynamoDBClient, err := GetDynamoDBClient(constants.USEast2)
 	if err != nil {
-		return fmt.Errorf("error generating section csv data results: %v", err)
+		return fmt.Errorf("error getting dynamodb client: %v", err)
 	}
 
-	// Set output generated after all sections generated successfully
-	section.OutputGenerated = true
-
-	// Update last modified
-	report.LastModifiedAt = GetCurrentTime()
-
-	// Update the report in DynamoDB
-	updatedReport, err := dynamodbattribute.MarshalMap(report)
+	sectionAttr, err := dynamodbattribute.Marshal(section)
 	if err != nil {
-		return err
+		return fmt.Errorf("error marshalling section: %v", err)
 	}
 
-	_, err = dynamoDBClient.PutItem(&dynamodb.PutItemInput{
+	sectionPath := fmt.Sprintf("%s[%d].%s[%d]", constants.PartsField, ref.PartIndex, constants.SectionsField, ref.SectionIndex)
+	input := &dynamodb.UpdateItemInput{
 		TableName: aws.String(tableName),
-		Item:      updatedReport,
-	})
-	return err
-}
-
-func GenerateSectionCsvDataResults(csvFile *os.File, section *models.ReportSection) error {
-	// Iterate over each csv data
-	for index := range section.CSVData {
-
-		err := AnalyzeOneDimensionalData(csvFile, &section.CSVData[index])
+		Key: map[string]*dynamodb.AttributeValue{
+			constants.ReportIDField: {
+				S: aws.String(reportID),
+			},
+		},
+		UpdateExpression:    aws.String("set " + sectionPath + " = :section, " + constants.LastModifiedAtField + " = :lm"),
+		ConditionExpression: aws.String(sectionPath + "." + constants.TitleField + " = :title"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":section": sectionAttr,
+			":title": {
+				S: aws.String(section.Title),
+			},
+			":lm": {
+				N: aws.String(strconv.FormatInt(GetCurrentTime(), 10)),
+			},
+		},
+	}
+
+	_, err = dynamoDBClient.UpdateItem(input)
+	// A report too large to save drops the oldest text output versions of the section until it fits
+	for isItemSizeError(err) && dropOldestTextOutputVersions(section) {
+		sectionAttr, err = dynamodbattribute.Marshal(section)
 		if err != nil {
-			return fmt.Errorf("error generating section csv data results: %v", err)
+			return fmt.Errorf("error marshalling section: %v", err)
 		}
+		input.ExpressionAttributeValues[":section"] = sectionAttr
+		_, err = dynamoDBClient.UpdateItem(input)
 	}
-	return nil
-}
-
-func GenerateChartOutputResults(csvFile *os.File, section *models.ReportSection) error {
-	// Iterate over each chart output
-	for index := range section.ChartOutputs {
-
-		err := AnalyzeTwoDimensionalData(csvFile, &section.ChartOutputs[index])
-		if err != nil {
-			return fmt.Errorf("error generating section chart output results: %v", err)
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return fmt.Errorf("section was moved or deleted while it generated")
 		}
+		return fmt.Errorf("failed to update item: %v", err)
 	}
+
 	return nil
 }
 
-func GenerateSectionStaticText(section *models.ReportSection, globalQuestions *[]models.ReportQuestion) {
-	// Iterate over each question, splicing answer into text
-	for _, question := range section.Questions {
-
-		// Generate static text
-		for i, textOutput := range section.TextOutputs {
-			if textOutput.Type == models.Static {
-				GenerateStaticText(&section.TextOutputs[i], question.Label, question.Answer)
-			}
-		}
-	}
-
-	// Iterate over each csv data, splicing result into text
-	for _, csvData := range section.CSVData {
-
-		// Generate static text
-		for i, textOutput := range section.TextOutputs {
-			if textOutput.Type == models.Static {
-				GenerateStaticText(&section.TextOutputs[i], csvData.Label, csvData.Result)
-			}
-		}
-	}
-
-	// Iterate over each global question, splicing answer into text
-	for _, question := range *globalQuestions {
+// isItemSizeError reports whether DynamoDB refused a write because the item would be
+// larger than its item size limit
+func isItemSizeError(err error) bool {
+	awsErr, ok := err.(awserr.Error)
+	return ok && awsErr.Code() == "ValidationException" && strings.Contains(awsErr.Message(), "maximum allowed size")
+}
 
-		// Generate static text
-		for i, textOutput := range section.TextOutputs {
-			if textOutput.Type == models.Static {
-				GenerateStaticText(&section.TextOutputs[i], question.Label, question.Answer)
-			}
-		}
-	}
+// GenerateSectionCsvDataResults computes every csv data result of the section in one pass over the csv
+func GenerateSectionCsvDataResults(csvFile *os.File, section *models.ReportSection) error {
+	return streamCSV(csvFile, nil, func(headers []string) ([]csvAggregator, error) {
+		return newCSVDataAggregators(headers, "", section)
+	})
 }
 
-func GenerateSectionGeneratorText(generator interfaces.Generator, section *models.ReportSection, globalQuestions *[]models.ReportQuestion) error {
-	// Preserve original inputs as to be able to re-create the section later with different answers to the questions.
-	originalInputs := []string{}
-	for _, textOutput := range section.TextOutputs {
-		if textOutput.Type == models.Generator {
-			originalInputs = append(originalInputs, textOutput.Input)
-		}
-	}
+// GenerateChartOutputResults computes every chart output result of the section in one pass over the csv
+func GenerateChartOutputResults(csvFile *os.File, section *models.ReportSection) error {
+	return streamCSV(csvFile, nil, func(headers []string) ([]csvAggregator, error) {
+		return newChartOutputAggregators(headers, "", section)
+	})
+}
 
-	// Used to make the concurrent channel
-	numberOfGeneratorSections := 0
-	for _, textOutput := range section.TextOutputs {
-		if textOutput.Type == models.Generator {
-			numberOfGeneratorSections++
+// GenerateSectionStaticText renders the placeholders of each static text output with the
+// answers and results of the section, the global questions and the labelled sections given
+func GenerateSectionStaticText(section *models.ReportSection, globalQuestions *[]models.ReportQuestion, sections map[string]*models.ReportSection) {
+	for i, textOutput := range section.TextOutputs {
+		if textOutput.Type != models.Static {
+			continue
 		}
-	}
 
-	// Splice question answers into prompts
-	for _, question := range section.Questions {
-
-		// Generate the inputs with question answers spliced in
-		for i, textOutput := range section.TextOutputs {
-			if textOutput.Type == models.Generator {
-				GenerateGeneratorInput(&section.TextOutputs[i], question.Label, question.Answer)
-			}
+		result, err := RenderPlaceholders(textOutput.Input, section, *globalQuestions, sections)
+		if err != nil {
+			section.TextOutputs[i].Result = ""
+			section.TextOutputs[i].Error = err.Error()
+			continue
 		}
+		section.TextOutputs[i].Result = result
+		section.TextOutputs[i].Error = ""
 	}
+}
 
-	// Splice the csv data results into prompts
-	for _, csvData := range section.CSVData {
-
-		// Generate the inputs with question answers spliced in
-		for i, textOutput := range section.TextOutputs {
-			if textOutput.Type == models.Generator {
-				GenerateGeneratorInput(&section.TextOutputs[i], csvData.Label, csvData.Result)
+// GenerateSectionGeneratorText generates the generator outputs of the section, each with the
+// options of the item overridden by its own. How each result was generated is recorded on it,
+// and outputs that fail to generate hold their error instead. An error is returned when an
+// output fails and the failure policy of the section is FailSection. Generators with a
+// quota are checked before any request is sent, every output failing when it is used.
+func GenerateSectionGeneratorText(ctx context.Context, generator interfaces.Generator, section *models.ReportSection, globalQuestions *[]models.ReportQuestion, sections map[string]*models.ReportSection, options *models.GenerationOptions) error {
+	if quotaChecker, ok := generator.(interfaces.QuotaChecker); ok && sectionHasGeneratorOutputs(section) {
+		err := quotaChecker.CheckQuota(ctx)
+		if err != nil {
+			for i, textOutput := range section.TextOutputs {
+				if textOutput.Type == models.Generator {
+					section.TextOutputs[i].Result = ""
+					section.TextOutputs[i].Error = err.Error()
+					section.TextOutputs[i].Generation = nil
+				}
 			}
+			return err
 		}
 	}
 
-	// Splice global question answers into prompts
-	for _, question := range *globalQuestions {
-
-		// Generate the inputs with question answers spliced in
-		for i, textOutput := range section.TextOutputs {
-			if textOutput.Type == models.Generator {
-				GenerateGeneratorInput(&section.TextOutputs[i], question.Label, question.Answer)
-			}
+	// Render the placeholders of the prompts, outputs with invalid placeholders failing
+	// without a request
+	prompts := make(map[int]string)
+	promptErrs := make(map[int]error)
+	for i, textOutput := range section.TextOutputs {
+		if textOutput.Type == models.Generator {
+			prompts[i], promptErrs[i] = RenderPlaceholders(textOutput.Input, section, *globalQuestions, sections)
 		}
 	}
 
 	type generateResult struct {
-		Index  int
-		Result string
-		Err    error
+		Index      int
+		Result     string
+		Generation *models.GenerationRecord
+		Err        error
 	}
 
 	// Create a channel for communication
-	resultsChan := make(chan generateResult, numberOfGeneratorSections)
+	resultsChan := make(chan generateResult, len(prompts))
 
 	log.Print("Starting GPT Generation\n")
 
 	for i, textOutput := range section.TextOutputs {
 		if textOutput.Type == models.Generator {
-			go func(index int, input string) {
-				log.Printf("input after splicing: %v", input)
+			go func(index int, textOutput models.ReportTextOutput) {
+				if promptErrs[index] != nil {
+					resultsChan <- generateResult{Index: index, Err: fmt.Errorf("error in prompt placeholders: %v", promptErrs[index])}
+					return
+				}
+				log.Printf("input after splicing: %v", prompts[index])
 
 				log.Print("Generating TextOutput: " + strconv.Itoa(index) + "\n")
-				result, err := generator.GeneratePromptResponse(input)
+				outputGenerator, generation, err := textOutputGenerator(generator, textOutput, options)
+				if err != nil {
+					resultsChan <- generateResult{Index: index, Err: err}
+					return
+				}
+				response, err := outputGenerator.GeneratePromptResponse(ctx, models.GenerationRequest{
+					Prompt:  prompts[index],
+					Options: generation.Options,
+				})
+				generation.GeneratedAt = GetCurrentTime()
+				generation.Prompt = prompts[index]
 				// Send a Result struct to the channel
-				resultsChan <- generateResult{Index: index, Result: result, Err: err}
-			}(i, textOutput.Input)
+				resultsChan <- generateResult{Index: index, Result: response.Text, Generation: generation, Err: err}
+			}(i, textOutput)
 		}
 	}
 
 	// Process the results
-	for i := 0; i < numberOfGeneratorSections; i++ {
+	var failedOutputs []string
+	for i := 0; i < len(prompts); i++ {
 		result := <-resultsChan
 		log.Print("Processing Result: " + strconv.Itoa(result.Index) + "\n")
 		log.Print("Result: " + result.Result + "\n")
 
 		if result.Err != nil {
 			log.Print("Err: " + string(result.Err.Error()) + "\n")
-			section.TextOutputs[result.Index].Result = "err generating: " + result.Err.Error()
+			section.TextOutputs[result.Index].Result = ""
+			section.TextOutputs[result.Index].Error = result.Err.Error()
+			section.TextOutputs[result.Index].Generation = nil
+			failedOutputs = append(failedOutputs, section.TextOutputs[result.Index].Title)
 		} else {
 			section.TextOutputs[result.Index].Result = result.Result
+			section.TextOutputs[result.Index].Error = ""
+			section.TextOutputs[result.Index].Generation = result.Generation
 		}
 	}
 
@@ -646,18 +710,22 @@ func GenerateSectionGeneratorText(generator interfaces.Generator, section *model
 
 	log.Print("Generation Finished")
 
-	// Restore original inputs
-	originalInputIndex := 0
-	for i, textOutput := range section.TextOutputs {
-		if textOutput.Type == models.Generator {
-			section.TextOutputs[i].Input = originalInputs[originalInputIndex]
-			originalInputIndex += 1
-		}
+	if len(failedOutputs) > 0 && section.FailurePolicy == models.FailSection {
+		return fmt.Errorf("generator outputs failed: %s", strings.Join(failedOutputs, ", "))
 	}
 
 	return nil
 }
 
+func sectionHasGeneratorOutputs(section *models.ReportSection) bool {
+	for _, textOutput := range section.TextOutputs {
+		if textOutput.Type == models.Generator {
+			return true
+		}
+	}
+	return false
+}
+
 // GetReportQuestion finds a question by its index in a slice of questions
 func GetReportQuestion(questions []models.ReportQuestion, index int) (*models.ReportQuestion, error) {
 	if index < len(questions) {
@@ -666,30 +734,32 @@ func GetReportQuestion(questions []models.ReportQuestion, index int) (*models.Re
 	return nil, errors.New("question not found")
 }
 
-// GenerateStaticText processes a TextOutput, splicing in answers into static text outputs.
-func GenerateStaticText(textOutput *models.ReportTextOutput, label, value string) {
-	// Define the pattern to be replaced
-	pattern := "**" + label
+// textOutputGenerator returns the generator of the provider and model named by the text
+// output, when the generator is a registry of them, along with the record of the model and
+// options the output is generated with
+func textOutputGenerator(generator interfaces.Generator, textOutput models.ReportTextOutput, options *models.GenerationOptions) (interfaces.Generator, *models.GenerationRecord, error) {
+	generation := &models.GenerationRecord{
+		Provider: textOutput.Provider,
+		Model:    textOutput.Model,
+		Options:  ResolveGenerationOptions(options, textOutput.Options),
+	}
 
-	// Replace the pattern with the answer in textOutput.Input
-	// If first pass, set it to the input, else set it to the generated output replaced.
-	// This way, you can splice question answers in multiple outputs
-	if textOutput.Result == "" {
-		textOutput.Result = strings.ReplaceAll(textOutput.Input, pattern, value)
-	} else {
-		textOutput.Result = strings.ReplaceAll(textOutput.Result, pattern, value)
+	registry, ok := generator.(interfaces.GeneratorRegistry)
+	if !ok {
+		return generator, generation, nil
 	}
-}
 
-// This function allows users to define answers in their openai prompts as well
-func GenerateGeneratorInput(textOutput *models.ReportTextOutput, label, value string) {
-	// Define the pattern to be replaced
-	pattern := "**" + label
+	provider, model, err := registry.ResolveModel(textOutput.Provider, textOutput.Model)
+	if err != nil {
+		return nil, nil, err
+	}
+	generation.Provider, generation.Model = provider, model
 
-	// Replace the pattern with the answer in textOutput.Input
-	// If first pass, set it to the input, else set it to the generated output replaced.
-	// This way, you can splice question answers in multiple inputs
-	textOutput.Input = strings.ReplaceAll(textOutput.Input, pattern, value)
+	outputGenerator, err := registry.ModelGenerator(provider, model)
+	if err != nil {
+		return nil, nil, err
+	}
+	return outputGenerator, generation, nil
 }
 
 // GetReportSection returns the section from a report based on partIndex and sectionIndex.
@@ -705,6 +775,7 @@ func GetReportSection(report *models.Report, partIndex int, sectionIndex int) (*
 }
 
 // ResetTextOutputResults sets all TextOutput.Result fields to an empty string in the provided section.
+// Generator results are kept in the history of their outputs.
 func ResetTextOutputResults(section *models.ReportSection, generateAIOutput bool) {
 	if section == nil {
 		return // or handle the error as you see fit
@@ -712,7 +783,10 @@ func ResetTextOutputResults(section *models.ReportSection, generateAIOutput bool
 
 	for i := range section.TextOutputs {
 		if generateAIOutput {
+			archiveTextOutputResult(&section.TextOutputs[i])
 			section.TextOutputs[i].Result = ""
+			section.TextOutputs[i].Generation = nil
+			section.TextOutputs[i].Error = ""
 		} else {
 			if section.TextOutputs[i].Type == models.Static {
 				section.TextOutputs[i].Result = ""
@@ -720,6 +794,7 @@ func ResetTextOutputResults(section *models.ReportSection, generateAIOutput bool
 		}
 
 	}
+	assignTextOutputIDs(section)
 }
 
 func updateReportTextOutputs(section *models.ReportSection, newTextOutputs []models.ReportTextOutput, clearGeneratorResult bool) {
@@ -731,11 +806,17 @@ func updateReportTextOutputs(section *models.ReportSection, newTextOutputs []mod
 				found = true
 				// Update existing ReportTextOutput
 				if clearGeneratorResult && newTextOutput.Type == models.Generator {
+					archiveTextOutputResult(&section.TextOutputs[i])
 					section.TextOutputs[i].Result = "" // Clear Result if specified and type is Generator
+					section.TextOutputs[i].Generation = nil
+					section.TextOutputs[i].Error = ""
 				} else {
 					section.TextOutputs[i].Result = newTextOutput.Result
 				}
 				section.TextOutputs[i].Input = newTextOutput.Input
+				section.TextOutputs[i].Provider = newTextOutput.Provider
+				section.TextOutputs[i].Model = newTextOutput.Model
+				section.TextOutputs[i].Options = newTextOutput.Options
 				break
 			}
 		}
@@ -743,10 +824,13 @@ func updateReportTextOutputs(section *models.ReportSection, newTextOutputs []mod
 		if !found {
 			if clearGeneratorResult && newTextOutput.Type == models.Generator {
 				newTextOutput.Result = "" // Clear Result if specified and type is Generator
+				newTextOutput.Generation = nil
+				newTextOutput.Error = ""
 			}
 			section.TextOutputs = append(section.TextOutputs, newTextOutput)
 		}
 	}
+	assignTextOutputIDs(section)
 }
 
 func insertSectionInReport(report *models.Report, partIndex int, sectionIndex int, section models.ReportSection) error {
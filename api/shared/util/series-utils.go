@@ -0,0 +1,69 @@
+package util
+
+import (
+	"api/shared/models"
+	"fmt"
+	"sort"
+)
+
+const (
+	defaultOtherSeriesLabel = "Other"
+	blankSeriesLabel        = "(Blank)"
+)
+
+// seriesKey is the key of a series in a chart row. With a single dependent
+// column the series value is the key, otherwise the dependent label is added.
+func seriesKey(series string, columnConfig *models.ReportOneDimConfig, dependentCount int) string {
+	if dependentCount == 1 {
+		return series
+	}
+	return fmt.Sprintf("%s - %s", series, columnConfig.AggregateValueLabel)
+}
+
+// rankSeries orders the series by their number of rows, most first. When there
+// are more series than the limit, the ones past it are returned to be merged.
+func rankSeries(seriesRows map[string]int, limit int) ([]string, map[string]bool) {
+	series := make([]string, 0, len(seriesRows))
+	for s := range seriesRows {
+		series = append(series, s)
+	}
+	sort.Slice(series, func(i, j int) bool {
+		if seriesRows[series[i]] != seriesRows[series[j]] {
+			return seriesRows[series[i]] > seriesRows[series[j]]
+		}
+		return series[i] < series[j]
+	})
+
+	if limit <= 0 || len(series) <= limit {
+		return series, nil
+	}
+	merged := make(map[string]bool, len(series)-limit)
+	for _, s := range series[limit:] {
+		merged[s] = true
+	}
+	return series[:limit], merged
+}
+
+// mergeOperationOutput adds the values accumulated in src to dst before they
+// are finalized. Counts, sums and average totals add up, and statistics keep
+// every value, so merged series give the same result as if the rows had been
+// in one series from the start.
+func mergeOperationOutput(dst, src map[string]interface{}) {
+	for key, value := range src {
+		switch v := value.(type) {
+		case int:
+			existing, _ := dst[key].(int)
+			dst[key] = existing + v
+		case float64:
+			existing, _ := dst[key].(float64)
+			dst[key] = existing + v
+		case *valueStatistics:
+			existing, ok := dst[key].(*valueStatistics)
+			if !ok {
+				existing = &valueStatistics{}
+				dst[key] = existing
+			}
+			existing.merge(v)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package util
+
+import (
+	"api/shared/models"
+	"fmt"
+	"math"
+	"sort"
+)
+
+const statisticsKeySuffix = "_stats"
+
+// valueStatistics accumulates every value seen for a key by one of the
+// statistic operations. Min, Max and StdDev are kept as running values,
+// while Median and Percentile need every value so they can be ordered.
+type valueStatistics struct {
+	values []float64
+	count  int
+	min    float64
+	max    float64
+	mean   float64
+	m2     float64 // Sum of squared differences from the running mean
+}
+
+func isStatisticOperation(operation models.ChartOperation) bool {
+	switch operation {
+	case models.Median, models.Min, models.Max, models.Percentile, models.StdDev:
+		return true
+	}
+	return false
+}
+
+// validateStatisticOperation checks the settings of a statistic operation before any row is read
+func validateStatisticOperation(columnConfig *models.ReportOneDimConfig) error {
+	if !isStatisticOperation(columnConfig.OperationType) {
+		return nil
+	}
+	if columnConfig.AggregateValueLabel == "" {
+		return fmt.Errorf("aggregate value cannot be unassigned for operation type: %s", columnConfig.OperationType)
+	}
+	if columnConfig.OperationType == models.Percentile && (columnConfig.Percentile <= 0 || columnConfig.Percentile > 100) {
+		return fmt.Errorf("percentile must be greater than 0 and at most 100, got: %v", columnConfig.Percentile)
+	}
+	return nil
+}
+
+func addStatisticValue(output map[string]interface{}, num float64, key string, operation models.ChartOperation) {
+	statsKey := key + statisticsKeySuffix
+	stats, exists := output[statsKey].(*valueStatistics)
+	if !exists {
+		stats = &valueStatistics{min: num, max: num}
+		output[statsKey] = stats
+	}
+	stats.add(num, operation == models.Median || operation == models.Percentile)
+}
+
+// calculateStatistics replaces the statistic accumulated for the config in the output with its final value.
+// Only the key of the config is touched, as chart groups hold the statistics of several dependent columns.
+func calculateStatistics(output map[string]interface{}, columnConfig *models.ReportOneDimConfig) {
+	statsKey := columnConfig.AggregateValueLabel + statisticsKeySuffix
+	stats, ok := output[statsKey].(*valueStatistics)
+	if !ok {
+		return
+	}
+	result := stats.result(columnConfig.OperationType, columnConfig.Percentile)
+	output[columnConfig.AggregateValueLabel] = math.Round(result*1000) / 1000
+	delete(output, statsKey)
+}
+
+func (s *valueStatistics) add(value float64, keepValue bool) {
+	if keepValue {
+		s.values = append(s.values, value)
+	}
+	s.count++
+	s.min = math.Min(s.min, value)
+	s.max = math.Max(s.max, value)
+
+	// Welford's online algorithm keeps the variance stable over large files
+	delta := value - s.mean
+	s.mean += delta / float64(s.count)
+	s.m2 += delta * (value - s.mean)
+}
+
+func (s *valueStatistics) result(operation models.ChartOperation, percentile float64) float64 {
+	switch operation {
+	case models.Min:
+		return s.min
+	case models.Max:
+		return s.max
+	case models.StdDev:
+		// Sample standard deviation, matching STDEV.S in spreadsheets
+		if s.count < 2 {
+			return 0
+		}
+		return math.Sqrt(s.m2 / float64(s.count-1))
+	case models.Median:
+		return percentileOf(s.values, 50)
+	case models.Percentile:
+		return percentileOf(s.values, percentile)
+	}
+	return 0
+}
+
+// percentileOf returns the percentile of the values, interpolating linearly
+// between the closest ranks the same way as PERCENTILE.INC in spreadsheets.
+func percentileOf(values []float64, percentile float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sort.Float64s(values)
+	rank := percentile / 100 * float64(len(values)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	return values[lower] + (values[upper]-values[lower])*(rank-float64(lower))
+}
+
+// merge adds the values accumulated by other, combining the running values with
+// the parallel form of Welford's algorithm
+func (s *valueStatistics) merge(other *valueStatistics) {
+	if other.count == 0 {
+		return
+	}
+	if s.count == 0 {
+		*s = valueStatistics{
+			values: append([]float64(nil), other.values...),
+			count:  other.count,
+			min:    other.min,
+			max:    other.max,
+			mean:   other.mean,
+			m2:     other.m2,
+		}
+		return
+	}
+	count := s.count + other.count
+	delta := other.mean - s.mean
+	s.values = append(s.values, other.values...)
+	s.min = math.Min(s.min, other.min)
+	s.max = math.Max(s.max, other.max)
+	s.mean += delta * float64(other.count) / float64(count)
+	s.m2 += other.m2 + delta*delta*float64(s.count)*float64(other.count)/float64(count)
+	s.count = count
+}
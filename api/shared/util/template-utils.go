@@ -220,6 +220,11 @@ func ConvertTemplateToReport(templateID, reportTitle, reportCity, reportType, us
 		return fmt.Errorf("template not found: %v", err)
 	}
 
+	err = ItemPlaceholderErrors(LintTemplatePlaceholders(template))
+	if err != nil {
+		return err
+	}
+
 	ownerNickName, err := GetUserNickname(userID)
 
 	if err != nil {
@@ -241,10 +246,11 @@ func ConvertTemplateToReport(templateID, reportTitle, reportCity, reportType, us
 		CreatedAt:      GetCurrentTime(),
 		LastModifiedAt: GetCurrentTime(),
 		// Create empty parts for filling
-		Parts:           make([]models.ReportPart, 0),
-		CSVID:           "no-csv-id", // Needed because CSVID is a GSI, and cannot be null
-		CSVColumnsS3Key: "no-csv-s3-key",
-		GlobalQuestions: make([]models.ReportQuestion, len(template.GlobalQuestions)),
+		Parts:             make([]models.ReportPart, 0),
+		CSVID:             "no-csv-id", // Needed because CSVID is a GSI, and cannot be null
+		CSVColumnsS3Key:   "no-csv-s3-key",
+		GlobalQuestions:   make([]models.ReportQuestion, len(template.GlobalQuestions)),
+		GenerationOptions: template.GenerationOptions,
 	}
 
 	// Convert Global Questions
@@ -271,11 +277,13 @@ func ConvertTemplateToReport(templateID, reportTitle, reportCity, reportType, us
 		for _, templateSection := range templatePart.Sections {
 			reportSection := models.ReportSection{
 				Title:           templateSection.Title,
+				Label:           templateSection.Label,
 				OutputGenerated: false,
 				Questions:       make([]models.ReportQuestion, len(templateSection.Questions)),
 				CSVData:         make([]models.ReportCSVData, len(templateSection.CSVData)),
 				TextOutputs:     make([]models.ReportTextOutput, len(templateSection.TextOutputs)),
 				ChartOutputs:    make([]models.ReportChartOutput, len(templateSection.ChartOutputs)),
+				FailurePolicy:   templateSection.FailurePolicy,
 			}
 
 			// Convert TemplateQuestions to ReportQuestions
@@ -290,19 +298,26 @@ func ConvertTemplateToReport(templateID, reportTitle, reportCity, reportType, us
 			// Convert TemplateTextOutputs to ReportTextOutputs
 			for k, textOutput := range templateSection.TextOutputs {
 				reportSection.TextOutputs[k] = models.ReportTextOutput{
-					Title:  textOutput.Title,
-					Type:   textOutput.Type,
-					Input:  textOutput.Input,
-					Result: "", // Initialize with empty result
+					ID:       uuid.New().String(),
+					Title:    textOutput.Title,
+					Type:     textOutput.Type,
+					Input:    textOutput.Input,
+					Result:   "", // Initialize with empty result
+					Provider: textOutput.Provider,
+					Model:    textOutput.Model,
+					Options:  textOutput.Options,
 				}
 			}
 
 			// Convert TemplateCsvData to ReportCsvData
 			for l, data := range templateSection.CSVData {
 				reportSection.CSVData[l] = models.ReportCSVData{
+					Dataset:         data.Dataset,
 					Label:           data.Label,
 					Description:     data.Description,
 					OperationType:   data.OperationType,
+					Percentile:      data.Percentile,
+					IntervalUnit:    data.IntervalUnit,
 					OperationColumn: "",
 					AcceptedValues:  make([]string, 0),
 				}
@@ -317,12 +332,16 @@ func ConvertTemplateToReport(templateID, reportTitle, reportCity, reportType, us
 						AggregateValueLabel: templateDependentColumn.AggregateValueLabel,
 						Description:         templateDependentColumn.Description,
 						OperationType:       templateDependentColumn.OperationType,
+						Percentile:          templateDependentColumn.Percentile,
+						IntervalUnit:        templateDependentColumn.IntervalUnit,
 						Column:              "",
 						AcceptedValues:      make([]string, 0),
 					}
 				}
 
 				reportSection.ChartOutputs[h] = models.ReportChartOutput{
+					Dataset:                chart.Dataset,
+					Label:                  chart.Label,
 					Title:                  chart.Title,
 					Type:                   chart.Type,
 					Description:            chart.Description,
@@ -330,6 +349,9 @@ func ConvertTemplateToReport(templateID, reportTitle, reportCity, reportType, us
 					YAxisTitle:             chart.YAxisTitle,
 					CartesianGrid:          chart.CartesianGrid,
 					IndependentColumnLabel: chart.IndependentColumnLabel,
+					TimeBucket:             chart.TimeBucket,
+					SeriesLimit:            chart.SeriesLimit,
+					OtherSeriesLabel:       chart.OtherSeriesLabel,
 					DependentColumns:       newDependentColumns,
 					Results:                make([]map[string]interface{}, 0),
 				}
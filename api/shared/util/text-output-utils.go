@@ -0,0 +1,235 @@
+package util
+
+import (
+	"api/shared/models"
+	"context"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// Generator outputs keep this many of their previous results to compare and restore
+	maxTextOutputHistory = 10
+	// Bytes of results and prompts the history of an output holds at most, so reports
+	// with many generator outputs stay under the DynamoDB item size limit. The newest
+	// version is kept whatever its size.
+	maxTextOutputHistoryBytes = 16 * 1024
+	// Bytes of the prompt kept with a version, since prompts can hold whole chart tables
+	maxTextOutputHistoryPromptBytes = 2 * 1024
+)
+
+var (
+	ErrTextOutputNotFound        = errors.New("text output not found")
+	ErrTextOutputVersionNotFound = errors.New("text output version not found")
+)
+
+// assignTextOutputIDs gives the text outputs of a section that have none an id
+func assignTextOutputIDs(section *models.ReportSection) {
+	for i := range section.TextOutputs {
+		if section.TextOutputs[i].ID == "" {
+			section.TextOutputs[i].ID = uuid.New().String()
+		}
+	}
+}
+
+// archiveTextOutputResult adds the result of a generator output to its history before it
+// is replaced, dropping the oldest results past the limits
+func archiveTextOutputResult(textOutput *models.ReportTextOutput) {
+	if textOutput.Type != models.Generator || textOutput.Result == "" || textOutput.Generation == nil {
+		return
+	}
+
+	version := models.TextOutputVersion{Result: textOutput.Result, Generation: *textOutput.Generation}
+	version.Generation.Prompt = historyPrompt(version.Generation.Prompt)
+	textOutput.History = append([]models.TextOutputVersion{version}, textOutput.History...)
+	if len(textOutput.History) > maxTextOutputHistory {
+		textOutput.History = textOutput.History[:maxTextOutputHistory]
+	}
+
+	size := 0
+	for i, version := range textOutput.History {
+		size += len(version.Result) + len(version.Generation.Prompt)
+		if i > 0 && size > maxTextOutputHistoryBytes {
+			textOutput.History = textOutput.History[:i]
+			break
+		}
+	}
+}
+
+// historyPrompt returns the prompt kept with a version in the history, cut short when it is long
+func historyPrompt(prompt string) string {
+	if len(prompt) <= maxTextOutputHistoryPromptBytes {
+		return prompt
+	}
+	cut := maxTextOutputHistoryPromptBytes
+	for cut > 0 && !utf8.RuneStart(prompt[cut]) {
+		cut--
+	}
+	return prompt[:cut] + "…"
+}
+
+// dropOldestTextOutputVersions removes the oldest version from the history of every text
+// output of the section, for a section too large to save. Returns false when there are none.
+func dropOldestTextOutputVersions(section *models.ReportSection) bool {
+	dropped := false
+	for i := range section.TextOutputs {
+		history := section.TextOutputs[i].History
+		if len(history) > 0 {
+			section.TextOutputs[i].History = history[:len(history)-1]
+			dropped = true
+		}
+	}
+	return dropped
+}
+
+// findTextOutput returns the index of the text output with the id, or the index given when
+// the id is empty
+func findTextOutput(section *models.ReportSection, index int, id string) (int, error) {
+	if id != "" {
+		for i, textOutput := range section.TextOutputs {
+			if textOutput.ID == id {
+				return i, nil
+			}
+		}
+		return 0, ErrTextOutputNotFound
+	}
+
+	if index < 0 || index >= len(section.TextOutputs) {
+		return 0, ErrTextOutputNotFound
+	}
+	return index, nil
+}
+
+// RegenerateTextOutput generates one text output of a section again with the answers and
+// results the section was last generated with, leaving its other outputs as they are. The
+// previous result of a generator output is kept in its history. An output that fails to
+// generate is not saved, so it keeps its result.
+func RegenerateTextOutput(ctx context.Context, reportID string, partIndex, sectionIndex, textOutputIndex int, textOutputID string, userID string) (*models.ReportTextOutput, error) {
+	report, err := GetReport(reportID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting report from DynamoDB: %v", err)
+	}
+
+	if report == nil {
+		return nil, fmt.Errorf("report not found")
+	}
+
+	section, err := GetReportSection(report, partIndex, sectionIndex)
+	if err != nil {
+		return nil, fmt.Errorf("error getting section: %v", err)
+	}
+
+	i, err := findTextOutput(section, textOutputIndex, textOutputID)
+	if err != nil {
+		return nil, err
+	}
+
+	ref := models.SectionRef{PartIndex: partIndex, SectionIndex: sectionIndex}
+	err = reportSectionReferenceGraph(report).cycleError(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	// Generate the output in a copy of the section holding only it
+	single := *section
+	single.TextOutputs = []models.ReportTextOutput{section.TextOutputs[i]}
+	sections := ReportSectionsByLabel(report)
+
+	if single.TextOutputs[0].Type == models.Generator {
+		organizationID, err := GetUserOrganization(userID)
+		if err != nil {
+			return nil, fmt.Errorf("error getting user organization: %v", err)
+		}
+
+		generator := NewMeteredRegistry(models.UsageContext{
+			UserID:         userID,
+			OrganizationID: organizationID,
+			ReportID:       reportID,
+			PartIndex:      partIndex,
+			SectionIndex:   sectionIndex,
+			SectionTitle:   section.Title,
+		})
+		err = GenerateSectionGeneratorText(ctx, generator, &single, &report.GlobalQuestions, sections, &report.GenerationOptions)
+		if err != nil {
+			return nil, fmt.Errorf("error creating generator output: %v", err)
+		}
+	} else {
+		GenerateSectionStaticText(&single, &report.GlobalQuestions, sections)
+	}
+
+	if single.TextOutputs[0].Error != "" {
+		return nil, fmt.Errorf("error generating text output: %s", single.TextOutputs[0].Error)
+	}
+
+	textOutput := &section.TextOutputs[i]
+	archiveTextOutputResult(textOutput)
+	textOutput.Result = single.TextOutputs[0].Result
+	textOutput.Generation = single.TextOutputs[0].Generation
+	textOutput.Error = ""
+	assignTextOutputIDs(section)
+
+	err = saveReportSection(reportID, ref, section)
+	if err != nil {
+		return nil, fmt.Errorf("error saving section: %v", err)
+	}
+
+	return textOutput, nil
+}
+
+// RestoreTextOutputVersion makes a result in the history of a generator output its result
+// again. Versions are counted from 0, the newest. The result it replaces takes its place
+// at the front of the history.
+func RestoreTextOutputVersion(reportID string, partIndex, sectionIndex, textOutputIndex int, textOutputID string, version int, userID string) (*models.ReportTextOutput, error) {
+	report, err := GetReport(reportID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting report from DynamoDB: %v", err)
+	}
+
+	if report == nil {
+		return nil, fmt.Errorf("report not found")
+	}
+
+	section, err := GetReportSection(report, partIndex, sectionIndex)
+	if err != nil {
+		return nil, fmt.Errorf("error getting section: %v", err)
+	}
+
+	i, err := findTextOutput(section, textOutputIndex, textOutputID)
+	if err != nil {
+		return nil, err
+	}
+
+	textOutput := &section.TextOutputs[i]
+	err = RestoreTextOutputResult(textOutput, version)
+	if err != nil {
+		return nil, err
+	}
+	assignTextOutputIDs(section)
+
+	err = saveReportSection(reportID, models.SectionRef{PartIndex: partIndex, SectionIndex: sectionIndex}, section)
+	if err != nil {
+		return nil, fmt.Errorf("error saving section: %v", err)
+	}
+
+	return textOutput, nil
+}
+
+// RestoreTextOutputResult swaps the result of a text output with a version in its history
+func RestoreTextOutputResult(textOutput *models.ReportTextOutput, version int) error {
+	if version < 0 || version >= len(textOutput.History) {
+		return ErrTextOutputVersionNotFound
+	}
+
+	restored := textOutput.History[version]
+	textOutput.History = append(textOutput.History[:version:version], textOutput.History[version+1:]...)
+	archiveTextOutputResult(textOutput)
+
+	textOutput.Result = restored.Result
+	generation := restored.Generation
+	textOutput.Generation = &generation
+	textOutput.Error = ""
+	return nil
+}
@@ -0,0 +1,156 @@
+package util
+
+import (
+	"api/shared/models"
+	"fmt"
+	"time"
+)
+
+// Buckets a chart can have between its first and last date. A placeholder date such as
+// 0001-01-01 would otherwise fill in tens of thousands of empty buckets.
+const maxTimeBuckets = 1000
+
+// isoWeekEpoch is the Monday that ISO week ordinals are counted from
+var isoWeekEpoch = time.Date(1969, time.December, 29, 0, 0, 0, 0, time.UTC)
+
+// timeBucketer maps the values of a date/time independent column to the
+// bucket they fall in. Every bucket has an ordinal so the buckets can be
+// sorted in date order and the gaps between them filled.
+type timeBucketer struct {
+	bucket   models.TimeBucket
+	layouts  []string
+	location *time.Location
+}
+
+func newTimeBucketer(reportOutput *models.ReportChartOutput) (*timeBucketer, error) {
+	switch reportOutput.TimeBucket {
+	case "":
+		return nil, nil
+	case models.YearBucket, models.QuarterBucket, models.MonthBucket,
+		models.ISOWeekBucket, models.DayOfWeekBucket, models.HourOfDayBucket:
+	default:
+		return nil, fmt.Errorf("unsupported time bucket: %s", reportOutput.TimeBucket)
+	}
+
+	location, err := loadTimeZone(reportOutput.TimeZone)
+	if err != nil {
+		return nil, err
+	}
+
+	return &timeBucketer{
+		bucket:   reportOutput.TimeBucket,
+		layouts:  dateLayouts(reportOutput.DateFormat),
+		location: location,
+	}, nil
+}
+
+// bucketOf returns the label and ordinal of the bucket the value falls in
+func (b *timeBucketer) bucketOf(value string) (string, int, bool) {
+	parsed, ok := parseTimeValue(value, b.layouts, b.location)
+	if !ok {
+		return "", 0, false
+	}
+
+	var ordinal int
+	switch b.bucket {
+	case models.YearBucket:
+		ordinal = parsed.Year()
+	case models.QuarterBucket:
+		ordinal = parsed.Year()*4 + (int(parsed.Month())-1)/3
+	case models.MonthBucket:
+		ordinal = parsed.Year()*12 + int(parsed.Month()) - 1
+	case models.ISOWeekBucket:
+		day := time.Date(parsed.Year(), parsed.Month(), parsed.Day(), 0, 0, 0, 0, time.UTC)
+		days := int(day.Sub(isoWeekEpoch).Hours() / 24)
+		ordinal = days / 7
+		if days < 0 && days%7 != 0 {
+			ordinal-- // Round down for dates before the epoch
+		}
+	case models.DayOfWeekBucket:
+		// Monday first, as in ISO weeks
+		ordinal = (int(parsed.Weekday()) + 6) % 7
+	case models.HourOfDayBucket:
+		ordinal = parsed.Hour()
+	}
+	return b.label(ordinal), ordinal, true
+}
+
+func (b *timeBucketer) label(ordinal int) string {
+	switch b.bucket {
+	case models.YearBucket:
+		return fmt.Sprintf("%d", ordinal)
+	case models.QuarterBucket:
+		return fmt.Sprintf("%d Q%d", ordinal/4, ordinal%4+1)
+	case models.MonthBucket:
+		return fmt.Sprintf("%d-%02d", ordinal/12, ordinal%12+1)
+	case models.ISOWeekBucket:
+		year, week := isoWeekEpoch.AddDate(0, 0, ordinal*7).ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	case models.DayOfWeekBucket:
+		return time.Weekday((ordinal + 1) % 7).String()
+	case models.HourOfDayBucket:
+		return fmt.Sprintf("%02d:00", ordinal)
+	}
+	return ""
+}
+
+// ordinalRange returns the ordinals to show given the ones seen in the data.
+// Day of week and hour of day always show the whole cycle, while the other
+// buckets cover every bucket between the first and last seen.
+func (b *timeBucketer) ordinalRange(ordinals map[string]int) (int, int, bool) {
+	switch b.bucket {
+	case models.DayOfWeekBucket:
+		return 0, 6, true
+	case models.HourOfDayBucket:
+		return 0, 23, true
+	}
+	if len(ordinals) == 0 {
+		return 0, 0, false
+	}
+	first, last := 0, 0
+	initialized := false
+	for _, ordinal := range ordinals {
+		if !initialized || ordinal < first {
+			first = ordinal
+		}
+		if !initialized || ordinal > last {
+			last = ordinal
+		}
+		initialized = true
+	}
+	return first, last, true
+}
+
+// fillEmptyBuckets adds a group for every bucket in range that had no rows,
+// with every key seen in the other groups set to zero. Returns an error when the
+// range has more buckets than a chart can have.
+func (b *timeBucketer) fillEmptyBuckets(output map[string]map[string]interface{}, ordinals map[string]int) error {
+	first, last, ok := b.ordinalRange(ordinals)
+	if !ok {
+		return nil
+	}
+	if last-first >= maxTimeBuckets {
+		return fmt.Errorf("dates from %s to %s span more than %d buckets, check the column for placeholder dates", b.label(first), b.label(last), maxTimeBuckets)
+	}
+
+	keys := make(map[string]bool)
+	for _, group := range output {
+		for key := range group {
+			keys[key] = true
+		}
+	}
+
+	for ordinal := first; ordinal <= last; ordinal++ {
+		label := b.label(ordinal)
+		if _, exists := output[label]; exists {
+			continue
+		}
+		group := make(map[string]interface{}, len(keys))
+		for key := range keys {
+			group[key] = 0
+		}
+		output[label] = group
+		ordinals[label] = ordinal
+	}
+	return nil
+}
@@ -1,8 +1,124 @@
 package util
 
-import "time"
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	// Lambda runtimes do not ship a zoneinfo database, so embed it for csv time zones
+	_ "time/tzdata"
+)
 
 func GetCurrentTime() int64 {
 	now := time.Now()
 	return now.Unix()
 }
+
+// Layouts tried in order when a column does not specify its date format
+var commonDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006/01/02 15:04:05",
+	"2006/01/02 15:04",
+	"2006/01/02",
+	"1/2/2006 15:04:05",
+	"1/2/2006 15:04",
+	"1/2/2006 3:04:05 PM",
+	"1/2/2006 3:04 PM",
+	"1/2/2006",
+	"Jan 2, 2006 15:04:05",
+	"Jan 2, 2006",
+	"2 Jan 2006 15:04:05",
+	"2 Jan 2006",
+}
+
+// Format tokens, longest first, and their equivalent in a Go time layout
+var dateFormatTokens = []struct {
+	token  string
+	layout string
+}{
+	{"YYYY", "2006"},
+	{"YY", "06"},
+	{"MMMM", "January"},
+	{"MMM", "Jan"},
+	{"MM", "01"},
+	{"M", "1"},
+	{"DD", "02"},
+	{"D", "2"},
+	{"dddd", "Monday"},
+	{"ddd", "Mon"},
+	{"HH", "15"},
+	{"H", "15"},
+	{"hh", "03"},
+	{"h", "3"},
+	{"mm", "04"},
+	{"m", "4"},
+	{"ss", "05"},
+	{"s", "5"},
+	{"A", "PM"},
+	{"a", "pm"},
+	{"ZZ", "-0700"},
+	{"Z", "-07:00"},
+}
+
+// dateLayouts returns the Go time layouts to parse a column with. The format
+// uses tokens like "MM/DD/YYYY HH:mm:ss", although a Go layout is accepted as
+// is. When the format is empty the common date layouts are tried instead.
+func dateLayouts(format string) []string {
+	if format == "" {
+		return commonDateLayouts
+	}
+	if strings.Contains(format, "2006") {
+		return []string{format}
+	}
+
+	var layout strings.Builder
+	for i := 0; i < len(format); {
+		matched := false
+		for _, t := range dateFormatTokens {
+			if strings.HasPrefix(format[i:], t.token) {
+				layout.WriteString(t.layout)
+				i += len(t.token)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			layout.WriteByte(format[i])
+			i++
+		}
+	}
+	return []string{layout.String()}
+}
+
+// loadTimeZone returns the location for an IANA time zone name, defaulting to UTC
+func loadTimeZone(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone '%s': %v", name, err)
+	}
+	return location, nil
+}
+
+// parseTimeValue parses a csv cell with the first layout that matches. Values
+// without an offset are read in the location, and values with one are
+// converted to it, so that buckets such as the hour of day are local times.
+func parseTimeValue(value string, layouts []string, location *time.Location) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range layouts {
+		parsed, err := time.ParseInLocation(layout, value, location)
+		if err == nil {
+			return parsed.In(location), true
+		}
+	}
+	return time.Time{}, false
+}
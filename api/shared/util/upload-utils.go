@@ -0,0 +1,411 @@
+package util
+
+import (
+	"api/shared/constants"
+	"api/shared/models"
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Files are uploaded under this prefix in the csv bucket. Once normalized they are
+// written to the csv key the report points to, which starts reading the columns.
+const rawUploadPrefix = "uploads/"
+
+// Delimiters tried when the delimiter of a csv is not given. Comma wins ties.
+var sniffedDelimiters = []rune{',', ';', '\t', '|'}
+
+var uploadContentTypes = map[models.UploadFileType]string{
+	models.CSVFile:  "text/csv",
+	models.TSVFile:  "text/tab-separated-values",
+	models.XLSXFile: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// ValidateUploadOptions checks the options of an upload and fills in the file type
+func ValidateUploadOptions(options *models.UploadOptions) error {
+	if options.FileType == "" {
+		options.FileType = models.CSVFile
+	}
+	if _, ok := uploadContentTypes[options.FileType]; !ok {
+		return fmt.Errorf("unsupported file type '%s'", options.FileType)
+	}
+	if options.Delimiter != "" {
+		if options.FileType == models.XLSXFile {
+			return fmt.Errorf("a delimiter can not be set for xlsx files")
+		}
+		if utf8.RuneCountInString(options.Delimiter) != 1 || options.Delimiter == "\"" || options.Delimiter == "\n" || options.Delimiter == "\r" {
+			return fmt.Errorf("invalid delimiter '%s', it must be a single character other than a quote or a line break", options.Delimiter)
+		}
+	}
+	if options.Sheet != "" && options.FileType != models.XLSXFile {
+		return fmt.Errorf("a sheet can only be set for xlsx files")
+	}
+	return nil
+}
+
+// rawUploadKey is the key a file is uploaded to before it is normalized to the csv key
+func rawUploadKey(csvKey string, fileType models.UploadFileType) string {
+	return rawUploadPrefix + strings.TrimSuffix(csvKey, ".csv") + "." + string(fileType)
+}
+
+// IsRawUploadKey reports whether a key of the csv bucket is a file waiting to be normalized
+func IsRawUploadKey(s3Key string) bool {
+	return strings.HasPrefix(s3Key, rawUploadPrefix)
+}
+
+// CSVKeyFromRawUpload returns the csv key an uploaded file is normalized to, which is
+// also the id of its upload operation
+func CSVKeyFromRawUpload(s3Key string) string {
+	key := strings.TrimPrefix(s3Key, rawUploadPrefix)
+	return strings.TrimSuffix(key, path.Ext(key)) + ".csv"
+}
+
+// NormalizeUploadedFile converts an uploaded file to a comma delimited UTF-8 csv, using the
+// options stored on its upload operation, and writes it to the csv key of the report. The
+// validation report is stored on the operation, which fails when the file is not valid.
+// The uploaded file is deleted once it is read. There is no report when the operation
+// was cancelled before the file was read.
+func NormalizeUploadedFile(s3Key string) (*models.ValidationReport, error) {
+	csvKey := CSVKeyFromRawUpload(s3Key)
+	options, err := GetOperationUploadOptions(csvKey)
+	if err != nil {
+		return nil, fmt.Errorf("error getting upload options: %v", err)
+	}
+
+	s3Client, err := GetS3Client(constants.USEast2)
+	if err != nil {
+		return nil, err
+	}
+
+	// The uploaded file is removed whether or not it can be normalized
+	bucket := os.Getenv(constants.CsvBucketName)
+	defer s3Client.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(s3Key),
+	})
+
+	err = StartOperation(csvKey, models.OperationProgress{Step: "Reading uploaded file", TotalSteps: 2})
+	if err == ErrOperationFinished {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error starting operation: %v", err)
+	}
+
+	file, err := GetCSVFileHandle(s3Key)
+	if err != nil {
+		return nil, fmt.Errorf("error loading uploaded file from S3: %v", err)
+	}
+	defer file.Close()
+
+	normalized, validation, err := NormalizeUpload(file, options)
+	if err != nil {
+		return nil, err
+	}
+
+	if !validation.Valid {
+		err = FailOperation(csvKey, "uploaded file is not valid", validation)
+		if err != nil && err != ErrOperationFinished {
+			return nil, fmt.Errorf("error setting operation failed: %v", err)
+		}
+		return validation, nil
+	}
+	defer normalized.Close()
+
+	// The warnings are stored first since writing the csv completes the operation
+	err = SetOperationValidation(csvKey, *validation)
+	if err == nil {
+		err = UpdateOperationProgress(csvKey, models.OperationProgress{Percent: 50, Step: "Reading columns", CompletedSteps: 1, TotalSteps: 2})
+	}
+	if err == ErrOperationFinished {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error storing validation report: %v", err)
+	}
+
+	_, err = s3Client.PutObject(&s3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(csvKey),
+		Body:        normalized,
+		ContentType: aws.String("text/csv"),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error uploading normalized csv: %v", err)
+	}
+
+	return validation, nil
+}
+
+// NormalizeUpload reads an uploaded file and returns a temporary file with the same rows
+// as a comma delimited UTF-8 csv without a byte order mark, along with the diagnostics of
+// its validation. Rows are padded or trimmed of trailing blank cells to the number of
+// headers, and blank rows are dropped. There is no file when the upload is not valid.
+func NormalizeUpload(file *os.File, options models.UploadOptions) (*os.File, *models.ValidationReport, error) {
+	normalized, err := createTempCSVFile()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	writer := &normalizedCSVWriter{
+		writer:    csv.NewWriter(normalized),
+		validator: newUploadValidator(options.FileType == models.XLSXFile),
+	}
+	if options.FileType == models.XLSXFile {
+		err = readXLSXSheet(file, options.Sheet, writer.write)
+	} else {
+		err = readDelimitedText(file, options, writer.write)
+	}
+
+	// Rows the csv reader can not parse are reported rather than failing the upload
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		writer.validator.malformed(parseErr)
+		err = nil
+	}
+	validation := writer.validator.finish()
+
+	if err == nil && validation.Valid {
+		writer.writer.Flush()
+		err = writer.writer.Error()
+	}
+	if err == nil && validation.Valid {
+		_, err = normalized.Seek(0, io.SeekStart)
+	}
+	if err != nil || !validation.Valid {
+		normalized.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, validation, nil
+	}
+	return normalized, validation, nil
+}
+
+type normalizedCSVWriter struct {
+	writer     *csv.Writer
+	validator  *uploadValidator
+	hasHeaders bool
+}
+
+func (w *normalizedCSVWriter) write(row []string, line int) error {
+	blank := true
+	for _, value := range row {
+		if strings.TrimSpace(value) != "" {
+			blank = false
+			break
+		}
+	}
+	if blank {
+		return nil
+	}
+
+	if !w.hasHeaders {
+		w.hasHeaders = true
+		row = w.validator.header(row, line)
+	} else if w.validator.headers != nil {
+		row = w.validator.row(row, line)
+	} else {
+		// Rows can not be checked without usable headers
+		return nil
+	}
+	if row == nil {
+		return nil
+	}
+	return w.writer.Write(row)
+}
+
+// readDelimitedText reads the rows of a csv or tsv in any of the encodings we receive,
+// detecting the delimiter from the header line when it is not given
+func readDelimitedText(file *os.File, options models.UploadOptions, writeRow func(row []string, line int) error) error {
+	decoded, err := decodeText(file)
+	if err != nil {
+		return err
+	}
+	reader := bufio.NewReaderSize(decoded, 64*1024)
+
+	delimiter := '\t'
+	switch {
+	case options.Delimiter != "":
+		delimiter, _ = utf8.DecodeRuneInString(options.Delimiter)
+	case options.FileType != models.TSVFile:
+		// The header line is expected to fit in the buffer, Peek returns what it has otherwise
+		head, _ := reader.Peek(reader.Size())
+		delimiter = sniffDelimiter(string(head))
+	}
+
+	csvReader := csv.NewReader(reader)
+	csvReader.Comma = delimiter
+	csvReader.FieldsPerRecord = -1
+
+	for {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		line, _ := csvReader.FieldPos(0)
+		if err := writeRow(record, line); err != nil {
+			return err
+		}
+	}
+}
+
+// sniffDelimiter picks the delimiter found most often in the first line, outside of quotes
+func sniffDelimiter(text string) rune {
+	counts := make(map[rune]int, len(sniffedDelimiters))
+	inQuotes := false
+	for _, r := range text {
+		if r == '"' {
+			inQuotes = !inQuotes
+			continue
+		}
+		if !inQuotes && (r == '\n' || r == '\r') {
+			break
+		}
+		if !inQuotes {
+			counts[r]++
+		}
+	}
+
+	delimiter := sniffedDelimiters[0]
+	for _, candidate := range sniffedDelimiters[1:] {
+		if counts[candidate] > counts[delimiter] {
+			delimiter = candidate
+		}
+	}
+	return delimiter
+}
+
+// decodeText returns a UTF-8 reader of the file. A byte order mark picks UTF-8 or UTF-16,
+// otherwise files that are not valid UTF-8 are read as Windows-1252, which also covers
+// Latin-1 exports.
+func decodeText(file *os.File) (io.Reader, error) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	reader := bufio.NewReader(file)
+	bom, _ := reader.Peek(3)
+
+	switch {
+	case len(bom) >= 3 && bom[0] == 0xEF && bom[1] == 0xBB && bom[2] == 0xBF:
+		reader.Discard(3)
+		return reader, nil
+	case len(bom) >= 2 && bom[0] == 0xFF && bom[1] == 0xFE:
+		reader.Discard(2)
+		return &transcodingReader{source: reader, next: utf16Decoder(false)}, nil
+	case len(bom) >= 2 && bom[0] == 0xFE && bom[1] == 0xFF:
+		reader.Discard(2)
+		return &transcodingReader{source: reader, next: utf16Decoder(true)}, nil
+	}
+
+	isUTF8, err := isValidUTF8(reader)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if isUTF8 {
+		return file, nil
+	}
+	return &transcodingReader{source: bufio.NewReader(file), next: decodeWindows1252}, nil
+}
+
+func isValidUTF8(reader *bufio.Reader) (bool, error) {
+	for {
+		r, size, err := reader.ReadRune()
+		if err == io.EOF {
+			return true, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("error reading uploaded file: %v", err)
+		}
+		if r == utf8.RuneError && size == 1 {
+			return false, nil
+		}
+	}
+}
+
+// transcodingReader reads runes of another encoding from the source and returns them as UTF-8
+type transcodingReader struct {
+	source  *bufio.Reader
+	next    func(source *bufio.Reader) (rune, error)
+	pending []byte
+}
+
+func (r *transcodingReader) Read(p []byte) (int, error) {
+	for len(r.pending) < len(p) {
+		c, err := r.next(r.source)
+		if err != nil {
+			if len(r.pending) == 0 {
+				return 0, err
+			}
+			break
+		}
+		r.pending = utf8.AppendRune(r.pending, c)
+	}
+	n := copy(p, r.pending)
+	r.pending = append(r.pending[:0], r.pending[n:]...)
+	return n, nil
+}
+
+func utf16Decoder(bigEndian bool) func(source *bufio.Reader) (rune, error) {
+	readUnit := func(source *bufio.Reader) (uint16, error) {
+		var unit [2]byte
+		if _, err := io.ReadFull(source, unit[:]); err != nil {
+			return 0, err
+		}
+		if bigEndian {
+			return uint16(unit[0])<<8 | uint16(unit[1]), nil
+		}
+		return uint16(unit[1])<<8 | uint16(unit[0]), nil
+	}
+
+	return func(source *bufio.Reader) (rune, error) {
+		unit, err := readUnit(source)
+		if err != nil {
+			return 0, err
+		}
+		if !utf16.IsSurrogate(rune(unit)) {
+			return rune(unit), nil
+		}
+		low, err := readUnit(source)
+		if err != nil {
+			return utf8.RuneError, nil
+		}
+		return utf16.DecodeRune(rune(unit), rune(low)), nil
+	}
+}
+
+// Windows-1252 differs from Latin-1 only in 0x80 to 0x9F. The five unused bytes map to
+// the control characters of the same value.
+var windows1252Runes = [32]rune{
+	'€', '\u0081', '‚', 'ƒ', '„', '…', '†', '‡',
+	'ˆ', '‰', 'Š', '‹', 'Œ', '\u008D', 'Ž', '\u008F',
+	'\u0090', '‘', '’', '“', '”', '•', '–', '—',
+	'˜', '™', 'š', '›', 'œ', '\u009D', 'ž', 'Ÿ',
+}
+
+func decodeWindows1252(source *bufio.Reader) (rune, error) {
+	b, err := source.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if b >= 0x80 && b <= 0x9F {
+		return windows1252Runes[b-0x80], nil
+	}
+	return rune(b), nil
+}
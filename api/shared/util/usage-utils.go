@@ -0,0 +1,313 @@
+package util
+
+import (
+	"api/shared/constants"
+	"api/shared/models"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/google/uuid"
+)
+
+// ErrNoOrganization is returned for the usage of the organization of a user who is not in one
+var ErrNoOrganization = errors.New("user is not in an organization")
+
+// Summaries are limited to a year of usage records
+const maxUsageSummaryMonths = 12
+
+// DynamoDBUsageLedger stores usage in the usage table. Each user has their records along
+// with a totals item for each month, and each organization has a totals item for each month.
+type DynamoDBUsageLedger struct{}
+
+// RecordUsage puts the record and adds it to the monthly totals of its user and
+// organization, all at once so the totals always match the records
+func (DynamoDBUsageLedger) RecordUsage(record models.UsageRecord) error {
+	tableName := os.Getenv(constants.UsageTable)
+	dynamoDBClient, err := GetDynamoDBClient(constants.USEast2)
+	if err != nil {
+		return err
+	}
+
+	record.UsageKey = usageKey(models.UserUsage, record.UserID)
+	record.SortKey = fmt.Sprintf("%s#%019d#%s", record.Month, time.Now().UnixNano(), uuid.New().String())
+
+	item, err := dynamodbattribute.MarshalMap(record)
+	if err != nil {
+		return fmt.Errorf("error marshalling usage record: %v", err)
+	}
+
+	items := []*dynamodb.TransactWriteItem{
+		{Put: &dynamodb.Put{TableName: aws.String(tableName), Item: item}},
+		{Update: addUsageTotals(tableName, models.UserUsage, record.UserID, record)},
+	}
+	if record.OrganizationID != "" {
+		items = append(items, &dynamodb.TransactWriteItem{
+			Update: addUsageTotals(tableName, models.OrganizationUsage, record.OrganizationID, record),
+		})
+	}
+
+	_, err = dynamoDBClient.TransactWriteItems(&dynamodb.TransactWriteItemsInput{TransactItems: items})
+	if err != nil {
+		return fmt.Errorf("error writing usage record: %v", err)
+	}
+
+	return nil
+}
+
+// addUsageTotals is the update adding a record to the totals of a month
+func addUsageTotals(tableName string, scope models.UsageScope, id string, record models.UsageRecord) *dynamodb.Update {
+	failed := 0
+	if record.Error != "" {
+		failed = 1
+	}
+
+	return &dynamodb.Update{
+		TableName: aws.String(tableName),
+		Key:       usageTotalsKey(scope, id, record.Month),
+		UpdateExpression: aws.String("ADD Requests :one, FailedRequests :failed, PromptTokens :prompt, " +
+			"CompletionTokens :completion, TotalTokens :total, CostUSD :cost"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":one":        {N: aws.String("1")},
+			":failed":     {N: aws.String(strconv.Itoa(failed))},
+			":prompt":     {N: aws.String(strconv.Itoa(record.PromptTokens))},
+			":completion": {N: aws.String(strconv.Itoa(record.CompletionTokens))},
+			":total":      {N: aws.String(strconv.Itoa(record.PromptTokens + record.CompletionTokens))},
+			":cost":       {N: aws.String(strconv.FormatFloat(record.CostUSD, 'f', -1, 64))},
+		},
+	}
+}
+
+// MonthlyUsage returns the totals of a user or organization in a month, which are empty
+// before their first request of the month
+func (DynamoDBUsageLedger) MonthlyUsage(scope models.UsageScope, id string, month string) (models.UsageTotals, error) {
+	tableName := os.Getenv(constants.UsageTable)
+	dynamoDBClient, err := GetDynamoDBClient(constants.USEast2)
+	if err != nil {
+		return models.UsageTotals{}, err
+	}
+
+	result, err := dynamoDBClient.GetItem(&dynamodb.GetItemInput{
+		TableName:      aws.String(tableName),
+		Key:            usageTotalsKey(scope, id, month),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return models.UsageTotals{}, fmt.Errorf("error getting usage totals: %v", err)
+	}
+
+	var totals models.UsageTotals
+	if result.Item == nil {
+		return totals, nil
+	}
+
+	err = dynamodbattribute.UnmarshalMap(result.Item, &totals)
+	if err != nil {
+		return models.UsageTotals{}, fmt.Errorf("error unmarshalling usage totals: %v", err)
+	}
+	return totals, nil
+}
+
+func usageKey(scope models.UsageScope, id string) string {
+	if scope == models.OrganizationUsage {
+		return "ORG#" + id
+	}
+	return "USER#" + id
+}
+
+// usageTotalsKey is the key of the totals of a month. Totals sort after the records of
+// the user, since their sort key does not start with a month.
+func usageTotalsKey(scope models.UsageScope, id, month string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		constants.UsageKeyField:     {S: aws.String(usageKey(scope, id))},
+		constants.UsageSortKeyField: {S: aws.String("TOTAL#" + month)},
+	}
+}
+
+// UsageMonthRange checks the months of a usage summary, e.g. 2024-05, defaulting to the
+// current month. The range can be at most a year.
+func UsageMonthRange(from, to string) (string, string, error) {
+	currentMonth := usageMonth(time.Now())
+	if from == "" {
+		from = currentMonth
+	}
+	if to == "" {
+		to = currentMonth
+	}
+
+	fromTime, err := time.Parse(usageMonthLayout, from)
+	if err != nil {
+		return "", "", fmt.Errorf("from must be a month like 2024-05")
+	}
+	toTime, err := time.Parse(usageMonthLayout, to)
+	if err != nil {
+		return "", "", fmt.Errorf("to must be a month like 2024-05")
+	}
+
+	if toTime.Before(fromTime) {
+		return "", "", fmt.Errorf("from must not be after to")
+	}
+	if !toTime.Before(fromTime.AddDate(0, maxUsageSummaryMonths, 0)) {
+		return "", "", fmt.Errorf("at most %d months can be summarized", maxUsageSummaryMonths)
+	}
+
+	return from, to, nil
+}
+
+// GetUsageSummary summarizes the usage of a user, or of everyone in their organization,
+// by user, report and month between two months given by UsageMonthRange. Only organization
+// administrators see the organization by user and report, others only by month. The quotas
+// of the current month are included.
+func GetUsageSummary(userID string, scope models.UsageScope, from, to string, organizationAdmin bool) (*models.UsageSummary, error) {
+	organizationID, err := GetUserOrganization(userID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting user organization: %v", err)
+	}
+
+	if scope == models.OrganizationUsage && organizationID == "" {
+		return nil, ErrNoOrganization
+	}
+
+	records, err := queryUsageRecords(scope, userID, organizationID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	config := usageConfigFromEnv()
+	quotas, err := getQuotaStatuses(DynamoDBUsageLedger{}, config.Quotas, userID, organizationID, usageMonth(time.Now()))
+	if err != nil {
+		return nil, fmt.Errorf("error getting usage quotas: %v", err)
+	}
+
+	var entries []models.UsageSummaryEntry
+	var totals models.UsageTotals
+	if scope == models.OrganizationUsage && !organizationAdmin {
+		entries, totals = SummarizeOrganizationUsage(records)
+	} else {
+		entries, totals = SummarizeUsage(records)
+	}
+	return &models.UsageSummary{
+		Scope:   scope,
+		From:    from,
+		To:      to,
+		Entries: entries,
+		Totals:  totals,
+		Quotas:  quotas,
+	}, nil
+}
+
+// queryUsageRecords fetches the records of a user, or of an organization from its index,
+// between two months
+func queryUsageRecords(scope models.UsageScope, userID, organizationID, from, to string) ([]models.UsageRecord, error) {
+	tableName := os.Getenv(constants.UsageTable)
+	dynamoDBClient, err := GetDynamoDBClient(constants.USEast2)
+	if err != nil {
+		return nil, err
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(tableName),
+		KeyConditionExpression: aws.String("#key = :key AND #sort BETWEEN :from AND :to"),
+		ExpressionAttributeNames: map[string]*string{
+			"#key":  aws.String(constants.UsageKeyField),
+			"#sort": aws.String(constants.UsageSortKeyField),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":key":  {S: aws.String(usageKey(models.UserUsage, userID))},
+			":from": {S: aws.String(from + "#")},
+			":to":   {S: aws.String(to + "#~")}, // After every record of the last month
+		},
+	}
+	if scope == models.OrganizationUsage {
+		input.IndexName = aws.String(constants.UsageOrganizationIDIndex)
+		input.ExpressionAttributeNames["#key"] = aws.String(constants.UsageOrganizationIDIndex)
+		input.ExpressionAttributeValues[":key"] = &dynamodb.AttributeValue{S: aws.String(organizationID)}
+	}
+
+	var records []models.UsageRecord
+	var unmarshalErr error
+	err = dynamoDBClient.QueryPages(input, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		var pageRecords []models.UsageRecord
+		unmarshalErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &pageRecords)
+		if unmarshalErr != nil {
+			return false
+		}
+		records = append(records, pageRecords...)
+		return !lastPage
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error querying usage records: %v", err)
+	}
+	if unmarshalErr != nil {
+		return nil, fmt.Errorf("error unmarshalling usage records: %v", unmarshalErr)
+	}
+
+	return records, nil
+}
+
+// SummarizeUsage totals usage records by user, report and month, ordered by month, user
+// and report, along with the totals of every record
+func SummarizeUsage(records []models.UsageRecord) ([]models.UsageSummaryEntry, models.UsageTotals) {
+	type entryKey struct {
+		userID, reportID, month string
+	}
+
+	var totals models.UsageTotals
+	entries := make(map[entryKey]*models.UsageSummaryEntry)
+	for _, record := range records {
+		key := entryKey{record.UserID, record.ReportID, record.Month}
+		entry, ok := entries[key]
+		if !ok {
+			entry = &models.UsageSummaryEntry{UserID: record.UserID, ReportID: record.ReportID, Month: record.Month}
+			entries[key] = entry
+		}
+
+		addUsageRecord(&entry.UsageTotals, record)
+		addUsageRecord(&totals, record)
+	}
+
+	summary := make([]models.UsageSummaryEntry, 0, len(entries))
+	for _, entry := range entries {
+		summary = append(summary, *entry)
+	}
+	sort.Slice(summary, func(i, j int) bool {
+		if summary[i].Month != summary[j].Month {
+			return summary[i].Month < summary[j].Month
+		}
+		if summary[i].UserID != summary[j].UserID {
+			return summary[i].UserID < summary[j].UserID
+		}
+		return summary[i].ReportID < summary[j].ReportID
+	})
+
+	return summary, totals
+}
+
+// SummarizeOrganizationUsage totals usage records by month only, so the usage of the
+// other members of an organization is not shown by user or report
+func SummarizeOrganizationUsage(records []models.UsageRecord) ([]models.UsageSummaryEntry, models.UsageTotals) {
+	monthRecords := make([]models.UsageRecord, len(records))
+	for i, record := range records {
+		record.UserID = ""
+		record.ReportID = ""
+		monthRecords[i] = record
+	}
+	return SummarizeUsage(monthRecords)
+}
+
+func addUsageRecord(totals *models.UsageTotals, record models.UsageRecord) {
+	totals.Requests++
+	if record.Error != "" {
+		totals.FailedRequests++
+	}
+	totals.PromptTokens += record.PromptTokens
+	totals.CompletionTokens += record.CompletionTokens
+	totals.TotalTokens += record.PromptTokens + record.CompletionTokens
+	totals.CostUSD += record.CostUSD
+}
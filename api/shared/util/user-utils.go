@@ -5,6 +5,7 @@ import (
 	"api/shared/models"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-sdk-go/aws"
@@ -26,6 +27,36 @@ func ExtractUserID(request events.APIGatewayProxyRequest) (string, error) {
 	return userID, nil
 }
 
+// IsOrganizationAdmin reports whether the user of the request is in the organization administrators group
+func IsOrganizationAdmin(request events.APIGatewayProxyRequest) bool {
+	claims, ok := request.RequestContext.Authorizer["claims"].(map[string]interface{})
+	if !ok {
+		return false
+	}
+
+	// The groups claim is a list, or a string like "[a b]" or "a,b" depending on the authorizer
+	var groups []string
+	switch claim := claims[constants.CognitoClaimGroups].(type) {
+	case []interface{}:
+		for _, group := range claim {
+			if name, ok := group.(string); ok {
+				groups = append(groups, name)
+			}
+		}
+	case string:
+		groups = strings.FieldsFunc(strings.Trim(claim, "[]"), func(r rune) bool {
+			return r == ',' || r == ' '
+		})
+	}
+
+	for _, group := range groups {
+		if group == constants.CognitoGroupOrganizationAdmins {
+			return true
+		}
+	}
+	return false
+}
+
 // GetUserNickname fetches the nickname of the user from Cognito User Pool
 func GetUserNickname(userID string) (string, error) {
 	// Create a new Cognito Identity Provider client
@@ -56,6 +87,31 @@ func GetUserNickname(userID string) (string, error) {
 	return "", fmt.Errorf("nickname not found for user: " + userID)
 }
 
+// GetUserOrganization fetches the organization of the user from Cognito User Pool.
+// Returns an empty string when the user is not in an organization.
+func GetUserOrganization(userID string) (string, error) {
+	client, err := GetCognitoClient(constants.USEast2)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := client.AdminGetUser(&cognitoidentityprovider.AdminGetUserInput{
+		UserPoolId: aws.String(os.Getenv(constants.UserPoolID)),
+		Username:   aws.String(userID),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	for _, attr := range result.UserAttributes {
+		if *attr.Name == constants.CognitoAttrOrganization {
+			return *attr.Value, nil
+		}
+	}
+
+	return "", nil
+}
+
 func GetAllUsers() ([]models.User, error) {
 	client, err := GetCognitoClient(constants.USEast2)
 	if err != nil {
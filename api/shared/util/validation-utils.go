@@ -0,0 +1,226 @@
+package util
+
+import (
+	"api/shared/models"
+	"encoding/csv"
+	"fmt"
+	"math"
+	"strings"
+)
+
+const (
+	// Row diagnostics listed for each kind, the rest are only counted
+	maxRowDiagnosticsPerKind = 20
+	// Columns where at least this share of the values are numbers are checked for values that are not
+	minNumericColumnShare = 0.5
+)
+
+// uploadValidator checks the rows of an uploaded file as it is normalized. Problems
+// that make the csv unusable are errors, problems with its values are warnings.
+type uploadValidator struct {
+	headers        []string
+	allowShortRows bool // Spreadsheets leave out empty cells at the end of a row
+	rows           int
+	report         models.ValidationReport
+	rowDiagnostics map[models.DiagnosticKind]int
+	columns        []columnValidation
+}
+
+type columnValidation struct {
+	blanks            int
+	numbers           int
+	notNumbers        int
+	firstNotNumberRow int
+}
+
+func newUploadValidator(allowShortRows bool) *uploadValidator {
+	return &uploadValidator{
+		allowShortRows: allowShortRows,
+		rowDiagnostics: make(map[models.DiagnosticKind]int),
+	}
+}
+
+// header checks the header row and returns it without trailing blank cells, or nil when
+// the headers can not be used
+func (v *uploadValidator) header(row []string, line int) []string {
+	// Spreadsheets often have formatted but empty cells after the last header
+	for len(row) > 0 && strings.TrimSpace(row[len(row)-1]) == "" {
+		row = row[:len(row)-1]
+	}
+
+	valid := true
+	seen := make(map[string]int, len(row))
+	for i, header := range row {
+		if strings.TrimSpace(header) == "" {
+			v.addDiagnostic(models.UploadDiagnostic{
+				Kind:     models.MissingHeaderDiagnostic,
+				Severity: models.ErrorSeverity,
+				Message:  fmt.Sprintf("column %d has no header", i+1),
+				Line:     line,
+			})
+			valid = false
+			continue
+		}
+		if hasEncodingProblem(header) {
+			v.addDiagnostic(models.UploadDiagnostic{
+				Kind:     models.EncodingDiagnostic,
+				Severity: models.ErrorSeverity,
+				Message:  fmt.Sprintf("header of column %d has characters that are not text, the file may be in another encoding", i+1),
+				Line:     line,
+				Column:   header,
+			})
+			valid = false
+		}
+		if first, ok := seen[header]; ok {
+			v.addDiagnostic(models.UploadDiagnostic{
+				Kind:     models.DuplicateHeaderDiagnostic,
+				Severity: models.ErrorSeverity,
+				Message:  fmt.Sprintf("columns %d and %d are both named '%s'", first+1, i+1, header),
+				Line:     line,
+				Column:   header,
+			})
+			valid = false
+			continue
+		}
+		seen[header] = i
+	}
+
+	if !valid {
+		return nil
+	}
+	v.headers = row
+	v.columns = make([]columnValidation, len(row))
+	return row
+}
+
+// row checks a row and returns it with one value per header, or nil when it does not
+// fit the headers
+func (v *uploadValidator) row(row []string, line int) []string {
+	v.rows++
+
+	for len(row) > len(v.headers) && strings.TrimSpace(row[len(row)-1]) == "" {
+		row = row[:len(row)-1]
+	}
+	if len(row) > len(v.headers) || (len(row) < len(v.headers) && !v.allowShortRows) {
+		v.addRowDiagnostic(models.UploadDiagnostic{
+			Kind:     models.RaggedRowDiagnostic,
+			Severity: models.ErrorSeverity,
+			Message:  fmt.Sprintf("row has %d values but there are %d headers, check for delimiters or quotes inside values", len(row), len(v.headers)),
+			Line:     line,
+		})
+		return nil
+	}
+	for len(row) < len(v.headers) {
+		row = append(row, "")
+	}
+
+	encodingProblem := false
+	for i, value := range row {
+		column := &v.columns[i]
+		value = strings.TrimSpace(value)
+		if value == "" {
+			column.blanks++
+			continue
+		}
+		if _, ok := parseNumericalValue(value, ""); ok {
+			column.numbers++
+		} else {
+			if column.notNumbers == 0 {
+				column.firstNotNumberRow = line
+			}
+			column.notNumbers++
+		}
+		encodingProblem = encodingProblem || hasEncodingProblem(value)
+	}
+	if encodingProblem {
+		v.addRowDiagnostic(models.UploadDiagnostic{
+			Kind:     models.EncodingDiagnostic,
+			Severity: models.WarningSeverity,
+			Message:  "row has characters that are not text, the file may be in another encoding",
+			Line:     line,
+		})
+	}
+	return row
+}
+
+// malformed records a row the csv reader could not parse, after which the file is not read further
+func (v *uploadValidator) malformed(err *csv.ParseError) {
+	v.addDiagnostic(models.UploadDiagnostic{
+		Kind:     models.MalformedRowDiagnostic,
+		Severity: models.ErrorSeverity,
+		Message:  fmt.Sprintf("row could not be read past column %d: %v", err.Column, err.Err),
+		Line:     err.StartLine,
+	})
+}
+
+// finish adds the column diagnostics and returns the report
+func (v *uploadValidator) finish() *models.ValidationReport {
+	if v.headers == nil && len(v.report.Diagnostics) == 0 {
+		v.addDiagnostic(models.UploadDiagnostic{
+			Kind:     models.MissingHeaderDiagnostic,
+			Severity: models.ErrorSeverity,
+			Message:  "file has no header row",
+		})
+	}
+
+	for i, header := range v.headers {
+		column := v.columns[i]
+		if v.rows > 0 && column.blanks == v.rows {
+			v.addDiagnostic(models.UploadDiagnostic{
+				Kind:     models.EmptyColumnDiagnostic,
+				Severity: models.WarningSeverity,
+				Message:  fmt.Sprintf("column '%s' has no values", header),
+				Column:   header,
+			})
+			continue
+		}
+
+		values := column.numbers + column.notNumbers
+		if column.notNumbers == 0 || float64(column.numbers) < float64(values)*minNumericColumnShare {
+			continue
+		}
+		rate := math.Round(float64(column.notNumbers)/float64(values)*1000) / 1000
+		v.addDiagnostic(models.UploadDiagnostic{
+			Kind:        models.NumericParseDiagnostic,
+			Severity:    models.WarningSeverity,
+			Message:     fmt.Sprintf("%d of the %d values of column '%s' are not numbers", column.notNumbers, values, header),
+			Line:        column.firstNotNumberRow,
+			Column:      header,
+			FailureRate: rate,
+		})
+	}
+
+	v.report.RowCount = v.rows
+	v.report.Valid = true
+	for _, diagnostic := range v.report.Diagnostics {
+		if diagnostic.Severity == models.ErrorSeverity {
+			v.report.Valid = false
+			break
+		}
+	}
+	return &v.report
+}
+
+func (v *uploadValidator) addDiagnostic(diagnostic models.UploadDiagnostic) {
+	v.report.Diagnostics = append(v.report.Diagnostics, diagnostic)
+}
+
+func (v *uploadValidator) addRowDiagnostic(diagnostic models.UploadDiagnostic) {
+	v.rowDiagnostics[diagnostic.Kind]++
+	if v.rowDiagnostics[diagnostic.Kind] > maxRowDiagnosticsPerKind {
+		v.report.OmittedDiagnostics++
+		return
+	}
+	v.addDiagnostic(diagnostic)
+}
+
+// hasEncodingProblem reports whether text has replacement characters, null bytes or C1
+// control characters, which are left by reading a file in the wrong encoding
+func hasEncodingProblem(text string) bool {
+	for _, r := range text {
+		if r == '\uFFFD' || r == 0 || (r >= 0x80 && r <= 0x9F) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,378 @@
+package util
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Workbook parts are read with encoding/xml rather than a spreadsheet library.
+// Only the cell values are needed, so formatting other than dates is ignored.
+
+type xlsxWorkbook struct {
+	Properties struct {
+		Date1904 bool `xml:"date1904,attr"`
+	} `xml:"workbookPr"`
+	Sheets []struct {
+		Name           string `xml:"name,attr"`
+		RelationshipID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type xlsxSharedStrings struct {
+	Items []xlsxStringItem `xml:"si"`
+}
+
+// xlsxStringItem is either plain text or rich text split into runs
+type xlsxStringItem struct {
+	Text string `xml:"t"`
+	Runs []struct {
+		Text string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (i *xlsxStringItem) String() string {
+	if len(i.Runs) == 0 {
+		return i.Text
+	}
+	var text strings.Builder
+	for _, run := range i.Runs {
+		text.WriteString(run.Text)
+	}
+	return text.String()
+}
+
+type xlsxStyles struct {
+	NumberFormats []struct {
+		ID   int    `xml:"numFmtId,attr"`
+		Code string `xml:"formatCode,attr"`
+	} `xml:"numFmts>numFmt"`
+	CellFormats []struct {
+		NumberFormatID int `xml:"numFmtId,attr"`
+	} `xml:"cellXfs>xf"`
+}
+
+type xlsxCell struct {
+	Reference string          `xml:"r,attr"`
+	Type      string          `xml:"t,attr"`
+	Style     int             `xml:"s,attr"`
+	Value     string          `xml:"v"`
+	Inline    *xlsxStringItem `xml:"is"`
+}
+
+// readXLSXSheet streams the rows of a sheet of the workbook, the first sheet when
+// the name is empty, along with their row numbers. Cells are written as they are
+// shown in a csv export, with dates formatted like 2006-01-02 15:04:05.
+func readXLSXSheet(file *os.File, sheetName string, writeRow func(row []string, line int) error) error {
+	size, err := fileSize(file)
+	if err != nil {
+		return err
+	}
+	archive, err := zip.NewReader(file, size)
+	if err != nil {
+		return fmt.Errorf("error opening xlsx file: %v", err)
+	}
+
+	var workbook xlsxWorkbook
+	if err := decodeXLSXPart(archive, "xl/workbook.xml", &workbook); err != nil {
+		return err
+	}
+	var relationships xlsxRelationships
+	if err := decodeXLSXPart(archive, "xl/_rels/workbook.xml.rels", &relationships); err != nil {
+		return err
+	}
+	// Workbooks with only numbers have no shared strings or styles
+	var sharedStrings xlsxSharedStrings
+	if err := decodeXLSXPart(archive, "xl/sharedStrings.xml", &sharedStrings); err != nil && err != errXLSXPartMissing {
+		return err
+	}
+	var styles xlsxStyles
+	if err := decodeXLSXPart(archive, "xl/styles.xml", &styles); err != nil && err != errXLSXPartMissing {
+		return err
+	}
+
+	sheetPath, err := findXLSXSheet(&workbook, &relationships, sheetName)
+	if err != nil {
+		return err
+	}
+	sheet, err := openXLSXPart(archive, sheetPath)
+	if err != nil {
+		return err
+	}
+	defer sheet.Close()
+
+	reader := &xlsxCellReader{
+		sharedStrings: sharedStrings.Items,
+		dateStyles:    xlsxDateStyles(&styles),
+		date1904:      workbook.Properties.Date1904,
+	}
+
+	decoder := xml.NewDecoder(sheet)
+	var row []string
+	line := 0
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error reading xlsx sheet: %v", err)
+		}
+
+		switch element := token.(type) {
+		case xml.StartElement:
+			switch element.Name.Local {
+			case "row":
+				row = row[:0]
+				line++
+				// Empty rows are left out of the sheet
+				for _, attr := range element.Attr {
+					if attr.Name.Local == "r" {
+						if number, err := strconv.Atoi(attr.Value); err == nil {
+							line = number
+						}
+					}
+				}
+			case "c":
+				var cell xlsxCell
+				if err := decoder.DecodeElement(&cell, &element); err != nil {
+					return fmt.Errorf("error reading xlsx cell: %v", err)
+				}
+				column := len(row)
+				if cell.Reference != "" {
+					column, err = xlsxColumnIndex(cell.Reference)
+					if err != nil {
+						return err
+					}
+				}
+				// Empty cells are left out of the sheet
+				for len(row) < column {
+					row = append(row, "")
+				}
+				value, err := reader.value(&cell)
+				if err != nil {
+					return err
+				}
+				if column < len(row) {
+					row[column] = value
+				} else {
+					row = append(row, value)
+				}
+			}
+		case xml.EndElement:
+			if element.Name.Local == "row" {
+				if err := writeRow(row, line); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+var errXLSXPartMissing = fmt.Errorf("xlsx part missing")
+
+func openXLSXPart(archive *zip.Reader, name string) (io.ReadCloser, error) {
+	for _, part := range archive.File {
+		if part.Name == name {
+			return part.Open()
+		}
+	}
+	return nil, errXLSXPartMissing
+}
+
+func decodeXLSXPart(archive *zip.Reader, name string, value interface{}) error {
+	part, err := openXLSXPart(archive, name)
+	if err == errXLSXPartMissing {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("error opening %s: %v", name, err)
+	}
+	defer part.Close()
+
+	if err := xml.NewDecoder(part).Decode(value); err != nil {
+		return fmt.Errorf("error reading %s: %v", name, err)
+	}
+	return nil
+}
+
+func findXLSXSheet(workbook *xlsxWorkbook, relationships *xlsxRelationships, sheetName string) (string, error) {
+	if len(workbook.Sheets) == 0 {
+		return "", fmt.Errorf("xlsx file has no sheets")
+	}
+
+	sheet := workbook.Sheets[0]
+	if sheetName != "" {
+		found := false
+		names := make([]string, len(workbook.Sheets))
+		for i, s := range workbook.Sheets {
+			names[i] = s.Name
+			if s.Name == sheetName {
+				sheet = s
+				found = true
+			}
+		}
+		if !found {
+			return "", fmt.Errorf("sheet '%s' not found, the workbook has sheets %s", sheetName, strings.Join(names, ", "))
+		}
+	}
+
+	for _, relationship := range relationships.Relationships {
+		if relationship.ID == sheet.RelationshipID {
+			// Targets are relative to the workbook part unless they start at the root
+			if strings.HasPrefix(relationship.Target, "/") {
+				return strings.TrimPrefix(relationship.Target, "/"), nil
+			}
+			return path.Join("xl", relationship.Target), nil
+		}
+	}
+	return "", fmt.Errorf("sheet '%s' has no part in the xlsx file", sheet.Name)
+}
+
+// Columns a sheet can have, the last is XFD
+const maxXLSXColumns = 16384
+
+// xlsxColumnIndex returns the zero based column of a cell reference like AB12
+func xlsxColumnIndex(reference string) (int, error) {
+	column := 0
+	letters := 0
+	for _, r := range reference {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		column = column*26 + int(r-'A'+1)
+		letters++
+		if column > maxXLSXColumns {
+			return 0, fmt.Errorf("xlsx cell reference '%s' is past the last column", reference)
+		}
+	}
+
+	row := reference[letters:]
+	if letters == 0 || row == "" || strings.Trim(row, "0123456789") != "" {
+		return 0, fmt.Errorf("xlsx cell reference '%s' is not a column and row", reference)
+	}
+	return column - 1, nil
+}
+
+// xlsxDateStyles flags the cell styles whose number format shows a date or a time,
+// since dates are stored as numbers of days
+func xlsxDateStyles(styles *xlsxStyles) []bool {
+	customFormats := make(map[int]string, len(styles.NumberFormats))
+	for _, format := range styles.NumberFormats {
+		customFormats[format.ID] = format.Code
+	}
+
+	dateStyles := make([]bool, len(styles.CellFormats))
+	for i, cellFormat := range styles.CellFormats {
+		id := cellFormat.NumberFormatID
+		if code, ok := customFormats[id]; ok {
+			dateStyles[i] = isDateFormatCode(code)
+		} else {
+			// Built in date and time formats
+			dateStyles[i] = (id >= 14 && id <= 22) || (id >= 45 && id <= 47)
+		}
+	}
+	return dateStyles
+}
+
+// isDateFormatCode reports whether a number format has date or time parts once
+// its quoted text, escaped characters and bracketed sections are removed
+func isDateFormatCode(code string) bool {
+	var stripped strings.Builder
+	inQuotes, inBrackets := false, false
+	for i := 0; i < len(code); i++ {
+		c := code[i]
+		switch {
+		case c == '"':
+			inQuotes = !inQuotes
+		case inQuotes:
+		case c == '\\':
+			i++
+		case c == '[':
+			inBrackets = true
+		case c == ']':
+			inBrackets = false
+		case inBrackets:
+		default:
+			stripped.WriteByte(c)
+		}
+	}
+	lowered := strings.ToLower(stripped.String())
+	if lowered == "general" {
+		return false
+	}
+	return strings.ContainsAny(lowered, "ydhms")
+}
+
+type xlsxCellReader struct {
+	sharedStrings []xlsxStringItem
+	dateStyles    []bool
+	date1904      bool
+}
+
+func (r *xlsxCellReader) value(cell *xlsxCell) (string, error) {
+	switch cell.Type {
+	case "s":
+		index, err := strconv.Atoi(cell.Value)
+		if err != nil || index < 0 || index >= len(r.sharedStrings) {
+			return "", fmt.Errorf("invalid shared string in cell %s", cell.Reference)
+		}
+		return r.sharedStrings[index].String(), nil
+	case "inlineStr":
+		if cell.Inline == nil {
+			return "", nil
+		}
+		return cell.Inline.String(), nil
+	case "b":
+		if cell.Value == "1" {
+			return "true", nil
+		}
+		return "false", nil
+	case "str", "e":
+		return cell.Value, nil
+	}
+
+	if cell.Value != "" && cell.Style >= 0 && cell.Style < len(r.dateStyles) && r.dateStyles[cell.Style] {
+		serial, err := strconv.ParseFloat(cell.Value, 64)
+		if err == nil {
+			return formatXLSXDate(serial, r.date1904), nil
+		}
+	}
+	return cell.Value, nil
+}
+
+// formatXLSXDate converts a number of days since the workbook epoch to a date, a time of day or both
+func formatXLSXDate(serial float64, date1904 bool) string {
+	// The 1900 epoch starts a day early to account for the leap day Excel counts in 1900
+	epoch := time.Date(1899, time.December, 30, 0, 0, 0, 0, time.UTC)
+	if date1904 {
+		epoch = time.Date(1904, time.January, 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	days := math.Floor(serial)
+	seconds := math.Round((serial - days) * 24 * 60 * 60)
+	date := epoch.AddDate(0, 0, int(days)).Add(time.Duration(seconds) * time.Second)
+
+	switch {
+	case seconds == 0:
+		return date.Format("2006-01-02")
+	case days == 0:
+		return date.Format("15:04:05")
+	default:
+		return date.Format("2006-01-02 15:04:05")
+	}
+}
@@ -0,0 +1,60 @@
+package util_test
+
+import (
+	"encoding/csv"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// writeMockCSV writes the rows to a csv in a temporary directory and returns an open handle to it
+func writeMockCSV(tb testing.TB, rows [][]string) *os.File {
+	tb.Helper()
+
+	file, err := os.Create(filepath.Join(tb.TempDir(), "mock.csv"))
+	if err != nil {
+		tb.Fatalf("error creating mock csv: %v", err)
+	}
+	tb.Cleanup(func() { file.Close() })
+
+	writer := csv.NewWriter(file)
+	if err := writer.WriteAll(rows); err != nil {
+		tb.Fatalf("error writing mock csv: %v", err)
+	}
+
+	return file
+}
+
+// mockIncidentRows returns a small incident export with known aggregates
+func mockIncidentRows() [][]string {
+	return [][]string{
+		{"Station", "Year", "Incident Type", "Travel Time", "Turnout Time"},
+		{"1", "2016", "Fire", "100", "60"},
+		{"1", "2016", "Medical", "200", "40"},
+		{"2", "2016", "Fire", "300", "80"},
+		{"1", "2017", "Fire", "400", "20"},
+		{"2", "2017", "Medical", "500", "50"},
+		{"3", "2017", "Medical", "600", "70"},
+	}
+}
+
+// mockLargeIncidentRows generates a deterministic incident export with the given number of rows
+func mockLargeIncidentRows(rowCount int) [][]string {
+	random := rand.New(rand.NewSource(42))
+	incidentTypes := []string{"Fire", "Medical", "Alarm", "Rescue", "Hazmat"}
+
+	rows := make([][]string, 0, rowCount+1)
+	rows = append(rows, []string{"Station", "Year", "Incident Type", "Travel Time", "Turnout Time"})
+	for i := 0; i < rowCount; i++ {
+		rows = append(rows, []string{
+			strconv.Itoa(1 + random.Intn(8)),
+			strconv.Itoa(2016 + random.Intn(8)),
+			incidentTypes[random.Intn(len(incidentTypes))],
+			strconv.Itoa(60 + random.Intn(900)),
+			strconv.Itoa(20 + random.Intn(120)),
+		})
+	}
+	return rows
+}
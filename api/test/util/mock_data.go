@@ -31,10 +31,10 @@ func mockStaticData() *models.ReportSection {
 				OperationType:   "Average",
 				OperationColumn: "Travel Time",
 				AcceptedValues:  nil,
-				FilterColumns: map[string][]string{
+				FilterColumns: models.FilterFromColumns(map[string][]string{
 					"Station": {"1", "2", "3"},
 					"Year":    {"2016", "2017", "2018"},
-				},
+				}),
 				Result: "218.477",
 			},
 			{
@@ -43,13 +43,13 @@ func mockStaticData() *models.ReportSection {
 				OperationType:   "SetElementOccurences",
 				OperationColumn: "Incident Type",
 				AcceptedValues:  []string{"Fire"},
-				FilterColumns: map[string][]string{
+				FilterColumns: models.FilterFromColumns(map[string][]string{
 					"District":      {"4"},
 					"Incident Type": {"Fire"},
 					"Station":       {"1"},
 					"Year":          {"2016"},
 					"Address":       {"281 WOODLAWN RD"},
-				},
+				}),
 				Result: "1",
 			},
 			{
@@ -126,9 +126,9 @@ func mockStaticData() *models.ReportSection {
 						Description:         "Area for station 1 incidents over the years",
 						OperationType:       "SetElementOccurences",
 						AcceptedValues:      nil,
-						FilterColumns: map[string][]string{
+						FilterColumns: models.FilterFromColumns(map[string][]string{
 							"Station": {"1"},
-						},
+						}),
 					},
 					{
 						AggregateValueLabel: "Station 2",
@@ -136,9 +136,9 @@ func mockStaticData() *models.ReportSection {
 						Description:         "Area for station 1 incidents over the years",
 						OperationType:       "SetElementOccurences",
 						AcceptedValues:      nil,
-						FilterColumns: map[string][]string{
+						FilterColumns: models.FilterFromColumns(map[string][]string{
 							"Station": {"2"},
-						},
+						}),
 					},
 				},
 				Results: []map[string]interface{}{
@@ -203,3 +203,67 @@ func mockGeneratorData() *models.ReportSection {
 
 	return section
 }
+
+// mockAnalysisData returns a section whose outputs are computed from mockIncidentRows
+func mockAnalysisData() *models.ReportSection {
+	return &models.ReportSection{
+		Title: "Analysis Section",
+		CSVData: []models.ReportCSVData{
+			{
+				Label:           "avgTravel",
+				OperationType:   models.Average,
+				OperationColumn: "Travel Time",
+				FilterColumns: models.FilterFromColumns(map[string][]string{
+					"Station": {"1", "2"},
+				}),
+			},
+			{
+				Label:           "totalTurnout",
+				OperationType:   models.NumericalSum,
+				OperationColumn: "Turnout Time",
+			},
+			{
+				Label:           "fireCount",
+				OperationType:   models.SetElementOccurrences,
+				OperationColumn: "Incident Type",
+				AcceptedValues:  []string{"Fire"},
+			},
+			{
+				Label:           "missingCount",
+				OperationType:   models.SetElementOccurrences,
+				OperationColumn: "Incident Type",
+				AcceptedValues:  []string{"Hazmat"},
+			},
+		},
+		ChartOutputs: []models.ReportChartOutput{
+			{
+				Title:             "Travel Time by Station",
+				IndependentColumn: "Station",
+				DependentColumns: []models.ReportOneDimConfig{
+					{
+						AggregateValueLabel: "Travel Time",
+						Column:              "Travel Time",
+						OperationType:       models.Average,
+					},
+					{
+						AggregateValueLabel: "Fires",
+						Column:              "Incident Type",
+						OperationType:       models.SetElementOccurrences,
+						AcceptedValues:      []string{"Fire"},
+					},
+				},
+			},
+			{
+				Title:             "Incident Types by Year",
+				IndependentColumn: "Year",
+				AcceptedValues:    []string{"2016", "2017"},
+				DependentColumns: []models.ReportOneDimConfig{
+					{
+						Column:        "Incident Type",
+						OperationType: models.UniqueOccurrences,
+					},
+				},
+			},
+		},
+	}
+}
@@ -1,12 +1,17 @@
 package util_test
 
+import (
+	"api/shared/models"
+	"context"
+)
+
 type MockOpenAiGenerator struct{}
 
-func (m MockOpenAiGenerator) GeneratePromptResponse(prompt string) (string, error) {
+func (m MockOpenAiGenerator) GeneratePromptResponse(ctx context.Context, request models.GenerationRequest) (models.GenerationResponse, error) {
 	// Mock responses for GeneratePromptResponse (you will need to implement this)
 	mockResponses := map[string]string{
 		"Tell me about this color: Blue":   "Blue is a calming color",
 		"Tell me about this city: Toronto": "Toronto is a vibrant city",
 	}
-	return mockResponses[prompt], nil
+	return models.GenerationResponse{Text: mockResponses[request.Prompt]}, nil
 }
@@ -0,0 +1,140 @@
+package util_test
+
+import (
+	"api/shared/models"
+	"api/shared/util"
+	"testing"
+)
+
+func mockMessyNumberRows() [][]string {
+	return [][]string{
+		{"Unit", "Cost", "Euro Cost"},
+		{"E1", "$1,200", "1.200,50"},
+		{"E2", " 12 ", "€ 3,5"},
+		{"E3", "4.5", "(1,00)"},
+		{"E4", "15%", ""},
+		{"E5", "(100)", "n/a"},
+		{"E6", "", "2"},
+		{"E7", "N/A", "1 000"},
+	}
+}
+
+func TestNumericalParsingPolicies(t *testing.T) {
+	csvFile := writeMockCSV(t, mockMessyNumberRows())
+
+	tests := []struct {
+		name             string
+		column           string
+		operation        models.ChartOperation
+		policy           models.InvalidValuePolicy
+		decimalSeparator string
+		expectedResult   string
+		expectedSkipped  int
+	}{
+		{"skip sum", "Cost", models.NumericalSum, models.SkipInvalidValue, "", "1131.5", 2},
+		{"skip", "Cost", models.Average, models.SkipInvalidValue, "", "226.3", 2},
+		{"zero", "Cost", models.Average, models.ZeroInvalidValue, "", "161.643", 0},
+		{"decimal comma", "Euro Cost", models.NumericalSum, models.SkipInvalidValue, ",", "2205", 2},
+	}
+
+	for _, test := range tests {
+		csvData := models.ReportCSVData{
+			Label:              "cost",
+			OperationType:      test.operation,
+			OperationColumn:    test.column,
+			InvalidValuePolicy: test.policy,
+			DecimalSeparator:   test.decimalSeparator,
+		}
+		if err := util.AnalyzeOneDimensionalData(csvFile, &csvData); err != nil {
+			t.Fatalf("%s: AnalyzeOneDimensionalData returned an error: %v", test.name, err)
+		}
+		if csvData.Result != test.expectedResult {
+			t.Errorf("%s: expected result %q, got %q", test.name, test.expectedResult, csvData.Result)
+		}
+		if csvData.SkippedRows != test.expectedSkipped {
+			t.Errorf("%s: expected %d skipped rows, got %d", test.name, test.expectedSkipped, csvData.SkippedRows)
+		}
+	}
+}
+
+func TestNumericalParsingThousandsSeparators(t *testing.T) {
+	tests := []struct {
+		value            string
+		decimalSeparator string
+		expected         string // Empty when the value is invalid
+	}{
+		{"1,200", "", "1200"},
+		{"12,345,678.9", "", "12345678.9"},
+		{"1 000", "", "1000"},
+		{"1'000", "", "1000"},
+		{"-1,000", "", "-1000"},
+		{"1.200,50", ",", "1200.5"},
+		{"1 200,5", ",", "1200.5"},
+		// Separators out of 3 digit groups are not dropped
+		{"1,5", "", ""},
+		{"1 2", "", ""},
+		{"1,2345", "", ""},
+		{"1234,567", "", ""},
+		{"1,,000", "", ""},
+		{",100", "", ""},
+		{"1.000,5", "", ""},
+		{"1.5,0", ",", ""},
+		{"1.500.000", "", ""},
+		{"0,5", ",", "0.5"},
+		{"3.5", ",", ""},
+	}
+
+	for _, test := range tests {
+		csvFile := writeMockCSV(t, [][]string{{"Value"}, {test.value}})
+		csvData := models.ReportCSVData{
+			Label:              "value",
+			OperationType:      models.NumericalSum,
+			OperationColumn:    "Value",
+			InvalidValuePolicy: models.SkipInvalidValue,
+			DecimalSeparator:   test.decimalSeparator,
+		}
+		if err := util.AnalyzeOneDimensionalData(csvFile, &csvData); err != nil {
+			t.Fatalf("%q: AnalyzeOneDimensionalData returned an error: %v", test.value, err)
+		}
+		if test.expected == "" && csvData.SkippedRows != 1 {
+			t.Errorf("%q with the decimal %q: expected the value to be invalid, got %q", test.value, test.decimalSeparator, csvData.Result)
+		}
+		if test.expected != "" && (csvData.Result != test.expected || csvData.SkippedRows != 0) {
+			t.Errorf("%q with the decimal %q: expected %q, got %q", test.value, test.decimalSeparator, test.expected, csvData.Result)
+		}
+	}
+}
+
+func TestNumericalParsingFailPolicy(t *testing.T) {
+	csvFile := writeMockCSV(t, mockMessyNumberRows())
+
+	// Items saved before the policy existed have none and keep failing
+	for _, policy := range []models.InvalidValuePolicy{models.FailInvalidValue, ""} {
+		csvData := models.ReportCSVData{
+			Label:              "cost",
+			OperationType:      models.NumericalSum,
+			OperationColumn:    "Cost",
+			InvalidValuePolicy: policy,
+		}
+		if err := util.AnalyzeOneDimensionalData(csvFile, &csvData); err == nil {
+			t.Errorf("Expected an error for an unparseable value with the policy %q", policy)
+		}
+	}
+}
+
+func TestNumericalParsingSkippedRowsPerChartColumn(t *testing.T) {
+	csvFile := writeMockCSV(t, mockMessyNumberRows())
+
+	chart := models.ReportChartOutput{
+		IndependentColumn: "Unit",
+		DependentColumns: []models.ReportOneDimConfig{
+			{AggregateValueLabel: "Cost", Column: "Cost", OperationType: models.NumericalSum, InvalidValuePolicy: models.SkipInvalidValue, SkippedRows: 10},
+		},
+	}
+	if err := util.AnalyzeTwoDimensionalData(csvFile, &chart); err != nil {
+		t.Fatalf("AnalyzeTwoDimensionalData returned an error: %v", err)
+	}
+	if chart.DependentColumns[0].SkippedRows != 2 {
+		t.Errorf("Expected 2 skipped rows, got %d", chart.DependentColumns[0].SkippedRows)
+	}
+}
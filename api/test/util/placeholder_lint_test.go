@@ -0,0 +1,122 @@
+package util_test
+
+import (
+	"api/shared/models"
+	"api/shared/util"
+	"errors"
+	"testing"
+)
+
+func lintTemplate() *models.Template {
+	return &models.Template{
+		GlobalQuestions: []models.TemplateQuestion{{Label: "city"}, {Label: "year"}},
+		Parts: []models.TemplatePart{{
+			Sections: []models.TemplateSection{
+				{
+					Questions: []models.TemplateQuestion{{Label: "q1"}, {Label: "spare"}},
+					CSVData:   []models.TemplateCSVData{{Label: "calls"}},
+					TextOutputs: []models.TemplateTextOutput{
+						{Title: "Summary", Input: "{{q1}} in {{city}}: {{#each csvData}}{{label}}={{result}}{{/each}}"},
+						{Title: "Broken", Input: "{{missing}} {{#each q1}}{{/each}}"},
+						{Title: "Unclosed", Input: "{{#if calls}}"},
+					},
+				},
+				{
+					Questions: []models.TemplateQuestion{{Label: "q1"}, {Label: "q10"}},
+					TextOutputs: []models.TemplateTextOutput{
+						{Title: "Legacy", Input: "**q10 is **bold**, **Response time** was fast\n**nope is not\nbut **this is** bold"},
+					},
+				},
+				{
+					Questions: []models.TemplateQuestion{{Label: "dup"}, {Label: "city"}},
+					CSVData:   []models.TemplateCSVData{{Label: "dup"}},
+					TextOutputs: []models.TemplateTextOutput{
+						{Title: "Loop", Input: "{{#each questions}}{{@number}}. {{answer}}{{/each}}"},
+					},
+				},
+			},
+		}},
+	}
+}
+
+func TestLintTemplatePlaceholders(t *testing.T) {
+	report := util.LintTemplatePlaceholders(lintTemplate())
+
+	expected := []struct {
+		kind         models.PlaceholderIssueKind
+		severity     models.DiagnosticSeverity
+		sectionIndex int
+		textOutput   string
+		label        string
+	}{
+		{models.UndefinedPlaceholderIssue, models.ErrorSeverity, 0, "Broken", "missing"},
+		{models.InvalidPlaceholderIssue, models.ErrorSeverity, 0, "Broken", "q1"},
+		{models.InvalidPlaceholderIssue, models.ErrorSeverity, 0, "Unclosed", ""},
+		{models.UnusedQuestionIssue, models.WarningSeverity, 0, "", "spare"},
+		{models.UndefinedPlaceholderIssue, models.WarningSeverity, 1, "Legacy", "nope"},
+		{models.UnusedQuestionIssue, models.WarningSeverity, 1, "", "q1"},
+		{models.PrefixLabelIssue, models.WarningSeverity, 1, "", "q1"},
+		{models.DuplicateLabelIssue, models.ErrorSeverity, 2, "", "dup"},
+		{models.DuplicateLabelIssue, models.WarningSeverity, 2, "", "city"},
+		{models.UnusedQuestionIssue, models.WarningSeverity, -1, "", "year"},
+	}
+
+	if report.Valid {
+		t.Errorf("Expected the template to be invalid")
+	}
+	if len(report.Issues) != len(expected) {
+		t.Fatalf("Expected %d issues, got %+v", len(expected), report.Issues)
+	}
+	for i, issue := range report.Issues {
+		e := expected[i]
+		if issue.Kind != e.kind || issue.Severity != e.severity || issue.SectionIndex != e.sectionIndex || issue.TextOutput != e.textOutput || issue.Label != e.label {
+			t.Errorf("Issue %d: expected %+v, got %+v", i, e, issue)
+		}
+	}
+}
+
+func TestLintReportPlaceholdersValid(t *testing.T) {
+	report := &models.Report{
+		GlobalQuestions: []models.ReportQuestion{{Label: "city"}},
+		Parts: []models.ReportPart{{
+			Sections: []models.ReportSection{{
+				Questions: []models.ReportQuestion{{Label: "q1"}},
+				TextOutputs: []models.ReportTextOutput{
+					{Title: "Legacy", Input: "**q1 in **city"},
+					{Title: "New", Input: `{{#each globalQuestions}}{{this.label}}{{/each}} {{questions.0.answer | default:"-"}}`},
+				},
+			}},
+		}},
+	}
+
+	lint := util.LintReportPlaceholders(report)
+	if !lint.Valid || len(lint.Issues) != 0 {
+		t.Errorf("Expected no issues, got %+v", lint.Issues)
+	}
+}
+
+func TestSectionPlaceholderErrors(t *testing.T) {
+	lint := util.LintTemplatePlaceholders(lintTemplate())
+
+	var lintErr *util.PlaceholderLintError
+	if err := util.SectionPlaceholderErrors(lint, models.SectionRef{PartIndex: 0, SectionIndex: 0}); !errors.As(err, &lintErr) || lintErr.Report.Valid {
+		t.Errorf("Expected the errors of the section to block it, got %v", err)
+	}
+
+	// Only warnings in the section, stray ** text among them
+	if err := util.SectionPlaceholderErrors(lint, models.SectionRef{PartIndex: 0, SectionIndex: 1}); err != nil {
+		t.Errorf("Expected warnings and errors elsewhere not to block the section, got %v", err)
+	}
+
+	if err := util.ItemPlaceholderErrors(lint); !errors.As(err, &lintErr) {
+		t.Errorf("Expected the errors of the template to block it, got %v", err)
+	}
+
+	// Stray ** text rendered as it is before placeholders were checked, so it does not block converting
+	report := &models.Report{Parts: []models.ReportPart{{Sections: []models.ReportSection{{
+		TextOutputs: []models.ReportTextOutput{{Title: "Legacy", Input: "Up **5% on last year"}},
+	}}}}}
+	if err := util.ItemPlaceholderErrors(util.LintReportPlaceholders(report)); err != nil {
+		t.Errorf("Expected undefined legacy placeholders not to block the report, got %v", err)
+	}
+}
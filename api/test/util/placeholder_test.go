@@ -0,0 +1,256 @@
+package util_test
+
+import (
+	"api/shared/models"
+	"api/shared/util"
+	"context"
+	"strings"
+	"testing"
+)
+
+func placeholderSection() *models.ReportSection {
+	return &models.ReportSection{
+		Questions: []models.ReportQuestion{
+			{Label: "q1", Question: "Which station?", Answer: "Station 1"},
+			{Label: "q10", Question: "Which district?", Answer: "District 4"},
+			{Label: "empty", Question: "Anything else?", Answer: ""},
+		},
+		CSVData: []models.ReportCSVData{
+			{Label: "avgTravel", Description: "Average travel time", Result: "218.477"},
+			{Label: "calls", Description: "Number of calls", Result: "1234567"},
+			{Label: "share", Description: "Share of fire calls", Result: "0.1234"},
+			{Label: "q1", Description: "Shadowed by the question", Result: "0"},
+		},
+	}
+}
+
+func TestRenderPlaceholders(t *testing.T) {
+	section := placeholderSection()
+	globalQuestions := []models.ReportQuestion{
+		{Label: "city", Question: "Which city?", Answer: "Guelph"},
+		{Label: "since", Question: "Since when?", Answer: "2016-03-05"},
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"{{q1}} and {{q10}}", "Station 1 and District 4"},
+		{"{{ city }}", "Guelph"},
+		{"{{avgTravel | decimals:1}}s", "218.5s"},
+		{"{{calls | thousands}} calls", "1,234,567 calls"},
+		{"{{avgTravel | decimals:2 | thousands}}", "218.48"},
+		{"{{share | percent:1}} and {{share | percent}}", "12.3% and 12%"},
+		{`{{since | date:"MMMM D, YYYY"}}`, "March 5, 2016"},
+		{`{{city | decimals:2}} {{city | date:"YYYY"}}`, "Guelph Guelph"},
+		{`{{empty | default:"not answered"}} {{q1 | default:"none"}}`, "not answered Station 1"},
+		{`{{empty | default:"a | b"}}`, "a | b"},
+		{"{{#if q1}}yes{{else}}no{{/if}} {{#if empty}}yes{{else}}no{{/if}}", "yes no"},
+		{"{{#if avgTravel > 200}}slow{{else}}fast{{/if}}", "slow"},
+		{"{{#if avgTravel <= 100}}fast{{/if}}", ""},
+		{`{{#if city == "Guelph"}}home{{/if}}{{#if city != Guelph}}away{{/if}}`, "home"},
+		{"{{#each questions}}{{@number}}. {{question}} {{answer | default:\"-\"}}{{#if @last}}{{else}}\n{{/if}}{{/each}}",
+			"1. Which station? Station 1\n2. Which district? District 4\n3. Anything else? -"},
+		{"{{#each csvData}}{{#if @first}}{{else}}, {{/if}}{{label}}={{result}}{{/each}}", "avgTravel=218.477, calls=1234567, share=0.1234, q1=0"},
+		{"{{#each globalQuestions}}{{this.label}} {{city}};{{/each}}", "city Guelph;since Guelph;"},
+		{"{{questions.1.answer}}", "District 4"},
+		{`\{{q1}} is written {{q1}}`, "{{q1}} is written Station 1"},
+		{"{{#if q1}}{{#each questions}}{{#if answer}}[{{label}}]{{/if}}{{/each}}{{/if}}", "[q1][q10]"},
+	}
+
+	for _, test := range tests {
+		result, err := util.RenderPlaceholders(test.input, section, globalQuestions, nil)
+		if err != nil || result != test.expected {
+			t.Errorf("%q: expected %q, got %q (%v)", test.input, test.expected, result, err)
+		}
+	}
+}
+
+func TestRenderPlaceholdersErrors(t *testing.T) {
+	section := placeholderSection()
+
+	tests := []struct {
+		input string
+		err   string
+	}{
+		{"{{missing}}", "unknown placeholder 'missing'"},
+		{"{{q1", "placeholder is not closed"},
+		{"{{}}", "empty placeholder"},
+		{"{{q1 | bold}}", "unknown format 'bold'"},
+		{"{{q1 | decimals}}", "format 'decimals' needs an argument"},
+		{"{{q1 | decimals:many}}", "decimals must be a number of decimals"},
+		{"{{q1 | thousands:2}}", "format 'thousands' takes no argument"},
+		{"{{#if q1}}open", "{{#if q1}} is not closed with {{/if}}"},
+		{"{{#if q1}}open{{/each}}", "is not closed with {{/if}}"},
+		{"text{{/if}}", "unexpected {{/if}}"},
+		{"{{#unless q1}}{{/unless}}", "unknown block"},
+		{"{{#each q1}}{{/each}}", "q1 is not a list"},
+		{"{{questions}}", "is a list or an item, not a value"},
+		{"{{#if missing}}{{/if}}", "unknown placeholder 'missing'"},
+	}
+
+	for _, test := range tests {
+		_, err := util.RenderPlaceholders(test.input, section, nil, nil)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%q: expected error %q, got %v", test.input, test.err, err)
+		}
+	}
+}
+
+func TestRenderLegacyPlaceholders(t *testing.T) {
+	section := placeholderSection()
+	section.Questions = append(section.Questions, models.ReportQuestion{Label: "loop", Answer: "**q1"})
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// The longest label is used, whatever the order of the questions
+		{"**q10 then **q1", "District 4 then Station 1"},
+		{"**q1st", "Station 1st"},
+		// The question is used over the csv data with the same label
+		{"**q1 **avgTravel", "Station 1 218.477"},
+		// Values are not replaced again
+		{"**loop", "**q1"},
+		{"**bold** and **unknown", "**bold** and **unknown"},
+	}
+
+	for _, test := range tests {
+		result, err := util.RenderPlaceholders(test.input, section, nil, nil)
+		if err != nil || result != test.expected {
+			t.Errorf("%q: expected %q, got %q (%v)", test.input, test.expected, result, err)
+		}
+	}
+}
+
+type echoGenerator struct{}
+
+func (g echoGenerator) GeneratePromptResponse(ctx context.Context, request models.GenerationRequest) (models.GenerationResponse, error) {
+	return models.GenerationResponse{Text: "Prompt: " + request.Prompt}, nil
+}
+
+func TestGenerateSectionPlaceholders(t *testing.T) {
+	section := placeholderSection()
+	section.TextOutputs = []models.ReportTextOutput{
+		{Title: "Static", Type: models.Static, Input: "{{q1}}: {{avgTravel | decimals:0}}s"},
+		{Title: "Legacy", Type: models.Static, Input: "**q10"},
+		{Title: "Broken", Type: models.Static, Input: "{{#if q1}}", Result: "Previous result"},
+		{Title: "Generator", Type: models.Generator, Input: "Summarize {{calls | thousands}} calls in {{city}}"},
+		{Title: "Broken Generator", Type: models.Generator, Input: "{{unknown}}"},
+	}
+	globalQuestions := &[]models.ReportQuestion{{Label: "city", Answer: "Guelph"}}
+
+	util.GenerateSectionStaticText(section, globalQuestions, nil)
+	err := util.GenerateSectionGeneratorText(context.Background(), echoGenerator{}, section, globalQuestions, nil, &models.GenerationOptions{})
+	if err != nil {
+		t.Fatalf("GenerateSectionGeneratorText returned an error: %v", err)
+	}
+
+	expected := []struct {
+		result string
+		err    string
+	}{
+		{"Station 1: 218s", ""},
+		{"District 4", ""},
+		{"", "{{#if q1}} is not closed with {{/if}}"},
+		{"Prompt: Summarize 1,234,567 calls in Guelph", ""},
+		{"", "error in prompt placeholders: unknown placeholder 'unknown'"},
+	}
+	for i, textOutput := range section.TextOutputs {
+		if textOutput.Result != expected[i].result || textOutput.Error != expected[i].err {
+			t.Errorf("%s: expected %q and error %q, got %q and error %q", textOutput.Title, expected[i].result, expected[i].err, textOutput.Result, textOutput.Error)
+		}
+	}
+
+	if section.TextOutputs[3].Input != "Summarize {{calls | thousands}} calls in {{city}}" {
+		t.Errorf("Expected the input to be kept, got %q", section.TextOutputs[3].Input)
+	}
+}
+
+func chartPlaceholderSection() *models.ReportSection {
+	return &models.ReportSection{
+		ChartOutputs: []models.ReportChartOutput{
+			{
+				Label:                  "calls",
+				Title:                  "Calls by month",
+				IndependentColumn:      "Date",
+				IndependentColumnLabel: "Month",
+				DependentColumns:       []models.ReportOneDimConfig{{AggregateValueLabel: "Calls"}, {AggregateValueLabel: "Average | min"}},
+				Results: []map[string]interface{}{
+					{"Date": "June", "Calls": 305, "Average | min": 4.5},
+					{"Date": "July", "Calls": 412.0, "Average | min": 3.25},
+					{"Date": "August", "Calls": 412, "Average | min": 6.0},
+					{"Date": "September", "Average | min": 5.0},
+				},
+			},
+			{
+				Title:             "Calls by station",
+				IndependentColumn: "Station",
+				Series:            []string{"Fire", "Medical"},
+				Results: []map[string]interface{}{
+					{"Station": "1", "Fire": 10, "Medical": 20},
+				},
+			},
+		},
+	}
+}
+
+func TestRenderChartPlaceholders(t *testing.T) {
+	section := chartPlaceholderSection()
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"{{calls.table}}", "| Month | Calls | Average \\| min |\n| --- | --- | --- |\n| June | 305 | 4.5 |\n| July | 412 | 3.25 |\n| August | 412 | 6 |\n| September |  | 5 |"},
+		{"{{calls.csv}}", "Month,Calls,Average | min\nJune,305,4.5\nJuly,412,3.25\nAugust,412,6\nSeptember,,5"},
+		{"Calls peaked in {{calls.max.label}} at {{calls.max.value}}", "Calls peaked in July at 412"},
+		{"{{calls.min.label}} {{calls.total | thousands}}", "June 1,129"},
+		{"{{#each calls.top.2}}{{@number}}. {{label}} {{/each}}", "1. July 2. August "},
+		{"{{#each calls.top.9}}{{label}} {{/each}}", "July August June "},
+		{"{{calls.columns.1.name}}: {{calls.columns.1.max.label}} {{calls.columns.1.min.value}}", "Average | min: August 3.25"},
+		{"{{#each calls.rows}}{{label}}={{value | default:\"-\"}} {{/each}}", "June=305 July=412 August=412 September=- "},
+		{"{{#each charts}}{{title}}: {{max.label}} {{max.value}}; {{/each}}", "Calls by month: July 412; Calls by station: 1 10; "},
+		{"{{charts.1.table}}", "| Station | Fire | Medical |\n| --- | --- | --- |\n| 1 | 10 | 20 |"},
+		{"{{#if calls.top}}ranked{{/if}}", "ranked"},
+		{"**calls", "| Month | Calls | Average \\| min |\n| --- | --- | --- |\n| June | 305 | 4.5 |\n| July | 412 | 3.25 |\n| August | 412 | 6 |\n| September |  | 5 |"},
+	}
+
+	for _, test := range tests {
+		result, err := util.RenderPlaceholders(test.input, section, nil, nil)
+		if err != nil || result != test.expected {
+			t.Errorf("%q: expected %q, got %q (%v)", test.input, test.expected, result, err)
+		}
+	}
+
+	// A chart without results has empty helpers
+	section.ChartOutputs[0].Results = nil
+	result, err := util.RenderPlaceholders(`{{#if calls.max.value}}peak{{else}}no calls{{/if}} {{calls.total | default:"0"}}`, section, nil, nil)
+	if err != nil || result != "no calls 0" {
+		t.Errorf("Expected empty helpers without results, got %q (%v)", result, err)
+	}
+
+	if _, err := util.RenderPlaceholders("{{calls}}", section, nil, nil); err == nil || !strings.Contains(err.Error(), "is a list or an item") {
+		t.Errorf("Expected a chart not to be a value, got %v", err)
+	}
+}
+
+func TestLintChartPlaceholders(t *testing.T) {
+	section := chartPlaceholderSection()
+	section.TextOutputs = []models.ReportTextOutput{
+		{Title: "Peak", Input: "{{calls.max.label}} {{#each calls.top.3}}{{label}}{{/each}} {{#each charts}}{{title}}{{/each}}"},
+		{Title: "Loop", Input: "{{#each calls}}{{/each}} {{#each charts}}{{unknown}}{{/each}}"},
+	}
+	report := &models.Report{Parts: []models.ReportPart{{Sections: []models.ReportSection{*section}}}}
+
+	lint := util.LintReportPlaceholders(report)
+	if len(lint.Issues) != 2 {
+		t.Fatalf("Expected 2 issues, got %+v", lint.Issues)
+	}
+	if issue := lint.Issues[0]; issue.Kind != models.InvalidPlaceholderIssue || issue.TextOutput != "Loop" || issue.Label != "calls" {
+		t.Errorf("Expected a chart not to be a list, got %+v", issue)
+	}
+	if issue := lint.Issues[1]; issue.Kind != models.UndefinedPlaceholderIssue || issue.Label != "unknown" {
+		t.Errorf("Expected an unknown chart field, got %+v", issue)
+	}
+}
@@ -0,0 +1,137 @@
+package util_test
+
+import (
+	"api/shared/models"
+	"api/shared/util"
+	"context"
+	"reflect"
+	"testing"
+)
+
+func temperature(value float32) *float32 {
+	return &value
+}
+
+func TestResolveGenerationOptions(t *testing.T) {
+	defaults := &models.GenerationOptions{
+		SystemPrompt:  "Write in formal third person for a municipal fire department",
+		Temperature:   temperature(0.7),
+		MaxTokens:     400,
+		StopSequences: []string{"END"},
+	}
+
+	if options := util.ResolveGenerationOptions(defaults, nil); !reflect.DeepEqual(options, *defaults) {
+		t.Errorf("Expected the defaults without overrides, got %+v", options)
+	}
+
+	options := util.ResolveGenerationOptions(defaults, &models.GenerationOptions{Temperature: temperature(0), StopSequences: []string{}})
+	expected := models.GenerationOptions{
+		SystemPrompt:  defaults.SystemPrompt,
+		Temperature:   temperature(0),
+		MaxTokens:     400,
+		StopSequences: []string{},
+	}
+	if !reflect.DeepEqual(options, expected) {
+		t.Errorf("Expected a temperature of 0 and no stop sequences to override the defaults, got %+v", options)
+	}
+
+	if options := util.ResolveGenerationOptions(nil, &models.GenerationOptions{MaxTokens: 50}); options.MaxTokens != 50 || options.Temperature != nil {
+		t.Errorf("Expected only the overrides without defaults, got %+v", options)
+	}
+}
+
+func TestValidateGenerationOptions(t *testing.T) {
+	valid := []*models.GenerationOptions{
+		nil,
+		{},
+		{Temperature: temperature(0), MaxTokens: 100, StopSequences: []string{"a", "b", "c", "d"}},
+	}
+	for _, options := range valid {
+		if err := util.ValidateGenerationOptions(options); err != nil {
+			t.Errorf("Expected %+v to be valid, got %v", options, err)
+		}
+	}
+
+	invalid := []*models.GenerationOptions{
+		{Temperature: temperature(-0.1)},
+		{Temperature: temperature(2.5)},
+		{MaxTokens: -1},
+		{StopSequences: []string{"a", "b", "c", "d", "e"}},
+		{StopSequences: []string{""}},
+	}
+	for _, options := range invalid {
+		if err := util.ValidateGenerationOptions(options); err == nil {
+			t.Errorf("Expected %+v to be invalid", options)
+		}
+	}
+}
+
+func TestGenerationOptionsRequests(t *testing.T) {
+	var request fakeProviderRequest
+	registry := newFakeProviderRegistry(t, "", &request)
+
+	options := models.GenerationOptions{
+		SystemPrompt:  "Be brief",
+		Temperature:   temperature(0),
+		MaxTokens:     64,
+		StopSequences: []string{"END"},
+	}
+
+	openAI, _ := registry.ModelGenerator(models.OpenAIProvider, "")
+	if _, err := openAI.GeneratePromptResponse(context.Background(), models.GenerationRequest{Prompt: "Hello", Options: options}); err != nil {
+		t.Fatalf("GeneratePromptResponse returned an error: %v", err)
+	}
+	if len(request.Messages) != 2 || request.Messages[0].Role != "system" || request.Messages[0].Content != "Be brief" {
+		t.Errorf("Expected a system message before the prompt, got %+v", request.Messages)
+	}
+	// A temperature of 0 is sent as the smallest one, since 0 is left out of the request
+	if request.Temperature == nil || *request.Temperature > 1e-6 || request.MaxTokens != 64 || !reflect.DeepEqual(request.Stop, []string{"END"}) {
+		t.Errorf("Expected the options in the openai request, got %+v", request)
+	}
+
+	anthropic, _ := registry.ModelGenerator(models.AnthropicProvider, "")
+	if _, err := anthropic.GeneratePromptResponse(context.Background(), models.GenerationRequest{Prompt: "Hello", Options: options}); err != nil {
+		t.Fatalf("GeneratePromptResponse returned an error: %v", err)
+	}
+	if request.System != "Be brief" || len(request.Messages) != 1 || request.Temperature == nil || *request.Temperature != 0 ||
+		request.MaxTokens != 64 || !reflect.DeepEqual(request.StopSequences, []string{"END"}) {
+		t.Errorf("Expected the options in the anthropic request, got %+v", request)
+	}
+
+	if _, err := anthropic.GeneratePromptResponse(context.Background(), models.GenerationRequest{Prompt: "Hello"}); err != nil {
+		t.Fatalf("GeneratePromptResponse returned an error: %v", err)
+	}
+	if request.MaxTokens != 1024 || request.Temperature != nil || request.System != "" {
+		t.Errorf("Expected only the default max tokens without options, got %+v", request)
+	}
+}
+
+func TestGenerateSectionGeneratorTextOptions(t *testing.T) {
+	var request fakeProviderRequest
+	registry := newFakeProviderRegistry(t, "", &request)
+
+	section := &models.ReportSection{
+		TextOutputs: []models.ReportTextOutput{
+			{Title: "Summary", Type: models.Generator, Input: "Summarize", Options: &models.GenerationOptions{MaxTokens: 80}},
+		},
+	}
+	defaults := &models.GenerationOptions{SystemPrompt: "Write formally", MaxTokens: 400}
+
+	err := util.GenerateSectionGeneratorText(context.Background(), registry, section, &[]models.ReportQuestion{}, nil, defaults)
+	if err != nil {
+		t.Fatalf("GenerateSectionGeneratorText returned an error: %v", err)
+	}
+
+	if request.MaxTokens != 80 || request.Messages[0].Content != "Write formally" {
+		t.Errorf("Expected the output options over the report options, got %+v", request)
+	}
+
+	generation := section.TextOutputs[0].Generation
+	if generation == nil || generation.Provider != models.OpenAIProvider || generation.Model != "gpt-3.5-turbo" || generation.GeneratedAt == 0 {
+		t.Fatalf("Expected the provider and model the output was generated with, got %+v", generation)
+	}
+	expected := models.GenerationOptions{SystemPrompt: "Write formally", MaxTokens: 80}
+	if !reflect.DeepEqual(generation.Options, expected) {
+		t.Errorf("Expected the options the output was generated with, got %+v", generation.Options)
+	}
+}
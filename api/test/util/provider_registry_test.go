@@ -0,0 +1,176 @@
+package util_test
+
+import (
+	"api/shared/models"
+	"api/shared/util"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeProviderRequest holds the fields of chat completions and messages requests
+type fakeProviderRequest struct {
+	Model    string `json:"model"`
+	Messages []struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	} `json:"messages"`
+	System        string   `json:"system"`
+	Temperature   *float32 `json:"temperature"`
+	MaxTokens     int      `json:"max_tokens"`
+	Stop          []string `json:"stop"`
+	StopSequences []string `json:"stop_sequences"`
+}
+
+// newFakeProviderServer answers chat completions and messages requests with the path and
+// model they were sent with, along with the key they were authorized with. The tokens
+// used are the lengths of the prompt and result. The last request is stored in the
+// request given.
+func newFakeProviderServer(t *testing.T, lastRequest *fakeProviderRequest) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body fakeProviderRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || len(body.Messages) == 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if lastRequest != nil {
+			*lastRequest = body
+		}
+		prompt := body.Messages[len(body.Messages)-1].Content
+
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasSuffix(r.URL.Path, "/v1/messages") {
+			if body.Model == "missing-model" {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, `{"type":"error","error":{"type":"not_found_error","message":"model: missing-model"}}`)
+				return
+			}
+			text := fmt.Sprintf("%s %s %s: %s", r.URL.Path, body.Model, r.Header.Get("x-api-key"), prompt)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"content": []map[string]string{{"type": "text", "text": text}},
+				"usage":   map[string]int{"input_tokens": len(prompt), "output_tokens": len(text)},
+			})
+			return
+		}
+
+		key := strings.TrimSpace(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer"))
+		if key == "" {
+			key = r.Header.Get("api-key")
+		}
+		text := fmt.Sprintf("%s %s %s: %s", r.URL.Path, body.Model, key, prompt)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{{"message": map[string]string{"role": "assistant", "content": text}}},
+			"usage":   map[string]int{"prompt_tokens": len(prompt), "completion_tokens": len(text)},
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newFakeProviderRegistry(t *testing.T, defaultProvider models.GeneratorProvider, lastRequest *fakeProviderRequest) *util.ProviderRegistry {
+	server := newFakeProviderServer(t, lastRequest)
+	return util.NewProviderRegistry(util.GeneratorConfig{
+		DefaultProvider: defaultProvider,
+		Providers: map[models.GeneratorProvider]util.ProviderConfig{
+			models.OpenAIProvider:           {APIKey: "openai-key", BaseURL: server.URL + "/v1", DefaultModel: "gpt-3.5-turbo"},
+			models.AnthropicProvider:        {APIKey: "anthropic-key", BaseURL: server.URL, DefaultModel: "claude-3-haiku"},
+			models.AzureOpenAIProvider:      {APIKey: "azure-key", BaseURL: server.URL, DefaultModel: "reports-gpt4"},
+			models.OpenAICompatibleProvider: {BaseURL: server.URL + "/ollama/v1"},
+		},
+	})
+}
+
+func TestProviderRegistryModels(t *testing.T) {
+	registry := newFakeProviderRegistry(t, "", nil)
+
+	tests := []struct {
+		provider models.GeneratorProvider
+		model    string
+		expected string
+	}{
+		{"", "", "/v1/chat/completions gpt-3.5-turbo openai-key: Hello"},
+		{models.OpenAIProvider, "gpt-4", "/v1/chat/completions gpt-4 openai-key: Hello"},
+		{models.AnthropicProvider, "", "/v1/messages claude-3-haiku anthropic-key: Hello"},
+		{models.AnthropicProvider, "claude-3-opus", "/v1/messages claude-3-opus anthropic-key: Hello"},
+		{models.AzureOpenAIProvider, "", "/openai/deployments/reports-gpt4/chat/completions reports-gpt4 azure-key: Hello"},
+		{models.OpenAICompatibleProvider, "llama3", "/ollama/v1/chat/completions llama3 : Hello"},
+	}
+
+	for _, test := range tests {
+		generator, err := registry.ModelGenerator(test.provider, test.model)
+		if err != nil {
+			t.Errorf("%s %s: ModelGenerator returned an error: %v", test.provider, test.model, err)
+			continue
+		}
+		response, err := generator.GeneratePromptResponse(context.Background(), models.GenerationRequest{Prompt: "Hello"})
+		if err != nil || response.Text != test.expected {
+			t.Errorf("%s %s: expected %q, got %q (%v)", test.provider, test.model, test.expected, response.Text, err)
+		}
+	}
+}
+
+func TestProviderRegistryErrors(t *testing.T) {
+	registry := newFakeProviderRegistry(t, models.AnthropicProvider, nil)
+
+	response, err := registry.GeneratePromptResponse(context.Background(), models.GenerationRequest{Prompt: "Hello"})
+	if err != nil || !strings.HasPrefix(response.Text, "/v1/messages claude-3-haiku") {
+		t.Errorf("Expected the default provider to be used, got %q (%v)", response.Text, err)
+	}
+
+	// The compatible server has no model of its own
+	if _, err := registry.ModelGenerator(models.OpenAICompatibleProvider, ""); err == nil {
+		t.Errorf("Expected an error for a provider without a model")
+	}
+	if _, err := registry.ModelGenerator("cohere", "command"); err == nil {
+		t.Errorf("Expected an error for an unknown provider")
+	}
+
+	unconfigured := util.NewProviderRegistry(util.GeneratorConfig{})
+	if _, err := unconfigured.ModelGenerator(models.AnthropicProvider, "claude-3-haiku"); err == nil {
+		t.Errorf("Expected an error for a provider that is not configured")
+	}
+
+	generator, _ := registry.ModelGenerator(models.AnthropicProvider, "missing-model")
+	if _, err := generator.GeneratePromptResponse(context.Background(), models.GenerationRequest{Prompt: "Hello"}); err == nil || !strings.Contains(err.Error(), "model: missing-model") {
+		t.Errorf("Expected the error message of the provider, got %v", err)
+	}
+}
+
+func TestGenerateSectionGeneratorTextModels(t *testing.T) {
+	registry := newFakeProviderRegistry(t, "", nil)
+
+	section := &models.ReportSection{
+		TextOutputs: []models.ReportTextOutput{
+			{Title: "Default", Type: models.Generator, Input: "Summarize"},
+			{Title: "Claude", Type: models.Generator, Input: "Summarize", Provider: models.AnthropicProvider, Model: "claude-3-opus"},
+			{Title: "Local", Type: models.Generator, Input: "Summarize", Provider: models.OpenAICompatibleProvider},
+			{Title: "Static", Type: models.Static, Input: "Unchanged", Provider: models.AnthropicProvider},
+		},
+	}
+
+	err := util.GenerateSectionGeneratorText(context.Background(), registry, section, &[]models.ReportQuestion{}, nil, &models.GenerationOptions{})
+	if err != nil {
+		t.Fatalf("GenerateSectionGeneratorText returned an error: %v", err)
+	}
+
+	expected := []struct {
+		result string
+		err    string
+	}{
+		{"/v1/chat/completions gpt-3.5-turbo openai-key: Summarize", ""},
+		{"/v1/messages claude-3-opus anthropic-key: Summarize", ""},
+		{"", "no model given for generator provider 'openai-compatible'"},
+		{"", ""},
+	}
+	for i, textOutput := range section.TextOutputs {
+		if textOutput.Result != expected[i].result || textOutput.Error != expected[i].err {
+			t.Errorf("%s: expected %q and error %q, got %q and error %q", textOutput.Title, expected[i].result, expected[i].err, textOutput.Result, textOutput.Error)
+		}
+	}
+}
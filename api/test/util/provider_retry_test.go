@@ -0,0 +1,164 @@
+package util_test
+
+import (
+	"api/shared/models"
+	"api/shared/util"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newFlakyProviderRegistry answers chat completions requests with respond, or successfully
+// when respond does not answer them. The number of requests made is counted.
+func newFlakyProviderRegistry(t *testing.T, policy util.RetryPolicy, respond func(attempt int32, w http.ResponseWriter) bool) (*util.ProviderRegistry, *int32) {
+	t.Helper()
+
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempt := atomic.AddInt32(&attempts, 1)
+		if respond(attempt, w) {
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"choices":[{"message":{"role":"assistant","content":"attempt %d"}}]}`, attempt)
+	}))
+	t.Cleanup(server.Close)
+
+	registry := util.NewProviderRegistry(util.GeneratorConfig{
+		Providers: map[models.GeneratorProvider]util.ProviderConfig{
+			models.OpenAIProvider: {APIKey: "openai-key", BaseURL: server.URL + "/v1", DefaultModel: "gpt-3.5-turbo"},
+		},
+		Retry: &policy,
+	})
+	return registry, &attempts
+}
+
+func respondWithStatus(w http.ResponseWriter, status int, retryAfter string) bool {
+	if retryAfter != "" {
+		w.Header().Set("Retry-After", retryAfter)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	fmt.Fprint(w, `{"error":{"message":"try again","type":"server_error"}}`)
+	return true
+}
+
+func TestRetryingGeneratorStatuses(t *testing.T) {
+	policy := util.RetryPolicy{Timeout: time.Second, MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+
+	tests := []struct {
+		name     string
+		statuses []int
+		result   string
+		attempts int32
+	}{
+		{"server errors", []int{http.StatusServiceUnavailable, http.StatusInternalServerError}, "attempt 3", 3},
+		{"rate limited", []int{http.StatusTooManyRequests}, "attempt 2", 2},
+		{"too many failures", []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway}, "", 3},
+		{"bad request", []int{http.StatusBadRequest}, "", 1},
+	}
+
+	for _, test := range tests {
+		registry, attempts := newFlakyProviderRegistry(t, policy, func(attempt int32, w http.ResponseWriter) bool {
+			if int(attempt) <= len(test.statuses) {
+				return respondWithStatus(w, test.statuses[attempt-1], "")
+			}
+			return false
+		})
+
+		response, err := registry.GeneratePromptResponse(context.Background(), models.GenerationRequest{Prompt: "Hello"})
+		result := response.Text
+		if result != test.result || (test.result == "") != (err != nil) || *attempts != test.attempts {
+			t.Errorf("%s: expected %q after %d attempts, got %q after %d (%v)", test.name, test.result, test.attempts, result, *attempts, err)
+		}
+	}
+}
+
+func TestRetryingGeneratorRetryAfter(t *testing.T) {
+	policy := util.RetryPolicy{Timeout: time.Second, MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Second}
+	registry, attempts := newFlakyProviderRegistry(t, policy, func(attempt int32, w http.ResponseWriter) bool {
+		if attempt == 1 {
+			return respondWithStatus(w, http.StatusTooManyRequests, "1")
+		}
+		return false
+	})
+
+	start := time.Now()
+	response, err := registry.GeneratePromptResponse(context.Background(), models.GenerationRequest{Prompt: "Hello"})
+	if err != nil || response.Text != "attempt 2" {
+		t.Fatalf("Expected the second attempt to succeed, got %q (%v)", response.Text, err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("Expected the retry to wait for the Retry-After delay, waited %v", elapsed)
+	}
+
+	// Delays longer than the policy allows are not waited for
+	policy.MaxDelay = 100 * time.Millisecond
+	registry, attempts = newFlakyProviderRegistry(t, policy, func(attempt int32, w http.ResponseWriter) bool {
+		return respondWithStatus(w, http.StatusTooManyRequests, "120")
+	})
+	if _, err := registry.GeneratePromptResponse(context.Background(), models.GenerationRequest{Prompt: "Hello"}); err == nil || *attempts != 1 {
+		t.Errorf("Expected to give up after one attempt, got %d attempts (%v)", *attempts, err)
+	}
+}
+
+func TestRetryingGeneratorTimeout(t *testing.T) {
+	policy := util.RetryPolicy{Timeout: 50 * time.Millisecond, MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+	registry, attempts := newFlakyProviderRegistry(t, policy, func(attempt int32, w http.ResponseWriter) bool {
+		time.Sleep(200 * time.Millisecond)
+		return false
+	})
+
+	_, err := registry.GeneratePromptResponse(context.Background(), models.GenerationRequest{Prompt: "Hello"})
+	if err == nil || !strings.Contains(err.Error(), "timed out") || *attempts != 2 {
+		t.Errorf("Expected both attempts to time out, got %d attempts (%v)", *attempts, err)
+	}
+
+	// A cancelled generation is not retried
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = registry.GeneratePromptResponse(ctx, models.GenerationRequest{Prompt: "Hello"})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the cancellation error, got %v", err)
+	}
+}
+
+type failingGenerator struct{}
+
+func (g failingGenerator) GeneratePromptResponse(ctx context.Context, request models.GenerationRequest) (models.GenerationResponse, error) {
+	if request.Prompt == "Fail" {
+		return models.GenerationResponse{}, errors.New("model overloaded")
+	}
+	return models.GenerationResponse{Text: "Generated " + request.Prompt}, nil
+}
+
+func TestGenerateSectionFailurePolicy(t *testing.T) {
+	for _, policy := range []models.SectionFailurePolicy{"", models.FailOutput, models.FailSection} {
+		section := &models.ReportSection{
+			FailurePolicy: policy,
+			TextOutputs: []models.ReportTextOutput{
+				{Title: "Summary", Type: models.Generator, Input: "Summary"},
+				{Title: "Outlook", Type: models.Generator, Input: "Fail", Result: "err generating: old error"},
+			},
+		}
+
+		err := util.GenerateSectionGeneratorText(context.Background(), failingGenerator{}, section, &[]models.ReportQuestion{}, nil, &models.GenerationOptions{})
+		if (err != nil) != (policy == models.FailSection) {
+			t.Errorf("%q: expected an error only when the section fails, got %v", policy, err)
+		}
+
+		summary, outlook := section.TextOutputs[0], section.TextOutputs[1]
+		if summary.Result != "Generated Summary" || summary.Error != "" {
+			t.Errorf("%q: expected the summary to generate, got %+v", policy, summary)
+		}
+		if outlook.Result != "" || outlook.Error != "model overloaded" || outlook.Generation != nil {
+			t.Errorf("%q: expected the outlook to hold its error, got %+v", policy, outlook)
+		}
+	}
+}
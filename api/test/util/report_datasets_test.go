@@ -0,0 +1,86 @@
+package util_test
+
+import (
+	"api/shared/models"
+	"api/shared/util"
+	"reflect"
+	"testing"
+)
+
+func mockStaffingRows() [][]string {
+	return [][]string{
+		{"Station", "Firefighters"},
+		{"1", "12"},
+		{"2", "8"},
+		{"3", "4"},
+	}
+}
+
+func TestAnalyzeSectionDataUsesOutputDataset(t *testing.T) {
+	incidentFile := writeMockCSV(t, mockIncidentRows())
+	staffingFile := writeMockCSV(t, mockStaffingRows())
+
+	section := models.ReportSection{
+		CSVData: []models.ReportCSVData{
+			{Label: "calls", OperationType: models.SetElementOccurrences, OperationColumn: "Incident Type"},
+			{Label: "staff", OperationType: models.NumericalSum, OperationColumn: "Firefighters", Dataset: "staffing"},
+		},
+		ChartOutputs: []models.ReportChartOutput{
+			{
+				IndependentColumn: "Station",
+				Dataset:           "staffing",
+				DependentColumns: []models.ReportOneDimConfig{
+					{AggregateValueLabel: "Staff", Column: "Firefighters", OperationType: models.NumericalSum},
+				},
+			},
+		},
+	}
+
+	// Each output reads its own dataset, so neither pass fails on the columns of the other csv
+	if err := util.AnalyzeSectionData(incidentFile, models.Dataset{}, &section); err != nil {
+		t.Fatalf("AnalyzeSectionData returned an error for the report csv: %v", err)
+	}
+	staffing := models.Dataset{Name: "staffing", CSVID: "datasets/report/staffing.csv"}
+	if err := util.AnalyzeSectionData(staffingFile, staffing, &section); err != nil {
+		t.Fatalf("AnalyzeSectionData returned an error for the staffing dataset: %v", err)
+	}
+
+	if section.CSVData[0].Result != "6" {
+		t.Errorf("Expected 6 calls, got %s", section.CSVData[0].Result)
+	}
+	if section.CSVData[1].Result != "24" {
+		t.Errorf("Expected 24 staff, got %s", section.CSVData[1].Result)
+	}
+	expectedResults := []map[string]interface{}{
+		{"Station": "1", "Staff": 12.0},
+		{"Station": "2", "Staff": 8.0},
+		{"Station": "3", "Staff": 4.0},
+	}
+	if !reflect.DeepEqual(section.ChartOutputs[0].Results, expectedResults) {
+		t.Errorf("Dataset chart was not computed correctly. Got: \n %v \n, want: \n %v", section.ChartOutputs[0].Results, expectedResults)
+	}
+}
+
+func TestFindReportDataset(t *testing.T) {
+	report := models.Report{
+		CSVID:           "report.csv",
+		CSVColumnsS3Key: "report.csv.json",
+		Datasets: []models.Dataset{
+			{Name: "staffing", CSVID: "datasets/report/staffing.csv"},
+		},
+	}
+
+	dataset, err := util.FindReportDataset(&report, "")
+	if err != nil || dataset.CSVID != "report.csv" || dataset.CSVColumnsS3Key != "report.csv.json" {
+		t.Errorf("Expected the report csv for an empty dataset name, got %+v, %v", dataset, err)
+	}
+
+	dataset, err = util.FindReportDataset(&report, "staffing")
+	if err != nil || dataset.CSVID != "datasets/report/staffing.csv" {
+		t.Errorf("Expected the staffing dataset, got %+v, %v", dataset, err)
+	}
+
+	if _, err := util.FindReportDataset(&report, "budget"); err == nil {
+		t.Errorf("Expected an error for a dataset missing from the report")
+	}
+}
@@ -0,0 +1,164 @@
+package util_test
+
+import (
+	"api/shared/models"
+	"api/shared/util"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func mockDerivedColumns() []models.DerivedColumn {
+	return []models.DerivedColumn{
+		{Name: "Total Time", Expression: "[Travel Time] + [Turnout Time]"},
+		{Name: "Priority", Expression: `if([Total Time] > 400, "High", "Low")`},
+		{Name: "Code", Expression: `upper(left([Incident Type], 3)) & "-" & [Station]`},
+	}
+}
+
+func TestDerivedColumnsInAnalysis(t *testing.T) {
+	csvFile := writeMockCSV(t, mockIncidentRows())
+
+	section := &models.ReportSection{
+		CSVData: []models.ReportCSVData{
+			{Label: "fires at 1", OperationType: models.SetElementOccurrences, OperationColumn: "Code", AcceptedValues: []string{"FIR-1"}},
+		},
+		ChartOutputs: []models.ReportChartOutput{
+			{
+				IndependentColumn: "Priority",
+				DependentColumns: []models.ReportOneDimConfig{
+					{AggregateValueLabel: "Total", Column: "Total Time", OperationType: models.NumericalSum},
+				},
+			},
+		},
+	}
+	if err := util.AnalyzeSectionData(csvFile, models.Dataset{DerivedColumns: mockDerivedColumns()}, section); err != nil {
+		t.Fatalf("AnalyzeSectionData returned an error: %v", err)
+	}
+
+	if section.CSVData[0].Result != "2" {
+		t.Errorf("Expected 2 fires at station 1, got %s", section.CSVData[0].Result)
+	}
+	expectedResults := []map[string]interface{}{
+		{"Priority": "High", "Total": 1640.0},
+		{"Priority": "Low", "Total": 780.0},
+	}
+	if !reflect.DeepEqual(section.ChartOutputs[0].Results, expectedResults) {
+		t.Errorf("Derived column chart was not computed correctly. Got: \n %v \n, want: \n %v", section.ChartOutputs[0].Results, expectedResults)
+	}
+}
+
+func TestDerivedColumnsInUniqueValuesMap(t *testing.T) {
+	csvFile := writeMockCSV(t, mockIncidentRows())
+
+	uniqueValues, err := util.GetUniqueColumnValuesMapInCSV(csvFile, mockDerivedColumns())
+	if err != nil {
+		t.Fatalf("GetUniqueColumnValuesMapInCSV returned an error: %v", err)
+	}
+
+	var priorities []string
+	for _, value := range uniqueValues["Priority"].Values {
+		priorities = append(priorities, value.Value)
+	}
+	sort.Strings(priorities)
+	if !reflect.DeepEqual(priorities, []string{"High", "Low"}) {
+		t.Errorf("Expected derived column values [High Low], got %v", priorities)
+	}
+	if uniqueValues["Station"].DistinctCount != 3 {
+		t.Errorf("Expected 3 unique stations, got %v", uniqueValues["Station"])
+	}
+}
+
+// longTextExpression builds 40000 bytes, more than half of the longest text an expression can build
+var longTextExpression = `replace(replace(replace("` + strings.Repeat("a", 40) + `", "a", "aaaaaaaaaa"), "a", "aaaaaaaaaa"), "a", "aaaaaaaaaa")`
+
+func TestDerivedColumnExpressions(t *testing.T) {
+	csvFile := writeMockCSV(t, [][]string{
+		{"Name", "Amount", "Staff A", "Staff B", "Dispatched", "Arrived"},
+		{" engine 1 ", "$1,200.50", "4", "", "2023-01-15 08:10:00", "2023-01-15 08:15:30"},
+	})
+
+	tests := []struct {
+		expression string
+		expected   string
+	}{
+		{"1 + 2 * 3 - 4 / 2", "5"},
+		{"(1 + 2) * 3 % 4", "1"},
+		{"0.1 + 0.2", "0.3"},
+		{"[Amount] * 2", "2401"},
+		{`trim([Name]) & "!"`, "engine 1!"},
+		{`mid(trim([Name]), 1, 6)`, "engine"},
+		{`right(trim([Name]), 1) = 1`, "true"},
+		{`replace(upper(trim([Name])), " ", "_")`, "ENGINE_1"},
+		{`len("héllo")`, "5"},
+		{`coalesce([Staff B], [Staff A])`, "4"},
+		{`number([Staff A]) + number(coalesce([Staff B], 0))`, "4"},
+		{`isblank([Staff B]) and not isblank([Staff A])`, "true"},
+		{`case([Staff A] < 2, "small", [Staff A] < 5, "medium", "large")`, "medium"},
+		{`"a" < "b" || false`, "true"},
+		{`round(10 / 3, 2)`, "3.33"},
+		{`max(1, [Staff A], 3)`, "4"},
+		{`year([Dispatched]) & "-" & month([Dispatched])`, "2023-1"},
+		{`weekday([Dispatched])`, "Sunday"},
+		{`formatdate([Dispatched], "YYYY-MM")`, "2023-01"},
+		{`datediff([Dispatched], [Arrived], "minutes")`, "5.5"},
+		{`formatdate("15/01/2023", "MMM D", "DD/MM/YYYY")`, "Jan 15"},
+		{`"say ""hi"""`, `say "hi"`},
+		// Values that cannot be computed are left blank
+		{`[Staff A] / [Staff B]`, ""},
+		{`[Name] * 2`, ""},
+		{`if([Staff B], "yes")`, ""},
+		{`replace([Name], [Staff B], "x")`, ""},
+		{`len(` + longTextExpression + `)`, "40000"},
+		{longTextExpression + " & " + longTextExpression, ""},
+		{"concat(" + longTextExpression + ", " + longTextExpression + ")", ""},
+		{`replace(` + longTextExpression + `, "a", "aa")`, ""},
+	}
+
+	for _, test := range tests {
+		derivedColumns := []models.DerivedColumn{{Name: "Result", Expression: test.expression}}
+		uniqueValues, err := util.GetUniqueColumnValuesMapInCSV(csvFile, derivedColumns)
+		if err != nil {
+			t.Errorf("%s: GetUniqueColumnValuesMapInCSV returned an error: %v", test.expression, err)
+			continue
+		}
+		if values := uniqueValues["Result"].Values; len(values) != 1 || values[0].Value != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.expression, test.expected, values)
+		}
+	}
+}
+
+func TestDerivedColumnErrors(t *testing.T) {
+	csvFile := writeMockCSV(t, mockIncidentRows())
+
+	tests := []struct {
+		name           string
+		derivedColumns []models.DerivedColumn
+	}{
+		{"unknown column", []models.DerivedColumn{{Name: "A", Expression: "[Missing] + 1"}}},
+		{"later column", []models.DerivedColumn{{Name: "A", Expression: "[B]"}, {Name: "B", Expression: "1"}}},
+		{"unknown function", []models.DerivedColumn{{Name: "A", Expression: "exec([Station])"}}},
+		{"bare column name", []models.DerivedColumn{{Name: "A", Expression: "Station + 1"}}},
+		{"wrong arguments", []models.DerivedColumn{{Name: "A", Expression: "upper([Station], 1)"}}},
+		{"syntax", []models.DerivedColumn{{Name: "A", Expression: "(1 + 2"}}},
+		{"trailing tokens", []models.DerivedColumn{{Name: "A", Expression: "1 2"}}},
+		{"unterminated string", []models.DerivedColumn{{Name: "A", Expression: `"abc`}}},
+		{"name clash", []models.DerivedColumn{{Name: "Station", Expression: "1"}}},
+		{"empty name", []models.DerivedColumn{{Name: " ", Expression: "1"}}},
+		{"empty replace", []models.DerivedColumn{{Name: "A", Expression: `replace([Station], "", "x")`}}},
+	}
+
+	for _, test := range tests {
+		if _, err := util.GetUniqueColumnValuesMapInCSV(csvFile, test.derivedColumns); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+
+	if err := util.ValidateDerivedColumns([]models.DerivedColumn{{Name: "A", Expression: "[Anything] & 1"}}); err != nil {
+		t.Errorf("Expected a valid expression without a csv, got %v", err)
+	}
+	if err := util.ValidateDerivedColumns([]models.DerivedColumn{{Name: "A", Expression: "1 +"}}); err == nil {
+		t.Errorf("Expected a syntax error without a csv")
+	}
+}
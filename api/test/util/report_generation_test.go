@@ -0,0 +1,46 @@
+package util_test
+
+import (
+	"api/shared/models"
+	"api/shared/util"
+	"reflect"
+	"testing"
+)
+
+func TestReportSectionRefs(t *testing.T) {
+	report := &models.Report{
+		Parts: []models.ReportPart{
+			{Sections: []models.ReportSection{{Title: "Intro"}, {Title: "Travel Times"}}},
+			{},
+			{Sections: []models.ReportSection{{Title: "Summary"}}},
+		},
+	}
+
+	all, err := util.ReportSectionRefs(report, nil)
+	expected := []models.SectionRef{{PartIndex: 0, SectionIndex: 0}, {PartIndex: 0, SectionIndex: 1}, {PartIndex: 2, SectionIndex: 0}}
+	if err != nil || !reflect.DeepEqual(all, expected) {
+		t.Errorf("Expected every section of the report, got %v (%v)", all, err)
+	}
+
+	chosen := []models.SectionRef{{PartIndex: 2, SectionIndex: 0}, {PartIndex: 0, SectionIndex: 1}}
+	refs, err := util.ReportSectionRefs(report, chosen)
+	if err != nil || !reflect.DeepEqual(refs, chosen) {
+		t.Errorf("Expected the chosen sections in order, got %v (%v)", refs, err)
+	}
+
+	invalid := [][]models.SectionRef{
+		{{PartIndex: 1, SectionIndex: 0}},
+		{{PartIndex: 3, SectionIndex: 0}},
+		{{PartIndex: 0, SectionIndex: -1}},
+		{{PartIndex: 0, SectionIndex: 1}, {PartIndex: 0, SectionIndex: 1}},
+	}
+	for _, sections := range invalid {
+		if _, err := util.ReportSectionRefs(report, sections); err == nil {
+			t.Errorf("Expected an error for sections %v", sections)
+		}
+	}
+
+	if _, err := util.ReportSectionRefs(&models.Report{}, nil); err == nil {
+		t.Errorf("Expected an error for a report without sections")
+	}
+}
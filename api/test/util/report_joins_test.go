@@ -0,0 +1,153 @@
+package util_test
+
+import (
+	"api/shared/models"
+	"api/shared/util"
+	"encoding/csv"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func mockUnitResponseRows() [][]string {
+	return [][]string{
+		{"Incident Number", "Unit", "Station", "Travel Time"},
+		{"1001", "E1", "1", "240"},
+		{"1001", "L1", "1", "300"},
+		{"1002", "E2", "2", "420"},
+		{"1004", "E3", "3", "360"},
+	}
+}
+
+func mockJoinIncidentRows() [][]string {
+	return [][]string{
+		{"Incident Number", "Incident Type", "Station"},
+		{"1001", "Fire", "1"},
+		{"1002", "Medical", "2"},
+		{"1003", "Medical", "2"},
+		{"", "Alarm", "3"},
+	}
+}
+
+func readJoinedCSV(t *testing.T, file *os.File) [][]string {
+	t.Helper()
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatalf("error reading joined csv: %v", err)
+	}
+	return rows
+}
+
+func TestJoinCSVLeftJoinKeepsUnmatchedRows(t *testing.T) {
+	units := writeMockCSV(t, mockUnitResponseRows())
+	incidents := writeMockCSV(t, mockJoinIncidentRows())
+
+	join := models.Join{
+		Name:         "responses",
+		Type:         models.LeftJoin,
+		RightDataset: "units",
+		Keys:         []models.JoinKey{{LeftColumn: "Incident Number"}},
+	}
+
+	// The incidents are the left side, so the larger unit csv is held in the hash table
+	joined, err := util.JoinCSV(join, util.JoinSide{File: incidents}, util.JoinSide{File: units, Dataset: models.Dataset{Name: "units"}})
+	if err != nil {
+		t.Fatalf("JoinCSV returned an error: %v", err)
+	}
+	defer joined.Close()
+
+	expectedRows := [][]string{
+		{"Incident Number", "Incident Type", "Report.Station", "Unit", "units.Station", "Travel Time"},
+		{"1001", "Fire", "1", "E1", "1", "240"},
+		{"1001", "Fire", "1", "L1", "1", "300"},
+		{"1002", "Medical", "2", "E2", "2", "420"},
+		{"1003", "Medical", "2", "", "", ""},
+		{"", "Alarm", "3", "", "", ""},
+	}
+	rows := readJoinedCSV(t, joined)
+	if !reflect.DeepEqual(rows, expectedRows) {
+		t.Errorf("Left join was not computed correctly. Got: \n %v \n, want: \n %v", rows, expectedRows)
+	}
+}
+
+func TestJoinCSVWithSmallerLeftSide(t *testing.T) {
+	units := writeMockCSV(t, mockUnitResponseRows())
+	incidents := writeMockCSV(t, mockJoinIncidentRows()[:3])
+
+	for _, joinType := range []models.JoinType{models.InnerJoin, models.LeftJoin} {
+		join := models.Join{
+			Name:        "responses",
+			Type:        joinType,
+			LeftPrefix:  "Incident",
+			RightPrefix: "Unit",
+			Keys:        []models.JoinKey{{LeftColumn: "Incident Number"}},
+		}
+		joined, err := util.JoinCSV(join, util.JoinSide{File: incidents}, util.JoinSide{File: units})
+		if err != nil {
+			t.Fatalf("%s: JoinCSV returned an error: %v", joinType, err)
+		}
+
+		// The units are streamed, so the rows follow their order
+		rows := readJoinedCSV(t, joined)
+		joined.Close()
+		expectedRows := [][]string{
+			{"Incident Number", "Incident Type", "Incident.Station", "Unit", "Unit.Station", "Travel Time"},
+			{"1001", "Fire", "1", "E1", "1", "240"},
+			{"1001", "Fire", "1", "L1", "1", "300"},
+			{"1002", "Medical", "2", "E2", "2", "420"},
+		}
+		if !reflect.DeepEqual(rows, expectedRows) {
+			t.Errorf("%s join was not computed correctly. Got: \n %v \n, want: \n %v", joinType, rows, expectedRows)
+		}
+	}
+}
+
+func TestJoinCSVCanBeAnalysed(t *testing.T) {
+	units := writeMockCSV(t, mockUnitResponseRows())
+	incidents := writeMockCSV(t, mockJoinIncidentRows())
+
+	join := models.Join{
+		Name: "responses",
+		Type: models.InnerJoin,
+		Keys: []models.JoinKey{{LeftColumn: "Incident Number", RightColumn: "Incident Number"}},
+	}
+	joined, err := util.JoinCSV(join, util.JoinSide{File: units, Dataset: models.Dataset{Name: "units"}}, util.JoinSide{File: incidents})
+	if err != nil {
+		t.Fatalf("JoinCSV returned an error: %v", err)
+	}
+	defer joined.Close()
+
+	chart := models.ReportChartOutput{
+		IndependentColumn: "Incident Type",
+		DependentColumns: []models.ReportOneDimConfig{
+			{AggregateValueLabel: "Avg Travel", Column: "Travel Time", OperationType: models.Average},
+		},
+	}
+	if err := util.AnalyzeTwoDimensionalData(joined, &chart); err != nil {
+		t.Fatalf("AnalyzeTwoDimensionalData returned an error: %v", err)
+	}
+
+	expectedResults := []map[string]interface{}{
+		{"Incident Type": "Fire", "Avg Travel": 270.0},
+		{"Incident Type": "Medical", "Avg Travel": 420.0},
+	}
+	if !reflect.DeepEqual(chart.Results, expectedResults) {
+		t.Errorf("Joined chart was not computed correctly. Got: \n %v \n, want: \n %v", chart.Results, expectedResults)
+	}
+}
+
+func TestJoinCSVErrors(t *testing.T) {
+	units := writeMockCSV(t, mockUnitResponseRows())
+	incidents := writeMockCSV(t, mockJoinIncidentRows())
+
+	joins := []models.Join{
+		{Name: "no keys", Type: models.InnerJoin},
+		{Name: "missing key", Type: models.InnerJoin, Keys: []models.JoinKey{{LeftColumn: "Incident ID"}}},
+		{Name: "full join", Type: "Full", Keys: []models.JoinKey{{LeftColumn: "Incident Number"}}},
+	}
+	for _, join := range joins {
+		if _, err := util.JoinCSV(join, util.JoinSide{File: units}, util.JoinSide{File: incidents}); err == nil {
+			t.Errorf("Expected an error for join %s", join.Name)
+		}
+	}
+}
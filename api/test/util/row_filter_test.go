@@ -0,0 +1,188 @@
+package util_test
+
+import (
+	"api/shared/models"
+	"api/shared/util"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+func mockFilterRows() [][]string {
+	return [][]string{
+		{"Priority", "Dispatched", "Unit", "Notes", "Travel Time"},
+		{"1", "2023-01-15", "E1", "Structure fire", "100"},
+		{"2", "2023-06-30", "e2", "", "200"},
+		{"3", "2023-07-01", "E3", "Alarm", "300"},
+		{"1", "2022-12-31", "E4", "Smoke", "400"},
+		{"2", "2024-01-01", "L1", "", "500"},
+		{"1", "2023-12-31", "R1", "Medical", "600"},
+	}
+}
+
+func floatPointer(value float64) *float64 {
+	return &value
+}
+
+func TestFilterOperators(t *testing.T) {
+	csvFile := writeMockCSV(t, mockFilterRows())
+
+	tests := []struct {
+		name     string
+		filter   models.Filter
+		expected string
+	}{
+		{"priority 1 or 2 in 2023 with an E unit", models.Filter{
+			{Conditions: []models.FilterCondition{{Column: "Priority", Values: []string{"1", "2"}}}},
+			{Conditions: []models.FilterCondition{{Column: "Dispatched", Operator: models.DateRangeFilter, From: "2023-01-01", To: "2023-12-31"}}},
+			{Conditions: []models.FilterCondition{{Column: "Unit", Operator: models.PrefixFilter, Values: []string{"E"}}}},
+		}, "2"},
+		{"not in", models.Filter{
+			{Conditions: []models.FilterCondition{{Column: "Priority", Operator: models.NotInFilter, Values: []string{"1"}}}},
+		}, "3"},
+		{"range", models.Filter{
+			{Conditions: []models.FilterCondition{{Column: "Travel Time", Operator: models.RangeFilter, Min: floatPointer(200), Max: floatPointer(400)}}},
+		}, "3"},
+		{"open range", models.Filter{
+			{Conditions: []models.FilterCondition{{Column: "Travel Time", Operator: models.RangeFilter, Min: floatPointer(450)}}},
+		}, "2"},
+		{"date range with a time", models.Filter{
+			{Conditions: []models.FilterCondition{{Column: "Dispatched", Operator: models.DateRangeFilter, From: "06/30/2023 00:00", To: "07/01/2023 00:00", DateFormat: "YYYY-MM-DD"}}},
+		}, "2"},
+		{"contains ignores case", models.Filter{
+			{Conditions: []models.FilterCondition{{Column: "Notes", Operator: models.ContainsFilter, Values: []string{"FIRE", "smoke"}}}},
+		}, "2"},
+		{"regex", models.Filter{
+			{Conditions: []models.FilterCondition{{Column: "Unit", Operator: models.RegexFilter, Values: []string{"^[EL][0-9]$"}}}},
+		}, "4"},
+		{"empty", models.Filter{
+			{Conditions: []models.FilterCondition{{Column: "Notes", Operator: models.EmptyFilter}}},
+		}, "2"},
+		{"not empty", models.Filter{
+			{Conditions: []models.FilterCondition{{Column: "Notes", Operator: models.NotEmptyFilter}}},
+		}, "4"},
+		{"or group", models.Filter{
+			{Conditions: []models.FilterCondition{
+				{Column: "Unit", Values: []string{"L1"}},
+				{Column: "Notes", Operator: models.ContainsFilter, Values: []string{"medical"}},
+			}},
+		}, "2"},
+		// An In condition without values matches no row rather than leaving its group out
+		{"empty in", models.Filter{
+			{Conditions: []models.FilterCondition{{Column: "Priority", Operator: models.InFilter}}},
+		}, "0"},
+		{"or group with an empty in", models.Filter{
+			{Conditions: []models.FilterCondition{
+				{Column: "Unit", Values: []string{"L1"}},
+				{Column: "Priority", Operator: models.InFilter},
+			}},
+		}, "1"},
+		{"missing column", models.Filter{
+			{Conditions: []models.FilterCondition{{Column: "Station", Operator: models.NotEmptyFilter}}},
+		}, "0"},
+	}
+
+	for _, test := range tests {
+		csvData := models.ReportCSVData{
+			Label:           "calls",
+			OperationType:   models.SetElementOccurrences,
+			OperationColumn: "Priority",
+			FilterColumns:   test.filter,
+		}
+		if err := util.AnalyzeOneDimensionalData(csvFile, &csvData); err != nil {
+			t.Fatalf("%s: AnalyzeOneDimensionalData returned an error: %v", test.name, err)
+		}
+		if csvData.Result != test.expected {
+			t.Errorf("%s: expected %s rows, got %s", test.name, test.expected, csvData.Result)
+		}
+	}
+}
+
+func TestFilterInvalidConditions(t *testing.T) {
+	csvFile := writeMockCSV(t, mockFilterRows())
+
+	conditions := []models.FilterCondition{
+		{Column: "Unit", Operator: models.RegexFilter, Values: []string{"("}},
+		{Column: "Unit", Operator: models.RegexFilter},
+		{Column: "Travel Time", Operator: models.RangeFilter},
+		{Column: "Dispatched", Operator: models.DateRangeFilter, From: "someday"},
+		{Column: "Unit", Operator: models.PrefixFilter},
+		{Column: "Unit", Operator: "Between"},
+	}
+
+	for _, condition := range conditions {
+		chart := models.ReportChartOutput{
+			IndependentColumn: "Unit",
+			FilterColumns:     models.Filter{{Conditions: []models.FilterCondition{condition}}},
+			DependentColumns: []models.ReportOneDimConfig{
+				{AggregateValueLabel: "Calls", Column: "Priority", OperationType: models.SetElementOccurrences},
+			},
+		}
+		if err := util.AnalyzeTwoDimensionalData(csvFile, &chart); err == nil {
+			t.Errorf("Expected an error for the %s filter %v", condition.Operator, condition)
+		}
+	}
+}
+
+func TestFilterLegacyJSONDecoding(t *testing.T) {
+	var csvData models.ReportCSVData
+	err := json.Unmarshal([]byte(`{"FilterColumns": {"Year": ["2023"], "Station": ["1", "2"], "Unit": []}}`), &csvData)
+	if err != nil {
+		t.Fatalf("Unmarshal returned an error: %v", err)
+	}
+
+	expected := models.Filter{
+		{Conditions: []models.FilterCondition{{Column: "Station", Operator: models.InFilter, Values: []string{"1", "2"}}}},
+		{Conditions: []models.FilterCondition{{Column: "Year", Operator: models.InFilter, Values: []string{"2023"}}}},
+	}
+	if !reflect.DeepEqual(csvData.FilterColumns, expected) {
+		t.Errorf("Legacy filter was not decoded correctly. Got: \n %+v \n, want: \n %+v", csvData.FilterColumns, expected)
+	}
+
+	// The new format round trips
+	encoded, err := json.Marshal(csvData)
+	if err != nil {
+		t.Fatalf("Marshal returned an error: %v", err)
+	}
+	var decoded models.ReportCSVData
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("Unmarshal returned an error: %v", err)
+	}
+	if !reflect.DeepEqual(decoded.FilterColumns, expected) {
+		t.Errorf("Filter did not round trip through JSON. Got: \n %+v \n, want: \n %+v", decoded.FilterColumns, expected)
+	}
+}
+
+func TestFilterLegacyDynamoDBDecoding(t *testing.T) {
+	item := map[string]*dynamodb.AttributeValue{
+		"FilterColumns": {M: map[string]*dynamodb.AttributeValue{
+			"Station": {L: []*dynamodb.AttributeValue{{S: aws.String("1")}}},
+		}},
+	}
+
+	var chart models.ReportChartOutput
+	if err := dynamodbattribute.UnmarshalMap(item, &chart); err != nil {
+		t.Fatalf("UnmarshalMap returned an error: %v", err)
+	}
+	expected := models.AcceptedValuesFilter("Station", []string{"1"})
+	if !reflect.DeepEqual(chart.FilterColumns, expected) {
+		t.Errorf("Legacy filter was not decoded correctly. Got: \n %+v \n, want: \n %+v", chart.FilterColumns, expected)
+	}
+
+	// The new format round trips
+	marshalled, err := dynamodbattribute.MarshalMap(chart)
+	if err != nil {
+		t.Fatalf("MarshalMap returned an error: %v", err)
+	}
+	var decoded models.ReportChartOutput
+	if err := dynamodbattribute.UnmarshalMap(marshalled, &decoded); err != nil {
+		t.Fatalf("UnmarshalMap returned an error: %v", err)
+	}
+	if !reflect.DeepEqual(decoded.FilterColumns, expected) {
+		t.Errorf("Filter did not round trip through DynamoDB. Got: \n %+v \n, want: \n %+v", decoded.FilterColumns, expected)
+	}
+}
@@ -0,0 +1,94 @@
+package util_test
+
+import (
+	"api/shared/models"
+	"api/shared/util"
+	"reflect"
+	"testing"
+)
+
+func mockProfileRows() [][]string {
+	return [][]string{
+		{"Incident", "Cost", "Dispatched", "Transported", "Station", "Notes", "Hydrant"},
+		{"1001", "$1,200.50", "2023-01-15 08:10:00", "Yes", "North", "Kitchen fire", ""},
+		{"1002", "300", "2023-03-02 14:00:00", "no", "South", "Smoke alarm", ""},
+		{"1003", "", "2022-12-31 23:59:00", "YES", "North", "", ""},
+		{"1004", "99.5", "2023-02-10 06:30:00", "", "South", "Lift assist", ""},
+		{"1005", "(50)", "", "No", "North", "Odor of gas", ""},
+		{"1006", "0", "2023-01-01 00:00:00", "yes", "South", "Fall", ""},
+	}
+}
+
+func TestProfileCSVColumnTypes(t *testing.T) {
+	csvFile := writeMockCSV(t, mockProfileRows())
+
+	derivedColumns := []models.DerivedColumn{{Name: "Large", Expression: "number([Cost]) > 200"}}
+	columnData, err := util.ProfileCSVColumns(csvFile, derivedColumns)
+	if err != nil {
+		t.Fatalf("ProfileCSVColumns returned an error: %v", err)
+	}
+	if columnData.ColumnsMap["Station"].DistinctCount != 2 {
+		t.Errorf("Expected 2 unique stations, got %v", columnData.ColumnsMap["Station"])
+	}
+	schema := columnData.Schema
+	if schema.RowCount != 6 {
+		t.Errorf("Expected 6 rows, got %d", schema.RowCount)
+	}
+
+	expectedTypes := []models.ColumnType{
+		models.IntegerColumn,
+		models.DecimalColumn,
+		models.DateTimeColumn,
+		models.BooleanColumn,
+		models.CategoricalColumn,
+		models.TextColumn,
+		models.TextColumn,
+		models.BooleanColumn,
+	}
+	for i, column := range schema.Columns {
+		if column.Type != expectedTypes[i] {
+			t.Errorf("Expected column %s to be %s, got %s", column.Name, expectedTypes[i], column.Type)
+		}
+	}
+}
+
+func TestProfileCSVColumnStatistics(t *testing.T) {
+	csvFile := writeMockCSV(t, mockProfileRows())
+
+	columnData, err := util.ProfileCSVColumns(csvFile, nil)
+	if err != nil {
+		t.Fatalf("ProfileCSVColumns returned an error: %v", err)
+	}
+	schema := columnData.Schema
+
+	minCost, maxCost, meanCost := -50.0, 1200.5, 310.0
+	expectedCost := models.ColumnProfile{
+		Name:        "Cost",
+		Type:        models.DecimalColumn,
+		BlankCount:  1,
+		Cardinality: 5,
+		Min:         &minCost,
+		Max:         &maxCost,
+		Mean:        &meanCost,
+	}
+	if !reflect.DeepEqual(schema.Columns[1], expectedCost) {
+		t.Errorf("Cost was not profiled correctly. Got: \n %+v \n, want: \n %+v", schema.Columns[1], expectedCost)
+	}
+
+	expectedDispatched := models.ColumnProfile{
+		Name:         "Dispatched",
+		Type:         models.DateTimeColumn,
+		BlankCount:   1,
+		Cardinality:  5,
+		EarliestDate: "2022-12-31T23:59:00Z",
+		LatestDate:   "2023-03-02T14:00:00Z",
+	}
+	if !reflect.DeepEqual(schema.Columns[2], expectedDispatched) {
+		t.Errorf("Dispatched was not profiled correctly. Got: \n %+v \n, want: \n %+v", schema.Columns[2], expectedDispatched)
+	}
+
+	hydrant := schema.Columns[6]
+	if hydrant.BlankCount != 6 || hydrant.Cardinality != 0 {
+		t.Errorf("Expected an empty Hydrant column, got %+v", hydrant)
+	}
+}
@@ -0,0 +1,111 @@
+package util_test
+
+import (
+	"api/shared/models"
+	"api/shared/util"
+	"reflect"
+	"testing"
+)
+
+func TestAnalyzeSectionData(t *testing.T) {
+	section := mockAnalysisData()
+	csvFile := writeMockCSV(t, mockIncidentRows())
+
+	err := util.AnalyzeSectionData(csvFile, models.Dataset{}, section)
+	if err != nil {
+		t.Fatalf("AnalyzeSectionData returned an error: %v", err)
+	}
+
+	expectedCSVResults := []string{"300", "320", "3", "0"}
+	for i, csvData := range section.CSVData {
+		if csvData.Result != expectedCSVResults[i] {
+			t.Errorf("CSVData %s: expected result %q, got %q", csvData.Label, expectedCSVResults[i], csvData.Result)
+		}
+	}
+
+	expectedStationResults := []map[string]interface{}{
+		{"Station": "1", "Travel Time": 233.333, "Fires": 2},
+		{"Station": "2", "Travel Time": 400.0, "Fires": 1},
+		{"Station": "3", "Travel Time": 600.0},
+	}
+	if !reflect.DeepEqual(section.ChartOutputs[0].Results, expectedStationResults) {
+		t.Errorf("Station chart results were not computed correctly. Got: \n %v \n, want: \n %v", section.ChartOutputs[0].Results, expectedStationResults)
+	}
+
+	expectedYearResults := []map[string]interface{}{
+		{"Year": "2016", "Fire": 2, "Medical": 1},
+		{"Year": "2017", "Fire": 1, "Medical": 2},
+	}
+	if !reflect.DeepEqual(section.ChartOutputs[1].Results, expectedYearResults) {
+		t.Errorf("Year chart results were not computed correctly. Got: \n %v \n, want: \n %v", section.ChartOutputs[1].Results, expectedYearResults)
+	}
+}
+
+func TestAnalyzeSectionDataMatchesPerOutputAnalysis(t *testing.T) {
+	csvFile := writeMockCSV(t, mockLargeIncidentRows(2000))
+
+	singlePass := mockAnalysisData()
+	if err := util.AnalyzeSectionData(csvFile, models.Dataset{}, singlePass); err != nil {
+		t.Fatalf("AnalyzeSectionData returned an error: %v", err)
+	}
+
+	perOutput := mockAnalysisData()
+	for i := range perOutput.CSVData {
+		if err := util.AnalyzeOneDimensionalData(csvFile, &perOutput.CSVData[i]); err != nil {
+			t.Fatalf("AnalyzeOneDimensionalData returned an error: %v", err)
+		}
+	}
+	for i := range perOutput.ChartOutputs {
+		if err := util.AnalyzeTwoDimensionalData(csvFile, &perOutput.ChartOutputs[i]); err != nil {
+			t.Fatalf("AnalyzeTwoDimensionalData returned an error: %v", err)
+		}
+	}
+
+	if !reflect.DeepEqual(singlePass, perOutput) {
+		t.Errorf("Single pass results differ from per output results. Got: \n %v \n, want: \n %v", singlePass, perOutput)
+	}
+}
+
+func TestAnalyzeSectionDataMissingColumn(t *testing.T) {
+	section := &models.ReportSection{
+		CSVData: []models.ReportCSVData{
+			{Label: "missing", OperationType: models.NumericalSum, OperationColumn: "Not A Column"},
+		},
+	}
+	csvFile := writeMockCSV(t, mockIncidentRows())
+
+	if err := util.AnalyzeSectionData(csvFile, models.Dataset{}, section); err == nil {
+		t.Errorf("Expected an error for a column missing from the csv")
+	}
+}
+
+func BenchmarkAnalyzeSectionData(b *testing.B) {
+	csvFile := writeMockCSV(b, mockLargeIncidentRows(100000))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		section := mockAnalysisData()
+		if err := util.AnalyzeSectionData(csvFile, models.Dataset{}, section); err != nil {
+			b.Fatalf("AnalyzeSectionData returned an error: %v", err)
+		}
+	}
+}
+
+func BenchmarkAnalyzePerOutput(b *testing.B) {
+	csvFile := writeMockCSV(b, mockLargeIncidentRows(100000))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		section := mockAnalysisData()
+		for j := range section.CSVData {
+			if err := util.AnalyzeOneDimensionalData(csvFile, &section.CSVData[j]); err != nil {
+				b.Fatalf("AnalyzeOneDimensionalData returned an error: %v", err)
+			}
+		}
+		for j := range section.ChartOutputs {
+			if err := util.AnalyzeTwoDimensionalData(csvFile, &section.ChartOutputs[j]); err != nil {
+				b.Fatalf("AnalyzeTwoDimensionalData returned an error: %v", err)
+			}
+		}
+	}
+}
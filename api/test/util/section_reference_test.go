@@ -0,0 +1,157 @@
+package util_test
+
+import (
+	"api/shared/models"
+	"api/shared/util"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func referenceReport() *models.Report {
+	return &models.Report{
+		Parts: []models.ReportPart{
+			{Sections: []models.ReportSection{{
+				Title: "Summary",
+				Label: "summary",
+				TextOutputs: []models.ReportTextOutput{
+					{Title: "Overview", Input: "{{sections.stations.calls | thousands}} calls, busiest {{sections.stations.busiest.max.label}}"},
+				},
+			}}},
+			{Sections: []models.ReportSection{
+				{
+					Title:     "Calls by station",
+					Label:     "stations",
+					Questions: []models.ReportQuestion{{Label: "period", Answer: "2023"}},
+					CSVData:   []models.ReportCSVData{{Label: "calls", Result: "1234"}},
+					ChartOutputs: []models.ReportChartOutput{{
+						Label:             "busiest",
+						IndependentColumn: "Station",
+						DependentColumns:  []models.ReportOneDimConfig{{AggregateValueLabel: "Calls"}},
+						Results:           []map[string]interface{}{{"Station": "1", "Calls": 400}, {"Station": "2", "Calls": 834}},
+					}},
+					TextOutputs: []models.ReportTextOutput{
+						{Title: "Narrative", Input: "{{period}}", Result: "Station 2 was the busiest"},
+					},
+				},
+				{Title: "Unlabelled"},
+			}},
+		},
+	}
+}
+
+func TestRenderSectionPlaceholders(t *testing.T) {
+	report := referenceReport()
+	sections := util.ReportSectionsByLabel(report)
+	section := &report.Parts[0].Sections[0]
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{section.TextOutputs[0].Input, "1,234 calls, busiest 2"},
+		{"{{sections.stations.title}}: {{sections.stations.textOutputs.0.result}}", "Calls by station: Station 2 was the busiest"},
+		{"{{#each sections.stations.questions}}{{label}}={{answer}}{{/each}}", "period=2023"},
+		{"{{#if sections.stations.calls > 1000}}busy{{/if}}", "busy"},
+	}
+
+	for _, test := range tests {
+		result, err := util.RenderPlaceholders(test.input, section, nil, sections)
+		if err != nil || result != test.expected {
+			t.Errorf("%q: expected %q, got %q (%v)", test.input, test.expected, result, err)
+		}
+	}
+
+	if len(sections) != 2 {
+		t.Errorf("Expected only the sections with a label, got %v", sections)
+	}
+
+	for _, input := range []string{"{{sections.unknown.calls}}", "{{sections.stations.missing}}", "{{sections}}"} {
+		if _, err := util.RenderPlaceholders(input, section, nil, sections); err == nil {
+			t.Errorf("%q: expected an error", input)
+		}
+	}
+}
+
+func TestLintSectionReferences(t *testing.T) {
+	report := referenceReport()
+	if lint := util.LintReportPlaceholders(report); !lint.Valid || len(lint.Issues) != 0 {
+		t.Errorf("Expected no issues, got %+v", lint.Issues)
+	}
+
+	// The stations section refers back to the summary
+	stations := &report.Parts[1].Sections[0]
+	stations.TextOutputs = append(stations.TextOutputs, models.ReportTextOutput{Title: "Back", Input: "{{sections.summary.title}}"})
+	report.Parts[1].Sections[1] = models.ReportSection{
+		Title: "Other",
+		Label: "stations",
+		TextOutputs: []models.ReportTextOutput{
+			{Title: "Unknown", Input: "{{sections.missing.calls}} {{sections.summary.calls}} {{sections}}"},
+		},
+	}
+
+	lint := util.LintReportPlaceholders(report)
+
+	expected := []struct {
+		kind         models.PlaceholderIssueKind
+		partIndex    int
+		sectionIndex int
+		message      string
+	}{
+		{models.DuplicateLabelIssue, 1, 1, "section label 'stations' is used by another section"},
+		{models.ReferenceCycleIssue, 0, 0, "sections refer to each other in a cycle: 'summary' -> 'stations' -> 'summary'"},
+		{models.ReferenceCycleIssue, 1, 0, "sections refer to each other in a cycle: 'stations' -> 'summary' -> 'stations'"},
+		{models.UndefinedPlaceholderIssue, 1, 1, "no section has the label 'missing'"},
+		{models.UndefinedPlaceholderIssue, 1, 1, "section 'summary' has no question, csv data or chart with the label 'calls'"},
+		{models.InvalidPlaceholderIssue, 1, 1, "needs the label of a section"},
+	}
+
+	if lint.Valid || len(lint.Issues) != len(expected) {
+		t.Fatalf("Expected %d issues, got %+v", len(expected), lint.Issues)
+	}
+	for i, issue := range lint.Issues {
+		e := expected[i]
+		if issue.Kind != e.kind || issue.Severity != models.ErrorSeverity || issue.PartIndex != e.partIndex || issue.SectionIndex != e.sectionIndex || !strings.Contains(issue.Message, e.message) {
+			t.Errorf("Issue %d: expected %+v, got %+v", i, e, issue)
+		}
+	}
+}
+
+func TestLintSectionSelfReference(t *testing.T) {
+	template := &models.Template{Parts: []models.TemplatePart{{Sections: []models.TemplateSection{{
+		Label:       "self",
+		TextOutputs: []models.TemplateTextOutput{{Title: "Loop", Input: "{{sections.self.textOutputs.0.result}}"}},
+	}}}}}
+
+	lint := util.LintTemplatePlaceholders(template)
+	if len(lint.Issues) != 1 || lint.Issues[0].Kind != models.ReferenceCycleIssue || lint.Issues[0].Message != "section 'self' refers to itself" {
+		t.Errorf("Expected the section to refer to itself, got %+v", lint.Issues)
+	}
+}
+
+func TestReportSectionGenerationOrder(t *testing.T) {
+	report := referenceReport()
+	// Stations refers to the unlabelled section, which now has a label
+	report.Parts[1].Sections[1].Label = "details"
+	stations := &report.Parts[1].Sections[0]
+	stations.TextOutputs = append(stations.TextOutputs, models.ReportTextOutput{Title: "Details", Input: "{{sections.details.title}}"})
+
+	order, err := util.ReportSectionGenerationOrder(report, models.SectionRef{PartIndex: 0, SectionIndex: 0})
+	if err != nil {
+		t.Fatalf("ReportSectionGenerationOrder returned an error: %v", err)
+	}
+	expected := []models.SectionRef{{PartIndex: 1, SectionIndex: 1}, {PartIndex: 1, SectionIndex: 0}, {PartIndex: 0, SectionIndex: 0}}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("Expected the referenced sections first, got %+v", order)
+	}
+
+	order, err = util.ReportSectionGenerationOrder(report, models.SectionRef{PartIndex: 1, SectionIndex: 1})
+	if err != nil || !reflect.DeepEqual(order, []models.SectionRef{{PartIndex: 1, SectionIndex: 1}}) {
+		t.Errorf("Expected only the section, got %+v (%v)", order, err)
+	}
+
+	stations.TextOutputs = append(stations.TextOutputs, models.ReportTextOutput{Title: "Back", Input: "{{sections.summary.title}}"})
+	if _, err := util.ReportSectionGenerationOrder(report, models.SectionRef{PartIndex: 0, SectionIndex: 0}); err == nil {
+		t.Errorf("Expected an error for a cycle of references")
+	}
+}
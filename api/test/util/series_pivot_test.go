@@ -0,0 +1,91 @@
+package util_test
+
+import (
+	"api/shared/models"
+	"api/shared/util"
+	"reflect"
+	"testing"
+)
+
+func TestSeriesPivotZeroFillsMissingCells(t *testing.T) {
+	csvFile := writeMockCSV(t, mockIncidentRows())
+
+	chart := models.ReportChartOutput{
+		IndependentColumn: "Station",
+		SeriesColumn:      "Incident Type",
+		DependentColumns: []models.ReportOneDimConfig{
+			{AggregateValueLabel: "Calls", Column: "Incident Type", OperationType: models.SetElementOccurrences},
+		},
+	}
+	if err := util.AnalyzeTwoDimensionalData(csvFile, &chart); err != nil {
+		t.Fatalf("AnalyzeTwoDimensionalData returned an error: %v", err)
+	}
+
+	expectedResults := []map[string]interface{}{
+		{"Station": "1", "Fire": 2, "Medical": 1},
+		{"Station": "2", "Fire": 1, "Medical": 1},
+		{"Station": "3", "Fire": 0, "Medical": 1},
+	}
+	if !reflect.DeepEqual(chart.Results, expectedResults) {
+		t.Errorf("Series were not pivoted correctly. Got: \n %v \n, want: \n %v", chart.Results, expectedResults)
+	}
+	if !reflect.DeepEqual(chart.Series, []string{"Fire", "Medical"}) {
+		t.Errorf("Expected series [Fire Medical], got %v", chart.Series)
+	}
+}
+
+func TestSeriesPivotMergesOtherSeries(t *testing.T) {
+	csvFile := writeMockCSV(t, mockIncidentRows())
+
+	chart := models.ReportChartOutput{
+		IndependentColumn: "Incident Type",
+		SeriesColumn:      "Station",
+		SeriesLimit:       1,
+		DependentColumns: []models.ReportOneDimConfig{
+			{AggregateValueLabel: "Avg", Column: "Travel Time", OperationType: models.Average},
+			{AggregateValueLabel: "StdDev", Column: "Travel Time", OperationType: models.StdDev},
+		},
+	}
+	if err := util.AnalyzeTwoDimensionalData(csvFile, &chart); err != nil {
+		t.Fatalf("AnalyzeTwoDimensionalData returned an error: %v", err)
+	}
+
+	// Station 1 has the most rows, stations 2 and 3 are merged before their values are finalized
+	expectedResults := []map[string]interface{}{
+		{"Incident Type": "Fire", "1 - Avg": 250.0, "1 - StdDev": 212.132, "Other - Avg": 300.0, "Other - StdDev": 0.0},
+		{"Incident Type": "Medical", "1 - Avg": 200.0, "1 - StdDev": 0.0, "Other - Avg": 550.0, "Other - StdDev": 70.711},
+	}
+	if !reflect.DeepEqual(chart.Results, expectedResults) {
+		t.Errorf("Other series was not merged correctly. Got: \n %v \n, want: \n %v", chart.Results, expectedResults)
+	}
+	expectedSeries := []string{"1 - Avg", "1 - StdDev", "Other - Avg", "Other - StdDev"}
+	if !reflect.DeepEqual(chart.Series, expectedSeries) {
+		t.Errorf("Expected series %v, got %v", expectedSeries, chart.Series)
+	}
+}
+
+func TestSeriesPivotErrors(t *testing.T) {
+	csvFile := writeMockCSV(t, mockIncidentRows())
+
+	charts := []models.ReportChartOutput{
+		{
+			IndependentColumn: "Station",
+			SeriesColumn:      "District",
+			DependentColumns: []models.ReportOneDimConfig{
+				{AggregateValueLabel: "Calls", Column: "Incident Type", OperationType: models.SetElementOccurrences},
+			},
+		},
+		{
+			IndependentColumn: "Station",
+			SeriesColumn:      "Year",
+			DependentColumns: []models.ReportOneDimConfig{
+				{Column: "Incident Type", OperationType: models.UniqueOccurrences},
+			},
+		},
+	}
+	for _, chart := range charts {
+		if err := util.AnalyzeTwoDimensionalData(csvFile, &chart); err == nil {
+			t.Errorf("Expected an error for series column %s with %s", chart.SeriesColumn, chart.DependentColumns[0].OperationType)
+		}
+	}
+}
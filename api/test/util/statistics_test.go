@@ -0,0 +1,77 @@
+package util_test
+
+import (
+	"api/shared/models"
+	"api/shared/util"
+	"reflect"
+	"testing"
+)
+
+func TestStatisticOperationsOneDimensional(t *testing.T) {
+	csvFile := writeMockCSV(t, mockIncidentRows())
+
+	tests := []struct {
+		operation  models.ChartOperation
+		percentile float64
+		expected   string
+	}{
+		{models.Median, 0, "350"},
+		{models.Min, 0, "100"},
+		{models.Max, 0, "600"},
+		{models.Percentile, 90, "550"},
+		{models.Percentile, 100, "600"},
+		{models.StdDev, 0, "187.083"},
+	}
+
+	for _, test := range tests {
+		csvData := models.ReportCSVData{
+			Label:           "travel",
+			OperationType:   test.operation,
+			Percentile:      test.percentile,
+			OperationColumn: "Travel Time",
+		}
+		if err := util.AnalyzeOneDimensionalData(csvFile, &csvData); err != nil {
+			t.Fatalf("%s: AnalyzeOneDimensionalData returned an error: %v", test.operation, err)
+		}
+		if csvData.Result != test.expected {
+			t.Errorf("%s: expected result %q, got %q", test.operation, test.expected, csvData.Result)
+		}
+	}
+}
+
+func TestStatisticOperationsPerGroup(t *testing.T) {
+	csvFile := writeMockCSV(t, mockIncidentRows())
+
+	chart := models.ReportChartOutput{
+		IndependentColumn: "Station",
+		DependentColumns: []models.ReportOneDimConfig{
+			{AggregateValueLabel: "Median Travel", Column: "Travel Time", OperationType: models.Median},
+			{AggregateValueLabel: "Max Travel", Column: "Travel Time", OperationType: models.Max},
+		},
+	}
+	if err := util.AnalyzeTwoDimensionalData(csvFile, &chart); err != nil {
+		t.Fatalf("AnalyzeTwoDimensionalData returned an error: %v", err)
+	}
+
+	expectedResults := []map[string]interface{}{
+		{"Station": "1", "Median Travel": 200.0, "Max Travel": 400.0},
+		{"Station": "2", "Median Travel": 400.0, "Max Travel": 500.0},
+		{"Station": "3", "Median Travel": 600.0, "Max Travel": 600.0},
+	}
+	if !reflect.DeepEqual(chart.Results, expectedResults) {
+		t.Errorf("Chart results were not computed correctly. Got: \n %v \n, want: \n %v", chart.Results, expectedResults)
+	}
+}
+
+func TestPercentileRequiresValidPercentile(t *testing.T) {
+	csvFile := writeMockCSV(t, mockIncidentRows())
+
+	csvData := models.ReportCSVData{
+		Label:           "travel",
+		OperationType:   models.Percentile,
+		OperationColumn: "Travel Time",
+	}
+	if err := util.AnalyzeOneDimensionalData(csvFile, &csvData); err == nil {
+		t.Errorf("Expected an error for a percentile operation without a percentile")
+	}
+}
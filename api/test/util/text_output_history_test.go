@@ -0,0 +1,136 @@
+package util_test
+
+import (
+	"api/shared/models"
+	"api/shared/util"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestTextOutputHistory(t *testing.T) {
+	section := &models.ReportSection{
+		Questions: []models.ReportQuestion{{Label: "city", Answer: "Guelph"}},
+		TextOutputs: []models.ReportTextOutput{
+			{Title: "Static", Type: models.Static, Input: "{{city}}"},
+			{Title: "Generator", Type: models.Generator, Input: "Summarize {{city}}"},
+		},
+	}
+
+	var ids []string
+	for i := 0; i < 12; i++ {
+		section.Questions[0].Answer = fmt.Sprintf("City %d", i)
+		util.ResetTextOutputResults(section, true)
+		util.GenerateSectionStaticText(section, &[]models.ReportQuestion{}, nil)
+		err := util.GenerateSectionGeneratorText(context.Background(), echoGenerator{}, section, &[]models.ReportQuestion{}, nil, &models.GenerationOptions{})
+		if err != nil {
+			t.Fatalf("GenerateSectionGeneratorText returned an error: %v", err)
+		}
+
+		if i == 0 {
+			for _, textOutput := range section.TextOutputs {
+				ids = append(ids, textOutput.ID)
+			}
+		}
+	}
+
+	for i, textOutput := range section.TextOutputs {
+		if textOutput.ID == "" || textOutput.ID != ids[i] {
+			t.Errorf("%s: expected the id %q to be kept, got %q", textOutput.Title, ids[i], textOutput.ID)
+		}
+	}
+	if ids[0] == ids[1] {
+		t.Errorf("Expected the outputs to have different ids, got %q", ids[0])
+	}
+
+	if len(section.TextOutputs[0].History) != 0 {
+		t.Errorf("Expected static outputs to have no history, got %+v", section.TextOutputs[0].History)
+	}
+
+	generator := &section.TextOutputs[1]
+	if len(generator.History) != 10 {
+		t.Fatalf("Expected the history to keep 10 results, got %d", len(generator.History))
+	}
+	if generator.Result != "Prompt: Summarize City 11" || generator.Generation.Prompt != "Summarize City 11" {
+		t.Errorf("Expected the last result, got %q from %q", generator.Result, generator.Generation.Prompt)
+	}
+	newest := generator.History[0]
+	if newest.Result != "Prompt: Summarize City 10" || newest.Generation.Prompt != "Summarize City 10" {
+		t.Errorf("Expected the previous result first, got %+v", newest)
+	}
+	if oldest := generator.History[9]; oldest.Result != "Prompt: Summarize City 1" {
+		t.Errorf("Expected the oldest results to be dropped, got %q", oldest.Result)
+	}
+
+	// Restoring a version puts the current result at the front of the history
+	err := util.RestoreTextOutputResult(generator, 2)
+	if err != nil {
+		t.Fatalf("RestoreTextOutputResult returned an error: %v", err)
+	}
+	if generator.Result != "Prompt: Summarize City 8" || generator.Generation.Prompt != "Summarize City 8" {
+		t.Errorf("Expected the restored result, got %q from %q", generator.Result, generator.Generation.Prompt)
+	}
+	if len(generator.History) != 10 || generator.History[0].Result != "Prompt: Summarize City 11" || generator.History[1].Result != "Prompt: Summarize City 10" || generator.History[3].Result != "Prompt: Summarize City 7" {
+		t.Errorf("Expected the history to swap the results, got %+v", generator.History)
+	}
+
+	if err := util.RestoreTextOutputResult(generator, 10); err != util.ErrTextOutputVersionNotFound {
+		t.Errorf("Expected the version not to be found, got %v", err)
+	}
+}
+
+func TestTextOutputHistorySize(t *testing.T) {
+	// Long results drop the oldest versions before the count limit is reached
+	section := &models.ReportSection{
+		Questions:   []models.ReportQuestion{{Label: "notes"}},
+		TextOutputs: []models.ReportTextOutput{{Title: "Generator", Type: models.Generator, Input: "{{notes}}"}},
+	}
+	generate := func(answer string) {
+		t.Helper()
+		section.Questions[0].Answer = answer
+		util.ResetTextOutputResults(section, true)
+		err := util.GenerateSectionGeneratorText(context.Background(), echoGenerator{}, section, &[]models.ReportQuestion{}, nil, &models.GenerationOptions{})
+		if err != nil {
+			t.Fatalf("GenerateSectionGeneratorText returned an error: %v", err)
+		}
+	}
+	for i := 0; i < 6; i++ {
+		generate(fmt.Sprintf("%d %s", i, strings.Repeat("x", 3000)))
+	}
+
+	// Each version holds its result and the first 2048 bytes of its prompt, about 5000 bytes
+	history := section.TextOutputs[0].History
+	if len(history) != 3 || !strings.HasPrefix(history[0].Generation.Prompt, "4 ") || !strings.HasPrefix(history[2].Generation.Prompt, "2 ") {
+		t.Fatalf("Expected the 3 newest versions to be kept, got %d", len(history))
+	}
+	if len(history[0].Generation.Prompt) > 2048+len("…") || section.TextOutputs[0].Generation.Prompt != section.Questions[0].Answer {
+		t.Errorf("Expected only the prompts of the history to be cut short, got %d bytes", len(history[0].Generation.Prompt))
+	}
+
+	// A version larger than the whole budget is still kept, on its own
+	generate(strings.Repeat("y", 20000))
+	generate("short")
+	history = section.TextOutputs[0].History
+	if len(history) != 1 || len(history[0].Result) != len("Prompt: ")+20000 {
+		t.Errorf("Expected the newest version to be kept, got %d versions", len(history))
+	}
+}
+
+func TestResetTextOutputHistory(t *testing.T) {
+	// Results of outputs that failed to generate are not kept
+	textOutput := models.ReportTextOutput{Type: models.Generator, Error: "timeout"}
+	section := &models.ReportSection{TextOutputs: []models.ReportTextOutput{textOutput}}
+	util.ResetTextOutputResults(section, true)
+	if len(section.TextOutputs[0].History) != 0 {
+		t.Errorf("Expected no history, got %+v", section.TextOutputs[0].History)
+	}
+
+	// Static generation leaves the results of generator outputs in place
+	section.TextOutputs[0].Result = "Generated"
+	section.TextOutputs[0].Generation = &models.GenerationRecord{Model: "gpt-4o"}
+	util.ResetTextOutputResults(section, false)
+	if section.TextOutputs[0].Result != "Generated" || len(section.TextOutputs[0].History) != 0 {
+		t.Errorf("Expected the result to be kept, got %+v", section.TextOutputs[0])
+	}
+}
@@ -0,0 +1,141 @@
+package util_test
+
+import (
+	"api/shared/models"
+	"api/shared/util"
+	"reflect"
+	"testing"
+)
+
+func mockTimestampRows() [][]string {
+	return [][]string{
+		{"Dispatched", "Incident Type"},
+		{"01/15/2023 08:10", "Fire"},
+		{"01/20/2023 09:30", "Medical"},
+		{"03/02/2023 08:45", "Fire"},
+		{"unknown", "Fire"},
+	}
+}
+
+func mockCallsChart(bucket models.TimeBucket) models.ReportChartOutput {
+	return models.ReportChartOutput{
+		IndependentColumn: "Dispatched",
+		TimeBucket:        bucket,
+		DateFormat:        "MM/DD/YYYY HH:mm",
+		DependentColumns: []models.ReportOneDimConfig{
+			{AggregateValueLabel: "Calls", Column: "Incident Type", OperationType: models.SetElementOccurrences},
+		},
+	}
+}
+
+func TestTimeBucketMonthFillsEmptyBuckets(t *testing.T) {
+	csvFile := writeMockCSV(t, mockTimestampRows())
+
+	chart := mockCallsChart(models.MonthBucket)
+	if err := util.AnalyzeTwoDimensionalData(csvFile, &chart); err != nil {
+		t.Fatalf("AnalyzeTwoDimensionalData returned an error: %v", err)
+	}
+
+	expectedResults := []map[string]interface{}{
+		{"Dispatched": "2023-01", "Calls": 2},
+		{"Dispatched": "2023-02", "Calls": 0},
+		{"Dispatched": "2023-03", "Calls": 1},
+	}
+	if !reflect.DeepEqual(chart.Results, expectedResults) {
+		t.Errorf("Month buckets were not computed correctly. Got: \n %v \n, want: \n %v", chart.Results, expectedResults)
+	}
+	if chart.SkippedRows != 1 {
+		t.Errorf("Expected 1 skipped row, got %d", chart.SkippedRows)
+	}
+}
+
+func TestTimeBucketRangeLimit(t *testing.T) {
+	rows := append(mockTimestampRows(), []string{"01/01/0001 00:00", "Fire"})
+	csvFile := writeMockCSV(t, rows)
+
+	for _, bucket := range []models.TimeBucket{models.MonthBucket, models.ISOWeekBucket} {
+		chart := mockCallsChart(bucket)
+		if err := util.AnalyzeTwoDimensionalData(csvFile, &chart); err == nil {
+			t.Errorf("%s: expected an error for a placeholder date, got %d results", bucket, len(chart.Results))
+		}
+	}
+
+	// Years fill in fine
+	chart := mockCallsChart(models.YearBucket)
+	rows[len(rows)-1][0] = "01/01/1990 00:00"
+	if err := util.AnalyzeTwoDimensionalData(writeMockCSV(t, rows), &chart); err != nil || len(chart.Results) != 34 {
+		t.Errorf("Expected 34 years, got %d (%v)", len(chart.Results), err)
+	}
+}
+
+func TestTimeBucketHourOfDayCoversWholeDay(t *testing.T) {
+	csvFile := writeMockCSV(t, mockTimestampRows())
+
+	chart := mockCallsChart(models.HourOfDayBucket)
+	if err := util.AnalyzeTwoDimensionalData(csvFile, &chart); err != nil {
+		t.Fatalf("AnalyzeTwoDimensionalData returned an error: %v", err)
+	}
+
+	if len(chart.Results) != 24 {
+		t.Fatalf("Expected 24 hour buckets, got %d", len(chart.Results))
+	}
+	if chart.Results[0]["Dispatched"] != "00:00" || chart.Results[23]["Dispatched"] != "23:00" {
+		t.Errorf("Hour buckets are not in order: first %v, last %v", chart.Results[0]["Dispatched"], chart.Results[23]["Dispatched"])
+	}
+	if chart.Results[8]["Calls"] != 2 || chart.Results[9]["Calls"] != 1 || chart.Results[10]["Calls"] != 0 {
+		t.Errorf("Hour buckets have wrong counts: %v", chart.Results[8:11])
+	}
+}
+
+func TestTimeBucketDayOfWeekAndQuarter(t *testing.T) {
+	csvFile := writeMockCSV(t, mockTimestampRows())
+
+	chart := mockCallsChart(models.DayOfWeekBucket)
+	if err := util.AnalyzeTwoDimensionalData(csvFile, &chart); err != nil {
+		t.Fatalf("AnalyzeTwoDimensionalData returned an error: %v", err)
+	}
+	// 2023-01-15 is a Sunday, 2023-01-20 a Friday and 2023-03-02 a Thursday
+	if chart.Results[0]["Dispatched"] != "Monday" || chart.Results[6]["Dispatched"] != "Sunday" {
+		t.Errorf("Day of week buckets do not start on Monday: %v", chart.Results)
+	}
+	if chart.Results[3]["Calls"] != 1 || chart.Results[4]["Calls"] != 1 || chart.Results[6]["Calls"] != 1 {
+		t.Errorf("Day of week buckets have wrong counts: %v", chart.Results)
+	}
+
+	chart = mockCallsChart(models.QuarterBucket)
+	if err := util.AnalyzeTwoDimensionalData(csvFile, &chart); err != nil {
+		t.Fatalf("AnalyzeTwoDimensionalData returned an error: %v", err)
+	}
+	expectedResults := []map[string]interface{}{
+		{"Dispatched": "2023 Q1", "Calls": 3},
+	}
+	if !reflect.DeepEqual(chart.Results, expectedResults) {
+		t.Errorf("Quarter buckets were not computed correctly. Got: \n %v \n, want: \n %v", chart.Results, expectedResults)
+	}
+}
+
+func TestTimeBucketTimeZoneAndISOWeek(t *testing.T) {
+	csvFile := writeMockCSV(t, [][]string{
+		{"Dispatched", "Incident Type"},
+		{"2023-01-01T02:00:00Z", "Fire"},
+		{"2023-01-16T12:00:00Z", "Fire"},
+	})
+
+	chart := mockCallsChart(models.ISOWeekBucket)
+	chart.DateFormat = ""
+	chart.TimeZone = "America/Toronto"
+	if err := util.AnalyzeTwoDimensionalData(csvFile, &chart); err != nil {
+		t.Fatalf("AnalyzeTwoDimensionalData returned an error: %v", err)
+	}
+
+	// The first call is on 2022-12-31 in Toronto, which is in week 52 of 2022
+	expectedResults := []map[string]interface{}{
+		{"Dispatched": "2022-W52", "Calls": 1},
+		{"Dispatched": "2023-W01", "Calls": 0},
+		{"Dispatched": "2023-W02", "Calls": 0},
+		{"Dispatched": "2023-W03", "Calls": 1},
+	}
+	if !reflect.DeepEqual(chart.Results, expectedResults) {
+		t.Errorf("ISO week buckets were not computed correctly. Got: \n %v \n, want: \n %v", chart.Results, expectedResults)
+	}
+}
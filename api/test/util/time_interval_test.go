@@ -0,0 +1,112 @@
+package util_test
+
+import (
+	"api/shared/models"
+	"api/shared/util"
+	"reflect"
+	"testing"
+)
+
+func mockIntervalRows() [][]string {
+	return [][]string{
+		{"Station", "Dispatched", "Arrived"},
+		{"A", "2023-01-15 08:10:00", "2023-01-15 08:15:30"},
+		{"A", "2023-01-15 09:00:00", "2023-01-15 09:02:30"},
+		{"B", "2023-01-15 23:58:00", "2023-01-16 00:08:00"},
+		{"B", "2023-01-15 10:00:00", "2023-01-15 09:59:00"},
+		{"B", "2023-01-15 11:00:00", ""},
+	}
+}
+
+func TestIntervalOneDimensionalUnits(t *testing.T) {
+	csvFile := writeMockCSV(t, mockIntervalRows())
+
+	tests := []struct {
+		unit             models.IntervalUnit
+		operation        models.ChartOperation
+		decimalSeparator string
+		expectedResult   string
+	}{
+		{models.SecondsInterval, models.Average, "", "360"},
+		{models.MinutesInterval, models.Max, "", "10"},
+		{models.HHMMSSInterval, models.NumericalSum, "", "00:18:00"},
+		{models.HHMMSSInterval, models.SetElementOccurrences, "", "3"},
+		// The separator of the column does not apply to the intervals computed from it
+		{models.MinutesInterval, models.NumericalSum, ",", "18"},
+	}
+
+	for _, test := range tests {
+		csvData := models.ReportCSVData{
+			Label:            "response",
+			OperationType:    test.operation,
+			StartColumn:      "Dispatched",
+			EndColumn:        "Arrived",
+			IntervalUnit:     test.unit,
+			DecimalSeparator: test.decimalSeparator,
+		}
+		if err := util.AnalyzeOneDimensionalData(csvFile, &csvData); err != nil {
+			t.Fatalf("%s %s: AnalyzeOneDimensionalData returned an error: %v", test.unit, test.operation, err)
+		}
+		if csvData.Result != test.expectedResult {
+			t.Errorf("%s %s: expected result %q, got %q", test.unit, test.operation, test.expectedResult, csvData.Result)
+		}
+		// The negative and the missing interval are dropped
+		if csvData.SkippedRows != 2 {
+			t.Errorf("%s %s: expected 2 skipped rows, got %d", test.unit, test.operation, csvData.SkippedRows)
+		}
+	}
+}
+
+func TestIntervalChartPerStation(t *testing.T) {
+	csvFile := writeMockCSV(t, mockIntervalRows())
+
+	chart := models.ReportChartOutput{
+		IndependentColumn: "Station",
+		DependentColumns: []models.ReportOneDimConfig{
+			{
+				AggregateValueLabel: "Median Response",
+				OperationType:       models.Median,
+				StartColumn:         "Dispatched",
+				EndColumn:           "Arrived",
+				IntervalUnit:        models.HHMMSSInterval,
+				DateFormat:          "YYYY-MM-DD HH:mm:ss",
+			},
+		},
+	}
+	if err := util.AnalyzeTwoDimensionalData(csvFile, &chart); err != nil {
+		t.Fatalf("AnalyzeTwoDimensionalData returned an error: %v", err)
+	}
+
+	// HH:MM:SS intervals are plotted in minutes
+	expectedResults := []map[string]interface{}{
+		{"Station": "A", "Median Response": 4.0},
+		{"Station": "B", "Median Response": 10.0},
+	}
+	if !reflect.DeepEqual(chart.Results, expectedResults) {
+		t.Errorf("Interval chart was not computed correctly. Got: \n %v \n, want: \n %v", chart.Results, expectedResults)
+	}
+	if chart.DependentColumns[0].SkippedRows != 2 {
+		t.Errorf("Expected 2 skipped rows, got %d", chart.DependentColumns[0].SkippedRows)
+	}
+	if chart.DependentColumns[0].ResultUnit != models.MinutesInterval || chart.DependentColumns[0].IntervalUnit != models.HHMMSSInterval {
+		t.Errorf("Expected the interval to be plotted in minutes, got %+v", chart.DependentColumns[0])
+	}
+}
+
+func TestIntervalRequiresBothColumns(t *testing.T) {
+	csvFile := writeMockCSV(t, mockIntervalRows())
+
+	csvData := models.ReportCSVData{
+		Label:         "response",
+		OperationType: models.Average,
+		StartColumn:   "Dispatched",
+	}
+	if err := util.AnalyzeOneDimensionalData(csvFile, &csvData); err == nil {
+		t.Errorf("Expected an error for an interval without an end column")
+	}
+
+	csvData.EndColumn = "Cleared"
+	if err := util.AnalyzeOneDimensionalData(csvFile, &csvData); err == nil {
+		t.Errorf("Expected an error for an interval end column missing from the CSV")
+	}
+}
@@ -0,0 +1,107 @@
+package util_test
+
+import (
+	"api/shared/models"
+	"api/shared/util"
+	"encoding/json"
+	"math"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestUniqueValuesAreCountedAndCapped(t *testing.T) {
+	rows := [][]string{{"Incident Number", "Station"}}
+	for i := 0; i < 800; i++ {
+		rows = append(rows, []string{"INC-" + strconv.Itoa(i), strconv.Itoa(i%3 + 1)})
+	}
+	// A repeated incident number is listed first
+	rows = append(rows, []string{"INC-42", "1"})
+	csvFile := writeMockCSV(t, rows)
+
+	columnData, err := util.ProfileCSVColumns(csvFile, nil)
+	if err != nil {
+		t.Fatalf("ProfileCSVColumns returned an error: %v", err)
+	}
+
+	incidents := columnData.ColumnsMap["Incident Number"]
+	if !incidents.Truncated || incidents.CountLimitReached || incidents.DistinctCount != 800 || len(incidents.Values) != 500 {
+		t.Errorf("Expected 500 of 800 incident numbers, got %d of %d, truncated %v", len(incidents.Values), incidents.DistinctCount, incidents.Truncated)
+	}
+	if incidents.Values[0] != (models.CsvColumnValueCount{Value: "INC-42", Count: 2}) {
+		t.Errorf("Expected the repeated incident number first, got %v", incidents.Values[0])
+	}
+	if len(columnData.ColumnValues["Incident Number"]) != 800 {
+		t.Errorf("Expected every incident number to be kept for searching, got %d", len(columnData.ColumnValues["Incident Number"]))
+	}
+
+	expectedStations := models.CsvColumnValues{
+		Values: []models.CsvColumnValueCount{
+			{Value: "1", Count: 268},
+			{Value: "2", Count: 267},
+			{Value: "3", Count: 266},
+		},
+		DistinctCount: 3,
+	}
+	if !reflect.DeepEqual(columnData.ColumnsMap["Station"], expectedStations) {
+		t.Errorf("Stations were not counted correctly. Got: \n %+v \n, want: \n %+v", columnData.ColumnsMap["Station"], expectedStations)
+	}
+	if _, ok := columnData.ColumnValues["Station"]; ok {
+		t.Errorf("Expected no separate values for a column listed in full")
+	}
+}
+
+func TestSearchColumnValues(t *testing.T) {
+	values := []models.CsvColumnValueCount{
+		{Value: "Main St", Count: 3},
+		{Value: "Oak Ave", Count: 9},
+		{Value: "maple Dr", Count: 5},
+		{Value: "Market St", Count: 5},
+		{Value: "Elm St", Count: 1},
+	}
+
+	tests := []struct {
+		prefix   string
+		order    models.ColumnValueOrder
+		offset   int
+		limit    int
+		expected []string
+		total    int
+	}{
+		{"ma", models.FrequencyOrder, 0, 10, []string{"Market St", "maple Dr", "Main St"}, 3},
+		{"MA", models.AlphabeticalOrder, 0, 10, []string{"Main St", "Market St", "maple Dr"}, 3},
+		{"", models.FrequencyOrder, 1, 2, []string{"Market St", "maple Dr"}, 5},
+		{"", models.AlphabeticalOrder, 4, 2, []string{"maple Dr"}, 5},
+		{"", models.FrequencyOrder, 10, 2, []string{}, 5},
+		{"", models.FrequencyOrder, -4, 2, []string{}, 5},
+		{"", models.FrequencyOrder, math.MaxInt, 2, []string{}, 5},
+		{"Pine", models.FrequencyOrder, 0, 10, []string{}, 0},
+	}
+
+	for _, test := range tests {
+		page, total := util.SearchColumnValues(values, test.prefix, test.order, test.offset, test.limit)
+		got := []string{}
+		for _, value := range page {
+			got = append(got, value.Value)
+		}
+		if !reflect.DeepEqual(got, test.expected) || total != test.total {
+			t.Errorf("prefix %q %s offset %d: expected %v of %d, got %v of %d", test.prefix, test.order, test.offset, test.expected, test.total, got, total)
+		}
+	}
+}
+
+func TestLegacyColumnValuesMapDecoding(t *testing.T) {
+	var columnsMap models.CsvDataColumnUniqueValuesMap
+	err := json.Unmarshal([]byte(`{"Station": ["1", "2"]}`), &columnsMap)
+	if err != nil {
+		t.Fatalf("Unmarshal returned an error: %v", err)
+	}
+
+	expected := models.CsvColumnValues{
+		Values:        []models.CsvColumnValueCount{{Value: "1"}, {Value: "2"}},
+		DistinctCount: 2,
+	}
+	if !reflect.DeepEqual(columnsMap["Station"], expected) {
+		t.Errorf("Legacy column values were not decoded correctly. Got: \n %+v \n, want: \n %+v", columnsMap["Station"], expected)
+	}
+}
@@ -0,0 +1,241 @@
+package util_test
+
+import (
+	"api/shared/models"
+	"api/shared/util"
+	"archive/zip"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var expectedNormalizedRows = [][]string{
+	{"Station", "Café", "Travel Time"},
+	{"North", "Crème", "4,5"},
+	{"South", "", "6"},
+}
+
+func writeMockUpload(t *testing.T, name string, contents []byte) *os.File {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, contents, 0o600); err != nil {
+		t.Fatalf("error writing mock upload: %v", err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("error opening mock upload: %v", err)
+	}
+	t.Cleanup(func() { file.Close() })
+	return file
+}
+
+func readNormalizedUpload(t *testing.T, file *os.File, options models.UploadOptions) [][]string {
+	t.Helper()
+
+	normalized, validation, err := util.NormalizeUpload(file, options)
+	if err != nil {
+		t.Fatalf("NormalizeUpload returned an error: %v", err)
+	}
+	if !validation.Valid {
+		t.Fatalf("Expected the upload to be valid, got diagnostics %+v", validation.Diagnostics)
+	}
+	defer normalized.Close()
+
+	rows, err := csv.NewReader(normalized).ReadAll()
+	if err != nil {
+		t.Fatalf("normalized upload is not a valid csv: %v", err)
+	}
+	return rows
+}
+
+func TestNormalizeDelimitedUploads(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents []byte
+		options  models.UploadOptions
+	}{
+		{
+			name:     "utf-8 bom with semicolons",
+			contents: []byte("\xEF\xBB\xBFStation;Café;Travel Time\r\nNorth;Crème;\"4,5\"\r\nSouth;;6\r\n"),
+		},
+		{
+			name:     "windows-1252",
+			contents: []byte("Station,Caf\xE9,Travel Time\nNorth,Cr\xE8me,\"4,5\"\nSouth,,6\n"),
+		},
+		{
+			name:     "tsv with a blank line",
+			contents: []byte("Station\tCafé\tTravel Time\nNorth\tCrème\t4,5\n\nSouth\t\t6\n"),
+			options:  models.UploadOptions{FileType: models.TSVFile},
+		},
+		{
+			name:     "utf-16 little endian",
+			contents: utf16LE("\uFEFFStation\tCafé\tTravel Time\r\nNorth\tCrème\t4,5\r\nSouth\t\t6\t\r\n"),
+		},
+		{
+			name:     "given delimiter",
+			contents: []byte("Station|Café|Travel Time\nNorth|Crème|4,5\nSouth||6\n"),
+			options:  models.UploadOptions{Delimiter: "|"},
+		},
+	}
+
+	for _, test := range tests {
+		rows := readNormalizedUpload(t, writeMockUpload(t, "upload.csv", test.contents), test.options)
+		if !reflect.DeepEqual(rows, expectedNormalizedRows) {
+			t.Errorf("%s: got %v, want %v", test.name, rows, expectedNormalizedRows)
+		}
+	}
+}
+
+func TestValidateUploadOptions(t *testing.T) {
+	options := models.UploadOptions{}
+	if err := util.ValidateUploadOptions(&options); err != nil || options.FileType != models.CSVFile {
+		t.Errorf("Expected empty options to default to csv, got %v, %v", options.FileType, err)
+	}
+
+	invalid := []models.UploadOptions{
+		{FileType: "xls"},
+		{FileType: models.CSVFile, Delimiter: ";;"},
+		{FileType: models.CSVFile, Delimiter: "\""},
+		{FileType: models.CSVFile, Sheet: "Incidents"},
+		{FileType: models.XLSXFile, Delimiter: ";"},
+	}
+	for _, options := range invalid {
+		if err := util.ValidateUploadOptions(&options); err == nil {
+			t.Errorf("Expected an error for options %+v", options)
+		}
+	}
+}
+
+func TestNormalizeXLSXUpload(t *testing.T) {
+	file := writeMockUpload(t, "upload.xlsx", mockWorkbook(t))
+
+	rows := readNormalizedUpload(t, file, models.UploadOptions{FileType: models.XLSXFile, Sheet: "Incidents"})
+	expected := [][]string{
+		{"Station", "Alarm Date", "Travel Time", "Confirmed", "Notes"},
+		{"North", "2023-01-01", "4.5", "true", "First call"},
+		{"South", "2023-01-02 12:00:00", "6", "false", ""},
+	}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Errorf("xlsx rows were not normalized correctly. Got: \n %v \n, want: \n %v", rows, expected)
+	}
+
+	rows = readNormalizedUpload(t, file, models.UploadOptions{FileType: models.XLSXFile})
+	if !reflect.DeepEqual(rows, [][]string{{"Summary"}, {"Only sheet one"}}) {
+		t.Errorf("Expected the first sheet when no sheet is given, got %v", rows)
+	}
+
+	if _, _, err := util.NormalizeUpload(file, models.UploadOptions{FileType: models.XLSXFile, Sheet: "Missing"}); err == nil {
+		t.Errorf("Expected an error for a sheet that is not in the workbook")
+	}
+}
+
+func TestNormalizeXLSXMalformedReference(t *testing.T) {
+	for _, reference := range []string{"a1", "1", "A", "A1B", "XFE1", "ZZZZZZZZ1", "ZZZZZZZZZZZZZZZZ1"} {
+		parts := mockWorkbookParts()
+		parts["xl/worksheets/sheet1.xml"] = `<?xml version="1.0" encoding="UTF-8"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>
+<row r="1"><c r="` + reference + `" t="s"><v>5</v></c></row>
+</sheetData></worksheet>`
+		file := writeMockUpload(t, "upload.xlsx", zipWorkbook(t, parts))
+
+		if _, _, err := util.NormalizeUpload(file, models.UploadOptions{FileType: models.XLSXFile}); err == nil {
+			t.Errorf("Expected an error for the cell reference %q", reference)
+		}
+	}
+
+	// The last column is read, its missing headers are left to validation
+	parts := mockWorkbookParts()
+	parts["xl/worksheets/sheet1.xml"] = `<?xml version="1.0" encoding="UTF-8"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>
+<row r="1"><c r="XFD1" t="s"><v>5</v></c></row>
+</sheetData></worksheet>`
+	file := writeMockUpload(t, "upload.xlsx", zipWorkbook(t, parts))
+	if _, _, err := util.NormalizeUpload(file, models.UploadOptions{FileType: models.XLSXFile}); err != nil {
+		t.Errorf("Expected the last column to be read, got %v", err)
+	}
+}
+
+func utf16LE(text string) []byte {
+	var encoded []byte
+	for _, r := range text {
+		encoded = append(encoded, byte(r), byte(r>>8))
+	}
+	return encoded
+}
+
+// mockWorkbook builds a workbook with a summary sheet and an incidents sheet that uses
+// shared strings, a rich text run, an inline string, booleans, date styles and a skipped cell
+func mockWorkbook(t *testing.T) []byte {
+	t.Helper()
+	return zipWorkbook(t, mockWorkbookParts())
+}
+
+func mockWorkbookParts() map[string]string {
+	return map[string]string{
+		"xl/workbook.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="Summary" sheetId="1" r:id="rId1"/><sheet name="Incidents" sheetId="2" r:id="rId2"/></sheets>
+</workbook>`,
+		"xl/_rels/workbook.xml.rels": `<?xml version="1.0" encoding="UTF-8"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="/xl/worksheets/sheet2.xml"/>
+</Relationships>`,
+		"xl/sharedStrings.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<si><t>Station</t></si><si><t>Alarm Date</t></si><si><t>Travel Time</t></si><si><t>North</t></si>
+<si><r><t>Sou</t></r><r><t>th</t></r></si><si><t>Summary</t></si><si><t>Only sheet one</t></si>
+</sst>`,
+		"xl/styles.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<numFmts><numFmt numFmtId="164" formatCode="yyyy\-mm\-dd\ hh:mm"/><numFmt numFmtId="165" formatCode="0.0&quot; min&quot;"/></numFmts>
+<cellXfs><xf numFmtId="0"/><xf numFmtId="14"/><xf numFmtId="164"/><xf numFmtId="165"/></cellXfs>
+</styleSheet>`,
+		"xl/worksheets/sheet1.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>
+<row r="1"><c r="A1" t="s"><v>5</v></c></row>
+<row r="2"><c r="A2" t="s"><v>6</v></c></row>
+</sheetData></worksheet>`,
+		"xl/worksheets/sheet2.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>
+<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c><c r="C1" t="s"><v>2</v></c><c r="D1" t="inlineStr"><is><t>Confirmed</t></is></c><c r="E1" t="str"><v>Notes</v></c><c r="F1" s="0"/></row>
+<row r="2"><c r="A2" t="s"><v>3</v></c><c r="B2" s="1"><v>44927</v></c><c r="C2" s="3"><v>4.5</v></c><c r="D2" t="b"><v>1</v></c><c r="E2" t="inlineStr"><is><t>First call</t></is></c></row>
+<row r="3"><c r="A3" t="s"><v>4</v></c><c r="B3" s="2"><v>44928.5</v></c><c r="C3"><v>6</v></c><c r="D3" t="b"><v>0</v></c></row>
+<row r="5"><c r="A5" s="0"/></row>
+</sheetData></worksheet>`,
+	}
+}
+
+func zipWorkbook(t *testing.T, parts map[string]string) []byte {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "workbook.xlsx")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("error creating mock workbook: %v", err)
+	}
+	defer file.Close()
+
+	archive := zip.NewWriter(file)
+	for name, contents := range parts {
+		part, err := archive.Create(name)
+		if err != nil {
+			t.Fatalf("error creating workbook part: %v", err)
+		}
+		if _, err := part.Write([]byte(contents)); err != nil {
+			t.Fatalf("error writing workbook part: %v", err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatalf("error closing mock workbook: %v", err)
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("error reading mock workbook: %v", err)
+	}
+	return contents
+}
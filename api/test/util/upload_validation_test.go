@@ -0,0 +1,121 @@
+package util_test
+
+import (
+	"api/shared/models"
+	"api/shared/util"
+	"strings"
+	"testing"
+)
+
+func validateMockUpload(t *testing.T, contents string) *models.ValidationReport {
+	t.Helper()
+
+	normalized, validation, err := util.NormalizeUpload(writeMockUpload(t, "upload.csv", []byte(contents)), models.UploadOptions{})
+	if err != nil {
+		t.Fatalf("NormalizeUpload returned an error: %v", err)
+	}
+	if normalized != nil {
+		normalized.Close()
+	}
+	if (normalized == nil) == validation.Valid {
+		t.Errorf("Expected a normalized file only for valid uploads")
+	}
+	return validation
+}
+
+func findDiagnostic(validation *models.ValidationReport, kind models.DiagnosticKind) (models.UploadDiagnostic, bool) {
+	for _, diagnostic := range validation.Diagnostics {
+		if diagnostic.Kind == kind {
+			return diagnostic, true
+		}
+	}
+	return models.UploadDiagnostic{}, false
+}
+
+func TestUploadValidationHeaders(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		kind     models.DiagnosticKind
+	}{
+		{"empty file", "", models.MissingHeaderDiagnostic},
+		{"blank header", "Station,,Year\nNorth,1,2023\n", models.MissingHeaderDiagnostic},
+		{"duplicate header", "Station,Year,Station\nNorth,2023,South\n", models.DuplicateHeaderDiagnostic},
+		{"null bytes in header", "S\x00t\x00a\x00t\x00i\x00o\x00n\x00\n", models.EncodingDiagnostic},
+	}
+
+	for _, test := range tests {
+		validation := validateMockUpload(t, test.contents)
+		diagnostic, ok := findDiagnostic(validation, test.kind)
+		if validation.Valid || !ok || diagnostic.Severity != models.ErrorSeverity {
+			t.Errorf("%s: expected a %s error, got %+v", test.name, test.kind, validation.Diagnostics)
+		}
+	}
+}
+
+func TestUploadValidationRaggedRows(t *testing.T) {
+	var contents strings.Builder
+	contents.WriteString("Station,Year\n")
+	for i := 0; i < 30; i++ {
+		contents.WriteString("North,2023,Extra\n")
+	}
+	// A quoted value spanning lines starts the row on its first line
+	contents.WriteString("\"South\nStation\",2023\nEast\n")
+
+	validation := validateMockUpload(t, contents.String())
+	if validation.Valid {
+		t.Fatalf("Expected ragged rows to fail validation")
+	}
+
+	ragged := []models.UploadDiagnostic{}
+	for _, diagnostic := range validation.Diagnostics {
+		if diagnostic.Kind == models.RaggedRowDiagnostic {
+			ragged = append(ragged, diagnostic)
+		}
+	}
+	if len(ragged) != 20 || validation.OmittedDiagnostics != 11 {
+		t.Errorf("Expected 20 ragged rows listed and 11 omitted, got %d and %d", len(ragged), validation.OmittedDiagnostics)
+	}
+	if ragged[0].Line != 2 || ragged[19].Line != 21 {
+		t.Errorf("Expected ragged rows on lines 2 to 21, got %d to %d", ragged[0].Line, ragged[19].Line)
+	}
+	if validation.RowCount != 32 {
+		t.Errorf("Expected 32 rows, got %d", validation.RowCount)
+	}
+}
+
+func TestUploadValidationMalformedQuotes(t *testing.T) {
+	validation := validateMockUpload(t, "Station,Year\nNorth,2023\n\"South\"x,2024\n")
+
+	diagnostic, ok := findDiagnostic(validation, models.MalformedRowDiagnostic)
+	if validation.Valid || !ok || diagnostic.Line != 3 {
+		t.Errorf("Expected a malformed row on line 3, got %+v", validation.Diagnostics)
+	}
+}
+
+func TestUploadValidationWarnings(t *testing.T) {
+	rows := []string{"Station,Travel Time,Notes,Unit"}
+	for i := 0; i < 7; i++ {
+		rows = append(rows, "North,4.5,,E1")
+	}
+	rows = append(rows, "South,n/a,,E2")
+	validation := validateMockUpload(t, strings.Join(rows, "\n"))
+
+	if !validation.Valid || validation.RowCount != 8 {
+		t.Fatalf("Expected 8 valid rows with warnings, got %+v", validation)
+	}
+
+	numeric, ok := findDiagnostic(validation, models.NumericParseDiagnostic)
+	if !ok || numeric.Column != "Travel Time" || numeric.FailureRate != 0.125 || numeric.Line != 9 || numeric.Severity != models.WarningSeverity {
+		t.Errorf("Expected 12.5%% of travel times to fail parsing from line 9, got %+v", numeric)
+	}
+
+	empty, ok := findDiagnostic(validation, models.EmptyColumnDiagnostic)
+	if !ok || empty.Column != "Notes" {
+		t.Errorf("Expected the notes column to be empty, got %+v", empty)
+	}
+
+	if len(validation.Diagnostics) != 2 {
+		t.Errorf("Expected only the numeric and empty column warnings, got %+v", validation.Diagnostics)
+	}
+}
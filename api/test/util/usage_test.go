@@ -0,0 +1,271 @@
+package util_test
+
+import (
+	"api/shared/models"
+	"api/shared/util"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// fakeUsageLedger keeps usage records and monthly totals in memory
+type fakeUsageLedger struct {
+	mu      sync.Mutex
+	records []models.UsageRecord
+	totals  map[string]models.UsageTotals
+}
+
+func newFakeUsageLedger() *fakeUsageLedger {
+	return &fakeUsageLedger{totals: make(map[string]models.UsageTotals)}
+}
+
+func (l *fakeUsageLedger) RecordUsage(record models.UsageRecord) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.records = append(l.records, record)
+	l.addTokens(models.UserUsage, record.UserID, record.Month, record.PromptTokens+record.CompletionTokens)
+	if record.OrganizationID != "" {
+		l.addTokens(models.OrganizationUsage, record.OrganizationID, record.Month, record.PromptTokens+record.CompletionTokens)
+	}
+	return nil
+}
+
+func (l *fakeUsageLedger) MonthlyUsage(scope models.UsageScope, id string, month string) (models.UsageTotals, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.totals[string(scope)+id+month], nil
+}
+
+func (l *fakeUsageLedger) addTokens(scope models.UsageScope, id, month string, tokens int) {
+	totals := l.totals[string(scope)+id+month]
+	totals.Requests++
+	totals.TotalTokens += tokens
+	l.totals[string(scope)+id+month] = totals
+}
+
+func newFakeMeteredRegistry(t *testing.T, ledger *fakeUsageLedger, quotas models.UsageQuotas, lastRequest *fakeProviderRequest) *util.MeteredRegistry {
+	return &util.MeteredRegistry{
+		Registry: newFakeProviderRegistry(t, "", lastRequest),
+		Ledger:   ledger,
+		Config: util.UsageConfig{
+			Quotas: quotas,
+			Prices: map[string]models.TokenPrice{"gpt-3.5-turbo": {Prompt: 1000000, Completion: 2000000}},
+		},
+		Usage: models.UsageContext{
+			UserID:         "user-1",
+			OrganizationID: "fire-department",
+			ReportID:       "report-1",
+			PartIndex:      1,
+			SectionIndex:   2,
+			SectionTitle:   "Response Times",
+		},
+	}
+}
+
+func TestMeteredRegistryRecordsUsage(t *testing.T) {
+	ledger := newFakeUsageLedger()
+	registry := newFakeMeteredRegistry(t, ledger, models.UsageQuotas{}, nil)
+
+	section := &models.ReportSection{
+		TextOutputs: []models.ReportTextOutput{
+			{Title: "Default", Type: models.Generator, Input: "Summarize"},
+			{Title: "Missing", Type: models.Generator, Input: "Summarize", Provider: models.AnthropicProvider, Model: "missing-model"},
+		},
+	}
+
+	err := util.GenerateSectionGeneratorText(context.Background(), registry, section, &[]models.ReportQuestion{}, nil, &models.GenerationOptions{})
+	if err != nil {
+		t.Fatalf("GenerateSectionGeneratorText returned an error: %v", err)
+	}
+
+	if len(ledger.records) != 2 {
+		t.Fatalf("Expected a record for each request, got %+v", ledger.records)
+	}
+
+	for _, record := range ledger.records {
+		if record.UserID != "user-1" || record.OrganizationID != "fire-department" || record.ReportID != "report-1" ||
+			record.PartIndex != 1 || record.SectionIndex != 2 || record.SectionTitle != "Response Times" {
+			t.Errorf("Expected the usage context on the record, got %+v", record)
+		}
+		if record.Month != time.Now().UTC().Format("2006-01") || record.CreatedAt == 0 {
+			t.Errorf("Expected the month and time of the request, got %+v", record)
+		}
+
+		switch record.Model {
+		case "gpt-3.5-turbo":
+			result := section.TextOutputs[0].Result
+			if record.Provider != models.OpenAIProvider || record.PromptTokens != len("Summarize") || record.CompletionTokens != len(result) || record.Error != "" {
+				t.Errorf("Expected the tokens of the openai request, got %+v", record)
+			}
+			if cost := float64(len("Summarize") + 2*len(result)); record.CostUSD != cost {
+				t.Errorf("Expected a cost of %v, got %v", cost, record.CostUSD)
+			}
+		case "missing-model":
+			if record.Provider != models.AnthropicProvider || record.PromptTokens != 0 || record.CostUSD != 0 || !strings.Contains(record.Error, "missing-model") {
+				t.Errorf("Expected the failed request to be recorded with its error, got %+v", record)
+			}
+		default:
+			t.Errorf("Unexpected record %+v", record)
+		}
+	}
+}
+
+func TestMeteredRegistryQuotas(t *testing.T) {
+	month := time.Now().UTC().Format("2006-01")
+
+	tests := []struct {
+		name      string
+		quotas    models.UsageQuotas
+		generated bool
+		err       string
+	}{
+		{"no quotas", models.UsageQuotas{}, true, ""},
+		{"within quotas", models.UsageQuotas{UserMonthlyTokens: 101, OrganizationMonthlyTokens: 501}, true, ""},
+		{"user quota used", models.UsageQuotas{UserMonthlyTokens: 100}, false, "monthly token quota of the user is used: 100 of 100 tokens in " + month},
+		{"organization quota used", models.UsageQuotas{UserMonthlyTokens: 1000, OrganizationMonthlyTokens: 500}, false, "monthly token quota of the organization is used"},
+	}
+
+	for _, test := range tests {
+		ledger := newFakeUsageLedger()
+		ledger.totals["user"+"user-1"+month] = models.UsageTotals{TotalTokens: 100}
+		ledger.totals["organization"+"fire-department"+month] = models.UsageTotals{TotalTokens: 500}
+
+		var request fakeProviderRequest
+		registry := newFakeMeteredRegistry(t, ledger, test.quotas, &request)
+
+		section := &models.ReportSection{
+			TextOutputs: []models.ReportTextOutput{
+				{Title: "Summary", Type: models.Generator, Input: "Summarize", Result: "Previous result"},
+				{Title: "Static", Type: models.Static, Input: "Unchanged", Result: "Unchanged"},
+			},
+		}
+
+		err := util.GenerateSectionGeneratorText(context.Background(), registry, section, &[]models.ReportQuestion{}, nil, &models.GenerationOptions{})
+		if test.generated {
+			if err != nil || section.TextOutputs[0].Error != "" || len(ledger.records) != 1 {
+				t.Errorf("%s: expected the output to generate, got %v and %+v", test.name, err, section.TextOutputs[0])
+			}
+			continue
+		}
+
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: expected error %q, got %v", test.name, test.err, err)
+		}
+		if request.Model != "" || len(ledger.records) != 0 {
+			t.Errorf("%s: expected no request to be sent, got %+v", test.name, request)
+		}
+		if section.TextOutputs[0].Result != "" || section.TextOutputs[0].Error != err.Error() || section.TextOutputs[1].Result != "Unchanged" {
+			t.Errorf("%s: expected only the generator output to fail, got %+v", test.name, section.TextOutputs)
+		}
+	}
+}
+
+func TestSummarizeUsage(t *testing.T) {
+	records := []models.UsageRecord{
+		{UserID: "user-2", ReportID: "report-1", Month: "2024-05", PromptTokens: 10, CompletionTokens: 5, CostUSD: 0.5},
+		{UserID: "user-1", ReportID: "report-2", Month: "2024-05", PromptTokens: 20, CompletionTokens: 10},
+		{UserID: "user-1", ReportID: "report-1", Month: "2024-06", PromptTokens: 1, CompletionTokens: 2},
+		{UserID: "user-2", ReportID: "report-1", Month: "2024-05", PromptTokens: 30, CompletionTokens: 15, CostUSD: 1.5},
+		{UserID: "user-2", ReportID: "report-1", Month: "2024-05", Error: "model overloaded"},
+	}
+
+	entries, totals := util.SummarizeUsage(records)
+
+	expected := []models.UsageSummaryEntry{
+		{UserID: "user-1", ReportID: "report-2", Month: "2024-05", UsageTotals: models.UsageTotals{Requests: 1, PromptTokens: 20, CompletionTokens: 10, TotalTokens: 30}},
+		{UserID: "user-2", ReportID: "report-1", Month: "2024-05", UsageTotals: models.UsageTotals{Requests: 3, FailedRequests: 1, PromptTokens: 40, CompletionTokens: 20, TotalTokens: 60, CostUSD: 2}},
+		{UserID: "user-1", ReportID: "report-1", Month: "2024-06", UsageTotals: models.UsageTotals{Requests: 1, PromptTokens: 1, CompletionTokens: 2, TotalTokens: 3}},
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("Expected %d entries, got %+v", len(expected), entries)
+	}
+	for i := range expected {
+		if entries[i] != expected[i] {
+			t.Errorf("Entry %d: expected %+v, got %+v", i, expected[i], entries[i])
+		}
+	}
+
+	if totals.Requests != 5 || totals.FailedRequests != 1 || totals.TotalTokens != 93 || totals.CostUSD != 2 {
+		t.Errorf("Expected the totals of every record, got %+v", totals)
+	}
+}
+
+func TestSummarizeOrganizationUsage(t *testing.T) {
+	records := []models.UsageRecord{
+		{UserID: "user-2", ReportID: "report-1", Month: "2024-05", PromptTokens: 10, CompletionTokens: 5},
+		{UserID: "user-1", ReportID: "report-2", Month: "2024-05", PromptTokens: 20, CompletionTokens: 10},
+		{UserID: "user-1", ReportID: "report-1", Month: "2024-06", PromptTokens: 1, CompletionTokens: 2},
+	}
+
+	entries, totals := util.SummarizeOrganizationUsage(records)
+
+	expected := []models.UsageSummaryEntry{
+		{Month: "2024-05", UsageTotals: models.UsageTotals{Requests: 2, PromptTokens: 30, CompletionTokens: 15, TotalTokens: 45}},
+		{Month: "2024-06", UsageTotals: models.UsageTotals{Requests: 1, PromptTokens: 1, CompletionTokens: 2, TotalTokens: 3}},
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("Expected %d entries, got %+v", len(expected), entries)
+	}
+	for i := range expected {
+		if entries[i] != expected[i] {
+			t.Errorf("Entry %d: expected %+v, got %+v", i, expected[i], entries[i])
+		}
+	}
+
+	if totals.Requests != 3 || totals.TotalTokens != 48 {
+		t.Errorf("Expected the totals of every record, got %+v", totals)
+	}
+	if records[0].UserID != "user-2" {
+		t.Errorf("Expected the records to be left as they are, got %+v", records[0])
+	}
+}
+
+func TestIsOrganizationAdmin(t *testing.T) {
+	tests := []struct {
+		groups   interface{}
+		expected bool
+	}{
+		{nil, false},
+		{"OrganizationAdmins", true},
+		{"Editors,OrganizationAdmins", true},
+		{"[Editors OrganizationAdmins]", true},
+		{[]interface{}{"OrganizationAdmins"}, true},
+		{"OrganizationAdminsX", false},
+		{"Editors", false},
+	}
+
+	for _, test := range tests {
+		claims := map[string]interface{}{"sub": "user-1"}
+		if test.groups != nil {
+			claims["cognito:groups"] = test.groups
+		}
+		request := events.APIGatewayProxyRequest{RequestContext: events.APIGatewayProxyRequestContext{
+			Authorizer: map[string]interface{}{"claims": claims},
+		}}
+		if got := util.IsOrganizationAdmin(request); got != test.expected {
+			t.Errorf("%v: expected %v, got %v", test.groups, test.expected, got)
+		}
+	}
+}
+
+func TestUsageMonthRange(t *testing.T) {
+	month := time.Now().UTC().Format("2006-01")
+	if from, to, err := util.UsageMonthRange("", ""); err != nil || from != month || to != month {
+		t.Errorf("Expected the current month by default, got %s to %s (%v)", from, to, err)
+	}
+
+	if _, _, err := util.UsageMonthRange("2024-01", "2024-12"); err != nil {
+		t.Errorf("Expected a year to be valid, got %v", err)
+	}
+
+	for _, months := range [][2]string{{"2024-13", "2024-12"}, {"May 2024", "2024-06"}, {"2024-06", "2024-05"}, {"2024-01", "2025-01"}} {
+		if _, _, err := util.UsageMonthRange(months[0], months[1]); err == nil {
+			t.Errorf("Expected %s to %s to be invalid", months[0], months[1])
+		}
+	}
+}
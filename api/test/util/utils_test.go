@@ -3,6 +3,7 @@ package util_test
 import (
 	"api/shared/models"
 	"api/shared/util"
+	"context"
 	"fmt"
 	"os"
 	"reflect"
@@ -12,7 +13,7 @@ import (
 func TestGenerateSectionStaticText(t *testing.T) {
 	section := mockStaticData()
 	globalQuestions := &[]models.ReportQuestion{}
-	util.GenerateSectionStaticText(section, globalQuestions)
+	util.GenerateSectionStaticText(section, globalQuestions, nil)
 
 	// Expected results after function execution
 	expectedTextOutput := []models.ReportTextOutput{
@@ -44,7 +45,7 @@ func TestGenerateSectionGeneratorText(t *testing.T) {
 	// Mock function for GeneratePromptResponse
 	mockGenerator := MockOpenAiGenerator{}
 
-	err := util.GenerateSectionGeneratorText(mockGenerator, section, globalQuestions)
+	err := util.GenerateSectionGeneratorText(context.Background(), mockGenerator, section, globalQuestions, nil, &models.GenerationOptions{})
 	if err != nil {
 		t.Errorf("GenerateSectionGeneratorText returned an error: %v", err)
 	}
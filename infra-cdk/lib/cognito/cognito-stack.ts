@@ -55,6 +55,11 @@ export class CognitoUserPoolStack extends cdk.Stack {
           required: true,
         },
       },
+      customAttributes: {
+        // The organization whose usage quota the generation of the user counts towards,
+        // set by administrators
+        organization: new cognito.StringAttribute({ mutable: true }),
+      },
       signInAliases: {
         email: true,
       },
@@ -81,6 +86,19 @@ export class CognitoUserPoolStack extends cdk.Stack {
         userPassword: true,
         userSrp: true,
       },
+      // Users can not move themselves to another organization
+      writeAttributes: new cognito.ClientAttributes().withStandardAttributes({
+        email: true,
+        nickname: true,
+        phoneNumber: true,
+      }),
+    });
+
+    // Members can see the usage of everyone in their organization by user and report
+    new cognito.CfnUserPoolGroup(this, "OrganizationAdminsGroup", {
+      userPoolId: this.userPool.userPoolId,
+      groupName: "OrganizationAdmins",
+      description: "Organization administrators",
     });
 
     // Output User Pool ID
@@ -2,6 +2,7 @@ export enum DynamoDBTable {
   ReportTable = "ReportTable",
   TemplateTable = "TemplateTable",
   OperationsTable = "OperationsTable",
+  UsageTable = "UsageTable",
 }
 
 export enum TableFields {
@@ -11,4 +12,7 @@ export enum TableFields {
   DeleteAt = "DeleteAt",
   CSVID = "CSVID",
   OperationID = "OperationID",
+  UsageKey = "UsageKey",
+  SortKey = "SortKey",
+  OrganizationID = "OrganizationID",
 }
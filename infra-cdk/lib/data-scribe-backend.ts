@@ -32,9 +32,11 @@ const lambdaFunctionsStack = new LambdasStack(app, "LambdaStack", {
   reportTable: dynamoDBStack.reportTable,
   templateTable: dynamoDBStack.templateTable,
   operationsTable: dynamoDBStack.operationTable,
+  usageTable: dynamoDBStack.usageTable,
   userPool: cognitoStack.userPool,
   csvBucket: s3BucketStack.csvBucket,
   columnDataBucket: s3BucketStack.columnDataBucket,
+  buildJoinColumnsLambda: s3BucketStack.buildJoinColumnsLambda,
 });
 
 const apiGatewayStack = new GatewayStack(app, "GatewayStack", {
@@ -44,11 +46,19 @@ const apiGatewayStack = new GatewayStack(app, "GatewayStack", {
   getAllReportsLambda: lambdaFunctionsStack.getAllReportsLambda,
   createReportLambda: lambdaFunctionsStack.createReportLambda,
   generateSectionLambda: lambdaFunctionsStack.generateSectionLambda,
+  generateReportLambda: lambdaFunctionsStack.generateReportLambda,
   getAllReportTypesLambda: lambdaFunctionsStack.getAllReportTypesLambda,
   uploadCSVLambda: lambdaFunctionsStack.uploadCSVLambda,
   getCSVUniqueColumnsMapLambda:
     lambdaFunctionsStack.getCSVUniqueColumnsMapLambda,
+  getCSVColumnSchemaLambda: lambdaFunctionsStack.getCSVColumnSchemaLambda,
+  searchCSVColumnValuesLambda: lambdaFunctionsStack.searchCSVColumnValuesLambda,
   setSectionResponsesLambda: lambdaFunctionsStack.setSectionResponsesLambda,
+  setDerivedColumnsLambda: lambdaFunctionsStack.setDerivedColumnsLambda,
+  setJoinsLambda: lambdaFunctionsStack.setJoinsLambda,
+  regenerateTextOutputLambda: lambdaFunctionsStack.regenerateTextOutputLambda,
+  restoreTextOutputVersionLambda:
+    lambdaFunctionsStack.restoreTextOutputVersionLambda,
 
   // Template Lambdas
   getTemplateByIDLambda: lambdaFunctionsStack.getTemplateByIDLambda,
@@ -65,10 +75,13 @@ const apiGatewayStack = new GatewayStack(app, "GatewayStack", {
   updateItemTitleLambda: lambdaFunctionsStack.updateItemTitleLambda,
   shareItemLambda: lambdaFunctionsStack.shareItemLambda,
   convertItemLambda: lambdaFunctionsStack.convertItemLambda,
+  lintItemPlaceholdersLambda: lambdaFunctionsStack.lintItemPlaceholdersLambda,
   deleteItemLambda: lambdaFunctionsStack.deleteItemLambda,
   restoreItemLambda: lambdaFunctionsStack.restoreItemLambda,
   updateItemGlobalQuestionsLambda:
     lambdaFunctionsStack.updateItemGlobalQuestionsLambda,
+  updateItemGenerationOptionsLambda:
+    lambdaFunctionsStack.updateItemGenerationOptionsLambda,
 
   // User Lambdas
   getUserIDLambda: lambdaFunctionsStack.getUserIDLambda,
@@ -76,6 +89,10 @@ const apiGatewayStack = new GatewayStack(app, "GatewayStack", {
 
   // Operation Lambdas
   getOperationStatusLambda: lambdaFunctionsStack.getOperationStatusLambda,
+  cancelOperationLambda: lambdaFunctionsStack.cancelOperationLambda,
+
+  // Usage Lambdas
+  getUsageSummaryLambda: lambdaFunctionsStack.getUsageSummaryLambda,
 
   // User Pool
   userPool: cognitoStack.userPool,
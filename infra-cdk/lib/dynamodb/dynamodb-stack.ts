@@ -7,6 +7,7 @@ export class DynamoDBStack extends cdk.Stack {
   public readonly reportTable: dynamodb.Table;
   public readonly templateTable: dynamodb.Table;
   public readonly operationTable: dynamodb.Table;
+  public readonly usageTable: dynamodb.Table;
 
   constructor(scope: Construct, id: string, props?: cdk.StackProps) {
     super(scope, id, props);
@@ -56,5 +57,35 @@ export class DynamoDBStack extends cdk.Stack {
         deletionProtection: true,
       }
     );
+
+    // This table is the ledger of generator requests, with the monthly totals of each
+    // user and organization their quotas are checked against
+    this.usageTable = new dynamodb.Table(this, DynamoDBTable.UsageTable, {
+      partitionKey: {
+        name: TableFields.UsageKey,
+        type: dynamodb.AttributeType.STRING,
+      },
+      sortKey: {
+        name: TableFields.SortKey,
+        type: dynamodb.AttributeType.STRING,
+      },
+      billingMode: dynamodb.BillingMode.PAY_PER_REQUEST,
+      pointInTimeRecovery: true,
+      deletionProtection: true,
+    });
+
+    // Summarizes the usage of everyone in an organization
+    this.usageTable.addGlobalSecondaryIndex({
+      indexName: TableFields.OrganizationID,
+      partitionKey: {
+        name: TableFields.OrganizationID,
+        type: dynamodb.AttributeType.STRING,
+      },
+      sortKey: {
+        name: TableFields.SortKey,
+        type: dynamodb.AttributeType.STRING,
+      },
+      projectionType: dynamodb.ProjectionType.ALL,
+    });
   }
 }
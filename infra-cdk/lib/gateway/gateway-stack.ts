@@ -13,10 +13,17 @@ interface GatewayStackProps extends cdk.StackProps {
   getAllReportsLambda: lambda.IFunction;
   createReportLambda: lambda.IFunction;
   generateSectionLambda: lambda.IFunction;
+  generateReportLambda: lambda.IFunction;
   getAllReportTypesLambda: lambda.IFunction;
   uploadCSVLambda: lambda.IFunction;
   getCSVUniqueColumnsMapLambda: lambda.IFunction;
+  getCSVColumnSchemaLambda: lambda.IFunction;
+  searchCSVColumnValuesLambda: lambda.IFunction;
   setSectionResponsesLambda: lambda.IFunction;
+  setDerivedColumnsLambda: lambda.IFunction;
+  setJoinsLambda: lambda.IFunction;
+  regenerateTextOutputLambda: lambda.IFunction;
+  restoreTextOutputVersionLambda: lambda.IFunction;
 
   // Template Lambas
   getTemplateByIDLambda: lambda.IFunction;
@@ -33,9 +40,11 @@ interface GatewayStackProps extends cdk.StackProps {
   updateItemTitleLambda: lambda.IFunction;
   shareItemLambda: lambda.IFunction;
   convertItemLambda: lambda.IFunction;
+  lintItemPlaceholdersLambda: lambda.IFunction;
   deleteItemLambda: lambda.IFunction;
   restoreItemLambda: lambda.IFunction;
   updateItemGlobalQuestionsLambda: lambda.IFunction;
+  updateItemGenerationOptionsLambda: lambda.IFunction;
 
   // User Lambdas
   getUserIDLambda: lambda.IFunction;
@@ -43,6 +52,10 @@ interface GatewayStackProps extends cdk.StackProps {
 
   // Operation Lambdas
   getOperationStatusLambda: lambda.IFunction;
+  cancelOperationLambda: lambda.IFunction;
+
+  // Usage Lambdas
+  getUsageSummaryLambda: lambda.IFunction;
 
   // Cognito User Pool
   userPool: cognito.UserPool;
@@ -105,6 +118,8 @@ export class GatewayStack extends cdk.Stack {
 
     const operationsResource = gateway.root.addResource("operations");
 
+    const usageResource = gateway.root.addResource("usage");
+
     // Report Endpoints
 
     const getReportByIDEndpoint = reportResource.addResource("get");
@@ -164,6 +179,38 @@ export class GatewayStack extends cdk.Stack {
       }
     );
 
+    const regenerateTextOutputEndpoint =
+      reportSectionsResource.addResource("regenerate-output");
+    regenerateTextOutputEndpoint.addMethod(
+      "PUT",
+      new apigateway.LambdaIntegration(props.regenerateTextOutputLambda),
+      {
+        authorizer,
+        authorizationType: apigateway.AuthorizationType.COGNITO,
+      }
+    );
+
+    const restoreTextOutputVersionEndpoint =
+      reportSectionsResource.addResource("restore-output");
+    restoreTextOutputVersionEndpoint.addMethod(
+      "PUT",
+      new apigateway.LambdaIntegration(props.restoreTextOutputVersionLambda),
+      {
+        authorizer,
+        authorizationType: apigateway.AuthorizationType.COGNITO,
+      }
+    );
+
+    const generateReportEndpoint = reportResource.addResource("generate");
+    generateReportEndpoint.addMethod(
+      "POST",
+      new apigateway.LambdaIntegration(props.generateReportLambda),
+      {
+        authorizer,
+        authorizationType: apigateway.AuthorizationType.COGNITO,
+      }
+    );
+
     const uploadCSVEndpoint = csvResource.addResource("upload");
     uploadCSVEndpoint.addMethod(
       "POST",
@@ -184,10 +231,66 @@ export class GatewayStack extends cdk.Stack {
         authorizationType: apigateway.AuthorizationType.COGNITO,
         requestParameters: {
           "method.request.querystring.reportID": true,
+          "method.request.querystring.dataset": false,
         },
       }
     );
 
+    const getReportCsvColumnSchemaEndpoint =
+      csvResource.addResource("getColumnSchema");
+    getReportCsvColumnSchemaEndpoint.addMethod(
+      "GET",
+      new apigateway.LambdaIntegration(props.getCSVColumnSchemaLambda),
+      {
+        authorizer,
+        authorizationType: apigateway.AuthorizationType.COGNITO,
+        requestParameters: {
+          "method.request.querystring.reportID": true,
+          "method.request.querystring.dataset": false,
+        },
+      }
+    );
+
+    const searchReportCsvColumnValuesEndpoint =
+      csvResource.addResource("searchColumnValues");
+    searchReportCsvColumnValuesEndpoint.addMethod(
+      "GET",
+      new apigateway.LambdaIntegration(props.searchCSVColumnValuesLambda),
+      {
+        authorizer,
+        authorizationType: apigateway.AuthorizationType.COGNITO,
+        requestParameters: {
+          "method.request.querystring.reportID": true,
+          "method.request.querystring.column": true,
+          "method.request.querystring.dataset": false,
+          "method.request.querystring.prefix": false,
+          "method.request.querystring.sort": false,
+          "method.request.querystring.page": false,
+          "method.request.querystring.pageSize": false,
+        },
+      }
+    );
+
+    const setDerivedColumnsEndpoint = csvResource.addResource("derivedColumns");
+    setDerivedColumnsEndpoint.addMethod(
+      "PUT",
+      new apigateway.LambdaIntegration(props.setDerivedColumnsLambda),
+      {
+        authorizer,
+        authorizationType: apigateway.AuthorizationType.COGNITO,
+      }
+    );
+
+    const setJoinsEndpoint = csvResource.addResource("joins");
+    setJoinsEndpoint.addMethod(
+      "PUT",
+      new apigateway.LambdaIntegration(props.setJoinsLambda),
+      {
+        authorizer,
+        authorizationType: apigateway.AuthorizationType.COGNITO,
+      }
+    );
+
     const setSectionResponsesEndpoint =
       sharedSectionResource.addResource("responses");
     setSectionResponsesEndpoint.addMethod(
@@ -342,6 +445,16 @@ export class GatewayStack extends cdk.Stack {
       }
     );
 
+    const lintItemPlaceholdersEndpoint = sharedResource.addResource("lint");
+    lintItemPlaceholdersEndpoint.addMethod(
+      "GET",
+      new apigateway.LambdaIntegration(props.lintItemPlaceholdersLambda),
+      {
+        authorizer,
+        authorizationType: apigateway.AuthorizationType.COGNITO,
+      }
+    );
+
     const deleteItemEndpoint = sharedResource.addResource("delete");
     deleteItemEndpoint.addMethod(
       "DELETE",
@@ -382,6 +495,18 @@ export class GatewayStack extends cdk.Stack {
       }
     );
 
+    const updateItemGenerationOptionsEndpoint = sharedResource.addResource(
+      "updateGenerationOptions"
+    );
+    updateItemGenerationOptionsEndpoint.addMethod(
+      "PUT",
+      new apigateway.LambdaIntegration(props.updateItemGenerationOptionsLambda),
+      {
+        authorizer,
+        authorizationType: apigateway.AuthorizationType.COGNITO,
+      }
+    );
+
     // --------------------------------------------------------- //
     // User Endpoints
 
@@ -418,5 +543,29 @@ export class GatewayStack extends cdk.Stack {
         authorizationType: apigateway.AuthorizationType.COGNITO,
       }
     );
+
+    const cancelOperationEndpoint = operationsResource.addResource("cancel");
+    cancelOperationEndpoint.addMethod(
+      "PUT",
+      new apigateway.LambdaIntegration(props.cancelOperationLambda),
+      {
+        authorizer,
+        authorizationType: apigateway.AuthorizationType.COGNITO,
+      }
+    );
+
+    // --------------------------------------------------------- //
+
+    // Usage Endpoints
+
+    const getUsageSummaryEndpoint = usageResource.addResource("summary");
+    getUsageSummaryEndpoint.addMethod(
+      "GET",
+      new apigateway.LambdaIntegration(props.getUsageSummaryLambda),
+      {
+        authorizer,
+        authorizationType: apigateway.AuthorizationType.COGNITO,
+      }
+    );
   }
 }
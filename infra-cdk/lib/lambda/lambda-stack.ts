@@ -11,9 +11,11 @@ interface LambdasStackProps extends cdk.StackProps {
   reportTable: dynamodb.Table;
   templateTable: dynamodb.Table;
   operationsTable: dynamodb.Table;
+  usageTable: dynamodb.Table;
   userPool: cognito.UserPool;
   readonly csvBucket: s3.Bucket;
   readonly columnDataBucket: s3.Bucket;
+  readonly buildJoinColumnsLambda: lambda.IFunction;
 }
 
 export class LambdasStack extends cdk.Stack {
@@ -22,10 +24,18 @@ export class LambdasStack extends cdk.Stack {
   public readonly getAllReportsLambda: lambda.IFunction;
   public readonly createReportLambda: lambda.IFunction;
   public readonly generateSectionLambda: lambda.IFunction;
+  public readonly generateReportLambda: lambda.IFunction;
+  public readonly runReportGenerationLambda: lambda.IFunction;
   public readonly getAllReportTypesLambda: lambda.IFunction;
   public readonly uploadCSVLambda: lambda.IFunction;
   public readonly getCSVUniqueColumnsMapLambda: lambda.IFunction;
+  public readonly getCSVColumnSchemaLambda: lambda.IFunction;
+  public readonly searchCSVColumnValuesLambda: lambda.IFunction;
   public readonly setSectionResponsesLambda: lambda.IFunction;
+  public readonly setDerivedColumnsLambda: lambda.IFunction;
+  public readonly setJoinsLambda: lambda.IFunction;
+  public readonly regenerateTextOutputLambda: lambda.IFunction;
+  public readonly restoreTextOutputVersionLambda: lambda.IFunction;
 
   // Template Lambas
   public readonly getTemplateByIDLambda: lambda.IFunction;
@@ -42,9 +52,11 @@ export class LambdasStack extends cdk.Stack {
   public readonly updateItemTitleLambda: lambda.IFunction;
   public readonly shareItemLambda: lambda.IFunction;
   public readonly convertItemLambda: lambda.IFunction;
+  public readonly lintItemPlaceholdersLambda: lambda.IFunction;
   public readonly deleteItemLambda: lambda.IFunction;
   public readonly restoreItemLambda: lambda.IFunction;
   public readonly updateItemGlobalQuestionsLambda: lambda.IFunction;
+  public readonly updateItemGenerationOptionsLambda: lambda.IFunction;
 
   // User Lambdas
   public readonly getUserIDLambda: lambda.IFunction;
@@ -52,6 +64,10 @@ export class LambdasStack extends cdk.Stack {
 
   // Operation lambdas
   public readonly getOperationStatusLambda: lambda.IFunction;
+  public readonly cancelOperationLambda: lambda.IFunction;
+
+  // Usage Lambdas
+  public readonly getUsageSummaryLambda: lambda.IFunction;
 
   // --------------------------------------------------------- //
 
@@ -133,6 +149,41 @@ export class LambdasStack extends cdk.Stack {
       flag: "r",
     });
 
+    // Other generator providers are optional, their keys are read from the keys folder
+    // like the openAI key and their settings from the cdk context, e.g.
+    // cdk deploy -c GENERATOR_PROVIDER=anthropic -c ANTHROPIC_MODEL=claude-3-haiku-20240307
+    const readOptionalKey = (fileName: string) => {
+      const keyPath = path.join(__dirname, "../../../keys", fileName);
+      return fs.existsSync(keyPath)
+        ? fs.readFileSync(keyPath, { encoding: "utf8", flag: "r" }).trim()
+        : "";
+    };
+    const generatorEnvironment: { [key: string]: string } = {};
+    const generatorSettings: { [key: string]: string } = {
+      ANTHROPIC_API_KEY: readOptionalKey("anthropic-key.txt"),
+      AZURE_OPENAI_API_KEY: readOptionalKey("azure-openai-key.txt"),
+      OPENAI_COMPATIBLE_API_KEY: readOptionalKey("openai-compatible-key.txt"),
+    };
+    for (const name of [
+      "GENERATOR_PROVIDER",
+      "OPENAI_BASE_URL",
+      "OPENAI_MODEL",
+      "ANTHROPIC_BASE_URL",
+      "ANTHROPIC_MODEL",
+      "AZURE_OPENAI_ENDPOINT",
+      "AZURE_OPENAI_API_VERSION",
+      "AZURE_OPENAI_DEPLOYMENT",
+      "OPENAI_COMPATIBLE_BASE_URL",
+      "OPENAI_COMPATIBLE_MODEL",
+    ]) {
+      generatorSettings[name] = this.node.tryGetContext(name) ?? "";
+    }
+    for (const [name, value] of Object.entries(generatorSettings)) {
+      if (value !== "") {
+        generatorEnvironment[name] = value;
+      }
+    }
+
     this.generateSectionLambda = new lambda.Function(
       this,
       "GenerateSectionLambda",
@@ -145,8 +196,11 @@ export class LambdasStack extends cdk.Stack {
         environment: {
           REPORT_TABLE: props.reportTable.tableName,
           OPERATION_TABLE: props.operationsTable.tableName,
+          USAGE_TABLE: props.usageTable.tableName,
+          USER_POOL_ID: props.userPool.userPoolId,
           CSV_BUCKET_NAME: props.csvBucket.bucketName,
           OPENAI_API_KEY: openAIKey,
+          ...generatorEnvironment,
         },
         timeout: cdk.Duration.minutes(2.5),
         memorySize: 2048,
@@ -159,6 +213,63 @@ export class LambdasStack extends cdk.Stack {
     );
     props.csvBucket.grantReadWrite(this.generateSectionLambda);
     props.operationsTable.grantReadWriteData(this.generateSectionLambda);
+    props.usageTable.grantReadWriteData(this.generateSectionLambda);
+
+    // Generates the sections of a report in the background, invoked by generateReportLambda
+    this.runReportGenerationLambda = new lambda.Function(
+      this,
+      "RunReportGenerationLambda",
+      {
+        code: lambda.Code.fromAsset(
+          path.join(__dirname, "../../bin/lambdas/run-report-generation")
+        ),
+        handler: "main",
+        runtime: lambda.Runtime.PROVIDED_AL2023,
+        environment: {
+          REPORT_TABLE: props.reportTable.tableName,
+          OPERATION_TABLE: props.operationsTable.tableName,
+          USAGE_TABLE: props.usageTable.tableName,
+          USER_POOL_ID: props.userPool.userPoolId,
+          CSV_BUCKET_NAME: props.csvBucket.bucketName,
+          OPENAI_API_KEY: openAIKey,
+          ...generatorEnvironment,
+        },
+        timeout: cdk.Duration.minutes(15),
+        memorySize: 2048,
+        retryAttempts: 0,
+      }
+    );
+    props.reportTable.grantReadWriteData(this.runReportGenerationLambda);
+    props.userPool.grant(
+      this.runReportGenerationLambda,
+      "cognito-idp:AdminGetUser"
+    );
+    props.csvBucket.grantRead(this.runReportGenerationLambda);
+    props.operationsTable.grantReadWriteData(this.runReportGenerationLambda);
+    props.usageTable.grantReadWriteData(this.runReportGenerationLambda);
+
+    this.generateReportLambda = new lambda.Function(
+      this,
+      "GenerateReportLambda",
+      {
+        code: lambda.Code.fromAsset(
+          path.join(__dirname, "../../bin/lambdas/generate-report")
+        ),
+        handler: "main",
+        runtime: lambda.Runtime.PROVIDED_AL2023,
+        environment: {
+          REPORT_TABLE: props.reportTable.tableName,
+          OPERATION_TABLE: props.operationsTable.tableName,
+          REPORT_GENERATION_LAMBDA_NAME:
+            this.runReportGenerationLambda.functionName,
+        },
+        memorySize: 1024,
+      }
+    );
+    props.reportTable.grantReadData(this.generateReportLambda);
+    props.userPool.grant(this.generateReportLambda, "cognito-idp:AdminGetUser");
+    props.operationsTable.grantReadWriteData(this.generateReportLambda);
+    this.runReportGenerationLambda.grantInvoke(this.generateReportLambda);
 
     this.uploadCSVLambda = new lambda.Function(this, "UploadCSVLambda", {
       code: lambda.Code.fromAsset(
@@ -200,6 +311,45 @@ export class LambdasStack extends cdk.Stack {
     props.columnDataBucket.grantReadWrite(this.getCSVUniqueColumnsMapLambda);
     props.operationsTable.grantReadWriteData(this.getCSVUniqueColumnsMapLambda);
 
+    this.getCSVColumnSchemaLambda = new lambda.Function(
+      this,
+      "GetCSVColumnSchemaLambda",
+      {
+        code: lambda.Code.fromAsset(
+          path.join(__dirname, "../../bin/lambdas/get-csv-column-schema")
+        ),
+        handler: "main",
+        runtime: lambda.Runtime.PROVIDED_AL2023,
+        environment: {
+          REPORT_TABLE: props.reportTable.tableName,
+          COLUMN_DATA_BUCKET_NAME: props.columnDataBucket.bucketName,
+        },
+        timeout: cdk.Duration.seconds(30),
+      }
+    );
+    props.reportTable.grantReadData(this.getCSVColumnSchemaLambda);
+    props.columnDataBucket.grantRead(this.getCSVColumnSchemaLambda);
+
+    this.searchCSVColumnValuesLambda = new lambda.Function(
+      this,
+      "SearchCSVColumnValuesLambda",
+      {
+        code: lambda.Code.fromAsset(
+          path.join(__dirname, "../../bin/lambdas/search-csv-column-values")
+        ),
+        handler: "main",
+        runtime: lambda.Runtime.PROVIDED_AL2023,
+        memorySize: 1024,
+        environment: {
+          REPORT_TABLE: props.reportTable.tableName,
+          COLUMN_DATA_BUCKET_NAME: props.columnDataBucket.bucketName,
+        },
+        timeout: cdk.Duration.seconds(30),
+      }
+    );
+    props.reportTable.grantReadData(this.searchCSVColumnValuesLambda);
+    props.columnDataBucket.grantRead(this.searchCSVColumnValuesLambda);
+
     this.setSectionResponsesLambda = new lambda.Function(
       this,
       "SetSectionResponsesLambda",
@@ -218,6 +368,96 @@ export class LambdasStack extends cdk.Stack {
     );
     props.reportTable.grantReadWriteData(this.setSectionResponsesLambda);
 
+    this.setDerivedColumnsLambda = new lambda.Function(
+      this,
+      "SetDerivedColumnsLambda",
+      {
+        code: lambda.Code.fromAsset(
+          path.join(__dirname, "../../bin/lambdas/set-derived-columns")
+        ),
+        handler: "main",
+        runtime: lambda.Runtime.PROVIDED_AL2023,
+        memorySize: 2048,
+        environment: {
+          REPORT_TABLE: props.reportTable.tableName,
+          OPERATION_TABLE: props.operationsTable.tableName,
+          CSV_BUCKET_NAME: props.csvBucket.bucketName,
+          COLUMN_DATA_BUCKET_NAME: props.columnDataBucket.bucketName,
+          JOIN_COLUMNS_LAMBDA_NAME: props.buildJoinColumnsLambda.functionName,
+        },
+        timeout: cdk.Duration.minutes(1),
+      }
+    );
+    props.reportTable.grantReadWriteData(this.setDerivedColumnsLambda);
+    props.operationsTable.grantReadWriteData(this.setDerivedColumnsLambda);
+    props.csvBucket.grantRead(this.setDerivedColumnsLambda);
+    props.columnDataBucket.grantReadWrite(this.setDerivedColumnsLambda);
+    props.buildJoinColumnsLambda.grantInvoke(this.setDerivedColumnsLambda);
+
+    this.setJoinsLambda = new lambda.Function(this, "SetJoinsLambda", {
+      code: lambda.Code.fromAsset(
+        path.join(__dirname, "../../bin/lambdas/set-joins")
+      ),
+      handler: "main",
+      runtime: lambda.Runtime.PROVIDED_AL2023,
+      memorySize: 1024,
+      environment: {
+        REPORT_TABLE: props.reportTable.tableName,
+        OPERATION_TABLE: props.operationsTable.tableName,
+        JOIN_COLUMNS_LAMBDA_NAME: props.buildJoinColumnsLambda.functionName,
+      },
+    });
+    props.reportTable.grantReadWriteData(this.setJoinsLambda);
+    props.operationsTable.grantReadWriteData(this.setJoinsLambda);
+    props.buildJoinColumnsLambda.grantInvoke(this.setJoinsLambda);
+
+    this.regenerateTextOutputLambda = new lambda.Function(
+      this,
+      "RegenerateTextOutputLambda",
+      {
+        code: lambda.Code.fromAsset(
+          path.join(__dirname, "../../bin/lambdas/regenerate-text-output")
+        ),
+        handler: "main",
+        runtime: lambda.Runtime.PROVIDED_AL2023,
+        memorySize: 1024,
+        environment: {
+          REPORT_TABLE: props.reportTable.tableName,
+          USAGE_TABLE: props.usageTable.tableName,
+          USER_POOL_ID: props.userPool.userPoolId,
+          OPENAI_API_KEY: openAIKey,
+          ...generatorEnvironment,
+        },
+        timeout: cdk.Duration.minutes(2.5),
+      }
+    );
+    props.reportTable.grantReadWriteData(this.regenerateTextOutputLambda);
+    props.userPool.grant(
+      this.regenerateTextOutputLambda,
+      "cognito-idp:AdminGetUser"
+    );
+    props.usageTable.grantReadWriteData(this.regenerateTextOutputLambda);
+
+    this.restoreTextOutputVersionLambda = new lambda.Function(
+      this,
+      "RestoreTextOutputVersionLambda",
+      {
+        code: lambda.Code.fromAsset(
+          path.join(
+            __dirname,
+            "../../bin/lambdas/restore-text-output-version"
+          )
+        ),
+        handler: "main",
+        runtime: lambda.Runtime.PROVIDED_AL2023,
+        memorySize: 1024,
+        environment: {
+          REPORT_TABLE: props.reportTable.tableName,
+        },
+      }
+    );
+    props.reportTable.grantReadWriteData(this.restoreTextOutputVersionLambda);
+
     // --------------------------------------------------------- //
     // Template Lambdas
 
@@ -445,6 +685,25 @@ export class LambdasStack extends cdk.Stack {
     props.reportTable.grantReadWriteData(this.convertItemLambda);
     props.templateTable.grantReadWriteData(this.convertItemLambda);
 
+    this.lintItemPlaceholdersLambda = new lambda.Function(
+      this,
+      "LintItemPlaceholdersLambda",
+      {
+        code: lambda.Code.fromAsset(
+          path.join(__dirname, "../../bin/lambdas/lint-item-placeholders")
+        ),
+        handler: "main",
+        runtime: lambda.Runtime.PROVIDED_AL2023,
+        memorySize: 1024,
+        environment: {
+          REPORT_TABLE: props.reportTable.tableName,
+          TEMPLATE_TABLE: props.templateTable.tableName,
+        },
+      }
+    );
+    props.reportTable.grantReadData(this.lintItemPlaceholdersLambda);
+    props.templateTable.grantReadData(this.lintItemPlaceholdersLambda);
+
     this.deleteItemLambda = new lambda.Function(this, "DeleteItemLambda", {
       code: lambda.Code.fromAsset(
         path.join(__dirname, "../../bin/lambdas/delete-item")
@@ -496,6 +755,32 @@ export class LambdasStack extends cdk.Stack {
       this.updateItemGlobalQuestionsLambda
     );
 
+    this.updateItemGenerationOptionsLambda = new lambda.Function(
+      this,
+      "UpdateItemGenerationOptions",
+      {
+        code: lambda.Code.fromAsset(
+          path.join(
+            __dirname,
+            "../../bin/lambdas/update-item-generation-options"
+          )
+        ),
+        handler: "main",
+        runtime: lambda.Runtime.PROVIDED_AL2023,
+        environment: {
+          REPORT_TABLE: props.reportTable.tableName,
+          TEMPLATE_TABLE: props.templateTable.tableName,
+        },
+        memorySize: 1024,
+      }
+    );
+    props.reportTable.grantReadWriteData(
+      this.updateItemGenerationOptionsLambda
+    );
+    props.templateTable.grantReadWriteData(
+      this.updateItemGenerationOptionsLambda
+    );
+
     // --------------------------------------------------------- //
 
     // User Lambdas
@@ -544,5 +829,43 @@ export class LambdasStack extends cdk.Stack {
       }
     );
     props.operationsTable.grantReadData(this.getOperationStatusLambda);
+
+    this.cancelOperationLambda = new lambda.Function(
+      this,
+      "CancelOperationLambda",
+      {
+        code: lambda.Code.fromAsset(
+          path.join(__dirname, "../../bin/lambdas/cancel-operation")
+        ),
+        handler: "main",
+        runtime: lambda.Runtime.PROVIDED_AL2023,
+        memorySize: 1024,
+        environment: {
+          OPERATION_TABLE: props.operationsTable.tableName,
+        },
+      }
+    );
+    props.operationsTable.grantReadWriteData(this.cancelOperationLambda);
+
+    // Usage Lambdas
+
+    this.getUsageSummaryLambda = new lambda.Function(
+      this,
+      "GetUsageSummaryLambda",
+      {
+        code: lambda.Code.fromAsset(
+          path.join(__dirname, "../../bin/lambdas/get-usage-summary")
+        ),
+        handler: "main",
+        runtime: lambda.Runtime.PROVIDED_AL2023,
+        memorySize: 1024,
+        environment: {
+          USAGE_TABLE: props.usageTable.tableName,
+          USER_POOL_ID: props.userPool.userPoolId,
+        },
+      }
+    );
+    props.usageTable.grantReadData(this.getUsageSummaryLambda);
+    props.userPool.grant(this.getUsageSummaryLambda, "cognito-idp:AdminGetUser");
   }
 }
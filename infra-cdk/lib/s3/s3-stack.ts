@@ -16,6 +16,7 @@ interface S3BucketStackProps extends cdk.StackProps {
 export class S3BucketStack extends cdk.Stack {
   public readonly csvBucket: s3.Bucket;
   public readonly columnDataBucket: s3.Bucket;
+  public readonly buildJoinColumnsLambda: lambda.IFunction;
 
   constructor(scope: Construct, id: string, props: S3BucketStackProps) {
     super(scope, id, props);
@@ -47,8 +48,36 @@ export class S3BucketStack extends cdk.Stack {
       allowedHeaders: ["Content-Type"],
     });
 
+    // Builds the column values maps of the joins of a report in the background, invoked
+    // when the joins are set and when a csv they use is uploaded again
+    this.buildJoinColumnsLambda = new lambda.Function(
+      this,
+      "BuildJoinColumnsLambda",
+      {
+        code: lambda.Code.fromAsset(
+          path.join(__dirname, "../../bin/lambdas/build-join-columns")
+        ),
+        handler: "main",
+        runtime: lambda.Runtime.PROVIDED_AL2023,
+        environment: {
+          REPORT_TABLE: props.reportTable.tableName,
+          OPERATION_TABLE: props.operationTable.tableName,
+          CSV_BUCKET_NAME: this.csvBucket.bucketName,
+          COLUMN_DATA_BUCKET_NAME: this.columnDataBucket.bucketName,
+        },
+        memorySize: 2048,
+        timeout: cdk.Duration.minutes(15),
+        retryAttempts: 0,
+      }
+    );
+    props.reportTable.grantReadWriteData(this.buildJoinColumnsLambda);
+    props.operationTable.grantReadWriteData(this.buildJoinColumnsLambda);
+    this.csvBucket.grantRead(this.buildJoinColumnsLambda);
+    this.columnDataBucket.grantReadWrite(this.buildJoinColumnsLambda);
+
     // A lambda to process a new CSV file when its uploaded
     // It will set the columns and unique columns values in a Report
+    // Xlsx, tsv and other uploads are first converted to a csv, which triggers it again
     const readCsvColumnsLambda = new lambda.Function(
       this,
       "ReadCsvColumnsLambda",
@@ -63,14 +92,17 @@ export class S3BucketStack extends cdk.Stack {
           OPERATION_TABLE: props.operationTable.tableName,
           CSV_BUCKET_NAME: this.csvBucket.bucketName,
           COLUMN_DATA_BUCKET_NAME: this.columnDataBucket.bucketName,
+          JOIN_COLUMNS_LAMBDA_NAME: this.buildJoinColumnsLambda.functionName,
         },
         memorySize: 1024,
+        timeout: cdk.Duration.minutes(1),
       }
     );
     props.reportTable.grantReadWriteData(readCsvColumnsLambda);
-    props.operationTable.grantWriteData(readCsvColumnsLambda);
-    this.csvBucket.grantRead(readCsvColumnsLambda);
+    props.operationTable.grantReadWriteData(readCsvColumnsLambda);
+    this.csvBucket.grantReadWrite(readCsvColumnsLambda);
     this.columnDataBucket.grantReadWrite(readCsvColumnsLambda);
+    this.buildJoinColumnsLambda.grantInvoke(readCsvColumnsLambda);
 
     this.csvBucket.addEventNotification(
       s3.EventType.OBJECT_CREATED,